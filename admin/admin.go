@@ -0,0 +1,38 @@
+// Package admin guards the gRPC calls only admins can make, such as taking
+// back votes in bulk or swapping the fault rules, behind the admin token.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Key is the metadata key the admin token is sent in, as a bearer token, as
+// in "Bearer <token>".
+const Key = "authorization"
+
+// Check checks the call on ctx carries token. Without a token, admin calls
+// are off, and fail with PermissionDenied.
+func Check(ctx context.Context, token string) error {
+	if token == "" {
+		return status.Error(codes.PermissionDenied, "admin calls are off until ADMIN_TOKEN is set")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(Key) {
+		sent := strings.TrimPrefix(value, "Bearer ")
+		if sent != value && subtle.ConstantTimeCompare([]byte(sent), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "a valid admin token is required")
+}
+
+// WithToken has the calls made with the returned context carry token.
+func WithToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, Key, "Bearer "+token)
+}
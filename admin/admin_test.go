@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheck(t *testing.T) {
+	incoming := func(pairs ...string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+	}
+
+	t.Run("Accepts the admin token", func(t *testing.T) {
+		if err := Check(incoming(Key, "Bearer secret"), "secret"); err != nil {
+			t.Fatalf("Expected the call to be let through, got [%v]", err)
+		}
+	})
+
+	t.Run("Accepts the token it sends", func(t *testing.T) {
+		md, _ := metadata.FromOutgoingContext(WithToken(context.Background(), "secret"))
+		if err := Check(metadata.NewIncomingContext(context.Background(), md), "secret"); err != nil {
+			t.Fatalf("Expected the call to be let through, got [%v]", err)
+		}
+	})
+
+	t.Run("Refuses calls without the admin token", func(t *testing.T) {
+		for _, ctx := range []context.Context{
+			context.Background(),
+			incoming(Key, "Bearer wrong"),
+			incoming(Key, "secret"),
+			incoming(Key, "Bearer "),
+		} {
+			if err := Check(ctx, "secret"); status.Code(err) != codes.Unauthenticated {
+				t.Fatalf("Expected Unauthenticated, got [%v]", err)
+			}
+		}
+	})
+
+	t.Run("Refuses every call without an admin token set", func(t *testing.T) {
+		if err := Check(incoming(Key, "Bearer "), ""); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("Expected PermissionDenied, got [%v]", err)
+		}
+	})
+}
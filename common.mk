@@ -5,15 +5,18 @@ IMAGE_TAG ?= v12
 target_dir := target
 
 clean:
-	rm -rf gen
+	rm -rf gen ../fault/grpcfault/gen
 	rm -rf $(target_dir)
 	mkdir -p $(target_dir)
 	mkdir -p gen
 
 PROTOC ?= ../bin/protoc
 
+# Fault.proto goes to fault/grpcfault, which serves it for both services.
 protoc:
-	$(PROTOC) -I .. ../proto/*.proto --go_out=paths=source_relative:./gen --go-grpc_out=paths=source_relative:./gen
+	$(PROTOC) -I .. ../proto/Emoji.proto ../proto/Voting.proto --go_out=paths=source_relative:./gen --go-grpc_out=paths=source_relative:./gen
+	mkdir -p ../fault/grpcfault/gen
+	$(PROTOC) -I .. ../proto/Fault.proto --go_out=paths=source_relative:../fault/grpcfault/gen --go-grpc_out=paths=source_relative:../fault/grpcfault/gen
 
 package: protoc compile build-container
 
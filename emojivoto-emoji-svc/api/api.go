@@ -5,11 +5,16 @@ import (
 
 	"github.com/buoyantio/emojivoto/emojivoto-emoji-svc/emoji"
 	pb "github.com/buoyantio/emojivoto/emojivoto-emoji-svc/gen/proto"
+	"github.com/buoyantio/emojivoto/fault/grpcfault"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type EmojiServiceServer struct {
 	allEmoji emoji.AllEmoji
+	faults   *grpcfault.Injector
 	pb.UnimplementedEmojiServiceServer
 }
 
@@ -30,22 +35,38 @@ func (svc *EmojiServiceServer) ListAll(ctx context.Context, req *pb.ListAllEmoji
 }
 
 func (svc *EmojiServiceServer) FindByShortcode(ctx context.Context, req *pb.FindByShortcodeRequest) (*pb.FindByShortcodeResponse, error) {
-	var pbE *pb.Emoji
+	if err := svc.faults.InjectShortcode(ctx, req.Shortcode); err != nil {
+		return nil, err
+	}
+
+	if req.Shortcode == "" {
+		st, _ := status.New(codes.InvalidArgument, "shortcode is mandatory").WithDetails(&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "shortcode", Description: "must not be empty"}},
+		})
+		return nil, st.Err()
+	}
+
 	foundEmoji := svc.allEmoji.WithShortcode(req.Shortcode)
-	if foundEmoji != nil {
-		pbE = &pb.Emoji{
-			Unicode:   foundEmoji.Unicode,
-			Shortcode: foundEmoji.Shortcode,
-		}
+	if foundEmoji == nil {
+		st, _ := status.Newf(codes.NotFound, "no emoji with shortcode [%s]", req.Shortcode).WithDetails(&errdetails.ResourceInfo{
+			ResourceType: "emoji",
+			ResourceName: req.Shortcode,
+		})
+		return nil, st.Err()
 	}
 	return &pb.FindByShortcodeResponse{
-		Emoji: pbE,
+		Emoji: &pb.Emoji{
+			Unicode:   foundEmoji.Unicode,
+			Shortcode: foundEmoji.Shortcode,
+		},
 	}, nil
 }
 
-func NewGrpServer(grpcServer *grpc.Server, allEmoji emoji.AllEmoji) {
+func NewGrpServer(grpcServer *grpc.Server, allEmoji emoji.AllEmoji, faults *grpcfault.Injector, adminToken string) {
 	pb.RegisterEmojiServiceServer(grpcServer, &EmojiServiceServer{
 		allEmoji,
+		faults,
 		pb.UnimplementedEmojiServiceServer{},
 	})
+	grpcfault.NewGrpServer(grpcServer, faults, adminToken)
 }
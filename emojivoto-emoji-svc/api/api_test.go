@@ -6,6 +6,10 @@ import (
 
 	"github.com/buoyantio/emojivoto/emojivoto-emoji-svc/emoji"
 	pb "github.com/buoyantio/emojivoto/emojivoto-emoji-svc/gen/proto"
+	"github.com/buoyantio/emojivoto/fault/grpcfault"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestListAll(t *testing.T) {
@@ -66,23 +70,46 @@ func TestFindByShortcode(t *testing.T) {
 		}
 	})
 
-	t.Run("return nil if no emoji with such shortcode", func(t *testing.T) {
+	t.Run("fail with NotFound if no emoji with such shortcode", func(t *testing.T) {
 		allEmoji := emoji.NewAllEmoji()
 		emojivotoService := EmojiServiceServer{
 			allEmoji: allEmoji,
 		}
 
-		response, err := emojivotoService.FindByShortcode(context.Background(), &pb.FindByShortcodeRequest{
+		_, err := emojivotoService.FindByShortcode(context.Background(), &pb.FindByShortcodeRequest{
 			Shortcode: "doesnt-really-exist",
 		})
 
-		if err != nil {
-			t.Fatal(err)
+		st := status.Convert(err)
+		if st.Code() != codes.NotFound {
+			t.Fatalf("Expected the lookup to fail with [NotFound], got [%v]", err)
+		}
+		if len(st.Details()) != 1 || st.Details()[0].(*errdetails.ResourceInfo).ResourceName != "doesnt-really-exist" {
+			t.Fatalf("Expected the shortcode in the error details, got [%v]", st.Details())
 		}
 
-		if response.Emoji != nil {
-			t.Fatalf("Expected to return nil for emoji, returned [%s]", response.Emoji)
+		_, err = emojivotoService.FindByShortcode(context.Background(), &pb.FindByShortcodeRequest{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected an empty shortcode to fail with [InvalidArgument], got [%v]", err)
 		}
 	})
 
+	t.Run("fails lookups of shortcodes with fault rules", func(t *testing.T) {
+		faults := grpcfault.NewInjector(nil)
+		emojivotoService := EmojiServiceServer{
+			allEmoji: emoji.NewAllEmoji(),
+			faults:   faults,
+		}
+		if err := faults.SetRules([]grpcfault.Rule{{Name: "ghost", Shortcode: ":ghost:", Probability: 1, Code: codes.NotFound}}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := emojivotoService.FindByShortcode(context.Background(), &pb.FindByShortcodeRequest{Shortcode: ":ghost:"})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("Expected the lookup of [:ghost:] to fail with [NotFound], got [%v]", err)
+		}
+		if _, err := emojivotoService.FindByShortcode(context.Background(), &pb.FindByShortcodeRequest{Shortcode: ":joy:"}); err != nil {
+			t.Fatalf("Expected the lookup of [:joy:] to go through, got [%v]", err)
+		}
+	})
 }
@@ -13,6 +13,7 @@ import (
 	"contrib.go.opencensus.io/exporter/ocagent"
 	"github.com/buoyantio/emojivoto/emojivoto-emoji-svc/api"
 	"github.com/buoyantio/emojivoto/emojivoto-emoji-svc/emoji"
+	"github.com/buoyantio/emojivoto/fault/grpcfault"
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opencensus.io/plugin/ocgrpc"
@@ -24,6 +25,10 @@ var (
 	grpcPort    = os.Getenv("GRPC_PORT")
 	promPort    = os.Getenv("PROM_PORT")
 	ocagentHost = os.Getenv("OC_AGENT_HOST")
+	catalogPath = os.Getenv("EMOJI_CATALOG")
+	faultsPath  = os.Getenv("FAULT_RULES")
+	maxDropVar  = os.Getenv("FAULT_MAX_DROP")
+	adminToken  = os.Getenv("ADMIN_TOKEN")
 )
 
 func main() {
@@ -43,6 +48,30 @@ func main() {
 	trace.RegisterExporter(oce)
 
 	allEmoji := emoji.NewAllEmoji()
+	if catalogPath != "" {
+		allEmoji, err = emoji.LoadAllEmoji(catalogPath)
+		if err != nil {
+			log.Fatalf("Failed to load EMOJI_CATALOG: %v", err)
+		}
+		log.Printf("Serving [%d] emoji from EMOJI_CATALOG=[%s]", len(allEmoji.List()), catalogPath)
+	}
+
+	var rules []grpcfault.Rule
+	if faultsPath != "" {
+		rules, err = grpcfault.LoadRules(faultsPath)
+		if err != nil {
+			log.Fatalf("Failed to load FAULT_RULES: %v", err)
+		}
+		log.Printf("Loaded [%d] fault rules from FAULT_RULES=[%s]", len(rules), faultsPath)
+	}
+	faults := grpcfault.NewInjector(rules)
+	if maxDropVar != "" {
+		maxDrop, err := time.ParseDuration(maxDropVar)
+		if err != nil {
+			log.Fatalf("Invalid FAULT_MAX_DROP: %v", err)
+		}
+		faults.SetMaxDrop(maxDrop)
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", grpcPort))
 	if err != nil {
@@ -66,11 +95,14 @@ func main() {
 		grpc_prometheus.EnableHandlingTimeHistogram()
 		grpcServer := grpc.NewServer(
 			grpc.StatsHandler(&ocgrpc.ServerHandler{}),
-			grpc.StreamInterceptor(grpc_prometheus.StreamServerInterceptor),
-			grpc.UnaryInterceptor(grpc_prometheus.UnaryServerInterceptor),
+			grpc.ChainStreamInterceptor(grpc_prometheus.StreamServerInterceptor, faults.StreamServerInterceptor),
+			grpc.ChainUnaryInterceptor(grpc_prometheus.UnaryServerInterceptor, faults.UnaryServerInterceptor),
 		)
-		api.NewGrpServer(grpcServer, allEmoji)
+		api.NewGrpServer(grpcServer, allEmoji, faults, adminToken)
 		log.Printf("Starting grpc server on GRPC_PORT=[%s]", grpcPort)
+		if adminToken == "" {
+			log.Printf("Turning the admin calls off, as ADMIN_TOKEN isn't set")
+		}
 		err := grpcServer.Serve(lis)
 		errs <- err
 	}()
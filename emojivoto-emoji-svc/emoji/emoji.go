@@ -1,5 +1,11 @@
 package emoji
 
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
 //go:generate generateEmojiCodeMap -pkg emojivoto
 
 type Emoji struct {
@@ -146,3 +152,31 @@ func NewAllEmoji() AllEmoji {
 		emojiList,
 	}
 }
+
+// LoadAllEmoji reads a catalog of emoji from a JSON file, a list of objects
+// with a unicode and a shortcode each, as served by the web's /api/list. The
+// emoji and voting services can share one catalog this way.
+func LoadAllEmoji(path string) (AllEmoji, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var emojiList []*Emoji
+	if err := json.Unmarshal(data, &emojiList); err != nil {
+		return nil, fmt.Errorf("parsing emoji catalog [%s]: %v", path, err)
+	}
+	if len(emojiList) == 0 {
+		return nil, fmt.Errorf("emoji catalog [%s] is empty", path)
+	}
+	seen := make(map[string]bool, len(emojiList))
+	for _, e := range emojiList {
+		if e == nil || e.Shortcode == "" || e.Unicode == "" {
+			return nil, fmt.Errorf("emoji catalog [%s] has an emoji without a shortcode or unicode", path)
+		}
+		if seen[e.Shortcode] {
+			return nil, fmt.Errorf("emoji catalog [%s] lists [%s] twice", path, e.Shortcode)
+		}
+		seen[e.Shortcode] = true
+	}
+	return &inMemoryAllEmoji{emojiList}, nil
+}
@@ -1,6 +1,9 @@
 package emoji
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -59,3 +62,45 @@ func TestFindByShortcode(t *testing.T) {
 		}
 	})
 }
+
+func TestLoadAllEmoji(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emoji")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, catalog string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(catalog), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("loads a catalog", func(t *testing.T) {
+		allEmoji, err := LoadAllEmoji(write("catalog.json", `[{"unicode":"🍩","shortcode":":doughnut:"},{"unicode":"🌮","shortcode":":taco:"}]`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(allEmoji.List()) != 2 || allEmoji.WithShortcode(":taco:").Unicode != "🌮" {
+			t.Fatalf("Expected [:doughnut:] and [:taco:] in the catalog, got [%v]", allEmoji.List())
+		}
+	})
+
+	t.Run("rejects invalid catalogs", func(t *testing.T) {
+		for name, catalog := range map[string]string{
+			"empty.json":     `[]`,
+			"malformed.json": `{"shortcode":":taco:"}`,
+			"missing.json":   `[{"unicode":"🌮"}]`,
+			"twice.json":     `[{"unicode":"🌮","shortcode":":taco:"},{"unicode":"🌮","shortcode":":taco:"}]`,
+		} {
+			if _, err := LoadAllEmoji(write(name, catalog)); err == nil {
+				t.Fatalf("Expected catalog [%s] to be rejected", catalog)
+			}
+		}
+		if _, err := LoadAllEmoji(filepath.Join(dir, "nothere.json")); err == nil {
+			t.Fatalf("Expected a missing catalog to be rejected")
+		}
+	})
+}
@@ -2,474 +2,486 @@ package api
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
-	"math/rand"
 	"time"
 
+	"github.com/buoyantio/emojivoto/admin"
 	pb "github.com/buoyantio/emojivoto/emojivoto-voting-svc/gen/proto"
 	"github.com/buoyantio/emojivoto/emojivoto-voting-svc/voting"
+	"github.com/buoyantio/emojivoto/fault/grpcfault"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
-)
-
-var (
-	FloatZero = float32(0.0)
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type PollServiceServer struct {
-	poll                    voting.Poll
-	failureRate             float32
-	artificialDelayDuration time.Duration
+	polls       *voting.Polls
+	faults      *grpcfault.Injector
+	results     *resultsHub
+	tournaments *voting.Tournaments
+	ratings     *voting.Ratings
+	adminToken  string
 	pb.UnimplementedVotingServiceServer
 }
 
-func (pS *PollServiceServer) vote(shortcode string) (*pb.VoteResponse, error) {
-
-	time.Sleep(pS.artificialDelayDuration)
-
-	err := pS.poll.Vote(shortcode)
-	return &pb.VoteResponse{}, err
+// misbehave injects the faults of the rules for votes for shortcode before
+// a vote.
+func (pS *PollServiceServer) misbehave(ctx context.Context, shortcode string) error {
+	return pS.faults.InjectShortcode(ctx, shortcode)
 }
 
-func (pS *PollServiceServer) VoteDoughnut(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-
-	if pS.failureRate > FloatZero {
-		probability := rand.Float32()
-
-		if probability < pS.failureRate {
-			log.Printf("probability [%f] is less than failureRate [%f]", probability, pS.failureRate)
-			log.Printf("logging an error for doughnut")
-			return nil, fmt.Errorf("ERROR")
-		}
+// vote records a vote from one of the legacy per-emoji RPCs in the default
+// poll. Like any other vote, it must be for a shortcode on the ballot.
+func (pS *PollServiceServer) vote(ctx context.Context, shortcode string) (*pb.VoteResponse, error) {
+	if err := pS.misbehave(ctx, shortcode); err != nil {
+		return nil, err
 	}
-	log.Printf("voting for doughnut")
-	return pS.vote(":doughnut:")
-}
-
-func (pS *PollServiceServer) VotePoop(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":poop:")
-}
-
-func (pS *PollServiceServer) VoteJoy(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":joy:")
-}
-
-func (pS *PollServiceServer) VoteSunglasses(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":sunglasses:")
-}
-
-func (pS *PollServiceServer) VoteRelaxed(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":relaxed:")
-}
-
-func (pS *PollServiceServer) VoteStuckOutTongueWinkingEye(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":stuck_out_tongue_winking_eye:")
-}
-
-func (pS *PollServiceServer) VoteMoneyMouthFace(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":money_mouth_face:")
-}
-
-func (pS *PollServiceServer) VoteFlushed(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":flushed:")
-}
-
-func (pS *PollServiceServer) VoteMask(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":mask:")
-}
-
-func (pS *PollServiceServer) VoteNerdFace(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":nerd_face:")
-}
-
-func (pS *PollServiceServer) VoteGhost(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":ghost:")
-}
-
-func (pS *PollServiceServer) VoteSkullAndCrossbones(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":skull_and_crossbones:")
-}
-
-func (pS *PollServiceServer) VoteHeartEyesCat(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":heart_eyes_cat:")
-}
-
-func (pS *PollServiceServer) VoteHearNoEvil(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":hear_no_evil:")
-}
-
-func (pS *PollServiceServer) VoteSeeNoEvil(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":see_no_evil:")
-}
-
-func (pS *PollServiceServer) VoteSpeakNoEvil(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":speak_no_evil:")
-}
-
-func (pS *PollServiceServer) VoteBoy(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":boy:")
-}
-
-func (pS *PollServiceServer) VoteGirl(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":girl:")
-}
-
-func (pS *PollServiceServer) VoteMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":man:")
-}
-
-func (pS *PollServiceServer) VoteWoman(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":woman:")
-}
-
-func (pS *PollServiceServer) VoteOlderMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":older_man:")
-}
-
-func (pS *PollServiceServer) VotePoliceman(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":policeman:")
-}
-
-func (pS *PollServiceServer) VoteGuardsman(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":guardsman:")
-}
-
-func (pS *PollServiceServer) VoteConstructionWorkerMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":construction_worker_man:")
-}
-
-func (pS *PollServiceServer) VotePrince(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":prince:")
-}
-
-func (pS *PollServiceServer) VotePrincess(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":princess:")
-}
-
-func (pS *PollServiceServer) VoteManInTuxedo(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":man_in_tuxedo:")
-}
-
-func (pS *PollServiceServer) VoteBrideWithVeil(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":bride_with_veil:")
-}
-
-func (pS *PollServiceServer) VoteMrsClaus(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":mrs_claus:")
-}
-
-func (pS *PollServiceServer) VoteSanta(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":santa:")
-}
-
-func (pS *PollServiceServer) VoteTurkey(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":turkey:")
-}
-
-func (pS *PollServiceServer) VoteRabbit(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":rabbit:")
-}
-
-func (pS *PollServiceServer) VoteNoGoodWoman(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":no_good_woman:")
-}
-
-func (pS *PollServiceServer) VoteOkWoman(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":ok_woman:")
-}
-
-func (pS *PollServiceServer) VoteRaisingHandWoman(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":raising_hand_woman:")
-}
-
-func (pS *PollServiceServer) VoteBowingMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":bowing_man:")
-}
-
-func (pS *PollServiceServer) VoteManFacepalming(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":man_facepalming:")
-}
-
-func (pS *PollServiceServer) VoteWomanShrugging(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":woman_shrugging:")
-}
-
-func (pS *PollServiceServer) VoteMassageWoman(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":massage_woman:")
-}
-
-func (pS *PollServiceServer) VoteWalkingMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":walking_man:")
-}
-
-func (pS *PollServiceServer) VoteRunningMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":running_man:")
-}
-
-func (pS *PollServiceServer) VoteDancer(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":dancer:")
-}
-
-func (pS *PollServiceServer) VoteManDancing(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":man_dancing:")
-}
-
-func (pS *PollServiceServer) VoteDancingWomen(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":dancing_women:")
-}
-
-func (pS *PollServiceServer) VoteRainbow(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":rainbow:")
-}
-
-func (pS *PollServiceServer) VoteSkier(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":skier:")
-}
-
-func (pS *PollServiceServer) VoteGolfingMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":golfing_man:")
-}
-
-func (pS *PollServiceServer) VoteSurfingMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":surfing_man:")
-}
-
-func (pS *PollServiceServer) VoteBasketballMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":basketball_man:")
-}
-
-func (pS *PollServiceServer) VoteBikingMan(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":biking_man:")
-}
-
-func (pS *PollServiceServer) VotePointUp2(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":point_up_2:")
-}
-
-func (pS *PollServiceServer) VoteVulcanSalute(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":vulcan_salute:")
-}
-
-func (pS *PollServiceServer) VoteMetal(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":metal:")
-}
-
-func (pS *PollServiceServer) VoteCallMeHand(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":call_me_hand:")
-}
-
-func (pS *PollServiceServer) VoteThumbsup(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":thumbsup:")
-}
-
-func (pS *PollServiceServer) VoteWave(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":wave:")
-}
-
-func (pS *PollServiceServer) VoteClap(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":clap:")
-}
-
-func (pS *PollServiceServer) VoteRaisedHands(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":raised_hands:")
-}
-
-func (pS *PollServiceServer) VotePray(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":pray:")
-}
-
-func (pS *PollServiceServer) VoteDog(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":dog:")
-}
-
-func (pS *PollServiceServer) VoteCat2(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":cat2:")
-}
-
-func (pS *PollServiceServer) VotePig(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":pig:")
-}
-
-func (pS *PollServiceServer) VoteHatchingChick(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":hatching_chick:")
-}
 
-func (pS *PollServiceServer) VoteSnail(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":snail:")
-}
-
-func (pS *PollServiceServer) VoteBacon(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":bacon:")
-}
-
-func (pS *PollServiceServer) VotePizza(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":pizza:")
-}
-
-func (pS *PollServiceServer) VoteTaco(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":taco:")
-}
-
-func (pS *PollServiceServer) VoteBurrito(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":burrito:")
-}
-
-func (pS *PollServiceServer) VoteRamen(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":ramen:")
-}
-
-func (pS *PollServiceServer) VoteChampagne(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":champagne:")
-}
-
-func (pS *PollServiceServer) VoteTropicalDrink(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":tropical_drink:")
-}
-
-func (pS *PollServiceServer) VoteBeer(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":beer:")
-}
-
-func (pS *PollServiceServer) VoteTumblerGlass(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":tumbler_glass:")
-}
-
-func (pS *PollServiceServer) VoteWorldMap(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":world_map:")
+	err := pS.polls.Vote(voting.DefaultPollID, voterFromContext(ctx), shortcode)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.VoteResponse{}, nil
 }
 
-func (pS *PollServiceServer) VoteBeachUmbrella(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":beach_umbrella:")
-}
+func (pS *PollServiceServer) Vote(ctx context.Context, req *pb.VoteRequest) (*pb.VoteResponse, error) {
+	if err := pS.misbehave(ctx, req.Shortcode); err != nil {
+		return nil, err
+	}
 
-func (pS *PollServiceServer) VoteMountainSnow(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":mountain_snow:")
+	err := pS.polls.Vote(req.PollId, voterFromContext(ctx), req.Shortcode)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.VoteResponse{}, nil
 }
 
-func (pS *PollServiceServer) VoteCamping(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":camping:")
-}
+func (pS *PollServiceServer) VoteRanked(ctx context.Context, req *pb.VoteRankedRequest) (*pb.VoteRankedResponse, error) {
+	ranking := req.GetBallot().GetShortcodes()
+	if len(ranking) > 0 {
+		if err := pS.misbehave(ctx, ranking[0]); err != nil {
+			return nil, err
+		}
+	}
 
-func (pS *PollServiceServer) VoteSteamLocomotive(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":steam_locomotive:")
+	err := pS.polls.VoteRanked(req.PollId, voterFromContext(ctx), ranking)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.VoteRankedResponse{}, nil
 }
 
-func (pS *PollServiceServer) VoteFlightDeparture(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":flight_departure:")
-}
+func (pS *PollServiceServer) VoteApproval(ctx context.Context, req *pb.VoteApprovalRequest) (*pb.VoteApprovalResponse, error) {
+	if len(req.Shortcodes) > 0 {
+		if err := pS.misbehave(ctx, req.Shortcodes[0]); err != nil {
+			return nil, err
+		}
+	}
 
-func (pS *PollServiceServer) VoteRocket(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":rocket:")
+	err := pS.polls.VoteApproval(req.PollId, voterFromContext(ctx), req.Shortcodes)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.VoteApprovalResponse{}, nil
 }
 
-func (pS *PollServiceServer) VoteStar2(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":star2:")
+// Retract takes back the caller's own vote. Callers without a voter-id
+// can't, as their vote can't be told from anyone else's: RetractVotes is
+// there to take those back.
+func (pS *PollServiceServer) Retract(ctx context.Context, req *pb.RetractRequest) (*pb.RetractResponse, error) {
+	voter := voterFromContext(ctx)
+	if voter.ID == "" {
+		return nil, statusWithReason(codes.PermissionDenied, "ANONYMOUS_RETRACT", "only voters with a voter-id can take back their vote")
+	}
+	choices := req.Shortcodes
+	if len(choices) == 0 && req.Shortcode != "" {
+		choices = []string{req.Shortcode}
+	}
+	err := pS.polls.Retract(req.PollId, voter, choices)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.RetractResponse{}, nil
 }
 
-func (pS *PollServiceServer) VoteSunBehindSmallCloud(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":sun_behind_small_cloud:")
+// RetractVotes takes back every vote matching the filter in req, for
+// admins only: callers must send the admin token, as emojivoto-web does.
+func (pS *PollServiceServer) RetractVotes(ctx context.Context, req *pb.RetractVotesRequest) (*pb.RetractVotesResponse, error) {
+	if err := admin.Check(ctx, pS.adminToken); err != nil {
+		return nil, err
+	}
+	filter := voting.RetractFilter{
+		VoterID: req.VoterId,
+		Since:   fromPbTime(req.Since),
+		Until:   fromPbTime(req.Until),
+	}
+	retracted, err := pS.polls.RetractVotes(req.PollId, filter)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	log.Printf("Retracted [%d] votes from poll [%s]", retracted, req.PollId)
+	return &pb.RetractVotesResponse{Retracted: int32(retracted)}, nil
+}
+
+// voterFromContext describes the caller using the voter-* metadata set by
+// emojivoto-web, falling back to the gRPC peer for other clients. Only
+// callers that send a voter-id are limited to one vote per poll. All of the
+// caller's metadata is kept for the weight policy.
+func voterFromContext(ctx context.Context) voting.Voter {
+	voter := voting.Voter{
+		ID:        firstMetadataValue(ctx, "voter-id"),
+		Address:   firstMetadataValue(ctx, "voter-address"),
+		UserAgent: firstMetadataValue(ctx, "voter-user-agent"),
+	}
+	if voter.Address == "" {
+		if p, ok := peer.FromContext(ctx); ok {
+			voter.Address = p.Addr.String()
+		}
+	}
+	if voter.UserAgent == "" {
+		voter.UserAgent = firstMetadataValue(ctx, "user-agent")
+	}
+	voter.Metadata = make(map[string]string, len(voting.WeightMetadata))
+	for _, key := range voting.WeightMetadata {
+		if value := firstMetadataValue(ctx, key); value != "" {
+			voter.Metadata[key] = value
+		}
+	}
+	return voter
 }
 
-func (pS *PollServiceServer) VoteCloudWithRain(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":cloud_with_rain:")
+func firstMetadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
 }
 
-func (pS *PollServiceServer) VoteFire(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":fire:")
-}
+func (pS *PollServiceServer) Results(_ context.Context, req *pb.ResultsRequest) (*pb.ResultsResponse, error) {
+	results, ballots, e := pS.polls.Count(req.PollId)
+	if e != nil {
+		return nil, pollError(e)
+	}
 
-func (pS *PollServiceServer) VoteJackOLantern(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":jack_o_lantern:")
-}
+	votingResults := make([]*pb.VotingResult, 0)
+	votes, weighted := int32(0), 0.0
+	for _, e := range results {
+		result := pb.VotingResult{
+			Shortcode:     e.Shortcode,
+			Votes:         int32(e.NumVotes),
+			WeightedVotes: e.Weighted,
+			Rank:          int32(e.Rank),
+			DenseRank:     int32(e.DenseRank),
+			Share:         e.Share,
+			ShareLow:      e.ShareLow,
+			ShareHigh:     e.ShareHigh,
+		}
+		votingResults = append(votingResults, &result)
+		votes += int32(e.NumVotes)
+		weighted += e.Weighted
+	}
 
-func (pS *PollServiceServer) VoteBalloon(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":balloon:")
+	lead := voting.LeadOf(results)
+	response := &pb.ResultsResponse{
+		Results:       votingResults,
+		Ballots:       int32(ballots),
+		Votes:         votes,
+		WeightedVotes: weighted,
+		Confidence:    voting.Confidence,
+		Lead: &pb.Lead{
+			Leader:      lead.Leader,
+			RunnerUp:    lead.RunnerUp,
+			Margin:      lead.Margin,
+			Significant: lead.Significant,
+		},
+	}
+	return response, nil
 }
 
-func (pS *PollServiceServer) VoteTada(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":tada:")
-}
+func (pS *PollServiceServer) RankedResults(_ context.Context, req *pb.RankedResultsRequest) (*pb.RankedResultsResponse, error) {
+	runoff, err := pS.polls.RankedResults(req.PollId)
+	if err != nil {
+		return nil, pollError(err)
+	}
 
-func (pS *PollServiceServer) VoteTrophy(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":trophy:")
+	rounds := make([]*pb.RunoffRound, 0, len(runoff.Rounds))
+	for _, r := range runoff.Rounds {
+		tallies := make([]*pb.VotingResult, 0, len(r.Tallies))
+		for _, t := range r.Tallies {
+			tallies = append(tallies, &pb.VotingResult{Shortcode: t.Shortcode, Votes: int32(t.NumVotes)})
+		}
+		rounds = append(rounds, &pb.RunoffRound{
+			Tallies:    tallies,
+			Exhausted:  int32(r.Exhausted),
+			Eliminated: r.Eliminated,
+		})
+	}
+	return &pb.RankedResultsResponse{
+		Ballots: int32(runoff.Ballots),
+		Rounds:  rounds,
+		Winner:  runoff.Winner,
+		Tied:    runoff.Tied,
+	}, nil
+}
+
+func (pS *PollServiceServer) WatchResults(req *pb.WatchResultsRequest, stream pb.VotingService_WatchResultsServer) error {
+	updates, stop, err := pS.results.watch(req.PollId)
+	if err != nil {
+		return pollError(err)
+	}
+	defer stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return status.Errorf(codes.Unavailable, "stopped watching poll [%s]", req.PollId)
+			}
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+		}
+	}
 }
 
-func (pS *PollServiceServer) VoteIphone(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":iphone:")
-}
+func (pS *PollServiceServer) VoteHistory(_ context.Context, req *pb.VoteHistoryRequest) (*pb.VoteHistoryResponse, error) {
+	to := fromPbTime(req.To)
+	from := fromPbTime(req.From)
+	if from.IsZero() {
+		end := to
+		if end.IsZero() {
+			end = time.Now()
+		}
+		from = end.Add(-time.Hour)
+	}
+	var step time.Duration
+	if req.Step != nil {
+		step = req.Step.AsDuration()
+	}
 
-func (pS *PollServiceServer) VotePager(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":pager:")
-}
+	history, err := pS.polls.History(req.PollId, req.Shortcodes, from, to, step)
+	if err != nil {
+		return nil, pollError(err)
+	}
 
-func (pS *PollServiceServer) VoteFax(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":fax:")
+	series := make([]*pb.VoteHistorySeries, 0)
+	for _, s := range history.Series {
+		counts := make([]int32, len(s.Counts))
+		for i, numVotes := range s.Counts {
+			counts[i] = int32(numVotes)
+		}
+		series = append(series, &pb.VoteHistorySeries{Shortcode: s.Shortcode, Counts: counts})
+	}
+	return &pb.VoteHistoryResponse{
+		From:   timestamppb.New(history.From),
+		Step:   durationpb.New(history.Step),
+		Series: series,
+	}, nil
 }
 
-func (pS *PollServiceServer) VoteBulb(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":bulb:")
-}
+func (pS *PollServiceServer) Trending(_ context.Context, req *pb.TrendingRequest) (*pb.TrendingResponse, error) {
+	var halfLife, window time.Duration
+	if req.HalfLife != nil {
+		halfLife = req.HalfLife.AsDuration()
+	}
+	if req.Window != nil {
+		window = req.Window.AsDuration()
+	}
 
-func (pS *PollServiceServer) VoteMoneyWithWings(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":money_with_wings:")
-}
+	trends, err := pS.polls.Trending(req.PollId, halfLife, window)
+	if err != nil {
+		return nil, pollError(err)
+	}
 
-func (pS *PollServiceServer) VoteCrystalBall(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":crystal_ball:")
+	results := make([]*pb.TrendingResult, 0, len(trends.Trends))
+	for _, t := range trends.Trends {
+		results = append(results, &pb.TrendingResult{
+			Shortcode:    t.Shortcode,
+			Score:        t.Score,
+			Votes:        int32(t.NumVotes),
+			Rank:         int32(t.Rank),
+			PreviousRank: int32(t.PreviousRank),
+			Movement:     string(t.Movement),
+		})
+	}
+	return &pb.TrendingResponse{
+		Results:  results,
+		HalfLife: durationpb.New(trends.HalfLife),
+		Window:   durationpb.New(trends.Window),
+	}, nil
+}
+
+func (pS *PollServiceServer) CreatePoll(_ context.Context, req *pb.CreatePollRequest) (*pb.CreatePollResponse, error) {
+	schedule := voting.Schedule{
+		StartsAt: fromPbTime(req.StartsAt),
+		EndsAt:   fromPbTime(req.EndsAt),
+		Draft:    req.Draft,
+	}
+	rules := voting.BallotRules{
+		Mode:       voting.BallotMode(req.Mode),
+		MaxChoices: int(req.MaxChoices),
+	}
+	info, err := pS.polls.Create(req.Id, req.Title, req.Ballot, rules, schedule)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.CreatePollResponse{Poll: toPbPoll(info)}, nil
 }
 
-func (pS *PollServiceServer) VoteUnderage(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":underage:")
+func (pS *PollServiceServer) GetPoll(_ context.Context, req *pb.GetPollRequest) (*pb.GetPollResponse, error) {
+	info, err := pS.polls.Get(req.Id)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.GetPollResponse{Poll: toPbPoll(info)}, nil
 }
 
-func (pS *PollServiceServer) VoteInterrobang(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":interrobang:")
+func (pS *PollServiceServer) ListPolls(_ context.Context, req *pb.ListPollsRequest) (*pb.ListPollsResponse, error) {
+	polls := make([]*pb.Poll, 0)
+	for _, info := range pS.polls.List(req.IncludeArchived) {
+		polls = append(polls, toPbPoll(info))
+	}
+	return &pb.ListPollsResponse{Polls: polls}, nil
 }
 
-func (pS *PollServiceServer) Vote100(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":100:")
+func (pS *PollServiceServer) OpenPoll(_ context.Context, req *pb.OpenPollRequest) (*pb.OpenPollResponse, error) {
+	info, err := pS.polls.Open(req.Id)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.OpenPollResponse{Poll: toPbPoll(info)}, nil
 }
 
-func (pS *PollServiceServer) VoteCheckeredFlag(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":checkered_flag:")
+func (pS *PollServiceServer) ClosePoll(_ context.Context, req *pb.ClosePollRequest) (*pb.ClosePollResponse, error) {
+	info, err := pS.polls.Close(req.Id)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.ClosePollResponse{Poll: toPbPoll(info)}, nil
 }
 
-func (pS *PollServiceServer) VoteCrossedSwords(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":crossed_swords:")
+func (pS *PollServiceServer) ArchivePoll(_ context.Context, req *pb.ArchivePollRequest) (*pb.ArchivePollResponse, error) {
+	info, err := pS.polls.Archive(req.Id)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.ArchivePollResponse{Poll: toPbPoll(info)}, nil
+}
+
+func toPbPoll(info *voting.PollInfo) *pb.Poll {
+	return &pb.Poll{
+		Id:         info.ID,
+		Title:      info.Title,
+		Ballot:     info.Ballot,
+		Mode:       string(info.Mode),
+		MaxChoices: int32(info.MaxChoices),
+		Closed:     info.State == voting.PollClosed || info.State == voting.PollArchived,
+		State:      string(info.State),
+		StartsAt:   toPbTime(info.StartsAt),
+		EndsAt:     toPbTime(info.EndsAt),
+	}
 }
 
-func (pS *PollServiceServer) VoteFloppyDisk(_ context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
-	return pS.vote(":floppy_disk:")
+// toPbTime leaves unset times out of the response.
+func toPbTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
 }
 
-func (pS *PollServiceServer) Results(context.Context, *pb.ResultsRequest) (*pb.ResultsResponse, error) {
-	results, e := pS.poll.Results()
-	if e != nil {
-		return nil, e
+func fromPbTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
 	}
-
-	votingResults := make([]*pb.VotingResult, 0)
-	for _, e := range results {
-		result := pb.VotingResult{
-			Shortcode: e.Shortcode,
-			Votes:     int32(e.NumVotes),
+	return ts.AsTime()
+}
+
+// errorDomain names the voting service in the details of its errors.
+const errorDomain = "voting.emojivoto.buoyant.io"
+
+// pollErrors are the gRPC status of each kind of error from the voting
+// package, and the reason given in its details.
+var pollErrors = []struct {
+	err    error
+	code   codes.Code
+	reason string
+}{
+	{voting.ErrPollNotFound, codes.NotFound, "POLL_NOT_FOUND"},
+	{voting.ErrNoVote, codes.NotFound, "NO_VOTE"},
+	{voting.ErrTournamentNotFound, codes.NotFound, "TOURNAMENT_NOT_FOUND"},
+	{voting.ErrPollExists, codes.AlreadyExists, "POLL_EXISTS"},
+	{voting.ErrTournamentExists, codes.AlreadyExists, "TOURNAMENT_EXISTS"},
+	{voting.ErrPollNotOpen, codes.FailedPrecondition, "POLL_NOT_OPEN"},
+	{voting.ErrInvalidTransition, codes.FailedPrecondition, "INVALID_TRANSITION"},
+	{voting.ErrTournamentOver, codes.FailedPrecondition, "TOURNAMENT_OVER"},
+	{voting.ErrNotOnBallot, codes.InvalidArgument, "NOT_ON_BALLOT"},
+	{voting.ErrInvalidPoll, codes.InvalidArgument, "INVALID_POLL"},
+	{voting.ErrEmptyFilter, codes.InvalidArgument, "EMPTY_FILTER"},
+	{voting.ErrInvalidHistory, codes.InvalidArgument, "INVALID_HISTORY"},
+	{voting.ErrInvalidTrending, codes.InvalidArgument, "INVALID_TRENDING"},
+	{voting.ErrWrongBallot, codes.InvalidArgument, "WRONG_BALLOT"},
+	{voting.ErrInvalidBallot, codes.InvalidArgument, "INVALID_BALLOT"},
+	{voting.ErrInvalidTournament, codes.InvalidArgument, "INVALID_TOURNAMENT"},
+	{voting.ErrInvalidComparison, codes.InvalidArgument, "INVALID_COMPARISON"},
+	{voting.ErrStorage, codes.Unavailable, "STORAGE_UNAVAILABLE"},
+}
+
+// pollError gives errors from the voting package a matching gRPC status,
+// with the reason for it in an ErrorInfo detail. Errors that already have a
+// status keep it, context errors become Canceled or DeadlineExceeded, and
+// anything else is Internal.
+func pollError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	for _, e := range pollErrors {
+		if errors.Is(err, e.err) {
+			return statusWithReason(e.code, e.reason, err.Error())
 		}
-		votingResults = append(votingResults, &result)
 	}
+	return status.Error(codes.Internal, err.Error())
+}
 
-	response := &pb.ResultsResponse{
-		Results: votingResults,
+// statusWithReason is an error with the given status, and the reason for it
+// in an ErrorInfo detail.
+func statusWithReason(code codes.Code, reason, message string) error {
+	st := status.New(code, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain}); err == nil {
+		st = detailed
 	}
-	return response, nil
+	return st.Err()
 }
 
-func NewGrpServer(grpcServer *grpc.Server, poll voting.Poll, failureRate float32, artificialDelayDuration time.Duration) {
+func NewGrpServer(grpcServer *grpc.Server, polls *voting.Polls, tournaments *voting.Tournaments, faults *grpcfault.Injector, adminToken string) {
 	server := &PollServiceServer{
-		poll,
-		failureRate,
-		artificialDelayDuration,
+		polls,
+		faults,
+		newResultsHub(polls, watchInterval),
+		tournaments,
+		voting.NewRatings(polls),
+		adminToken,
 		pb.UnimplementedVotingServiceServer{},
 	}
 
 	pb.RegisterVotingServiceServer(grpcServer, server)
+	grpcfault.NewGrpServer(grpcServer, faults, adminToken)
 }
@@ -2,19 +2,608 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 
+	"github.com/buoyantio/emojivoto/admin"
+	"github.com/buoyantio/emojivoto/emojivoto-emoji-svc/emoji"
 	pb "github.com/buoyantio/emojivoto/emojivoto-voting-svc/gen/proto"
 	"github.com/buoyantio/emojivoto/emojivoto-voting-svc/voting"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+func newPollServiceServer(t *testing.T) *PollServiceServer {
+	ballot := make([]string, 0)
+	for _, e := range emoji.NewAllEmoji().List() {
+		ballot = append(ballot, e.Shortcode)
+	}
+	polls, err := voting.NewPolls(voting.NewMemoryStore(), ballot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tournaments, err := voting.NewTournaments(polls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &PollServiceServer{polls: polls, results: newResultsHub(polls, time.Millisecond), tournaments: tournaments, ratings: voting.NewRatings(polls)}
+}
+
+func TestVote(t *testing.T) {
+	t.Run("Computes vote for the requested shortcode", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+
+		shortcodeVotedFor := ":joy:"
+
+		_, err := emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: shortcodeVotedFor})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) == 0 || r[0].Shortcode != shortcodeVotedFor {
+			t.Fatalf("Voted for [%s] but results were [%v]", shortcodeVotedFor, r)
+		}
+	})
+
+	t.Run("Rejects shortcodes that are not in the catalog", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+
+		_, err := emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":not_an_emoji:"})
+
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected InvalidArgument when voting for an unknown shortcode, got [%v]", err)
+		}
+
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) != 0 {
+			t.Fatalf("Expected no votes to be recorded, got [%v]", r)
+		}
+	})
+
+	t.Run("Moves the vote of a voter identified in the metadata", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("voter-id", "voter-1"))
+		emojivotoService := newPollServiceServer(t)
+
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":joy:"})
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":ghost:"})
+
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) != 1 || r[0].Shortcode != ":ghost:" || r[0].NumVotes != 1 {
+			t.Fatalf("Expected a single vote for [:ghost:], got [%v]", r)
+		}
+	})
+
+	t.Run("Weighs votes by the caller's metadata", func(t *testing.T) {
+		emojivotoService := newPollServiceServer(t)
+		emojivotoService.polls.SetWeightPolicy(&voting.WeightPolicy{Default: 1, Rules: []voting.WeightRule{
+			{Match: map[string]string{"voter-class": "partner"}, Weight: 3},
+			{Match: map[string]string{"api-key": "s3cr3t"}, Weight: 5},
+		}})
+
+		partner := metadata.NewIncomingContext(context.Background(), metadata.Pairs("voter-class", "partner"))
+		emojivotoService.Vote(partner, &pb.VoteRequest{Shortcode: ":joy:"})
+		emojivotoService.Vote(context.Background(), &pb.VoteRequest{Shortcode: ":joy:"})
+		withKey := metadata.NewIncomingContext(context.Background(), metadata.Pairs("api-key", "s3cr3t"))
+		emojivotoService.Vote(withKey, &pb.VoteRequest{Shortcode: ":joy:"})
+
+		response, err := emojivotoService.Results(context.Background(), &pb.ResultsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := response.Results; len(r) != 1 || r[0].Votes != 3 || r[0].WeightedVotes != 5 {
+			t.Fatalf("Expected [3] votes weighing [5] for [:joy:], with metadata beyond the allowed keys ignored, got [%v]", r)
+		}
+	})
+}
+
+func TestVoteRanked(t *testing.T) {
+	t.Run("Counts ranked ballots by instant runoff", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+		_, err := emojivotoService.CreatePoll(ctx, &pb.CreatePollRequest{Id: "mascot", Ballot: []string{":joy:", ":ghost:", ":taco:"}, Mode: "ranked"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ballots := [][]string{{":joy:"}, {":joy:"}, {":ghost:"}, {":ghost:"}, {":taco:", ":ghost:"}}
+		for _, ranking := range ballots {
+			_, err := emojivotoService.VoteRanked(ctx, &pb.VoteRankedRequest{PollId: "mascot", Ballot: &pb.RankedBallot{Shortcodes: ranking}})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err = emojivotoService.VoteRanked(ctx, &pb.VoteRankedRequest{PollId: "mascot", Ballot: &pb.RankedBallot{}})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected an empty ballot to be an invalid argument, got [%v]", err)
+		}
+		_, err = emojivotoService.Vote(ctx, &pb.VoteRequest{PollId: "mascot", Shortcode: ":joy:"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected a single choice vote to be an invalid argument, got [%v]", err)
+		}
+
+		response, err := emojivotoService.RankedResults(ctx, &pb.RankedResultsRequest{PollId: "mascot"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Winner != ":ghost:" || response.Ballots != 5 || len(response.Rounds) != 2 {
+			t.Fatalf("Expected [:ghost:] to win in the second round, got [%v]", response)
+		}
+		if eliminated := response.Rounds[0].Eliminated; len(eliminated) != 1 || eliminated[0] != ":taco:" {
+			t.Fatalf("Expected [:taco:] to be eliminated first, got [%v]", eliminated)
+		}
+	})
+}
+
+func TestVoteApproval(t *testing.T) {
+	t.Run("Counts approvals and ballots", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+		_, err := emojivotoService.CreatePoll(ctx, &pb.CreatePollRequest{Id: "lunch", Mode: "approval", MaxChoices: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, choices := range [][]string{{":pizza:", ":taco:"}, {":taco:"}} {
+			if _, err := emojivotoService.VoteApproval(ctx, &pb.VoteApprovalRequest{PollId: "lunch", Shortcodes: choices}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err = emojivotoService.VoteApproval(ctx, &pb.VoteApprovalRequest{PollId: "lunch", Shortcodes: []string{":pizza:", ":taco:", ":joy:"}})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected too many choices to be an invalid argument, got [%v]", err)
+		}
+
+		response, err := emojivotoService.Results(ctx, &pb.ResultsRequest{PollId: "lunch"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Ballots != 2 || len(response.Results) != 2 || response.Results[0].Shortcode != ":taco:" || response.Results[0].Votes != 2 {
+			t.Fatalf("Expected [:taco:] approved on both of [2] ballots, got [%v]", response)
+		}
+	})
+}
+
+func TestRetract(t *testing.T) {
+	t.Run("Retracts the caller's vote", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("voter-id", "voter-1"))
+		emojivotoService := newPollServiceServer(t)
+
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":joy:"})
+		if _, err := emojivotoService.Retract(ctx, &pb.RetractRequest{Shortcode: ":joy:"}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := emojivotoService.Retract(ctx, &pb.RetractRequest{Shortcode: ":joy:"})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("Expected NotFound when there is no vote left, got [%v]", err)
+		}
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) != 0 {
+			t.Fatalf("Expected no votes to be left, got [%v]", r)
+		}
+	})
+
+	t.Run("Refuses to retract a vote without a voter-id", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":joy:"})
+		_, err := emojivotoService.Retract(ctx, &pb.RetractRequest{Shortcode: ":joy:"})
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("Expected PermissionDenied, got [%v]", err)
+		}
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) != 1 || r[0].NumVotes != 1 {
+			t.Fatalf("Expected the vote to be kept, got [%v]", r)
+		}
+	})
+
+	t.Run("Retracts votes in bulk only for admins", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(admin.Key, "Bearer "))
+		emojivotoService := newPollServiceServer(t)
+
+		_, err := emojivotoService.RetractVotes(ctx, &pb.RetractVotesRequest{VoterId: "vote-bot"})
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("Expected PermissionDenied without an admin token set, got [%v]", err)
+		}
+	})
+
+	t.Run("Retracts votes in bulk", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(admin.Key, "Bearer secret"))
+		emojivotoService := newPollServiceServer(t)
+		emojivotoService.adminToken = "secret"
+		bot := metadata.NewIncomingContext(context.Background(), metadata.Pairs("voter-id", "vote-bot"))
+
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":joy:"})
+		emojivotoService.Vote(bot, &pb.VoteRequest{Shortcode: ":ghost:"})
+
+		_, err := emojivotoService.RetractVotes(bot, &pb.RetractVotesRequest{VoterId: "vote-bot"})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("Expected Unauthenticated without the admin token, got [%v]", err)
+		}
+
+		_, err = emojivotoService.RetractVotes(ctx, &pb.RetractVotesRequest{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected InvalidArgument for an empty filter, got [%v]", err)
+		}
+
+		response, err := emojivotoService.RetractVotes(ctx, &pb.RetractVotesRequest{VoterId: "vote-bot"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Retracted != 1 {
+			t.Fatalf("Expected [1] vote to be retracted, got [%d]", response.Retracted)
+		}
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) != 1 || r[0].Shortcode != ":joy:" {
+			t.Fatalf("Expected only the vote for [:joy:] to be left, got [%v]", r)
+		}
+	})
+}
+
+func TestVoteHistory(t *testing.T) {
+	t.Run("Returns the votes cast over the last hour by default", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":doughnut:"})
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":doughnut:"})
+
+		history, err := emojivotoService.VoteHistory(ctx, &pb.VoteHistoryRequest{Shortcodes: []string{":doughnut:"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if history.Step.AsDuration() != time.Minute || len(history.Series) != 1 {
+			t.Fatalf("Expected a per-minute series for [:doughnut:], got [%v]", history)
+		}
+		counts := history.Series[0].Counts
+		if len(counts) < 60 || counts[len(counts)-1] != 2 {
+			t.Fatalf("Expected an hour of counts ending with [2], got [%v]", counts)
+		}
+	})
+
+	t.Run("Clips ranges to the retention period", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+
+		_, err := emojivotoService.VoteHistory(ctx, &pb.VoteHistoryRequest{
+			From: timestamppb.New(time.Now().Add(-100 * 24 * time.Hour)),
+			Step: durationpb.New(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("Expected the range to be clipped to the retention period, got [%v]", err)
+		}
+	})
+}
+
+func TestTrending(t *testing.T) {
+	t.Run("Ranks recent votes", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":doughnut:"})
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":doughnut:"})
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":joy:"})
+
+		trending, err := emojivotoService.Trending(ctx, &pb.TrendingRequest{HalfLife: durationpb.New(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trending.HalfLife.AsDuration() != time.Hour || trending.Window.AsDuration() != time.Hour {
+			t.Fatalf("Expected a half-life and window of an hour, got [%v]", trending)
+		}
+		if len(trending.Results) != 2 || trending.Results[0].Shortcode != ":doughnut:" || trending.Results[0].Votes != 2 || trending.Results[0].Movement != "rising" {
+			t.Fatalf("Expected [:doughnut:] rising to the top, got [%v]", trending.Results)
+		}
+	})
+
+	t.Run("Rejects invalid half-lives", func(t *testing.T) {
+		emojivotoService := newPollServiceServer(t)
+
+		_, err := emojivotoService.Trending(context.Background(), &pb.TrendingRequest{HalfLife: durationpb.New(-time.Hour)})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected a negative half-life to be an invalid argument, got [%v]", err)
+		}
+	})
+}
+
+func TestVoteBatch(t *testing.T) {
+	votes := []*pb.VoteRequest{
+		{Shortcode: ":joy:"},
+		{Shortcode: ":not_an_emoji:"},
+		{Shortcode: ":joy:", PollId: "nope"},
+		{Shortcode: ":ghost:"},
+	}
+	checkBatch := func(t *testing.T, emojivotoService *PollServiceServer, response *pb.VoteBatchResponse) {
+		if response.Accepted != 2 || len(response.Results) != len(votes) {
+			t.Fatalf("Expected [2] of [%d] votes accepted, got [%v]", len(votes), response)
+		}
+		expected := []codes.Code{codes.OK, codes.InvalidArgument, codes.NotFound, codes.OK}
+		for i, r := range response.Results {
+			if r.Index != int32(i) || codes.Code(r.Code) != expected[i] {
+				t.Fatalf("Expected vote [%d] to end with [%v], got [%v]", i, expected[i], r)
+			}
+		}
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) != 2 {
+			t.Fatalf("Expected votes for [:joy:] and [:ghost:], got [%v]", r)
+		}
+	}
+
+	t.Run("Reports on each vote in a batch", func(t *testing.T) {
+		emojivotoService := newPollServiceServer(t)
+
+		response, err := emojivotoService.VoteBatch(context.Background(), &pb.VoteBatchRequest{Votes: votes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkBatch(t, emojivotoService, response)
+
+		_, err = emojivotoService.VoteBatch(context.Background(), &pb.VoteBatchRequest{Votes: make([]*pb.VoteRequest, MaxBatchVotes+1)})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected too large a batch to be an invalid argument, got [%v]", err)
+		}
+	})
+
+	t.Run("Reports on each vote in a stream", func(t *testing.T) {
+		emojivotoService := newPollServiceServer(t)
+
+		stream := &voteStream{ctx: context.Background(), votes: votes}
+		if err := emojivotoService.VoteStream(stream); err != nil {
+			t.Fatal(err)
+		}
+		checkBatch(t, emojivotoService, stream.response)
+	})
+
+	t.Run("Stops reading a stream past the most votes", func(t *testing.T) {
+		emojivotoService := newPollServiceServer(t)
+
+		tooMany := make([]*pb.VoteRequest, MaxBatchVotes+5)
+		for i := range tooMany {
+			tooMany[i] = &pb.VoteRequest{Shortcode: ":joy:"}
+		}
+		stream := &voteStream{ctx: context.Background(), votes: tooMany}
+		if err := emojivotoService.VoteStream(stream); err != nil {
+			t.Fatal(err)
+		}
+
+		response := stream.response
+		if response.Accepted != MaxBatchVotes || len(response.Results) != MaxBatchVotes+1 {
+			t.Fatalf("Expected [%d] votes to be cast and one more reported, got [%d] cast and [%d] reported", MaxBatchVotes, response.Accepted, len(response.Results))
+		}
+		for i, r := range response.Results[:MaxBatchVotes] {
+			if r.Index != int32(i) || codes.Code(r.Code) != codes.OK {
+				t.Fatalf("Expected vote [%d] to be cast, got [%v]", i, r)
+			}
+		}
+		if r := response.Results[MaxBatchVotes]; r.Index != MaxBatchVotes || codes.Code(r.Code) != codes.ResourceExhausted {
+			t.Fatalf("Expected vote [%d] to be [ResourceExhausted], got [%v]", MaxBatchVotes, r)
+		}
+		if len(stream.votes) != 4 {
+			t.Fatalf("Expected the last [4] votes to go unread, got [%d] left", len(stream.votes))
+		}
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) != 1 || r[0].NumVotes != MaxBatchVotes {
+			t.Fatalf("Expected [%d] votes for [:joy:], got [%v]", MaxBatchVotes, r)
+		}
+	})
+}
+
+type voteStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	votes    []*pb.VoteRequest
+	response *pb.VoteBatchResponse
+}
+
+func (s *voteStream) Context() context.Context { return s.ctx }
+
+func (s *voteStream) Recv() (*pb.VoteRequest, error) {
+	if len(s.votes) == 0 {
+		return nil, io.EOF
+	}
+	v := s.votes[0]
+	s.votes = s.votes[1:]
+	return v, nil
+}
+
+func (s *voteStream) SendAndClose(response *pb.VoteBatchResponse) error {
+	s.response = response
+	return nil
+}
+
+type watchResultsStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	updates chan *pb.ResultsUpdate
+}
+
+func (s *watchResultsStream) Context() context.Context { return s.ctx }
+
+func (s *watchResultsStream) Send(update *pb.ResultsUpdate) error {
+	s.updates <- update
+	return nil
+}
+
+func nextUpdate(t *testing.T, updates chan *pb.ResultsUpdate) *pb.ResultsUpdate {
+	select {
+	case update := <-updates:
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a results update")
+		return nil
+	}
+}
+
+func TestWatchResults(t *testing.T) {
+	t.Run("Streams every result and then what changed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		emojivotoService := newPollServiceServer(t)
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":joy:"})
+
+		stream := &watchResultsStream{ctx: ctx, updates: make(chan *pb.ResultsUpdate, 10)}
+		errs := make(chan error, 1)
+		go func() { errs <- emojivotoService.WatchResults(&pb.WatchResultsRequest{}, stream) }()
+
+		first := nextUpdate(t, stream.updates)
+		if !first.Full || len(first.Results) != 1 || first.Results[0].Votes != 1 {
+			t.Fatalf("Expected every result first, got [%v]", first)
+		}
+
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":ghost:"})
+		second := nextUpdate(t, stream.updates)
+		if second.Full || len(second.Results) != 1 || second.Results[0].Shortcode != ":ghost:" {
+			t.Fatalf("Expected only the change to :ghost:, got [%v]", second)
+		}
+
+		cancel()
+		if err := <-errs; err != nil {
+			t.Fatalf("Expected the stream to end cleanly, got [%v]", err)
+		}
+	})
+
+	t.Run("Streams every result when the leaderboard is reordered", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		emojivotoService := newPollServiceServer(t)
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":joy:"})
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":ghost:"})
+
+		stream := &watchResultsStream{ctx: ctx, updates: make(chan *pb.ResultsUpdate, 10)}
+		go emojivotoService.WatchResults(&pb.WatchResultsRequest{}, stream)
+		nextUpdate(t, stream.updates)
+
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":ghost:"})
+		update := nextUpdate(t, stream.updates)
+		if !update.Full || len(update.Results) != 2 || update.Results[0].Shortcode != ":ghost:" || update.Results[1].Rank != 2 {
+			t.Fatalf("Expected every result with [:ghost:] ahead, got [%v]", update)
+		}
+	})
+
+	t.Run("Shares a feed between watchers and stops it when they leave", func(t *testing.T) {
+		emojivotoService := newPollServiceServer(t)
+		hub := emojivotoService.results
+
+		stops := make([]func(), 0)
+		for i := 0; i < 50; i++ {
+			_, stop, err := hub.watch("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			stops = append(stops, stop)
+		}
+		if len(hub.feeds) != 1 {
+			t.Fatalf("Expected a single feed, got [%d]", len(hub.feeds))
+		}
+		for _, stop := range stops {
+			stop()
+			stop()
+		}
+		if len(hub.feeds) != 0 {
+			t.Fatalf("Expected the feed to stop, got [%d]", len(hub.feeds))
+		}
+
+		if _, _, err := hub.watch("dinner"); err == nil {
+			t.Fatal("Expected watching an unknown poll to fail")
+		}
+	})
+}
+
+func TestTournaments(t *testing.T) {
+	t.Run("Runs a bracket of matchup polls", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+
+		created, err := emojivotoService.CreateTournament(ctx, &pb.CreateTournamentRequest{Id: "cup", Size: 4, MatchupDuration: durationpb.New(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rounds := created.Tournament.Rounds; len(rounds) != 2 || len(rounds[0].Matchups) != 2 || created.Tournament.MatchupDuration.AsDuration() != time.Hour {
+			t.Fatalf("Expected two rounds of hour long matchups, got [%v]", created.Tournament)
+		}
+		_, err = emojivotoService.CreateTournament(ctx, &pb.CreateTournamentRequest{Id: "cup"})
+		if status.Code(err) != codes.AlreadyExists {
+			t.Fatalf("Expected a second [cup] to already exist, got [%v]", err)
+		}
+
+		current, _ := emojivotoService.CurrentMatchup(ctx, &pb.CurrentMatchupRequest{TournamentId: "cup"})
+		underdog := current.Matchup.Entrants[1].Shortcode
+		if _, err := emojivotoService.Vote(ctx, &pb.VoteRequest{PollId: current.Matchup.PollId, Shortcode: underdog}); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if _, err := emojivotoService.AdvanceTournament(ctx, &pb.AdvanceTournamentRequest{Id: "cup"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		response, err := emojivotoService.GetTournament(ctx, &pb.GetTournamentRequest{Id: "cup"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first := response.Tournament.Rounds[0].Matchups[0]; first.Winner != underdog || first.State != "decided" {
+			t.Fatalf("Expected [%s] to win the first matchup, got [%v]", underdog, first)
+		}
+		if response.Tournament.Champion == "" {
+			t.Fatalf("Expected a champion, got [%v]", response.Tournament)
+		}
+
+		_, err = emojivotoService.AdvanceTournament(ctx, &pb.AdvanceTournamentRequest{Id: "cup"})
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("Expected a finished tournament not to advance, got [%v]", err)
+		}
+		_, err = emojivotoService.GetTournament(ctx, &pb.GetTournamentRequest{Id: "bowl"})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("Expected an unknown tournament not to be found, got [%v]", err)
+		}
+	})
+}
+
+func TestPairwise(t *testing.T) {
+	t.Run("Rates the emoji from the pairs compared", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+
+		pair, err := emojivotoService.NextPair(ctx, &pb.NextPairRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pair.First == "" || pair.First == pair.Second {
+			t.Fatalf("Expected two different emoji, got [%v]", pair)
+		}
+
+		compared, err := emojivotoService.ComparePair(ctx, &pb.ComparePairRequest{Winner: pair.Second, Loser: pair.First})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compared.Winner.Rating <= compared.Loser.Rating || compared.Winner.Comparisons != 1 {
+			t.Fatalf("Expected [%s] to be rated above [%s], got [%v]", pair.Second, pair.First, compared)
+		}
+
+		response, err := emojivotoService.Ratings(ctx, &pb.RatingsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ratings := response.Ratings
+		if len(ratings) != len(emoji.NewAllEmoji().List()) || ratings[0].Shortcode != pair.Second || ratings[len(ratings)-1].Shortcode != pair.First {
+			t.Fatalf("Expected [%s] first and [%s] last, got [%v]", pair.Second, pair.First, ratings)
+		}
+
+		_, err = emojivotoService.ComparePair(ctx, &pb.ComparePairRequest{Winner: ":unknown:", Loser: pair.First})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected an unknown emoji to be an invalid argument, got [%v]", err)
+		}
+	})
+}
+
 func TestVoteJoy(t *testing.T) {
 	t.Run("Computes vote", func(t *testing.T) {
 		ctx := context.Background()
-		poll := voting.NewPoll()
-		emojivotoService := PollServiceServer{
-			poll: poll,
-		}
+		emojivotoService := newPollServiceServer(t)
 
 		shortcodeVotedFor := ":joy:"
 
@@ -25,19 +614,25 @@ func TestVoteJoy(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		if r, _ := poll.Results(); len(r) == 0 || r[0].Shortcode != shortcodeVotedFor {
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) == 0 || r[0].Shortcode != shortcodeVotedFor {
 			t.Fatalf("Voted for [%s] but results were [%v]", shortcodeVotedFor, r)
 		}
 	})
+
+	t.Run("Rejects legacy votes for shortcodes off the ballot", func(t *testing.T) {
+		emojivotoService := newPollServiceServer(t)
+
+		_, err := emojivotoService.VoteRelaxed(context.Background(), &pb.VoteRequest{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected InvalidArgument for [:relaxed:], which isn't in the catalog, got [%v]", err)
+		}
+	})
 }
 
 func TestLeaderboard(t *testing.T) {
 	t.Run("Returns expected leaderboard", func(t *testing.T) {
 		ctx := context.Background()
-		poll := voting.NewPoll()
-		emojivotoService := PollServiceServer{
-			poll: poll,
-		}
+		emojivotoService := newPollServiceServer(t)
 
 		votedForTwice := ":wave:"
 		votedForOnce := ":ghost:"
@@ -64,7 +659,168 @@ func TestLeaderboard(t *testing.T) {
 		if response.Results[1].Shortcode != votedForOnce || response.Results[1].Votes != 1 {
 			t.Fatalf("Expected results to be [%v,%v], found: [%v]", votedForOnce, 1, response.Results)
 		}
+		if response.Votes != 3 || response.Confidence != voting.Confidence || response.Results[0].Share != 2.0/3 {
+			t.Fatalf("Expected [3] votes in total and [2/3] of them for [%v], found: [%v]", votedForTwice, response)
+		}
+		if response.Lead.Leader != votedForTwice || response.Lead.RunnerUp != votedForOnce || response.Lead.Significant {
+			t.Fatalf("Expected an insignificant lead for [%v], found: [%v]", votedForTwice, response.Lead)
+		}
+	})
+}
+
+func TestPolls(t *testing.T) {
+	t.Run("Keeps votes in separate polls", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+
+		created, err := emojivotoService.CreatePoll(ctx, &pb.CreatePollRequest{
+			Title:  "Lunch",
+			Ballot: []string{":pizza:", ":taco:"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created.Poll.Id != "lunch" {
+			t.Fatalf("Expected poll ID to be derived from its title, got [%s]", created.Poll.Id)
+		}
+
+		emojivotoService.Vote(ctx, &pb.VoteRequest{PollId: "lunch", Shortcode: ":taco:"})
+		emojivotoService.Vote(ctx, &pb.VoteRequest{Shortcode: ":joy:"})
+
+		lunch, err := emojivotoService.Results(ctx, &pb.ResultsRequest{PollId: "lunch"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lunch.Results) != 1 || lunch.Results[0].Shortcode != ":taco:" {
+			t.Fatalf("Expected lunch results to only contain [:taco:], found: [%v]", lunch.Results)
+		}
+
+		defaultResults, _ := emojivotoService.Results(ctx, &pb.ResultsRequest{})
+		if len(defaultResults.Results) != 1 || defaultResults.Results[0].Shortcode != ":joy:" {
+			t.Fatalf("Expected default results to only contain [:joy:], found: [%v]", defaultResults.Results)
+		}
+
+		list, _ := emojivotoService.ListPolls(ctx, &pb.ListPollsRequest{})
+		if len(list.Polls) != 2 || list.Polls[0].Id != voting.DefaultPollID {
+			t.Fatalf("Expected the default and lunch polls, found: [%v]", list.Polls)
+		}
+	})
+
+	t.Run("Rejects votes that are not on the poll's ballot", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+		emojivotoService.CreatePoll(ctx, &pb.CreatePollRequest{Id: "lunch", Ballot: []string{":pizza:"}})
+
+		_, err := emojivotoService.Vote(ctx, &pb.VoteRequest{PollId: "lunch", Shortcode: ":joy:"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected InvalidArgument, got [%v]", err)
+		}
+	})
+
+	t.Run("Rejects votes in closed and unknown polls", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+		emojivotoService.CreatePoll(ctx, &pb.CreatePollRequest{Id: "lunch"})
+
+		if _, err := emojivotoService.ClosePoll(ctx, &pb.ClosePollRequest{Id: "lunch"}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := emojivotoService.Vote(ctx, &pb.VoteRequest{PollId: "lunch", Shortcode: ":joy:"})
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("Expected FailedPrecondition, got [%v]", err)
+		}
+
+		_, err = emojivotoService.Vote(ctx, &pb.VoteRequest{PollId: "dinner", Shortcode: ":joy:"})
+		if status.Code(err) != codes.NotFound {
+			t.Fatalf("Expected NotFound, got [%v]", err)
+		}
+	})
+
+	t.Run("Schedules polls and moves them through their lifecycle", func(t *testing.T) {
+		ctx := context.Background()
+		emojivotoService := newPollServiceServer(t)
+		endsAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+		created, err := emojivotoService.CreatePoll(ctx, &pb.CreatePollRequest{
+			Id:     "lunch",
+			Draft:  true,
+			EndsAt: timestamppb.New(endsAt),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created.Poll.State != "draft" || created.Poll.StartsAt != nil || !created.Poll.EndsAt.AsTime().Equal(endsAt) {
+			t.Fatalf("Expected a draft poll ending at [%v], got [%v]", endsAt, created.Poll)
+		}
+
+		_, err = emojivotoService.Vote(ctx, &pb.VoteRequest{PollId: "lunch", Shortcode: ":joy:"})
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("Expected FailedPrecondition, got [%v]", err)
+		}
+		_, err = emojivotoService.ArchivePoll(ctx, &pb.ArchivePollRequest{Id: "lunch"})
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("Expected FailedPrecondition, got [%v]", err)
+		}
+
+		opened, err := emojivotoService.OpenPoll(ctx, &pb.OpenPollRequest{Id: "lunch"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opened.Poll.State != "open" || opened.Poll.StartsAt == nil {
+			t.Fatalf("Expected an open poll with a start time, got [%v]", opened.Poll)
+		}
+
+		emojivotoService.ClosePoll(ctx, &pb.ClosePollRequest{Id: "lunch"})
+		archived, err := emojivotoService.ArchivePoll(ctx, &pb.ArchivePollRequest{Id: "lunch"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if archived.Poll.State != "archived" || !archived.Poll.Closed {
+			t.Fatalf("Expected an archived poll, got [%v]", archived.Poll)
+		}
+
+		list, _ := emojivotoService.ListPolls(ctx, &pb.ListPollsRequest{})
+		if len(list.Polls) != 1 {
+			t.Fatalf("Expected archived polls to be hidden, found: [%v]", list.Polls)
+		}
+		list, _ = emojivotoService.ListPolls(ctx, &pb.ListPollsRequest{IncludeArchived: true})
+		if len(list.Polls) != 2 {
+			t.Fatalf("Expected archived polls to be listed, found: [%v]", list.Polls)
+		}
 	})
 }
 
 //TODO: test for errors
+
+func TestPollError(t *testing.T) {
+	t.Run("Gives errors a status with the reason in the details", func(t *testing.T) {
+		emojivotoService := newPollServiceServer(t)
+
+		_, err := emojivotoService.Vote(context.Background(), &pb.VoteRequest{Shortcode: ":joy:", PollId: "nope"})
+		st := status.Convert(err)
+		if st.Code() != codes.NotFound || len(st.Details()) != 1 {
+			t.Fatalf("Expected an unknown poll to be not found, with details, got [%v]", err)
+		}
+		if info := st.Details()[0].(*errdetails.ErrorInfo); info.Reason != "POLL_NOT_FOUND" || info.Domain != errorDomain {
+			t.Fatalf("Expected the reason in the details, got [%v]", info)
+		}
+	})
+
+	t.Run("Maps failures other than the caller's", func(t *testing.T) {
+		for _, test := range []struct {
+			err  error
+			code codes.Code
+		}{
+			{fmt.Errorf("%w: disk full", voting.ErrStorage), codes.Unavailable},
+			{fmt.Errorf("waiting: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+			{context.Canceled, codes.Canceled},
+			{status.Error(codes.ResourceExhausted, "slow down"), codes.ResourceExhausted},
+			{errors.New("boom"), codes.Internal},
+		} {
+			if err := pollError(test.err); status.Code(err) != test.code {
+				t.Fatalf("Expected [%v] to be [%v], got [%v]", test.err, test.code, err)
+			}
+		}
+	})
+}
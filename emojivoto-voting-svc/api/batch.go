@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	pb "github.com/buoyantio/emojivoto/emojivoto-voting-svc/gen/proto"
+	"github.com/buoyantio/emojivoto/emojivoto-voting-svc/voting"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxBatchVotes is the most votes VoteBatch and VoteStream take in one call.
+const MaxBatchVotes = 1000
+
+func (pS *PollServiceServer) VoteBatch(ctx context.Context, req *pb.VoteBatchRequest) (*pb.VoteBatchResponse, error) {
+	if len(req.Votes) > MaxBatchVotes {
+		return nil, tooManyVotes(fmt.Sprintf("[%d] votes in a batch", len(req.Votes)))
+	}
+
+	voter := voterFromContext(ctx)
+	response := &pb.VoteBatchResponse{Results: make([]*pb.BatchVoteResult, 0, len(req.Votes))}
+	for i, v := range req.Votes {
+		pS.batchVote(ctx, response, voter, i, v)
+	}
+	return response, nil
+}
+
+// VoteStream casts votes as they are streamed, and reports on them once the
+// stream ends. A stream can't go on past MaxBatchVotes: the vote that goes
+// over is reported as RESOURCE_EXHAUSTED, and the report sent without reading
+// any more, so the caller still learns how the votes before it went.
+func (pS *PollServiceServer) VoteStream(stream pb.VotingService_VoteStreamServer) error {
+	voter := voterFromContext(stream.Context())
+	response := &pb.VoteBatchResponse{Results: make([]*pb.BatchVoteResult, 0)}
+	for i := 0; ; i++ {
+		v, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(response)
+		}
+		if err != nil {
+			return err
+		}
+		if i >= MaxBatchVotes {
+			response.Results = append(response.Results, &pb.BatchVoteResult{
+				Index:   int32(i),
+				Code:    int32(codes.ResourceExhausted),
+				Message: fmt.Sprintf("more than [%d] votes in a stream, this vote and the ones after it weren't cast", MaxBatchVotes),
+			})
+			return stream.SendAndClose(response)
+		}
+		pS.batchVote(stream.Context(), response, voter, i, v)
+	}
+}
+
+func tooManyVotes(message string) error {
+	st, _ := status.Newf(codes.InvalidArgument, "%s, up to [%d] are allowed", message, MaxBatchVotes).WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "votes", Description: fmt.Sprintf("up to [%d] votes are allowed", MaxBatchVotes)}},
+	})
+	return st.Err()
+}
+
+// batchVote casts one vote of a batch or stream, and adds how it went to
+// the response. Faults for the vote's shortcode fail it alone.
+func (pS *PollServiceServer) batchVote(ctx context.Context, response *pb.VoteBatchResponse, voter voting.Voter, index int, req *pb.VoteRequest) {
+	err := pS.faults.InjectShortcode(ctx, req.Shortcode)
+	if err == nil {
+		if err = pS.polls.Vote(req.PollId, voter, req.Shortcode); err != nil {
+			err = pollError(err)
+		}
+	}
+
+	result := &pb.BatchVoteResult{Index: int32(index)}
+	if err != nil {
+		s := status.Convert(err)
+		result.Code, result.Message = int32(s.Code()), s.Message()
+	} else {
+		response.Accepted++
+	}
+	response.Results = append(response.Results, result)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	idempotencyKeyMetadata = "idempotency-key"
+
+	// DefaultIdempotencyWindow is how long a response is kept for calls
+	// retried with the same idempotency key.
+	DefaultIdempotencyWindow = 5 * time.Minute
+	// DefaultIdempotencyKeys is how many responses are kept at most, the
+	// oldest going first.
+	DefaultIdempotencyKeys = 10000
+)
+
+// Idempotency makes unary calls sent with idempotency-key metadata safe to
+// retry: a call repeated with the same key and method within the window gets
+// the original response instead of running again, and a repeat that arrives
+// while the original is still running waits for it. Only successful
+// responses are kept, so calls that failed can be retried.
+type Idempotency struct {
+	sync.Mutex
+	window  time.Duration
+	maxKeys int
+	calls   map[string]*idempotentCall
+	// order has the keys of calls, oldest first.
+	order *list.List
+	now   func() time.Time
+}
+
+type idempotentCall struct {
+	request  [sha256.Size]byte
+	started  time.Time
+	element  *list.Element
+	done     chan struct{}
+	response interface{}
+	failed   bool
+}
+
+// UnaryServerInterceptor dedupes calls with an idempotency key.
+func (id *Idempotency) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	key := firstMetadataValue(ctx, idempotencyKeyMetadata)
+	message, ok := req.(proto.Message)
+	if key == "" || !ok {
+		return handler(ctx, req)
+	}
+	bytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return handler(ctx, req)
+	}
+	request := sha256.Sum256(bytes)
+	callKey := info.FullMethod + " " + key
+
+	for {
+		id.Lock()
+		id.expire()
+		call, ok := id.calls[callKey]
+		if !ok {
+			call = &idempotentCall{request: request, started: id.now(), done: make(chan struct{})}
+			call.element = id.order.PushBack(callKey)
+			id.calls[callKey] = call
+			id.evict()
+			id.Unlock()
+			return id.run(ctx, req, handler, callKey, call)
+		}
+		id.Unlock()
+
+		if call.request != request {
+			return nil, status.Errorf(codes.InvalidArgument, "idempotency key [%s] was already used for a different [%s] request", key, info.FullMethod)
+		}
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if !call.failed {
+			log.Printf("Replaying the response to [%s] with idempotency key [%s]", info.FullMethod, key)
+			return call.response, nil
+		}
+		// The original call failed, so this one runs in its place.
+	}
+}
+
+func (id *Idempotency) run(ctx context.Context, req interface{}, handler grpc.UnaryHandler, callKey string, call *idempotentCall) (interface{}, error) {
+	response, err := handler(ctx, req)
+
+	id.Lock()
+	defer id.Unlock()
+
+	if err != nil {
+		call.failed = true
+		id.forget(callKey, call)
+	} else {
+		call.response = response
+	}
+	close(call.done)
+	return response, err
+}
+
+// expire forgets the calls that started before the window. Callers must
+// hold the lock.
+func (id *Idempotency) expire() {
+	cutoff := id.now().Add(-id.window)
+	for e := id.order.Front(); e != nil; e = id.order.Front() {
+		callKey := e.Value.(string)
+		if !id.calls[callKey].started.Before(cutoff) {
+			return
+		}
+		id.forget(callKey, id.calls[callKey])
+	}
+}
+
+// evict forgets the oldest calls while there are too many. Callers must hold
+// the lock.
+func (id *Idempotency) evict() {
+	for id.order.Len() > id.maxKeys {
+		callKey := id.order.Front().Value.(string)
+		id.forget(callKey, id.calls[callKey])
+	}
+}
+
+// forget forgets a call, unless it was already replaced. Callers must hold
+// the lock.
+func (id *Idempotency) forget(callKey string, call *idempotentCall) {
+	if id.calls[callKey] != call {
+		return
+	}
+	delete(id.calls, callKey)
+	id.order.Remove(call.element)
+}
+
+// NewIdempotency keeps the responses to calls with an idempotency key for
+// window, and keeps at most maxKeys of them.
+func NewIdempotency(window time.Duration, maxKeys int) *Idempotency {
+	return &Idempotency{
+		window:  window,
+		maxKeys: maxKeys,
+		calls:   make(map[string]*idempotentCall),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/buoyantio/emojivoto/emojivoto-voting-svc/gen/proto"
+	"github.com/buoyantio/emojivoto/emojivoto-voting-svc/voting"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestIdempotency(t *testing.T) {
+	voteInfo := &grpc.UnaryServerInfo{FullMethod: "/emojivoto.v1.VotingService/Vote"}
+	withKey := func(key string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(idempotencyKeyMetadata, key))
+	}
+
+	t.Run("Counts a retried vote once", func(t *testing.T) {
+		emojivotoService := newPollServiceServer(t)
+		idempotency := NewIdempotency(time.Minute, DefaultIdempotencyKeys)
+		vote := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return emojivotoService.Vote(ctx, req.(*pb.VoteRequest))
+		}
+
+		for _, key := range []string{"a", "a", "b", ""} {
+			_, err := idempotency.UnaryServerInterceptor(withKey(key), &pb.VoteRequest{Shortcode: ":joy:"}, voteInfo, vote)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if r, _ := emojivotoService.polls.Results(voting.DefaultPollID); len(r) != 1 || r[0].NumVotes != 3 {
+			t.Fatalf("Expected [3] votes for keys [a], [b] and none, got [%v]", r)
+		}
+	})
+
+	t.Run("Waits for the original call and replays its response", func(t *testing.T) {
+		idempotency := NewIdempotency(time.Minute, DefaultIdempotencyKeys)
+		release := make(chan struct{})
+		calls := 0
+		handler := func(_ context.Context, _ interface{}) (interface{}, error) {
+			calls++
+			<-release
+			return &pb.VoteResponse{}, nil
+		}
+
+		var wg sync.WaitGroup
+		responses := make([]interface{}, 2)
+		for i := range responses {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i], _ = idempotency.UnaryServerInterceptor(withKey("a"), &pb.VoteRequest{Shortcode: ":joy:"}, voteInfo, handler)
+			}(i)
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if calls != 1 || responses[0] != responses[1] {
+			t.Fatalf("Expected one call with its response replayed, got [%d] calls and [%v]", calls, responses)
+		}
+	})
+
+	t.Run("Runs a call again after it failed", func(t *testing.T) {
+		idempotency := NewIdempotency(time.Minute, DefaultIdempotencyKeys)
+		calls := 0
+		handler := func(_ context.Context, _ interface{}) (interface{}, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("ERROR")
+			}
+			return &pb.VoteResponse{}, nil
+		}
+
+		if _, err := idempotency.UnaryServerInterceptor(withKey("a"), &pb.VoteRequest{}, voteInfo, handler); err == nil {
+			t.Fatal("Expected the first call to fail")
+		}
+		if _, err := idempotency.UnaryServerInterceptor(withKey("a"), &pb.VoteRequest{}, voteInfo, handler); err != nil || calls != 2 {
+			t.Fatalf("Expected the retry to run, got [%d] calls and [%v]", calls, err)
+		}
+	})
+
+	t.Run("Rejects a key reused for a different request", func(t *testing.T) {
+		idempotency := NewIdempotency(time.Minute, DefaultIdempotencyKeys)
+		handler := func(_ context.Context, _ interface{}) (interface{}, error) {
+			return &pb.VoteResponse{}, nil
+		}
+
+		idempotency.UnaryServerInterceptor(withKey("a"), &pb.VoteRequest{Shortcode: ":joy:"}, voteInfo, handler)
+		_, err := idempotency.UnaryServerInterceptor(withKey("a"), &pb.VoteRequest{Shortcode: ":ghost:"}, voteInfo, handler)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("Expected a reused key to be an invalid argument, got [%v]", err)
+		}
+		retractInfo := &grpc.UnaryServerInfo{FullMethod: "/emojivoto.v1.VotingService/Retract"}
+		if _, err := idempotency.UnaryServerInterceptor(withKey("a"), &pb.RetractRequest{}, retractInfo, handler); err != nil {
+			t.Fatalf("Expected keys to be kept per method, got [%v]", err)
+		}
+	})
+
+	t.Run("Forgets keys after the window and beyond the limit", func(t *testing.T) {
+		idempotency := NewIdempotency(time.Minute, 2)
+		now := time.Now()
+		idempotency.now = func() time.Time { return now }
+		calls := 0
+		handler := func(_ context.Context, _ interface{}) (interface{}, error) {
+			calls++
+			return &pb.VoteResponse{}, nil
+		}
+		call := func(key string) {
+			idempotency.UnaryServerInterceptor(withKey(key), &pb.VoteRequest{}, voteInfo, handler)
+		}
+
+		call("a")
+		call("b")
+		call("c")
+		call("a")
+		if calls != 4 {
+			t.Fatalf("Expected [a] to be forgotten to keep [2] keys, got [%d] calls", calls)
+		}
+
+		now = now.Add(time.Minute + time.Second)
+		call("c")
+		if calls != 5 || len(idempotency.calls) != 1 {
+			t.Fatalf("Expected every key to expire, got [%d] calls and [%d] keys", calls, len(idempotency.calls))
+		}
+	})
+}
@@ -0,0 +1,440 @@
+package api
+
+import (
+	"context"
+
+	pb "github.com/buoyantio/emojivoto/emojivoto-voting-svc/gen/proto"
+)
+
+// LegacyVoteRPCs names the per-emoji RPCs below.
+var LegacyVoteRPCs = []string{
+	"VoteDoughnut", "VotePoop", "VoteJoy", "VoteSunglasses", "VoteRelaxed",
+	"VoteStuckOutTongueWinkingEye", "VoteMoneyMouthFace", "VoteFlushed",
+	"VoteMask", "VoteNerdFace", "VoteGhost", "VoteSkullAndCrossbones",
+	"VoteHeartEyesCat", "VoteHearNoEvil", "VoteSeeNoEvil", "VoteSpeakNoEvil",
+	"VoteBoy", "VoteGirl", "VoteMan", "VoteWoman", "VoteOlderMan",
+	"VotePoliceman", "VoteGuardsman", "VoteConstructionWorkerMan",
+	"VotePrince", "VotePrincess", "VoteManInTuxedo", "VoteBrideWithVeil",
+	"VoteMrsClaus", "VoteSanta", "VoteTurkey", "VoteRabbit",
+	"VoteNoGoodWoman", "VoteOkWoman", "VoteRaisingHandWoman", "VoteBowingMan",
+	"VoteManFacepalming", "VoteWomanShrugging", "VoteMassageWoman",
+	"VoteWalkingMan", "VoteRunningMan", "VoteDancer", "VoteManDancing",
+	"VoteDancingWomen", "VoteRainbow", "VoteSkier", "VoteGolfingMan",
+	"VoteSurfingMan", "VoteBasketballMan", "VoteBikingMan", "VotePointUp2",
+	"VoteVulcanSalute", "VoteMetal", "VoteCallMeHand", "VoteThumbsup",
+	"VoteWave", "VoteClap", "VoteRaisedHands", "VotePray", "VoteDog",
+	"VoteCat2", "VotePig", "VoteHatchingChick", "VoteSnail", "VoteBacon",
+	"VotePizza", "VoteTaco", "VoteBurrito", "VoteRamen", "VoteChampagne",
+	"VoteTropicalDrink", "VoteBeer", "VoteTumblerGlass", "VoteWorldMap",
+	"VoteBeachUmbrella", "VoteMountainSnow", "VoteCamping",
+	"VoteSteamLocomotive", "VoteFlightDeparture", "VoteRocket", "VoteStar2",
+	"VoteSunBehindSmallCloud", "VoteCloudWithRain", "VoteFire",
+	"VoteJackOLantern", "VoteBalloon", "VoteTada", "VoteTrophy", "VoteIphone",
+	"VotePager", "VoteFax", "VoteBulb", "VoteMoneyWithWings",
+	"VoteCrystalBall", "VoteUnderage", "VoteInterrobang", "Vote100",
+	"VoteCheckeredFlag", "VoteCrossedSwords", "VoteFloppyDisk",
+}
+
+// The per-emoji RPCs below predate Vote and are kept so existing clients and
+// service profiles keep working. They vote in the default poll, with the same
+// checks as Vote.
+
+func (pS *PollServiceServer) VoteDoughnut(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":doughnut:")
+}
+
+func (pS *PollServiceServer) VotePoop(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":poop:")
+}
+
+func (pS *PollServiceServer) VoteJoy(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":joy:")
+}
+
+func (pS *PollServiceServer) VoteSunglasses(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":sunglasses:")
+}
+
+func (pS *PollServiceServer) VoteRelaxed(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":relaxed:")
+}
+
+func (pS *PollServiceServer) VoteStuckOutTongueWinkingEye(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":stuck_out_tongue_winking_eye:")
+}
+
+func (pS *PollServiceServer) VoteMoneyMouthFace(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":money_mouth_face:")
+}
+
+func (pS *PollServiceServer) VoteFlushed(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":flushed:")
+}
+
+func (pS *PollServiceServer) VoteMask(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":mask:")
+}
+
+func (pS *PollServiceServer) VoteNerdFace(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":nerd_face:")
+}
+
+func (pS *PollServiceServer) VoteGhost(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":ghost:")
+}
+
+func (pS *PollServiceServer) VoteSkullAndCrossbones(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":skull_and_crossbones:")
+}
+
+func (pS *PollServiceServer) VoteHeartEyesCat(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":heart_eyes_cat:")
+}
+
+func (pS *PollServiceServer) VoteHearNoEvil(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":hear_no_evil:")
+}
+
+func (pS *PollServiceServer) VoteSeeNoEvil(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":see_no_evil:")
+}
+
+func (pS *PollServiceServer) VoteSpeakNoEvil(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":speak_no_evil:")
+}
+
+func (pS *PollServiceServer) VoteBoy(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":boy:")
+}
+
+func (pS *PollServiceServer) VoteGirl(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":girl:")
+}
+
+func (pS *PollServiceServer) VoteMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":man:")
+}
+
+func (pS *PollServiceServer) VoteWoman(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":woman:")
+}
+
+func (pS *PollServiceServer) VoteOlderMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":older_man:")
+}
+
+func (pS *PollServiceServer) VotePoliceman(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":policeman:")
+}
+
+func (pS *PollServiceServer) VoteGuardsman(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":guardsman:")
+}
+
+func (pS *PollServiceServer) VoteConstructionWorkerMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":construction_worker_man:")
+}
+
+func (pS *PollServiceServer) VotePrince(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":prince:")
+}
+
+func (pS *PollServiceServer) VotePrincess(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":princess:")
+}
+
+func (pS *PollServiceServer) VoteManInTuxedo(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":man_in_tuxedo:")
+}
+
+func (pS *PollServiceServer) VoteBrideWithVeil(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":bride_with_veil:")
+}
+
+func (pS *PollServiceServer) VoteMrsClaus(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":mrs_claus:")
+}
+
+func (pS *PollServiceServer) VoteSanta(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":santa:")
+}
+
+func (pS *PollServiceServer) VoteTurkey(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":turkey:")
+}
+
+func (pS *PollServiceServer) VoteRabbit(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":rabbit:")
+}
+
+func (pS *PollServiceServer) VoteNoGoodWoman(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":no_good_woman:")
+}
+
+func (pS *PollServiceServer) VoteOkWoman(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":ok_woman:")
+}
+
+func (pS *PollServiceServer) VoteRaisingHandWoman(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":raising_hand_woman:")
+}
+
+func (pS *PollServiceServer) VoteBowingMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":bowing_man:")
+}
+
+func (pS *PollServiceServer) VoteManFacepalming(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":man_facepalming:")
+}
+
+func (pS *PollServiceServer) VoteWomanShrugging(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":woman_shrugging:")
+}
+
+func (pS *PollServiceServer) VoteMassageWoman(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":massage_woman:")
+}
+
+func (pS *PollServiceServer) VoteWalkingMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":walking_man:")
+}
+
+func (pS *PollServiceServer) VoteRunningMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":running_man:")
+}
+
+func (pS *PollServiceServer) VoteDancer(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":dancer:")
+}
+
+func (pS *PollServiceServer) VoteManDancing(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":man_dancing:")
+}
+
+func (pS *PollServiceServer) VoteDancingWomen(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":dancing_women:")
+}
+
+func (pS *PollServiceServer) VoteRainbow(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":rainbow:")
+}
+
+func (pS *PollServiceServer) VoteSkier(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":skier:")
+}
+
+func (pS *PollServiceServer) VoteGolfingMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":golfing_man:")
+}
+
+func (pS *PollServiceServer) VoteSurfingMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":surfing_man:")
+}
+
+func (pS *PollServiceServer) VoteBasketballMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":basketball_man:")
+}
+
+func (pS *PollServiceServer) VoteBikingMan(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":biking_man:")
+}
+
+func (pS *PollServiceServer) VotePointUp2(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":point_up_2:")
+}
+
+func (pS *PollServiceServer) VoteVulcanSalute(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":vulcan_salute:")
+}
+
+func (pS *PollServiceServer) VoteMetal(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":metal:")
+}
+
+func (pS *PollServiceServer) VoteCallMeHand(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":call_me_hand:")
+}
+
+func (pS *PollServiceServer) VoteThumbsup(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":thumbsup:")
+}
+
+func (pS *PollServiceServer) VoteWave(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":wave:")
+}
+
+func (pS *PollServiceServer) VoteClap(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":clap:")
+}
+
+func (pS *PollServiceServer) VoteRaisedHands(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":raised_hands:")
+}
+
+func (pS *PollServiceServer) VotePray(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":pray:")
+}
+
+func (pS *PollServiceServer) VoteDog(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":dog:")
+}
+
+func (pS *PollServiceServer) VoteCat2(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":cat2:")
+}
+
+func (pS *PollServiceServer) VotePig(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":pig:")
+}
+
+func (pS *PollServiceServer) VoteHatchingChick(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":hatching_chick:")
+}
+
+func (pS *PollServiceServer) VoteSnail(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":snail:")
+}
+
+func (pS *PollServiceServer) VoteBacon(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":bacon:")
+}
+
+func (pS *PollServiceServer) VotePizza(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":pizza:")
+}
+
+func (pS *PollServiceServer) VoteTaco(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":taco:")
+}
+
+func (pS *PollServiceServer) VoteBurrito(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":burrito:")
+}
+
+func (pS *PollServiceServer) VoteRamen(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":ramen:")
+}
+
+func (pS *PollServiceServer) VoteChampagne(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":champagne:")
+}
+
+func (pS *PollServiceServer) VoteTropicalDrink(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":tropical_drink:")
+}
+
+func (pS *PollServiceServer) VoteBeer(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":beer:")
+}
+
+func (pS *PollServiceServer) VoteTumblerGlass(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":tumbler_glass:")
+}
+
+func (pS *PollServiceServer) VoteWorldMap(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":world_map:")
+}
+
+func (pS *PollServiceServer) VoteBeachUmbrella(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":beach_umbrella:")
+}
+
+func (pS *PollServiceServer) VoteMountainSnow(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":mountain_snow:")
+}
+
+func (pS *PollServiceServer) VoteCamping(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":camping:")
+}
+
+func (pS *PollServiceServer) VoteSteamLocomotive(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":steam_locomotive:")
+}
+
+func (pS *PollServiceServer) VoteFlightDeparture(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":flight_departure:")
+}
+
+func (pS *PollServiceServer) VoteRocket(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":rocket:")
+}
+
+func (pS *PollServiceServer) VoteStar2(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":star2:")
+}
+
+func (pS *PollServiceServer) VoteSunBehindSmallCloud(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":sun_behind_small_cloud:")
+}
+
+func (pS *PollServiceServer) VoteCloudWithRain(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":cloud_with_rain:")
+}
+
+func (pS *PollServiceServer) VoteFire(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":fire:")
+}
+
+func (pS *PollServiceServer) VoteJackOLantern(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":jack_o_lantern:")
+}
+
+func (pS *PollServiceServer) VoteBalloon(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":balloon:")
+}
+
+func (pS *PollServiceServer) VoteTada(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":tada:")
+}
+
+func (pS *PollServiceServer) VoteTrophy(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":trophy:")
+}
+
+func (pS *PollServiceServer) VoteIphone(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":iphone:")
+}
+
+func (pS *PollServiceServer) VotePager(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":pager:")
+}
+
+func (pS *PollServiceServer) VoteFax(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":fax:")
+}
+
+func (pS *PollServiceServer) VoteBulb(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":bulb:")
+}
+
+func (pS *PollServiceServer) VoteMoneyWithWings(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":money_with_wings:")
+}
+
+func (pS *PollServiceServer) VoteCrystalBall(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":crystal_ball:")
+}
+
+func (pS *PollServiceServer) VoteUnderage(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":underage:")
+}
+
+func (pS *PollServiceServer) VoteInterrobang(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":interrobang:")
+}
+
+func (pS *PollServiceServer) Vote100(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":100:")
+}
+
+func (pS *PollServiceServer) VoteCheckeredFlag(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":checkered_flag:")
+}
+
+func (pS *PollServiceServer) VoteCrossedSwords(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":crossed_swords:")
+}
+
+func (pS *PollServiceServer) VoteFloppyDisk(ctx context.Context, _ *pb.VoteRequest) (*pb.VoteResponse, error) {
+	return pS.vote(ctx, ":floppy_disk:")
+}
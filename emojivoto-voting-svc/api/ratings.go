@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+
+	pb "github.com/buoyantio/emojivoto/emojivoto-voting-svc/gen/proto"
+	"github.com/buoyantio/emojivoto/emojivoto-voting-svc/voting"
+)
+
+func (pS *PollServiceServer) NextPair(_ context.Context, _ *pb.NextPairRequest) (*pb.NextPairResponse, error) {
+	first, second, err := pS.ratings.Pair()
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.NextPairResponse{First: first, Second: second}, nil
+}
+
+func (pS *PollServiceServer) ComparePair(ctx context.Context, req *pb.ComparePairRequest) (*pb.ComparePairResponse, error) {
+	if err := pS.misbehave(ctx, req.Winner); err != nil {
+		return nil, err
+	}
+
+	winner, loser, err := pS.ratings.Compare(req.Winner, req.Loser)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.ComparePairResponse{Winner: toPbRating(winner), Loser: toPbRating(loser)}, nil
+}
+
+func (pS *PollServiceServer) Ratings(_ context.Context, _ *pb.RatingsRequest) (*pb.RatingsResponse, error) {
+	leaderboard := pS.ratings.Leaderboard()
+	response := &pb.RatingsResponse{Ratings: make([]*pb.Rating, 0, len(leaderboard))}
+	for _, r := range leaderboard {
+		response.Ratings = append(response.Ratings, toPbRating(r))
+	}
+	return response, nil
+}
+
+func toPbRating(r voting.Rating) *pb.Rating {
+	return &pb.Rating{
+		Shortcode:   r.Shortcode,
+		Rating:      r.Rating,
+		Deviation:   r.Deviation,
+		Comparisons: int32(r.Comparisons),
+	}
+}
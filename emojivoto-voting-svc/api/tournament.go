@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+
+	pb "github.com/buoyantio/emojivoto/emojivoto-voting-svc/gen/proto"
+	"github.com/buoyantio/emojivoto/emojivoto-voting-svc/voting"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (pS *PollServiceServer) CreateTournament(_ context.Context, req *pb.CreateTournamentRequest) (*pb.CreateTournamentResponse, error) {
+	tournament, err := pS.tournaments.Create(req.Id, req.Title, int(req.Size), req.Entrants, req.MatchupDuration.AsDuration())
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.CreateTournamentResponse{Tournament: toPbTournament(tournament)}, nil
+}
+
+func (pS *PollServiceServer) GetTournament(_ context.Context, req *pb.GetTournamentRequest) (*pb.GetTournamentResponse, error) {
+	tournament, err := pS.tournaments.Get(req.Id)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.GetTournamentResponse{Tournament: toPbTournament(tournament)}, nil
+}
+
+func (pS *PollServiceServer) ListTournaments(_ context.Context, _ *pb.ListTournamentsRequest) (*pb.ListTournamentsResponse, error) {
+	tournaments, err := pS.tournaments.List()
+	if err != nil {
+		return nil, pollError(err)
+	}
+	response := &pb.ListTournamentsResponse{Tournaments: make([]*pb.Tournament, 0, len(tournaments))}
+	for _, t := range tournaments {
+		response.Tournaments = append(response.Tournaments, toPbTournament(t))
+	}
+	return response, nil
+}
+
+func (pS *PollServiceServer) AdvanceTournament(_ context.Context, req *pb.AdvanceTournamentRequest) (*pb.AdvanceTournamentResponse, error) {
+	tournament, err := pS.tournaments.Advance(req.Id)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	return &pb.AdvanceTournamentResponse{Tournament: toPbTournament(tournament)}, nil
+}
+
+func (pS *PollServiceServer) CurrentMatchup(_ context.Context, req *pb.CurrentMatchupRequest) (*pb.CurrentMatchupResponse, error) {
+	tournament, err := pS.tournaments.Get(req.TournamentId)
+	if err != nil {
+		return nil, pollError(err)
+	}
+	response := &pb.CurrentMatchupResponse{Champion: tournament.Champion}
+	if m := tournament.Current(); m != nil {
+		response.Matchup = toPbMatchup(m)
+	}
+	return response, nil
+}
+
+func toPbTournament(t *voting.Tournament) *pb.Tournament {
+	rounds := make([]*pb.BracketRound, 0, len(t.Rounds))
+	for _, round := range t.Rounds {
+		matchups := make([]*pb.Matchup, 0, len(round))
+		for i := range round {
+			matchups = append(matchups, toPbMatchup(&round[i]))
+		}
+		rounds = append(rounds, &pb.BracketRound{Matchups: matchups})
+	}
+	return &pb.Tournament{
+		Id:              t.ID,
+		Title:           t.Title,
+		Seeds:           t.Seeds,
+		MatchupDuration: durationpb.New(t.MatchupDuration),
+		Rounds:          rounds,
+		Champion:        t.Champion,
+		CreatedAt:       timestamppb.New(t.CreatedAt),
+	}
+}
+
+func toPbMatchup(m *voting.Matchup) *pb.Matchup {
+	entrants := make([]*pb.MatchupEntrant, 0, len(m.Entrants))
+	for _, e := range m.Entrants {
+		entrants = append(entrants, &pb.MatchupEntrant{Shortcode: e.Shortcode, Seed: int32(e.Seed), Votes: int32(e.Votes)})
+	}
+	return &pb.Matchup{
+		Round:    int32(m.Round),
+		Index:    int32(m.Index),
+		PollId:   m.PollID,
+		State:    string(m.State),
+		Entrants: entrants,
+		Winner:   m.Winner,
+		StartsAt: toPbTime(m.StartsAt),
+		EndsAt:   toPbTime(m.EndsAt),
+	}
+}
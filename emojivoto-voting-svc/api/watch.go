@@ -0,0 +1,225 @@
+package api
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	pb "github.com/buoyantio/emojivoto/emojivoto-voting-svc/gen/proto"
+	"github.com/buoyantio/emojivoto/emojivoto-voting-svc/voting"
+)
+
+// watchInterval is the least time between two updates to a watcher.
+const watchInterval = 250 * time.Millisecond
+
+// resultsHub shares the work of watching a poll between all its watchers:
+// one feed per watched poll computes what changed and fans it out.
+type resultsHub struct {
+	sync.Mutex
+	polls    *voting.Polls
+	interval time.Duration
+	feeds    map[string]*resultsFeed
+}
+
+type resultsFeed struct {
+	watchers map[*resultsWatcher]struct{}
+	// kick wakes the feed up when a watcher joins.
+	kick chan struct{}
+	done chan struct{}
+}
+
+type resultsWatcher struct {
+	updates chan *pb.ResultsUpdate
+	// full is set until the watcher gets every result, when it joins or
+	// after it fell behind.
+	full bool
+}
+
+// watch starts watching a poll. Updates are delivered on the returned
+// channel, which is closed if the feed stops, until stop is called.
+func (h *resultsHub) watch(pollID string) (<-chan *pb.ResultsUpdate, func(), error) {
+	if pollID == "" {
+		pollID = voting.DefaultPollID
+	}
+	w := &resultsWatcher{updates: make(chan *pb.ResultsUpdate, 1), full: true}
+
+	h.Lock()
+	defer h.Unlock()
+
+	feed, ok := h.feeds[pollID]
+	if !ok {
+		changed, stop, err := h.polls.Watch(pollID)
+		if err != nil {
+			return nil, nil, err
+		}
+		feed = &resultsFeed{
+			watchers: make(map[*resultsWatcher]struct{}),
+			kick:     make(chan struct{}, 1),
+			done:     make(chan struct{}),
+		}
+		h.feeds[pollID] = feed
+		go h.run(pollID, feed, changed, stop)
+	}
+	feed.watchers[w] = struct{}{}
+	select {
+	case feed.kick <- struct{}{}:
+	default:
+	}
+
+	return w.updates, func() { h.unwatch(pollID, feed, w) }, nil
+}
+
+func (h *resultsHub) unwatch(pollID string, feed *resultsFeed, w *resultsWatcher) {
+	h.Lock()
+	defer h.Unlock()
+
+	if _, ok := feed.watchers[w]; !ok {
+		return
+	}
+	delete(feed.watchers, w)
+	if len(feed.watchers) == 0 {
+		delete(h.feeds, pollID)
+		close(feed.done)
+	}
+}
+
+// run computes the results of a poll whenever they change or a watcher
+// joins, at most once an interval, and sends each watcher what changed, or
+// every result when the leaderboard was reordered.
+func (h *resultsHub) run(pollID string, feed *resultsFeed, changed <-chan struct{}, stop func()) {
+	defer stop()
+
+	var lastAll []*pb.VotingResult
+	last := make(map[string]*pb.VotingResult)
+	for {
+		select {
+		case <-changed:
+		case <-feed.kick:
+		case <-feed.done:
+			return
+		}
+
+		results, err := h.polls.Results(pollID)
+		if err != nil {
+			log.Printf("Failed to get results of poll [%s] for watchers: %v", pollID, err)
+			h.closeFeed(pollID, feed)
+			return
+		}
+
+		all := make([]*pb.VotingResult, 0, len(results))
+		current := make(map[string]*pb.VotingResult, len(results))
+		for _, r := range results {
+			result := &pb.VotingResult{
+				Shortcode:     r.Shortcode,
+				Votes:         int32(r.NumVotes),
+				WeightedVotes: r.Weighted,
+				Rank:          int32(r.Rank),
+				DenseRank:     int32(r.DenseRank),
+			}
+			all = append(all, result)
+			current[r.Shortcode] = result
+		}
+		delta := make([]*pb.VotingResult, 0)
+		for _, r := range all {
+			if previous, ok := last[r.Shortcode]; !ok || previous.Votes != r.Votes || previous.WeightedVotes != r.WeightedVotes || previous.Rank != r.Rank || previous.DenseRank != r.DenseRank {
+				delta = append(delta, r)
+			}
+		}
+		for shortcode := range last {
+			if _, ok := current[shortcode]; !ok {
+				delta = append(delta, &pb.VotingResult{Shortcode: shortcode})
+			}
+		}
+		reordered := reordered(lastAll, last, all)
+		lastAll, last = all, current
+
+		h.Lock()
+		for w := range feed.watchers {
+			w.deliver(all, delta, reordered)
+		}
+		h.Unlock()
+
+		select {
+		case <-time.After(h.interval):
+		case <-feed.done:
+			return
+		}
+	}
+}
+
+// closeFeed stops a feed that can't go on, closing its watchers' channels.
+func (h *resultsHub) closeFeed(pollID string, feed *resultsFeed) {
+	h.Lock()
+	defer h.Unlock()
+
+	for w := range feed.watchers {
+		close(w.updates)
+	}
+	feed.watchers = make(map[*resultsWatcher]struct{})
+	if h.feeds[pollID] == feed {
+		delete(h.feeds, pollID)
+	}
+}
+
+// reordered reports whether the results in all are in a different order
+// than those in lastAll, other than for newcomers that come after every
+// result the watchers know of. Watchers keep the order of the results they
+// know, so they need every result to learn of another one.
+func reordered(lastAll []*pb.VotingResult, last map[string]*pb.VotingResult, all []*pb.VotingResult) bool {
+	current := make(map[string]bool, len(all))
+	for _, r := range all {
+		current[r.Shortcode] = true
+	}
+
+	i, newcomers := 0, false
+	for _, r := range all {
+		if _, ok := last[r.Shortcode]; !ok {
+			newcomers = true
+			continue
+		}
+		if newcomers {
+			return true
+		}
+		for i < len(lastAll) && !current[lastAll[i].Shortcode] {
+			i++
+		}
+		if i == len(lastAll) || lastAll[i].Shortcode != r.Shortcode {
+			return true
+		}
+		i++
+	}
+	return false
+}
+
+// deliver queues the next update for a watcher without blocking the feed. A
+// watcher that hasn't taken its last update yet fell behind, so it gets every
+// result instead, as it does when the results were reordered. Callers must
+// hold the hub's lock.
+func (w *resultsWatcher) deliver(all, delta []*pb.VotingResult, reordered bool) {
+	select {
+	case <-w.updates:
+		w.full = true
+	default:
+	}
+	if reordered {
+		w.full = true
+	}
+
+	update := &pb.ResultsUpdate{Full: w.full, Results: all}
+	if !w.full {
+		if len(delta) == 0 {
+			return
+		}
+		update.Results = delta
+	}
+	w.updates <- update
+	w.full = false
+}
+
+func newResultsHub(polls *voting.Polls, interval time.Duration) *resultsHub {
+	return &resultsHub{
+		polls:    polls,
+		interval: interval,
+		feeds:    make(map[string]*resultsFeed),
+	}
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/buoyantio/emojivoto/emojivoto-emoji-svc/emoji"
 	"github.com/buoyantio/emojivoto/emojivoto-voting-svc/api"
+	pb "github.com/buoyantio/emojivoto/emojivoto-voting-svc/gen/proto"
 	"github.com/buoyantio/emojivoto/emojivoto-voting-svc/voting"
+	"github.com/buoyantio/emojivoto/fault"
+	"github.com/buoyantio/emojivoto/fault/grpcfault"
 
 	"contrib.go.opencensus.io/exporter/ocagent"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
@@ -20,6 +27,8 @@ import (
 	"go.opencensus.io/plugin/ocgrpc"
 	"go.opencensus.io/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "modernc.org/sqlite"
 )
 
 var (
@@ -30,6 +39,33 @@ var (
 	failureRateFloat           = float64(0.0)
 	artificialDelayVar         = os.Getenv("ARTIFICIAL_DELAY")
 	artificialDelayDuration, _ = time.ParseDuration("0ms")
+	pollBackend                = os.Getenv("POLL_BACKEND")
+	pollDataDir                = os.Getenv("POLL_DATA_DIR")
+	pollSQLDriver              = os.Getenv("POLL_SQL_DRIVER")
+	pollSQLDSN                 = os.Getenv("POLL_SQL_DSN")
+	historyMinutesVar          = os.Getenv("VOTE_HISTORY_MINUTES")
+	historyMinutes             = voting.DefaultHistoryMinutes
+	historyRetentionVar        = os.Getenv("VOTE_HISTORY_RETENTION")
+	historyRetention           = voting.DefaultHistoryRetention
+	weightPolicyPath           = os.Getenv("VOTE_WEIGHT_POLICY")
+	idempotencyWindowVar       = os.Getenv("IDEMPOTENCY_WINDOW")
+	idempotencyWindow          = api.DefaultIdempotencyWindow
+	tiebreakVar                = os.Getenv("LEADERBOARD_TIEBREAK")
+	trendingHalfLifeVar        = os.Getenv("TRENDING_HALF_LIFE")
+	trendingHalfLife           = voting.DefaultTrendingHalfLife
+	emojiCatalogPath           = os.Getenv("EMOJI_CATALOG")
+	emojisvcHost               = os.Getenv("EMOJISVC_HOST")
+	faultRulesPath             = os.Getenv("FAULT_RULES")
+	faultMaxDropVar            = os.Getenv("FAULT_MAX_DROP")
+	adminToken                 = os.Getenv("ADMIN_TOKEN")
+	faultMaxDrop               = grpcfault.DefaultMaxDrop
+)
+
+const (
+	// ballotAttempts is how many times the ballot is asked of the emoji
+	// service, which may still be starting, before giving up.
+	ballotAttempts = 30
+	ballotBackoff  = 2 * time.Second
 )
 
 func main() {
@@ -48,7 +84,55 @@ func main() {
 	}
 	trace.RegisterExporter(oce)
 
-	poll := voting.NewPoll()
+	store, err := newStore(pollBackend, pollDataDir)
+	if err != nil {
+		log.Fatalf("Failed to create [%s] poll store: %v", pollBackend, err)
+	}
+
+	ballot, err := loadBallot()
+	if err != nil {
+		log.Fatalf("Failed to load the ballot: %v", err)
+	}
+	polls, err := voting.NewPolls(store, ballot)
+	if err != nil {
+		log.Fatalf("Failed to load polls: %v", err)
+	}
+	setDurationOrDefault("VOTE_HISTORY_MINUTES", historyMinutesVar, &historyMinutes)
+	setDurationOrDefault("VOTE_HISTORY_RETENTION", historyRetentionVar, &historyRetention)
+	polls.SetHistoryRetention(historyMinutes, historyRetention)
+	log.Printf("Keeping vote history by the minute for [%v] and by the hour for [%v]", historyMinutes, historyRetention)
+	if weightPolicyPath != "" {
+		policy, err := voting.LoadWeightPolicy(weightPolicyPath)
+		if err != nil {
+			log.Fatalf("Failed to load VOTE_WEIGHT_POLICY: %v", err)
+		}
+		polls.SetWeightPolicy(policy)
+		log.Printf("Weighing votes with [%d] rules from VOTE_WEIGHT_POLICY=[%s]", len(policy.Rules), weightPolicyPath)
+	}
+	tiebreak, err := voting.ParseTiebreak(tiebreakVar)
+	if err != nil {
+		log.Fatalf("Invalid LEADERBOARD_TIEBREAK: %v", err)
+	}
+	polls.SetTiebreak(tiebreak)
+	log.Printf("Breaking ties on leaderboards by [%s]", tiebreak)
+	tournaments, err := voting.NewTournaments(polls)
+	if err != nil {
+		log.Fatalf("Failed to load tournaments: %v", err)
+	}
+	setDurationOrDefault("TRENDING_HALF_LIFE", trendingHalfLifeVar, &trendingHalfLife)
+	if trendingHalfLife <= 0 {
+		log.Printf("Invalid value for TRENDING_HALF_LIFE %v. Using %v instead", trendingHalfLife, voting.DefaultTrendingHalfLife)
+		trendingHalfLife = voting.DefaultTrendingHalfLife
+	}
+	polls.SetTrendingHalfLife(trendingHalfLife)
+	log.Printf("Decaying votes on trending leaderboards with a half-life of [%v]", trendingHalfLife)
+	rules, err := loadFaultRules()
+	if err != nil {
+		log.Fatalf("Failed to load FAULT_RULES: %v", err)
+	}
+	faults := grpcfault.NewInjector(rules)
+	setDurationOrDefault("FAULT_MAX_DROP", faultMaxDropVar, &faultMaxDrop)
+	faults.SetMaxDrop(faultMaxDrop)
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", grpcPort))
 	if err != nil {
@@ -70,20 +154,22 @@ func main() {
 	// Start grpc server
 	go func() {
 		grpc_prometheus.EnableHandlingTimeHistogram()
+		setDurationOrDefault("IDEMPOTENCY_WINDOW", idempotencyWindowVar, &idempotencyWindow)
+		idempotency := api.NewIdempotency(idempotencyWindow, api.DefaultIdempotencyKeys)
 		grpcServer := grpc.NewServer(
 			grpc.StatsHandler(&ocgrpc.ServerHandler{}),
-			grpc.StreamInterceptor(grpc_prometheus.StreamServerInterceptor),
-			grpc.UnaryInterceptor(grpc_prometheus.UnaryServerInterceptor),
+			grpc.ChainStreamInterceptor(grpc_prometheus.StreamServerInterceptor, faults.StreamServerInterceptor),
+			grpc.ChainUnaryInterceptor(grpc_prometheus.UnaryServerInterceptor, faults.UnaryServerInterceptor, idempotency.UnaryServerInterceptor),
 		)
 
-		setFailureRateOrDefault(failureRateVar, &failureRateFloat)
-
-		setArtificialDelayOrDefault(artificialDelayVar, &artificialDelayDuration)
-
-		api.NewGrpServer(grpcServer, poll, float32(failureRateFloat), artificialDelayDuration)
+		api.NewGrpServer(grpcServer, polls, tournaments, faults, adminToken)
 		grpc_prometheus.Register(grpcServer)
 		log.Printf("Starting grpc server on GRPC_PORT=[%s]", grpcPort)
-		log.Printf("Using failureRate [%f] and artificialDelayDuration [%v]", failureRateFloat, artificialDelayDuration)
+		log.Printf("Injecting faults with [%d] rules", len(rules))
+		log.Printf("Replaying calls retried with an idempotency key for [%v]", idempotencyWindow)
+		if adminToken == "" {
+			log.Printf("Turning the admin calls off, as ADMIN_TOKEN isn't set")
+		}
 		err := grpcServer.Serve(lis)
 		errs <- err
 	}()
@@ -99,6 +185,149 @@ func main() {
 	log.Fatal(<-errs)
 }
 
+func newStore(backend, dataDir string) (voting.Store, error) {
+	switch backend {
+	case "", "memory":
+		return voting.NewMemoryStore(), nil
+	case "file":
+		if dataDir == "" {
+			return nil, fmt.Errorf("POLL_DATA_DIR must be set when POLL_BACKEND is [%s]", backend)
+		}
+		log.Printf("Storing votes in POLL_DATA_DIR=[%s]", dataDir)
+		return voting.NewFileStore(dataDir, voting.DefaultSnapshotEvery), nil
+	case "sql":
+		driver, dsn := pollSQLDriver, pollSQLDSN
+		if driver == "" {
+			driver = "sqlite"
+		}
+		if dsn == "" {
+			if dataDir == "" {
+				return nil, fmt.Errorf("POLL_SQL_DSN or POLL_DATA_DIR must be set when POLL_BACKEND is [%s]", backend)
+			}
+			if err := os.MkdirAll(dataDir, 0755); err != nil {
+				return nil, err
+			}
+			dsn = filepath.Join(dataDir, "votes.db")
+		}
+		log.Printf("Storing votes with POLL_SQL_DRIVER=[%s] in [%s]", driver, dsn)
+		return voting.NewSQLStore(driver, dsn)
+	default:
+		return nil, fmt.Errorf("unknown POLL_BACKEND [%s]", backend)
+	}
+}
+
+// loadBallot loads the shortcodes votes are checked against: from the
+// catalog file at EMOJI_CATALOG, else from the emoji service at
+// EMOJISVC_HOST, else from the catalog compiled into the service.
+func loadBallot() ([]string, error) {
+	var list []*emoji.Emoji
+	switch {
+	case emojiCatalogPath != "":
+		allEmoji, err := emoji.LoadAllEmoji(emojiCatalogPath)
+		if err != nil {
+			return nil, err
+		}
+		list = allEmoji.List()
+		log.Printf("Loaded [%d] shortcodes from EMOJI_CATALOG=[%s]", len(list), emojiCatalogPath)
+	case emojisvcHost != "":
+		var err error
+		list, err = listEmoji(emojisvcHost)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded [%d] shortcodes from the emoji service at EMOJISVC_HOST=[%s]", len(list), emojisvcHost)
+	default:
+		list = emoji.NewAllEmoji().List()
+		log.Printf("Loaded [%d] shortcodes from the built-in catalog", len(list))
+	}
+
+	ballot := make([]string, 0, len(list))
+	for _, e := range list {
+		ballot = append(ballot, e.Shortcode)
+	}
+	if len(ballot) > voting.MaxEmojiLabels {
+		log.Printf("The ballot has [%d] shortcodes, more than the [%d] counted apart in emojivoto_votes_total", len(ballot), voting.MaxEmojiLabels)
+	}
+	return ballot, nil
+}
+
+// listEmoji asks the emoji service for its catalog, retrying while it
+// starts up.
+func listEmoji(host string) ([]*emoji.Emoji, error) {
+	log.Printf("Connecting to [%s]", host)
+	conn, err := grpc.Dial(host, grpc.WithInsecure(), grpc.WithStatsHandler(new(ocgrpc.ClientHandler)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	client := pb.NewEmojiServiceClient(conn)
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		response, err := client.ListAll(ctx, &pb.ListAllEmojiRequest{})
+		cancel()
+		if err == nil && len(response.GetList()) > 0 {
+			list := make([]*emoji.Emoji, 0, len(response.GetList()))
+			for _, e := range response.GetList() {
+				list = append(list, &emoji.Emoji{Unicode: e.GetUnicode(), Shortcode: e.GetShortcode()})
+			}
+			return list, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("the emoji service at [%s] listed no emoji", host)
+		}
+		if attempt == ballotAttempts {
+			return nil, err
+		}
+		log.Printf("Failed to list emoji, retrying in [%v]: %v", ballotBackoff, err)
+		time.Sleep(ballotBackoff)
+	}
+}
+
+// loadFaultRules loads the fault rules from the file at FAULT_RULES. Without
+// one, FAILURE_RATE fails votes for :doughnut: at that rate, and
+// ARTIFICIAL_DELAY delays the calls that vote, as they always have.
+func loadFaultRules() ([]grpcfault.Rule, error) {
+	if faultRulesPath != "" {
+		rules, err := grpcfault.LoadRules(faultRulesPath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded [%d] fault rules from FAULT_RULES=[%s]", len(rules), faultRulesPath)
+		return rules, nil
+	}
+
+	rules := make([]grpcfault.Rule, 0)
+	setFailureRateOrDefault(failureRateVar, &failureRateFloat)
+	if failureRateFloat > 0 {
+		rules = append(rules, grpcfault.Rule{
+			Name:        "failure-rate",
+			Shortcode:   ":doughnut:",
+			Probability: math.Min(failureRateFloat, 1),
+			Code:        codes.Internal,
+			Message:     "failed to vote for :doughnut:",
+		})
+	}
+	setArtificialDelayOrDefault(artificialDelayVar, &artificialDelayDuration)
+	if artificialDelayDuration > 0 {
+		rpcs := []string{"Vote", "VoteBatch", "VoteStream", "VoteRanked", "VoteApproval", "ComparePair"}
+		for _, rpc := range append(rpcs, api.LegacyVoteRPCs...) {
+			rules = append(rules, grpcfault.Rule{
+				Name:        "artificial-delay",
+				RPC:         rpc,
+				Probability: 1,
+				Latency:     &fault.Latency{Distribution: fault.Fixed, Mean: artificialDelayDuration},
+			})
+		}
+	}
+	for i := range rules {
+		if err := rules[i].Check(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
 func setFailureRateOrDefault(failureRateVar string, failureRateFloat *float64) {
 	if failureRateVar != "" {
 		var err error
@@ -122,3 +351,14 @@ func setArtificialDelayOrDefault(artificialDelayVar string, artificialDelayDurat
 		}
 	}
 }
+
+func setDurationOrDefault(name, value string, duration *time.Duration) {
+	if value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("Invalid value for %s %v. Using %v instead", name, value, *duration)
+			return
+		}
+		*duration = parsed
+	}
+}
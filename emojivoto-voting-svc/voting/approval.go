@@ -0,0 +1,11 @@
+package voting
+
+import "sort"
+
+// approvalChoices sorts the choices of an approval ballot, so that the same
+// choices always make the same ballot.
+func approvalChoices(choices []string) []string {
+	sorted := append([]string(nil), choices...)
+	sort.Strings(sorted)
+	return sorted
+}
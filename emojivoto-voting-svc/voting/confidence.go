@@ -0,0 +1,74 @@
+package voting
+
+import "math"
+
+const (
+	// Confidence is the confidence level of the intervals around shares and
+	// of the test of the lead.
+	Confidence = 0.95
+	// confidenceZ is the two-sided standard normal quantile for Confidence.
+	confidenceZ = 1.959963984540054
+)
+
+// WilsonInterval is the Wilson score interval, at the Confidence level,
+// around the share of trials that were successes. Unlike the interval of
+// the normal approximation, it stays within 0 and 1 and holds up for few
+// trials and for shares close to either end. No trials tell nothing, so
+// their interval is 0 to 1.
+func WilsonInterval(successes, trials int) (low, high float64) {
+	if trials <= 0 {
+		return 0, 1
+	}
+	n := float64(trials)
+	p := float64(successes) / n
+	z2 := confidenceZ * confidenceZ
+
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	spread := confidenceZ / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+	return math.Max(0, center-spread), math.Min(1, center+spread)
+}
+
+// share sets the share of ballots of each result, with its Wilson interval.
+func share(results []*Result, ballots int) {
+	for _, r := range results {
+		if ballots > 0 {
+			r.Share = float64(r.NumVotes) / float64(ballots)
+		}
+		r.ShareLow, r.ShareHigh = WilsonInterval(r.NumVotes, ballots)
+	}
+}
+
+// Lead compares the leader of a poll with the runner-up.
+type Lead struct {
+	Leader   string
+	RunnerUp string
+	// Margin is how much more of the ballots the leader has.
+	Margin float64
+	// Significant is set when the lead is unlikely to be down to chance:
+	// when the Wilson interval around the leader's share of the votes for
+	// either of the two lies above a half. Between two choices with about
+	// 100 votes each, that takes a lead of some 30 votes, and with about
+	// 1000 each, some 90.
+	Significant bool
+}
+
+// LeadOf compares the first two of ranked results. The test suits plurality
+// and ranked polls, and is only a rough guide for approval polls, where a
+// ballot can approve of both.
+func LeadOf(results []*Result) Lead {
+	if len(results) == 0 {
+		return Lead{}
+	}
+	leader := results[0]
+	lead := Lead{Leader: leader.Shortcode, Margin: leader.Share}
+	runnerUpVotes := 0
+	if len(results) > 1 {
+		runnerUp := results[1]
+		lead.RunnerUp = runnerUp.Shortcode
+		lead.Margin -= runnerUp.Share
+		runnerUpVotes = runnerUp.NumVotes
+	}
+	low, _ := WilsonInterval(leader.NumVotes, leader.NumVotes+runnerUpVotes)
+	lead.Significant = low > 0.5
+	return lead
+}
@@ -0,0 +1,69 @@
+package voting
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConfidence(t *testing.T) {
+	t.Run("Computes Wilson score intervals", func(t *testing.T) {
+		for _, test := range []struct {
+			successes, trials int
+			low, high         float64
+		}{
+			{5, 10, 0.2366, 0.7634},
+			{0, 10, 0, 0.2775},
+			{10, 10, 0.7225, 1},
+			{60, 100, 0.5020, 0.6906},
+			{0, 0, 0, 1},
+		} {
+			low, high := WilsonInterval(test.successes, test.trials)
+			if math.Abs(low-test.low) > 0.0001 || math.Abs(high-test.high) > 0.0001 {
+				t.Fatalf("Expected [%d] of [%d] to lie in [%v, %v], got [%v, %v]", test.successes, test.trials, test.low, test.high, low, high)
+			}
+		}
+	})
+
+	t.Run("Tells a significant lead from chance", func(t *testing.T) {
+		for _, test := range []struct {
+			leader, runnerUp int
+			significant      bool
+		}{
+			{115, 85, true},
+			{110, 90, false},
+			{1045, 955, true},
+			{1040, 960, false},
+			{5, 0, true},
+			{3, 0, false},
+			{7, 7, false},
+		} {
+			results := []*Result{{Shortcode: ":doughnut:", NumVotes: test.leader}, {Shortcode: ":joy:", NumVotes: test.runnerUp}}
+			share(results, test.leader+test.runnerUp)
+			if lead := LeadOf(results); lead.Significant != test.significant {
+				t.Fatalf("Expected a lead of [%d] to [%d] to be significant [%t], got [%v]", test.leader, test.runnerUp, test.significant, lead)
+			}
+		}
+		if lead := LeadOf(nil); lead.Leader != "" || lead.Significant {
+			t.Fatalf("Expected no lead without results, got [%v]", lead)
+		}
+	})
+
+	t.Run("Shares the ballots of a poll", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		polls.Create("lunch", "Lunch", nil, BallotRules{Mode: BallotApproval}, Schedule{})
+		polls.VoteApproval("lunch", Voter{}, []string{":taco:", ":pizza:"})
+		polls.VoteApproval("lunch", Voter{}, []string{":taco:"})
+
+		results, _ := polls.Results("lunch")
+		if results[0].Shortcode != ":taco:" || results[0].Share != 1 || results[1].Share != 0.5 {
+			t.Fatalf("Expected [:taco:] on every ballot and [:pizza:] on half, got [%v] [%v]", results[0], results[1])
+		}
+		if results[1].ShareLow >= 0.5 || results[1].ShareHigh <= 0.5 {
+			t.Fatalf("Expected the share of [:pizza:] to lie around a half, got [%v]", results[1])
+		}
+		lead := LeadOf(results)
+		if lead.Leader != ":taco:" || lead.RunnerUp != ":pizza:" || lead.Margin != 0.5 || lead.Significant {
+			t.Fatalf("Expected an insignificant lead of [0.5] for [:taco:], got [%v]", lead)
+		}
+	})
+}
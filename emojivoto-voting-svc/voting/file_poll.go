@@ -0,0 +1,487 @@
+package voting
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const (
+	walFileName      = "votes.wal"
+	snapshotFileName = "snapshot.json"
+	pollFileName     = "poll.json"
+
+	// DefaultSnapshotEvery is how many logged votes trigger a new snapshot.
+	DefaultSnapshotEvery = 1000
+)
+
+// Operations logged besides votes.
+const (
+	walRetract      = "retract"
+	walRetractVotes = "retract_votes"
+)
+
+// walEntry is one line of the write-ahead log. Seq increases by one for
+// every entry ever logged, so entries already folded into a snapshot can be
+// skipped on replay. Op is empty for votes, which log their Ballot, while
+// retractions log the Choices they take back.
+type walEntry struct {
+	Seq     uint64         `json:"seq"`
+	Op      string         `json:"op,omitempty"`
+	Ballot  *Ballot        `json:"ballot,omitempty"`
+	Choices []string       `json:"choices,omitempty"`
+	VoterID string         `json:"voter_id,omitempty"`
+	Minute  int64          `json:"minute,omitempty"`
+	Filter  *RetractFilter `json:"filter,omitempty"`
+}
+
+// snapshot holds the whole tally: the current vote of every voter with an
+// ID, the votes cast without one, by minute and ballot, and the change at
+// which each choice reached its count.
+type snapshot struct {
+	Seq     uint64                  `json:"seq"`
+	Voters  map[string]snapshotVote `json:"voters,omitempty"`
+	Minutes []snapshotMinute        `json:"minutes,omitempty"`
+	Changes uint64                  `json:"changes,omitempty"`
+	Since   map[string]uint64       `json:"since,omitempty"`
+}
+
+type snapshotVote struct {
+	Ballot Ballot `json:"ballot"`
+	Minute int64  `json:"minute"`
+}
+
+type snapshotMinute struct {
+	Minute   int64  `json:"minute"`
+	Ballot   Ballot `json:"ballot"`
+	NumVotes int    `json:"votes"`
+}
+
+// filePoll keeps the same in-memory tally as inMemoryPoll, but appends every
+// vote to a write-ahead log and fsyncs it before acknowledging the vote. Every
+// SnapshotEvery votes the tally is written to a snapshot and the log is reset.
+type filePoll struct {
+	*inMemoryPoll
+	dir           string
+	wal           *os.File
+	seq           uint64
+	pending       int
+	snapshotEvery int
+	// broken is set when a failed write couldn't be undone, leaving the log
+	// in a state new entries can't safely follow.
+	broken error
+}
+
+func (p *filePoll) Vote(choice string) error {
+	return p.VoteAs(Voter{}, Ballot{Choices: []string{choice}, Weight: 1})
+}
+
+func (p *filePoll) VoteAs(voter Voter, ballot Ballot) error {
+	if err := ballot.check(); err != nil {
+		return err
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	if cast, ok := p.choices[voter.ID]; ok && cast.ballot.equal(ballot) {
+		return nil
+	}
+	minute := p.minute()
+	if err := p.log(walEntry{Ballot: &ballot, VoterID: voter.ID, Minute: minute}); err != nil {
+		return err
+	}
+	p.record(voter.ID, ballot, minute)
+	p.maybeSnapshot()
+	return nil
+}
+
+func (p *filePoll) Retract(voter Voter, choices []string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if err := p.retractable(voter.ID, choices); err != nil {
+		return err
+	}
+	if err := p.log(walEntry{Op: walRetract, Choices: choices, VoterID: voter.ID}); err != nil {
+		return err
+	}
+	p.retract(voter.ID, choices)
+	p.maybeSnapshot()
+	return nil
+}
+
+func (p *filePoll) RetractVotes(filter RetractFilter) (int, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if err := p.log(walEntry{Op: walRetractVotes, Filter: &filter}); err != nil {
+		return 0, err
+	}
+	retracted := p.retractVotes(filter)
+	log.Printf("Retracted [%d] votes", retracted)
+	p.maybeSnapshot()
+	return retracted, nil
+}
+
+// log appends the next entry to the write-ahead log. Callers must hold the
+// write lock.
+func (p *filePoll) log(entry walEntry) error {
+	if p.broken != nil {
+		return fmt.Errorf("%w: %v", ErrStorage, p.broken)
+	}
+	entry.Seq = p.seq + 1
+	if err := p.append(entry); err != nil {
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	p.seq++
+	p.pending++
+	return nil
+}
+
+func (p *filePoll) maybeSnapshot() {
+	if p.pending < p.snapshotEvery {
+		return
+	}
+	if err := p.snapshot(); err != nil {
+		// The entry is already durable in the log, so a failed snapshot
+		// only means a longer replay on the next start.
+		log.Printf("Failed to snapshot poll in [%s]: %v", p.dir, err)
+	}
+}
+
+// Close releases the write-ahead log.
+func (p *filePoll) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	return p.wal.Close()
+}
+
+func (p *filePoll) append(entry walEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	offset, err := p.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := p.wal.Write(append(line, '\n')); err != nil {
+		return p.undo(offset, fmt.Errorf("writing vote to log: %v", err))
+	}
+	if err := p.wal.Sync(); err != nil {
+		return p.undo(offset, fmt.Errorf("syncing vote log: %v", err))
+	}
+	return nil
+}
+
+// undo drops whatever part of a failed entry made it to the log, so that the
+// next entry doesn't land after it and a replay doesn't count a vote that was
+// never acknowledged. If that fails too, the poll stops taking votes.
+func (p *filePoll) undo(offset int64, cause error) error {
+	err := p.wal.Truncate(offset)
+	if err == nil {
+		_, err = p.wal.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		p.broken = fmt.Errorf("%v, and then failed to cut it from the log: %v", cause, err)
+		log.Printf("Vote log in [%s] is broken, no longer taking votes: %v", p.dir, p.broken)
+		return p.broken
+	}
+	return cause
+}
+
+// snapshot writes the current tally atomically and then truncates the log.
+// Callers must hold the write lock. If we crash between the rename and the
+// truncation, replay skips the log entries the snapshot already covers.
+func (p *filePoll) snapshot() error {
+	snap := snapshot{
+		Seq:     p.seq,
+		Voters:  make(map[string]snapshotVote, len(p.choices)),
+		Minutes: make([]snapshotMinute, 0, len(p.minutes)),
+		Changes: *p.changes,
+		Since:   p.since,
+	}
+	for voterID, cast := range p.choices {
+		snap.Voters[voterID] = snapshotVote{Ballot: cast.ballot, Minute: cast.minute}
+	}
+	for minute, bucket := range p.minutes {
+		for key, numVotes := range bucket {
+			snap.Minutes = append(snap.Minutes, snapshotMinute{Minute: minute, Ballot: p.ballots[key].ballot, NumVotes: numVotes})
+		}
+	}
+	body, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(p.dir, snapshotFileName), body); err != nil {
+		return err
+	}
+	if err := p.wal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := p.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := p.wal.Sync(); err != nil {
+		return err
+	}
+	p.pending = 0
+	return nil
+}
+
+// replay loads the latest snapshot and applies the log entries that came
+// after it. A torn final line, left by a crash mid-write, is cut off; it was
+// never acknowledged to a client.
+func (p *filePoll) replay() error {
+	body, err := ioutil.ReadFile(filepath.Join(p.dir, snapshotFileName))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		var snap snapshot
+		if err := json.Unmarshal(body, &snap); err != nil {
+			return fmt.Errorf("reading snapshot: %v", err)
+		}
+		for voterID, v := range snap.Voters {
+			p.tally(voterID, v.Ballot, v.Minute)
+		}
+		for _, m := range snap.Minutes {
+			p.tallyAnonymous(m.Ballot, m.Minute, m.NumVotes)
+		}
+		if snap.Since != nil {
+			*p.changes, p.since = snap.Changes, snap.Since
+		}
+		p.seq = snap.Seq
+	}
+
+	reader := bufio.NewReader(p.wal)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("Discarding torn entry at offset [%d] of the vote log", offset)
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("corrupt vote log entry at offset [%d]: %v", offset, err)
+		}
+		offset += int64(len(line))
+
+		if entry.Seq <= p.seq {
+			continue
+		}
+		p.apply(entry)
+		p.seq = entry.Seq
+		p.pending++
+	}
+
+	if err := p.wal.Truncate(offset); err != nil {
+		return err
+	}
+	_, err = p.wal.Seek(offset, io.SeekStart)
+	return err
+}
+
+// apply replays a log entry. Retractions are only logged once checked, so
+// they apply the same way again.
+func (p *filePoll) apply(entry walEntry) {
+	switch entry.Op {
+	case walRetract:
+		if p.retractable(entry.VoterID, entry.Choices) == nil {
+			p.retract(entry.VoterID, entry.Choices)
+		}
+	case walRetractVotes:
+		if entry.Filter != nil {
+			p.retractVotes(*entry.Filter)
+		}
+	default:
+		if entry.Ballot != nil {
+			p.tally(entry.VoterID, *entry.Ballot, entry.Minute)
+		}
+	}
+}
+
+func writeFileAtomic(path string, body []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// NewFilePoll opens, or creates, a durable poll stored in dir. Votes already
+// recorded there are replayed before it returns.
+func NewFilePoll(dir string, snapshotEvery int) (Poll, error) {
+	poll, err := newFilePoll(dir, snapshotEvery, BallotPlurality)
+	if err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+func newFilePoll(dir string, snapshotEvery int, mode BallotMode) (*filePoll, error) {
+	if snapshotEvery < 1 {
+		snapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	poll := &filePoll{
+		inMemoryPoll:  newInMemoryPoll(mode),
+		dir:           dir,
+		wal:           wal,
+		snapshotEvery: snapshotEvery,
+	}
+	if err := poll.replay(); err != nil {
+		wal.Close()
+		return nil, fmt.Errorf("replaying poll in [%s]: %v", dir, err)
+	}
+	numVotes := 0
+	for _, n := range poll.votes {
+		numVotes += n
+	}
+	log.Printf("Restored [%d] votes from [%s]", numVotes, dir)
+	return poll, nil
+}
+
+// fileStore keeps the default poll directly in dir, for compatibility with
+// data written before there were multiple polls, and every other poll in
+// dir/polls/<id>. Each poll directory holds its definition in poll.json.
+// Tournaments are kept in dir/tournaments/<id>.json.
+type fileStore struct {
+	dir           string
+	snapshotEvery int
+}
+
+func (s *fileStore) pollDir(id string) string {
+	if id == DefaultPollID {
+		return s.dir
+	}
+	return filepath.Join(s.dir, "polls", id)
+}
+
+func (s *fileStore) Open(info *PollInfo) (Poll, error) {
+	poll, err := newFilePoll(s.pollDir(info.ID), s.snapshotEvery, info.Mode)
+	if err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+func (s *fileStore) SavePoll(info *PollInfo) error {
+	body, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	dir := s.pollDir(info.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, pollFileName), body)
+}
+
+func (s *fileStore) LoadPolls() ([]*PollInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "polls", "*", pollFileName))
+	if err != nil {
+		return nil, err
+	}
+	paths = append([]string{filepath.Join(s.dir, pollFileName)}, paths...)
+
+	infos := make([]*PollInfo, 0, len(paths))
+	for _, path := range paths {
+		body, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		info := &PollInfo{}
+		if err := json.Unmarshal(body, info); err != nil {
+			return nil, fmt.Errorf("reading [%s]: %v", path, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *fileStore) SaveTournament(t *Tournament) error {
+	body, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(s.dir, "tournaments")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, t.ID+".json"), body)
+}
+
+func (s *fileStore) LoadTournaments() ([]*Tournament, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "tournaments", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	tournaments := make([]*Tournament, 0, len(paths))
+	for _, path := range paths {
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		t := &Tournament{}
+		if err := json.Unmarshal(body, t); err != nil {
+			return nil, fmt.Errorf("reading [%s]: %v", path, err)
+		}
+		tournaments = append(tournaments, t)
+	}
+	return tournaments, nil
+}
+
+// NewFileStore keeps every poll in its own directory under dir.
+func NewFileStore(dir string, snapshotEvery int) Store {
+	return &fileStore{dir: dir, snapshotEvery: snapshotEvery}
+}
@@ -0,0 +1,294 @@
+package voting
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func tempPollDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "emojivoto-poll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func votesFor(t *testing.T, poll Poll, choice string) int {
+	results, err := poll.Results()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Shortcode == choice {
+			return r.NumVotes
+		}
+	}
+	return 0
+}
+
+func TestFilePoll(t *testing.T) {
+	t.Run("Restores votes after reopening", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		poll, err := NewFilePoll(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll.Vote(":joy:")
+		poll.Vote(":joy:")
+		poll.Vote(":ghost:")
+		poll.(*filePoll).Close()
+
+		reopened, err := NewFilePoll(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.(*filePoll).Close()
+
+		if votes := votesFor(t, reopened, ":joy:"); votes != 2 {
+			t.Fatalf("Expected [2] votes for :joy: after reopening, got [%d]", votes)
+		}
+		if votes := votesFor(t, reopened, ":ghost:"); votes != 1 {
+			t.Fatalf("Expected [1] vote for :ghost: after reopening, got [%d]", votes)
+		}
+	})
+
+	t.Run("Restores the weight of votes from snapshot and log", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		poll, err := NewFilePoll(dir, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll.VoteAs(Voter{ID: "voter-1"}, Ballot{Choices: []string{":joy:"}, Weight: 2})
+		poll.VoteAs(Voter{}, Ballot{Choices: []string{":joy:"}, Weight: 0.5})
+		poll.VoteAs(Voter{}, Ballot{Choices: []string{":joy:"}, Weight: 0})
+		poll.(*filePoll).Close()
+
+		reopened, err := NewFilePoll(dir, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.(*filePoll).Close()
+
+		results, _ := reopened.Results()
+		if len(results) != 1 || results[0].NumVotes != 3 || results[0].Weighted != 2.5 {
+			t.Fatalf("Expected [3] votes for [:joy:] weighing [2.5] after reopening, got [%v]", results)
+		}
+	})
+
+	t.Run("Restores voters' current choices from snapshot and log", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		poll, err := NewFilePoll(dir, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll.VoteAs(Voter{ID: "voter-1"}, ballotFor(":joy:"))
+		poll.VoteAs(Voter{ID: "voter-2"}, ballotFor(":joy:"))
+		poll.VoteAs(Voter{ID: "voter-1"}, ballotFor(":ghost:"))
+		poll.(*filePoll).Close()
+
+		reopened, err := NewFilePoll(dir, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.(*filePoll).Close()
+
+		reopened.VoteAs(Voter{ID: "voter-2"}, ballotFor(":ghost:"))
+		if votes := votesFor(t, reopened, ":ghost:"); votes != 2 {
+			t.Fatalf("Expected [2] votes for :ghost: after moving votes, got [%d]", votes)
+		}
+		if votes := votesFor(t, reopened, ":joy:"); votes != 0 {
+			t.Fatalf("Expected no votes for :joy: after moving votes, got [%d]", votes)
+		}
+	})
+
+	t.Run("Restores retractions after reopening", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		poll, err := NewFilePoll(dir, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll.Vote(":joy:")
+		poll.Vote(":joy:")
+		poll.VoteAs(Voter{ID: "voter-1"}, ballotFor(":ghost:"))
+		poll.Retract(Voter{}, []string{":joy:"})
+		poll.RetractVotes(RetractFilter{VoterID: "voter-1"})
+		poll.(*filePoll).Close()
+
+		reopened, err := NewFilePoll(dir, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.(*filePoll).Close()
+
+		if votes := votesFor(t, reopened, ":joy:"); votes != 1 {
+			t.Fatalf("Expected [1] vote for :joy: after reopening, got [%d]", votes)
+		}
+		if votes := votesFor(t, reopened, ":ghost:"); votes != 0 {
+			t.Fatalf("Expected no votes for :ghost: after reopening, got [%d]", votes)
+		}
+	})
+
+	t.Run("Rolls up old votes the same way on replay", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		poll, err := NewFilePoll(dir, 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		now := start
+		poll.(*filePoll).now = func() time.Time { return now }
+		for i := 0; i < 3*24*60; i += 13 {
+			now = start.Add(time.Duration(i) * time.Minute)
+			poll.Vote(":joy:")
+			if i%5 == 0 {
+				poll.Vote(":ghost:")
+			}
+			if i%3 == 0 {
+				poll.Retract(Voter{}, []string{":ghost:"})
+			}
+		}
+		poll.(*filePoll).Close()
+
+		reopened, err := NewFilePoll(dir, 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.(*filePoll).Close()
+
+		if want, got := poll.(*filePoll).minutes, reopened.(*filePoll).minutes; !reflect.DeepEqual(want, got) {
+			t.Fatalf("Expected buckets [%v] after reopening, got [%v]", want, got)
+		}
+	})
+
+	t.Run("Combines snapshot and log without double counting", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		poll, err := NewFilePoll(dir, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			poll.Vote(":joy:")
+		}
+		poll.(*filePoll).Close()
+
+		if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+			t.Fatalf("Expected a snapshot to be written: %v", err)
+		}
+
+		reopened, err := NewFilePoll(dir, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.(*filePoll).Close()
+
+		if votes := votesFor(t, reopened, ":joy:"); votes != 5 {
+			t.Fatalf("Expected [5] votes for :joy: after reopening, got [%d]", votes)
+		}
+	})
+
+	t.Run("Skips log entries already in the snapshot", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		snap := `{"seq":2,"minutes":[{"minute":0,"ballot":{"choices":[":joy:"],"weight":1},"votes":2}]}`
+		wal := `{"seq":1,"ballot":{"choices":[":joy:"],"weight":1}}` + "\n" +
+			`{"seq":2,"ballot":{"choices":[":joy:"],"weight":1}}` + "\n" +
+			`{"seq":3,"ballot":{"choices":[":ghost:"],"weight":1}}` + "\n"
+		ioutil.WriteFile(filepath.Join(dir, snapshotFileName), []byte(snap), 0644)
+		ioutil.WriteFile(filepath.Join(dir, walFileName), []byte(wal), 0644)
+
+		poll, err := NewFilePoll(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer poll.(*filePoll).Close()
+
+		if votes := votesFor(t, poll, ":joy:"); votes != 2 {
+			t.Fatalf("Expected [2] votes for :joy:, got [%d]", votes)
+		}
+		if votes := votesFor(t, poll, ":ghost:"); votes != 1 {
+			t.Fatalf("Expected [1] vote for :ghost:, got [%d]", votes)
+		}
+	})
+
+	t.Run("Discards a torn final log entry", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		wal := `{"seq":1,"ballot":{"choices":[":joy:"],"weight":1}}` + "\n" + `{"seq":2,"bal`
+		ioutil.WriteFile(filepath.Join(dir, walFileName), []byte(wal), 0644)
+
+		poll, err := NewFilePoll(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll.Vote(":ghost:")
+		poll.(*filePoll).Close()
+
+		reopened, err := NewFilePoll(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.(*filePoll).Close()
+
+		if votes := votesFor(t, reopened, ":joy:"); votes != 1 {
+			t.Fatalf("Expected [1] vote for :joy:, got [%d]", votes)
+		}
+		if votes := votesFor(t, reopened, ":ghost:"); votes != 1 {
+			t.Fatalf("Expected [1] vote for :ghost:, got [%d]", votes)
+		}
+	})
+
+	t.Run("Stops taking votes when a failed write can't be undone", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		poll, err := NewFilePoll(dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fp := poll.(*filePoll)
+		defer fp.Close()
+
+		// Writes to a read-only log fail, and so does cutting them back out.
+		readOnly, err := os.Open(filepath.Join(dir, walFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fp.wal.Close()
+		fp.wal = readOnly
+		if err := poll.Vote(":joy:"); !errors.Is(err, ErrStorage) {
+			t.Fatalf("Expected a failed write to fail with [%v], got [%v]", ErrStorage, err)
+		}
+
+		writable, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fp.wal.Close()
+		fp.wal = writable
+		if err := poll.Vote(":joy:"); !errors.Is(err, ErrStorage) {
+			t.Fatalf("Expected the poll to keep refusing votes, got [%v]", err)
+		}
+		if votes := votesFor(t, poll, ":joy:"); votes != 0 {
+			t.Fatalf("Expected no votes for :joy:, got [%d]", votes)
+		}
+	})
+}
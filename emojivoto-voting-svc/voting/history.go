@@ -0,0 +1,301 @@
+package voting
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHistoryMinutes is how long per-minute vote counts are kept.
+	DefaultHistoryMinutes = 2 * time.Hour
+	// DefaultHistoryRetention is how long per-hour vote counts are kept.
+	DefaultHistoryRetention = 7 * 24 * time.Hour
+
+	// maxHistoryPoints bounds the size of a single series.
+	maxHistoryPoints = 1440
+)
+
+// Series is the number of votes cast for Shortcode in each step of a
+// HistoryRange.
+type Series struct {
+	Shortcode string
+	Counts    []int
+}
+
+// HistoryRange is the range a history actually covers: Counts[i] of every
+// series counts the votes cast in [From+i*Step, From+(i+1)*Step).
+type HistoryRange struct {
+	From   time.Time
+	Step   time.Duration
+	Series []*Series
+}
+
+// History counts the votes cast for each shortcode in per-minute buckets,
+// kept for a short while, and per-hour buckets, kept until the retention
+// period is up, so it takes bounded memory however long it runs. Polls keep
+// the history of a poll up to date as its votes are counted and taken back.
+//
+// The counts are split over shards that each have their own lock, so that
+// the stripes of a stripedPoll each count their votes in a shard of their
+// own, and reads add the shards up.
+type History struct {
+	shards []*historyShard
+	now    func() time.Time
+}
+
+// historyShard is a share of the counts of a History.
+type historyShard struct {
+	sync.Mutex
+	minutes         map[int64]map[string]int
+	hours           map[int64]map[string]int
+	minuteRetention time.Duration
+	retention       time.Duration
+	pruned          int64
+	now             func() time.Time
+}
+
+// SetRetention changes how long per-minute and per-hour counts are kept.
+func (h *History) SetRetention(minutes, retention time.Duration) {
+	for _, s := range h.shards {
+		s.Lock()
+		s.minuteRetention, s.retention = minutes, retention
+		s.pruned = 0
+		s.Unlock()
+	}
+}
+
+// Record counts a vote cast now.
+func (h *History) Record(choice string) {
+	h.add([]string{choice}, h.now(), 1)
+}
+
+// shard is the i-th shard of the history. A nil history has nil shards.
+func (h *History) shard(i int) *historyShard {
+	if h == nil {
+		return nil
+	}
+	return h.shards[i%len(h.shards)]
+}
+
+// add counts votes for choices cast at the given time in the first shard.
+func (h *History) add(choices []string, at time.Time, numVotes int) {
+	h.shard(0).add(choices, at, numVotes)
+}
+
+// remove takes back votes for choices from the first shard.
+func (h *History) remove(choices []string, at time.Time, span time.Duration, numVotes int) {
+	h.shard(0).remove(choices, at, span, numVotes)
+}
+
+// add counts votes for choices cast at the given time. A nil shard counts
+// nothing.
+func (s *historyShard) add(choices []string, at time.Time, numVotes int) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	s.prune(s.now())
+	for _, choice := range choices {
+		increment(s.minutes, at.Truncate(time.Minute).Unix(), choice, numVotes)
+		increment(s.hours, at.Truncate(time.Hour).Unix(), choice, numVotes)
+	}
+}
+
+// remove takes back votes for choices cast within span of the given time.
+// When the span covers more than one bucket, votes are taken back from the
+// newest bucket that has any, as polls retract anonymous votes. Counts never
+// go negative. A nil shard does nothing.
+func (s *historyShard) remove(choices []string, at time.Time, span time.Duration, numVotes int) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	for _, choice := range choices {
+		decrement(s.minutes, at, span, time.Minute, choice, numVotes)
+		decrement(s.hours, at, span, time.Hour, choice, numVotes)
+	}
+}
+
+func increment(buckets map[int64]map[string]int, at int64, choice string, numVotes int) {
+	if buckets[at] == nil {
+		buckets[at] = make(map[string]int)
+	}
+	buckets[at][choice] += numVotes
+}
+
+// decrement takes votes for choice back out of the buckets of the given step
+// that start within span of from, newest first.
+func decrement(buckets map[int64]map[string]int, from time.Time, span, step time.Duration, choice string, numVotes int) {
+	if span < step {
+		span = step
+	}
+	oldest := from.Truncate(step)
+	for t := oldest.Add(span - step); !t.Before(oldest) && numVotes > 0; t = t.Add(-step) {
+		counts := buckets[t.Unix()]
+		n := counts[choice]
+		if n == 0 {
+			continue
+		}
+		if n > numVotes {
+			n = numVotes
+		}
+		counts[choice] -= n
+		numVotes -= n
+		if counts[choice] == 0 {
+			delete(counts, choice)
+		}
+	}
+}
+
+// prune drops buckets past their retention, at most once a minute. Callers
+// must hold the lock.
+func (s *historyShard) prune(now time.Time) {
+	minute := now.Truncate(time.Minute).Unix()
+	if minute == s.pruned {
+		return
+	}
+	s.pruned = minute
+
+	for at := range s.minutes {
+		if now.Sub(time.Unix(at, 0)) > s.minuteRetention {
+			delete(s.minutes, at)
+		}
+	}
+	for at := range s.hours {
+		if now.Sub(time.Unix(at, 0)) > s.retention {
+			delete(s.hours, at)
+		}
+	}
+}
+
+// historyCounts are the counts of every shard of a History added up.
+type historyCounts struct {
+	minutes         map[int64]map[string]int
+	hours           map[int64]map[string]int
+	minuteRetention time.Duration
+	retention       time.Duration
+}
+
+// counts adds up the counts of every shard, pruned as of now.
+func (h *History) counts(now time.Time) *historyCounts {
+	c := &historyCounts{
+		minutes: make(map[int64]map[string]int),
+		hours:   make(map[int64]map[string]int),
+	}
+	for _, s := range h.shards {
+		s.Lock()
+		s.prune(now)
+		c.minuteRetention, c.retention = s.minuteRetention, s.retention
+		for at, counts := range s.minutes {
+			for choice, numVotes := range counts {
+				increment(c.minutes, at, choice, numVotes)
+			}
+		}
+		for at, counts := range s.hours {
+			for choice, numVotes := range counts {
+				increment(c.hours, at, choice, numVotes)
+			}
+		}
+		s.Unlock()
+	}
+	return c
+}
+
+// Range returns the votes cast for shortcodes, or for every shortcode with
+// votes when empty, between from and to in steps of step. Steps are rounded
+// up to whole minutes, and served from the hourly rollup when they are whole
+// hours. The range is aligned to steps and clipped to the history kept at
+// that resolution, and an unset or future end means up to now.
+func (h *History) Range(shortcodes []string, from, to time.Time, step time.Duration) (*HistoryRange, error) {
+	if step <= 0 {
+		step = time.Minute
+	}
+	if rem := step % time.Minute; rem != 0 {
+		step += time.Minute - rem
+	}
+
+	now := h.now()
+	c := h.counts(now)
+
+	buckets, kept := c.minutes, c.minuteRetention
+	if step%time.Hour == 0 {
+		buckets, kept = c.hours, c.retention
+	}
+	if to.IsZero() || to.After(now) {
+		// Include the step we're in.
+		to = now.Truncate(step).Add(step)
+	}
+	if oldest := now.Add(-kept); from.Before(oldest) {
+		from = oldest
+	}
+	from = from.Truncate(step)
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: [%v] is not before [%v]", ErrInvalidHistory, from, to)
+	}
+	points := int((to.Sub(from) + step - 1) / step)
+	if points > maxHistoryPoints {
+		return nil, fmt.Errorf("%w: [%d] steps of [%v] is more than [%d]", ErrInvalidHistory, points, step, maxHistoryPoints)
+	}
+
+	series := make(map[string]*Series)
+	for _, shortcode := range shortcodes {
+		series[shortcode] = &Series{Shortcode: shortcode, Counts: make([]int, points)}
+	}
+	for at, counts := range buckets {
+		t := time.Unix(at, 0)
+		if t.Before(from) || !t.Before(to) {
+			continue
+		}
+		i := int(t.Sub(from) / step)
+		for choice, numVotes := range counts {
+			s, ok := series[choice]
+			if !ok {
+				if len(shortcodes) > 0 {
+					continue
+				}
+				s = &Series{Shortcode: choice, Counts: make([]int, points)}
+				series[choice] = s
+			}
+			s.Counts[i] += numVotes
+		}
+	}
+
+	result := &HistoryRange{From: from, Step: step, Series: make([]*Series, 0, len(series))}
+	for _, s := range series {
+		result.Series = append(result.Series, s)
+	}
+	sort.Slice(result.Series, func(i, j int) bool {
+		return result.Series[i].Shortcode < result.Series[j].Shortcode
+	})
+	return result, nil
+}
+
+// NewHistory keeps per-minute counts for minutes and per-hour counts for
+// retention.
+func NewHistory(minutes, retention time.Duration) *History {
+	return newHistory(1, minutes, retention)
+}
+
+// newHistory returns a history split over the given number of shards.
+func newHistory(shards int, minutes, retention time.Duration) *History {
+	if shards < 1 {
+		shards = 1
+	}
+	h := &History{shards: make([]*historyShard, shards), now: time.Now}
+	for i := range h.shards {
+		h.shards[i] = &historyShard{
+			minutes:         make(map[int64]map[string]int),
+			hours:           make(map[int64]map[string]int),
+			minuteRetention: minutes,
+			retention:       retention,
+			now:             func() time.Time { return h.now() },
+		}
+	}
+	return h
+}
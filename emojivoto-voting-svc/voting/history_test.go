@@ -0,0 +1,117 @@
+package voting
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Takes votes back newest first", func(t *testing.T) {
+		history := NewHistory(time.Hour, 24*time.Hour)
+		now := start
+		history.now = func() time.Time { return now }
+
+		history.Record(":joy:")
+		now = now.Add(time.Minute)
+		history.Record(":joy:")
+		history.Record(":joy:")
+		now = now.Add(time.Minute)
+
+		history.remove([]string{":joy:"}, start, time.Hour, 2)
+		history.remove([]string{":ghost:"}, start, time.Minute, 1)
+		h, _ := history.Range(nil, start, now, time.Minute)
+		if len(h.Series) != 1 || h.Series[0].Counts[0] != 1 || h.Series[0].Counts[1] != 0 {
+			t.Fatalf("Expected a vote for :joy: in the first minute only, got [%v]", h.Series)
+		}
+		h, _ = history.Range(nil, start.Truncate(time.Hour), now, time.Hour)
+		if h.Series[0].Counts[0] != 1 {
+			t.Fatalf("Expected a vote for :joy: in the hour, got [%v]", h.Series[0].Counts)
+		}
+
+		history.remove([]string{":joy:"}, start, time.Minute, 5)
+		if h, _ := history.Range(nil, start, now, time.Minute); len(h.Series) != 0 {
+			t.Fatalf("Expected no votes to be left, got [%v]", h.Series)
+		}
+	})
+
+	t.Run("Counts votes per step", func(t *testing.T) {
+		history := NewHistory(time.Hour, 24*time.Hour)
+		now := start
+		history.now = func() time.Time { return now }
+
+		history.Record(":doughnut:")
+		now = now.Add(90 * time.Second)
+		history.Record(":doughnut:")
+		history.Record(":joy:")
+		now = now.Add(3 * time.Minute)
+
+		h, err := history.Range(nil, start, now, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !h.From.Equal(start) || h.Step != time.Minute {
+			t.Fatalf("Expected minutes from [%v], got [%v] from [%v]", start, h.Step, h.From)
+		}
+		if len(h.Series) != 2 || h.Series[0].Shortcode != ":doughnut:" || h.Series[1].Shortcode != ":joy:" {
+			t.Fatalf("Expected a series each for :doughnut: and :joy:, got [%v]", h.Series)
+		}
+		expected := []int{1, 1, 0, 0, 0}
+		for i, numVotes := range h.Series[0].Counts {
+			if i >= len(expected) || numVotes != expected[i] {
+				t.Fatalf("Expected :doughnut: counts [%v], got [%v]", expected, h.Series[0].Counts)
+			}
+		}
+
+		h, _ = history.Range([]string{":joy:", ":ghost:"}, start, now, 5*time.Minute)
+		if len(h.Series) != 2 || h.Series[0].Shortcode != ":ghost:" || h.Series[1].Counts[0] != 1 {
+			t.Fatalf("Expected only the requested series, got [%v]", h.Series)
+		}
+	})
+
+	t.Run("Rolls up to hours and forgets old votes", func(t *testing.T) {
+		history := NewHistory(time.Hour, 3*time.Hour)
+		now := start
+		history.now = func() time.Time { return now }
+
+		history.Record(":doughnut:")
+		now = now.Add(2 * time.Hour)
+		history.Record(":doughnut:")
+		history.Record(":doughnut:")
+
+		h, _ := history.Range(nil, start, now, time.Minute)
+		if !h.From.Equal(now.Add(-time.Hour)) {
+			t.Fatalf("Expected minutes to be clipped to the last hour, got [%v]", h.From)
+		}
+
+		h, _ = history.Range(nil, start, now.Add(time.Minute), time.Hour)
+		if len(h.Series) != 1 || len(h.Series[0].Counts) != 3 || h.Series[0].Counts[0] != 1 || h.Series[0].Counts[2] != 2 {
+			t.Fatalf("Expected hourly counts [1 0 2], got [%v]", h.Series)
+		}
+
+		now = now.Add(2 * time.Hour)
+		history.Record(":joy:")
+		h, _ = history.Range([]string{":doughnut:"}, start, now, time.Hour)
+		if !h.From.Equal(now.Add(-3*time.Hour)) || h.Series[0].Counts[0] != 0 || h.Series[0].Counts[1] != 2 {
+			t.Fatalf("Expected votes past the retention period to be forgotten, got [%v]", h.Series[0].Counts)
+		}
+		if len(history.shards[0].hours) != 2 || len(history.shards[0].minutes) != 1 {
+			t.Fatalf("Expected old buckets to be dropped, got [%d] hours and [%d] minutes", len(history.shards[0].hours), len(history.shards[0].minutes))
+		}
+	})
+
+	t.Run("Rejects empty and oversized ranges", func(t *testing.T) {
+		history := NewHistory(DefaultHistoryMinutes, DefaultHistoryRetention)
+		now := time.Now()
+
+		if _, err := history.Range(nil, now.Add(time.Hour), now, time.Minute); !errors.Is(err, ErrInvalidHistory) {
+			t.Fatalf("Expected a range ending before it starts to be rejected, got [%v]", err)
+		}
+		history.SetRetention(DefaultHistoryMinutes, 10000*time.Hour)
+		if _, err := history.Range(nil, now.Add(-5000*time.Hour), now, time.Hour); !errors.Is(err, ErrInvalidHistory) {
+			t.Fatalf("Expected too many steps to be rejected, got [%v]", err)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package voting
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// notifier wakes up the watchers of a poll when its results change. Wake-ups
+// coalesce: a watcher that hasn't caught up with the last one doesn't get
+// another. Notifying takes no lock, as every vote does it: the watchers are
+// swapped for a new list whenever one comes or goes, and votes read the list
+// in force.
+type notifier struct {
+	sync.Mutex
+	// watchers holds the []chan struct{} in force.
+	watchers atomic.Value
+}
+
+func (n *notifier) notify() {
+	watchers, _ := n.watchers.Load().([]chan struct{})
+	for _, ch := range watchers {
+		if len(ch) > 0 {
+			// A wake-up is waiting already.
+			continue
+		}
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *notifier) watch() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	n.Lock()
+	defer n.Unlock()
+	watchers, _ := n.watchers.Load().([]chan struct{})
+	n.watchers.Store(append(append([]chan struct{}(nil), watchers...), ch))
+
+	return ch, func() {
+		n.Lock()
+		defer n.Unlock()
+		watchers, _ := n.watchers.Load().([]chan struct{})
+		kept := make([]chan struct{}, 0, len(watchers))
+		for _, w := range watchers {
+			if w != ch {
+				kept = append(kept, w)
+			}
+		}
+		n.watchers.Store(kept)
+	}
+}
@@ -1,52 +1,577 @@
 package voting
 
 import (
+	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// Result is the number of votes for a choice. Weighted is their total
+// weight under the weight policy, Rank and DenseRank are the choice's place
+// on the leaderboard, as in rank, and Share is the share of ballots that
+// picked it, likely between ShareLow and ShareHigh. They are only set in the
+// results of Polls.
 type Result struct {
-	Shortcode string `json:"shortcode"`
-	NumVotes  int    `json:"votes"`
+	Shortcode string  `json:"shortcode"`
+	NumVotes  int     `json:"votes"`
+	Weighted  float64 `json:"weighted,omitempty"`
+	Rank      int     `json:"rank,omitempty"`
+	DenseRank int     `json:"dense_rank,omitempty"`
+	Share     float64 `json:"share,omitempty"`
+	ShareLow  float64 `json:"share_low,omitempty"`
+	ShareHigh float64 `json:"share_high,omitempty"`
+	// Since is the change to the tally at which the choice reached its
+	// count, for TiebreakFirst.
+	Since uint64 `json:"since,omitempty"`
 }
 
+// ByVotes sorts results by votes, and choices with as many votes by
+// shortcode.
 type ByVotes []*Result
 
 func (s ByVotes) Len() int      { return len(s) }
 func (s ByVotes) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 func (s ByVotes) Less(i, j int) bool {
-	return s[i].NumVotes > s[j].NumVotes
+	if s[i].NumVotes != s[j].NumVotes {
+		return s[i].NumVotes > s[j].NumVotes
+	}
+	return s[i].Shortcode < s[j].Shortcode
+}
+
+// Voter describes who cast a vote, as far as the voting service can tell.
+// Every field is optional. Polls keep a single current choice per voter ID,
+// so voting again with the same ID moves the vote instead of adding one.
+// Metadata is the request metadata the weight policy looks at, of the keys
+// in WeightMetadata.
+type Voter struct {
+	ID        string
+	Address   string
+	UserAgent string
+	Metadata  map[string]string
+}
+
+// RetractFilter picks votes to retract in bulk: those cast by VoterID, if
+// set, at or after Since and before Until, where a zero time leaves that end
+// of the range open. In-memory and file polls only know when a vote was cast
+// to the minute, and for anonymous votes from before the previous hour to the
+// hour, or from before yesterday to the day. They match a minute, hour or day
+// if it starts within the range.
+type RetractFilter struct {
+	VoterID string    `json:"voter_id,omitempty"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+}
+
+// IsEmpty reports whether the filter matches every vote.
+func (f RetractFilter) IsEmpty() bool {
+	return f.VoterID == "" && f.Since.IsZero() && f.Until.IsZero()
+}
+
+func (f RetractFilter) covers(t time.Time) bool {
+	return (f.Since.IsZero() || !t.Before(f.Since)) && (f.Until.IsZero() || t.Before(f.Until))
+}
+
+// Ballot is what a vote is cast for: a single choice in plurality polls,
+// a ranking of choices, most preferred first, in ranked polls, and the
+// approved choices in approval polls. Weight is how much it counts under the
+// weight policy.
+type Ballot struct {
+	Choices []string `json:"choices"`
+	Weight  float64  `json:"weight"`
+}
+
+func (b Ballot) String() string {
+	return strings.Join(b.Choices, ", ")
+}
+
+func (b Ballot) check() error {
+	if len(b.Choices) == 0 {
+		return fmt.Errorf("%w: a ballot needs at least one choice", ErrInvalidBallot)
+	}
+	return nil
+}
+
+func (b Ballot) equal(other Ballot) bool {
+	return b.Weight == other.Weight && sameChoices(b.Choices, other.Choices)
+}
+
+// counted is what a ballot counts for in the results of a poll voted in the
+// given mode: the first preference of a ranked ballot, and every choice of
+// any other.
+func (b Ballot) counted(mode BallotMode) []string {
+	if mode == BallotRanked {
+		return b.Choices[:1]
+	}
+	return b.Choices
+}
+
+// key identifies a ballot in the maps of a tally. It is never parsed back;
+// the ballot itself is kept alongside.
+func (b Ballot) key() ballotKey {
+	return ballotKey{choices: strings.Join(b.Choices, "\x00"), weight: b.Weight}
+}
+
+type ballotKey struct {
+	choices string
+	weight  float64
+}
+
+func sameChoices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// BallotCount is how many votes were cast with the same choices, and their
+// total weight.
+type BallotCount struct {
+	Choices  []string `json:"choices"`
+	NumVotes int      `json:"votes"`
+	Weighted float64  `json:"weighted,omitempty"`
+}
+
+// ballotCounts adds up the ballots cast with the same choices, whatever
+// their weight, in the order of their choices.
+type ballotCounts map[string]*BallotCount
+
+func (c ballotCounts) add(choices []string, numVotes int, weighted float64) {
+	key := strings.Join(choices, "\x00")
+	count, ok := c[key]
+	if !ok {
+		count = &BallotCount{Choices: append([]string(nil), choices...)}
+		c[key] = count
+	}
+	count.NumVotes += numVotes
+	count.Weighted += weighted
+}
+
+func (c ballotCounts) sorted() []*BallotCount {
+	counts := make([]*BallotCount, 0, len(c))
+	for _, count := range c {
+		if count.NumVotes > 0 {
+			counts = append(counts, count)
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i].Choices, counts[j].Choices
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return counts
+}
+
+// historian is implemented by polls that keep a History of their votes up
+// to date, taking back the votes they move and retract, in as many shards of
+// it as historyShards asks for. They time votes by the same clock as the
+// history.
+type historian interface {
+	historyShards() int
+	setHistory(history *History, now func() time.Time)
 }
 
 type Poll interface {
+	// Vote casts an anonymous vote for choice that counts once.
 	Vote(choice string) error
+	VoteAs(voter Voter, ballot Ballot) error
+	// Retract takes back a single vote cast with the given choices, in the
+	// same order, whatever its weight. Voters with an ID can only take back
+	// their own current vote, and can leave out its choices, and others only
+	// the newest vote with those choices cast without an ID. It fails with
+	// ErrNoVote rather than let a count go negative.
+	Retract(voter Voter, choices []string) error
+	// RetractVotes takes back every vote matching filter and returns how
+	// many it took back.
+	RetractVotes(filter RetractFilter) (int, error)
+	// Results counts the votes for each choice, and their total weight: the
+	// first preferences of ranked ballots, and every choice of approval
+	// ballots.
 	Results() ([]*Result, error)
+	// Ballots counts the votes cast with each distinct set of choices.
+	Ballots() ([]*BallotCount, error)
+}
+
+// castVote is the current vote of a voter with an ID.
+type castVote struct {
+	ballot Ballot
+	minute int64
+}
+
+// ballotTally is how many counted votes were cast with a ballot.
+type ballotTally struct {
+	ballot   Ballot
+	numVotes int
 }
 
 type inMemoryPoll struct {
+	mode  BallotMode
 	votes map[string]int
+	// weighted is the total weight of the votes for each choice.
+	weighted map[string]float64
+	// ballots counts the votes cast with each distinct ballot.
+	ballots map[ballotKey]*ballotTally
+	// choices is the current vote of every voter with an ID.
+	choices map[string]castVote
+	// minutes counts the votes cast without a voter ID with each ballot, by
+	// the Unix time of the minute they were cast in, so they can be
+	// retracted by time. Older minutes are rolled up into hours and days.
+	minutes map[int64]map[ballotKey]int
+	// rolledUp is the newest minute the buckets were rolled up as of.
+	rolledUp int64
+	// changes counts the changes to the tally. The stripes of a stripedPoll
+	// share theirs, so their changes are in one sequence.
+	changes *uint64
+	// change is the change being applied to the tally.
+	change uint64
+	// since is the change at which each choice reached its count. It is kept
+	// once the count drops to zero, so that the stripes of a stripedPoll can
+	// tell which of them changed a choice last.
+	since map[string]uint64
+	// changed, if set, collects the choices whose counts change, for a
+	// stripedPoll to add up again.
+	changed map[string]bool
+	// history, if set, counts the votes by when they were cast.
+	history *historyShard
 	sync.RWMutex
-	counter *prometheus.CounterVec
+	counter *emojiCounter
+	now     func() time.Time
 }
 
 func (p *inMemoryPoll) Vote(choice string) error {
+	return p.VoteAs(Voter{}, Ballot{Choices: []string{choice}, Weight: 1})
+}
+
+func (p *inMemoryPoll) VoteAs(voter Voter, ballot Ballot) error {
+	if err := ballot.check(); err != nil {
+		return err
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	p.record(voter.ID, ballot, p.minute())
+	return nil
+}
+
+func (p *inMemoryPoll) Retract(voter Voter, choices []string) error {
 	p.Lock()
 	defer p.Unlock()
 
-	if p.votes[choice] > 0 {
-		p.votes[choice] = p.votes[choice] + 1
-	} else {
-		p.votes[choice] = 1
+	if err := p.retractable(voter.ID, choices); err != nil {
+		return err
 	}
-	p.counter.With(prometheus.Labels{"emoji": choice}).Inc()
-	log.Printf("Voted for [%s], which now has a total of [%d] votes", choice, p.votes[choice])
+	p.retract(voter.ID, choices)
 	return nil
 }
 
+func (p *inMemoryPoll) RetractVotes(filter RetractFilter) (int, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	retracted := p.retractVotes(filter)
+	log.Printf("Retracted [%d] votes", retracted)
+	return retracted, nil
+}
+
+// minute is the Unix time of the minute we're in.
+func (p *inMemoryPoll) minute() int64 {
+	return p.now().Truncate(time.Minute).Unix()
+}
+
+// record counts a vote for ballot. Callers must hold the write lock.
+func (p *inMemoryPoll) record(voterID string, ballot Ballot, minute int64) {
+	previous, moved := p.tally(voterID, ballot, minute)
+	if moved && previous.equal(ballot) {
+		// Voting for the same ballot again changes nothing.
+		return
+	}
+	p.counter.vote(ballot, p.mode)
+	total := p.votes[ballot.Choices[0]]
+	if moved {
+		log.Printf("Moved vote from [%s] to [%s], which now has a total of [%d] votes", previous, ballot, total)
+		return
+	}
+	log.Printf("Voted for [%s], which now has a total of [%d] votes", ballot, total)
+}
+
+// tally applies a vote to the counts, taking back the voter's previous vote
+// if they have one, unless it is for the same ballot. Callers must hold the
+// write lock.
+func (p *inMemoryPoll) tally(voterID string, ballot Ballot, minute int64) (previous Ballot, moved bool) {
+	if voterID == "" {
+		p.tallyAnonymous(ballot, minute, 1)
+		return previous, false
+	}
+
+	var cast castVote
+	cast, moved = p.choices[voterID]
+	previous = cast.ballot
+	if moved && previous.equal(ballot) {
+		return previous, true
+	}
+	p.begin()
+	if moved {
+		p.untally(previous, cast.minute, time.Minute, 1)
+	}
+	p.choices[voterID] = castVote{ballot: ballot, minute: minute}
+	p.add(ballot, minute, 1)
+	return previous, moved
+}
+
+// tallyAnonymous applies votes cast without a voter ID to the counts.
+// Callers must hold the write lock.
+func (p *inMemoryPoll) tallyAnonymous(ballot Ballot, minute int64, numVotes int) {
+	p.begin()
+	p.rollUp(minute)
+	at := rollUpInto(minute, p.rolledUp)
+	if p.minutes[at] == nil {
+		p.minutes[at] = make(map[ballotKey]int)
+	}
+	p.minutes[at][ballot.key()] += numVotes
+	p.add(ballot, minute, numVotes)
+}
+
+// rollUp merges the buckets of anonymous votes cast before the previous hour
+// into hourly buckets, and those cast before yesterday into daily ones, as of
+// minute, so a poll keeps a bounded number of buckets however long it runs.
+// The buckets only depend on the newest minute rolled up as of, so replaying
+// the same votes rolls them up the same way. Callers must hold the write
+// lock.
+func (p *inMemoryPoll) rollUp(minute int64) {
+	if minute <= p.rolledUp {
+		return
+	}
+	p.rolledUp = minute
+
+	for at, bucket := range p.minutes {
+		into := rollUpInto(at, minute)
+		if into == at {
+			continue
+		}
+		if p.minutes[into] == nil {
+			p.minutes[into] = make(map[ballotKey]int)
+		}
+		for key, numVotes := range bucket {
+			p.minutes[into][key] += numVotes
+		}
+		delete(p.minutes, at)
+	}
+}
+
+// rollUpInto is the bucket that the bucket starting at the given Unix time
+// belongs in as of minute.
+func rollUpInto(at, minute int64) int64 {
+	return time.Unix(at, 0).Truncate(rollUpSpan(at, minute)).Unix()
+}
+
+// rollUpSpan is how long the bucket that the bucket starting at the given
+// Unix time belongs in spans as of minute: a day before yesterday, an hour
+// before the previous hour, and a minute otherwise.
+func rollUpSpan(at, minute int64) time.Duration {
+	const day = 24 * time.Hour
+	t, now := time.Unix(at, 0), time.Unix(minute, 0)
+	if now.Truncate(day).Sub(t.Truncate(day)) > day {
+		return day
+	}
+	if now.Truncate(time.Hour).Sub(t.Truncate(time.Hour)) > time.Hour {
+		return time.Hour
+	}
+	return time.Minute
+}
+
+// begin starts the next change to the tally. Callers must hold the write
+// lock.
+func (p *inMemoryPoll) begin() {
+	p.change = atomic.AddUint64(p.changes, 1)
+}
+
+// add counts votes for ballot cast in the given minute. Callers must hold
+// the write lock.
+func (p *inMemoryPoll) add(ballot Ballot, minute int64, numVotes int) {
+	key := ballot.key()
+	t, ok := p.ballots[key]
+	if !ok {
+		t = &ballotTally{ballot: ballot}
+		p.ballots[key] = t
+	}
+	t.numVotes += numVotes
+	for _, choice := range ballot.counted(p.mode) {
+		p.votes[choice] += numVotes
+		p.weighted[choice] += float64(numVotes) * ballot.Weight
+		p.since[choice] = p.change
+		if p.changed != nil {
+			p.changed[choice] = true
+		}
+	}
+	p.history.add(ballot.counted(p.mode), time.Unix(minute, 0), numVotes)
+}
+
+// untally takes votes for ballot cast within span of the given minute back
+// out of the counts. Callers must hold the write lock.
+func (p *inMemoryPoll) untally(ballot Ballot, minute int64, span time.Duration, numVotes int) {
+	key := ballot.key()
+	t, ok := p.ballots[key]
+	if !ok {
+		return
+	}
+	if numVotes > t.numVotes {
+		numVotes = t.numVotes
+	}
+	t.numVotes -= numVotes
+	if t.numVotes == 0 {
+		delete(p.ballots, key)
+	}
+	for _, choice := range ballot.counted(p.mode) {
+		p.votes[choice] -= numVotes
+		p.weighted[choice] -= float64(numVotes) * ballot.Weight
+		p.since[choice] = p.change
+		if p.changed != nil {
+			p.changed[choice] = true
+		}
+		if p.votes[choice] <= 0 {
+			delete(p.votes, choice)
+			delete(p.weighted, choice)
+		}
+	}
+	p.history.remove(ballot.counted(p.mode), time.Unix(minute, 0), span, numVotes)
+}
+
+func (p *inMemoryPoll) historyShards() int {
+	return 1
+}
+
+// setHistory has the votes counted and taken back from now on kept in
+// history, timed by now.
+func (p *inMemoryPoll) setHistory(history *History, now func() time.Time) {
+	p.setHistoryShard(history.shard(0), now)
+}
+
+func (p *inMemoryPoll) setHistoryShard(shard *historyShard, now func() time.Time) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.history, p.now = shard, now
+}
+
+// retractable checks there is a vote with the given choices for Retract to
+// take back. Callers must hold a lock.
+func (p *inMemoryPoll) retractable(voterID string, choices []string) error {
+	if voterID != "" {
+		cast, ok := p.choices[voterID]
+		if !ok {
+			return fmt.Errorf("%w: voter [%s] has no vote", ErrNoVote, voterID)
+		}
+		if len(choices) > 0 && !sameChoices(cast.ballot.Choices, choices) {
+			return fmt.Errorf("%w: voter [%s] has no vote for [%s]", ErrNoVote, voterID, strings.Join(choices, ", "))
+		}
+		return nil
+	}
+	if _, _, ok := p.newestAnonymous(choices); !ok {
+		return fmt.Errorf("%w: [%s] has no votes cast without a voter ID", ErrNoVote, strings.Join(choices, ", "))
+	}
+	return nil
+}
+
+// newestAnonymous finds the minute and ballot of the newest vote with the
+// given choices cast without a voter ID. Of the ballots with those choices
+// cast in the same minute, it picks the lightest, so that replaying a
+// retraction picks the same one. Callers must hold a lock.
+func (p *inMemoryPoll) newestAnonymous(choices []string) (int64, Ballot, bool) {
+	var newest int64
+	var ballot Ballot
+	found := false
+	for minute, bucket := range p.minutes {
+		if found && minute < newest {
+			continue
+		}
+		for key := range bucket {
+			b := p.ballots[key].ballot
+			if !sameChoices(b.Choices, choices) {
+				continue
+			}
+			if !found || minute > newest || b.Weight < ballot.Weight {
+				newest, ballot, found = minute, b, true
+			}
+		}
+	}
+	return newest, ballot, found
+}
+
+// retract takes back a vote that retractable allowed. Callers must hold the
+// write lock.
+func (p *inMemoryPoll) retract(voterID string, choices []string) {
+	ballot := p.takeBack(voterID, choices)
+	log.Printf("Retracted vote for [%s], which now has a total of [%d] votes", ballot, p.votes[ballot.Choices[0]])
+}
+
+// takeBack takes a vote that retractable allowed back out of the counts, and
+// returns its ballot. Anonymous votes are taken back newest first. Callers
+// must hold the write lock.
+func (p *inMemoryPoll) takeBack(voterID string, choices []string) Ballot {
+	p.begin()
+	if voterID != "" {
+		cast := p.choices[voterID]
+		delete(p.choices, voterID)
+		p.untally(cast.ballot, cast.minute, time.Minute, 1)
+		return cast.ballot
+	}
+
+	minute, ballot, _ := p.newestAnonymous(choices)
+	key := ballot.key()
+	p.minutes[minute][key]--
+	if p.minutes[minute][key] == 0 {
+		delete(p.minutes[minute], key)
+	}
+	if len(p.minutes[minute]) == 0 {
+		delete(p.minutes, minute)
+	}
+	p.untally(ballot, minute, rollUpSpan(minute, p.rolledUp), 1)
+	return ballot
+}
+
+// retractVotes takes back every vote matching filter. Callers must hold the
+// write lock.
+func (p *inMemoryPoll) retractVotes(filter RetractFilter) int {
+	p.begin()
+	retracted := 0
+	for voterID, cast := range p.choices {
+		if (filter.VoterID == "" || voterID == filter.VoterID) && filter.covers(time.Unix(cast.minute, 0)) {
+			delete(p.choices, voterID)
+			p.untally(cast.ballot, cast.minute, time.Minute, 1)
+			retracted++
+		}
+	}
+	if filter.VoterID != "" {
+		return retracted
+	}
+	for minute, bucket := range p.minutes {
+		if !filter.covers(time.Unix(minute, 0)) {
+			continue
+		}
+		for key, numVotes := range bucket {
+			p.untally(p.ballots[key].ballot, minute, rollUpSpan(minute, p.rolledUp), numVotes)
+			retracted += numVotes
+		}
+		delete(p.minutes, minute)
+	}
+	return retracted
+}
+
 func (p *inMemoryPoll) Results() ([]*Result, error) {
 	p.RLock()
 	defer p.RUnlock()
@@ -54,7 +579,7 @@ func (p *inMemoryPoll) Results() ([]*Result, error) {
 	results := make([]*Result, 0)
 
 	for emoji, numVotes := range p.votes {
-		results = append(results, &Result{emoji, numVotes})
+		results = append(results, &Result{Shortcode: emoji, NumVotes: numVotes, Weighted: p.weighted[emoji], Since: p.since[emoji]})
 	}
 
 	sort.Sort(ByVotes(results))
@@ -62,15 +587,104 @@ func (p *inMemoryPoll) Results() ([]*Result, error) {
 	return results, nil
 }
 
-var counter *prometheus.CounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+func (p *inMemoryPoll) Ballots() ([]*BallotCount, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	counts := make(ballotCounts)
+	p.addBallots(counts)
+	return counts.sorted(), nil
+}
+
+// addBallots adds the ballots of the tally to counts. Callers must hold a
+// lock.
+func (p *inMemoryPoll) addBallots(counts ballotCounts) {
+	for _, t := range p.ballots {
+		counts.add(t.ballot.Choices, t.numVotes, float64(t.numVotes)*t.ballot.Weight)
+	}
+}
+
+// MaxEmojiLabels caps the emoji label values of emojivoto_votes_total, so
+// that however many choices get votes, the metric stays bounded. Votes for
+// choices beyond it count under OtherEmojiLabel.
+const MaxEmojiLabels = 500
+
+// OtherEmojiLabel is the emoji label of votes for choices beyond
+// MaxEmojiLabels.
+const OtherEmojiLabel = "other"
+
+var counter = newEmojiCounter(promauto.NewCounterVec(prometheus.CounterOpts{
 	Name: "emojivoto_votes_total",
 	Help: "Number of emoji votes",
-}, []string{"emoji"})
+}, []string{"emoji"}), MaxEmojiLabels)
 
-func NewPoll() Poll {
-	poll := &inMemoryPoll{
-		votes:   make(map[string]int, 0),
-		counter: counter,
+// emojiCounter counts votes by emoji in Prometheus, with at most max emoji
+// label values. It keeps the counter of each emoji so that votes don't look
+// it up by its labels, and only takes its lock for emoji it hasn't seen.
+type emojiCounter struct {
+	vec      *prometheus.CounterVec
+	max      int
+	counters sync.Map
+	other    prometheus.Counter
+	mu       sync.Mutex
+	labels   int
+}
+
+func newEmojiCounter(vec *prometheus.CounterVec, max int) *emojiCounter {
+	return &emojiCounter{
+		vec:   vec,
+		max:   max,
+		other: vec.With(prometheus.Labels{"emoji": OtherEmojiLabel}),
+	}
+}
+
+// inc counts a vote for emoji.
+func (c *emojiCounter) inc(emoji string) {
+	if counter, ok := c.counters.Load(emoji); ok {
+		counter.(prometheus.Counter).Inc()
+		return
 	}
-	return poll
+	c.counterFor(emoji).Inc()
+}
+
+// vote counts a vote for ballot in a poll voted in the given mode.
+func (c *emojiCounter) vote(ballot Ballot, mode BallotMode) {
+	for _, choice := range ballot.counted(mode) {
+		c.inc(choice)
+	}
+}
+
+func (c *emojiCounter) counterFor(emoji string) prometheus.Counter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if counter, ok := c.counters.Load(emoji); ok {
+		return counter.(prometheus.Counter)
+	}
+	if c.labels >= c.max || emoji == OtherEmojiLabel {
+		return c.other
+	}
+	counter := c.vec.With(prometheus.Labels{"emoji": emoji})
+	c.counters.Store(emoji, counter)
+	c.labels++
+	return counter
+}
+
+func newInMemoryPoll(mode BallotMode) *inMemoryPoll {
+	return &inMemoryPoll{
+		mode:     mode,
+		changes:  new(uint64),
+		since:    make(map[string]uint64),
+		votes:    make(map[string]int, 0),
+		weighted: make(map[string]float64),
+		ballots:  make(map[ballotKey]*ballotTally),
+		choices:  make(map[string]castVote),
+		minutes:  make(map[int64]map[ballotKey]int),
+		counter:  counter,
+		now:      time.Now,
+	}
+}
+
+func NewPoll() Poll {
+	return newInMemoryPoll(BallotPlurality)
 }
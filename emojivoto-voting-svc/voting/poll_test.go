@@ -1,10 +1,20 @@
 package voting
 
 import (
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
+// ballotFor is a ballot for a single choice that counts once.
+func ballotFor(choice string) Ballot {
+	return Ballot{Choices: []string{choice}, Weight: 1}
+}
+
 func TestVote(t *testing.T) {
 	poll := NewPoll()
 
@@ -27,6 +37,42 @@ func TestVote(t *testing.T) {
 			t.Fatalf("Expected emoji to have [2] votes, got , got [%d]", results[0].NumVotes)
 		}
 	})
+
+	t.Run("Moves the vote of a voter with an ID", func(t *testing.T) {
+		poll := NewPoll()
+		voter := Voter{ID: "voter-1"}
+
+		poll.VoteAs(voter, ballotFor(":joy:"))
+		poll.VoteAs(voter, ballotFor(":joy:"))
+		poll.VoteAs(voter, ballotFor(":ghost:"))
+		poll.VoteAs(Voter{}, ballotFor(":joy:"))
+
+		results, _ := poll.Results()
+		if len(results) != 2 || results[0].NumVotes != 1 || results[1].NumVotes != 1 {
+			t.Fatalf("Expected one vote each for :joy: and :ghost:, got [%v]", results)
+		}
+	})
+
+	t.Run("Counts a vote for the same choice again only once", func(t *testing.T) {
+		vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "votes_total"}, []string{"emoji"})
+		for name, poll := range map[string]Poll{"locked": newInMemoryPoll(BallotPlurality), "striped": newStripedPoll(4, BallotPlurality)} {
+			switch p := poll.(type) {
+			case *inMemoryPoll:
+				p.counter = newEmojiCounter(vec, MaxEmojiLabels)
+			case *stripedPoll:
+				p.counter = newEmojiCounter(vec, MaxEmojiLabels)
+			}
+			vec.Reset()
+			voter := Voter{ID: "voter-1"}
+
+			poll.VoteAs(voter, ballotFor(":joy:"))
+			poll.VoteAs(voter, ballotFor(":joy:"))
+
+			if votes := testutil.ToFloat64(vec.With(prometheus.Labels{"emoji": ":joy:"})); votes != 1 {
+				t.Fatalf("Expected [1] counted vote for :joy: in the [%s] poll, got [%v]", name, votes)
+			}
+		}
+	})
 }
 
 func TestResults(t *testing.T) {
@@ -62,3 +108,119 @@ func TestResults(t *testing.T) {
 		}
 	})
 }
+
+func TestRetract(t *testing.T) {
+	t.Run("Takes back votes without going negative", func(t *testing.T) {
+		poll := NewPoll()
+		poll.Vote(":joy:")
+		poll.VoteAs(Voter{ID: "voter-1"}, ballotFor(":joy:"))
+
+		if err := poll.Retract(Voter{}, []string{":joy:"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := poll.Retract(Voter{}, []string{":joy:"}); !errors.Is(err, ErrNoVote) {
+			t.Fatalf("Expected anonymous retraction to leave the voter's vote alone, got [%v]", err)
+		}
+		if err := poll.Retract(Voter{ID: "voter-2"}, []string{":joy:"}); !errors.Is(err, ErrNoVote) {
+			t.Fatalf("Expected retraction by another voter to be rejected, got [%v]", err)
+		}
+		if err := poll.Retract(Voter{ID: "voter-1"}, []string{":joy:"}); err != nil {
+			t.Fatal(err)
+		}
+
+		results, _ := poll.Results()
+		if len(results) != 0 {
+			t.Fatalf("Expected no votes left, got [%v]", results)
+		}
+	})
+
+	t.Run("Takes back votes in bulk by voter or time", func(t *testing.T) {
+		poll := newInMemoryPoll(BallotPlurality)
+		now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+		poll.now = func() time.Time { return now }
+
+		poll.Vote(":joy:")
+		poll.VoteAs(Voter{ID: "voter-1"}, ballotFor(":ghost:"))
+		now = now.Add(10 * time.Minute)
+		for i := 0; i < 5; i++ {
+			poll.Vote(":robot:")
+		}
+		poll.VoteAs(Voter{ID: "voter-2"}, ballotFor(":joy:"))
+
+		retracted, _ := poll.RetractVotes(RetractFilter{VoterID: "voter-1"})
+		if retracted != 1 {
+			t.Fatalf("Expected [1] vote retracted for voter-1, got [%d]", retracted)
+		}
+		retracted, _ = poll.RetractVotes(RetractFilter{Since: now.Add(-time.Minute)})
+		if retracted != 6 {
+			t.Fatalf("Expected [6] recent votes retracted, got [%d]", retracted)
+		}
+
+		results, _ := poll.Results()
+		if len(results) != 1 || results[0].Shortcode != ":joy:" || results[0].NumVotes != 1 {
+			t.Fatalf("Expected only the first vote for :joy: to be left, got [%v]", results)
+		}
+		if err := poll.Retract(Voter{ID: "voter-2"}, []string{":joy:"}); !errors.Is(err, ErrNoVote) {
+			t.Fatalf("Expected voter-2's vote to be gone, got [%v]", err)
+		}
+	})
+
+	t.Run("Rolls up old anonymous votes into hours and days", func(t *testing.T) {
+		poll := newInMemoryPoll(BallotPlurality)
+		start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		now := start
+		poll.now = func() time.Time { return now }
+
+		cast, firstDay, secondDay := 0, 0, 0
+		for i := 0; i < 3*24*60; i += 7 {
+			now = start.Add(time.Duration(i) * time.Minute)
+			poll.Vote(":joy:")
+			cast++
+			switch i / (24 * 60) {
+			case 0:
+				firstDay++
+			case 1:
+				secondDay++
+			}
+		}
+
+		if len(poll.minutes) > 100 {
+			t.Fatalf("Expected old minutes to be rolled up, got [%d] buckets", len(poll.minutes))
+		}
+		if results, _ := poll.Results(); len(results) != 1 || results[0].NumVotes != cast {
+			t.Fatalf("Expected all [%d] votes to be kept, got [%v]", cast, results)
+		}
+		retracted, _ := poll.RetractVotes(RetractFilter{Since: start.Add(24 * time.Hour), Until: start.Add(48 * time.Hour)})
+		if retracted != secondDay {
+			t.Fatalf("Expected the [%d] votes of yesterday to be retracted by the hour, got [%d]", secondDay, retracted)
+		}
+		retracted, _ = poll.RetractVotes(RetractFilter{Since: start, Until: start.Add(time.Hour)})
+		if retracted != firstDay {
+			t.Fatalf("Expected the [%d] votes of the whole first day to be retracted, got [%d]", firstDay, retracted)
+		}
+	})
+}
+
+func TestEmojiCounter(t *testing.T) {
+	t.Run("Counts votes beyond the label cap as other", func(t *testing.T) {
+		vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "votes_total"}, []string{"emoji"})
+		counter := newEmojiCounter(vec, 2)
+
+		counter.inc(":joy:")
+		counter.inc(":joy:")
+		counter.inc(":ghost:")
+		for i := 0; i < 10; i++ {
+			counter.inc(fmt.Sprintf(":made_up_%d:", i))
+		}
+		counter.inc(":ghost:")
+
+		if labels := testutil.CollectAndCount(vec); labels != 3 {
+			t.Fatalf("Expected [3] emoji labels, got [%d]", labels)
+		}
+		for emoji, expected := range map[string]float64{":joy:": 2, ":ghost:": 2, OtherEmojiLabel: 10} {
+			if votes := testutil.ToFloat64(vec.With(prometheus.Labels{"emoji": emoji})); votes != expected {
+				t.Fatalf("Expected [%v] votes for [%s], got [%v]", expected, emoji, votes)
+			}
+		}
+	})
+}
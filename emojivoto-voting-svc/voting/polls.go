@@ -0,0 +1,917 @@
+package voting
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPollID names the poll that always exists and that requests without
+// a poll ID are sent to.
+const DefaultPollID = "default"
+
+var (
+	ErrPollNotFound      = errors.New("poll not found")
+	ErrPollExists        = errors.New("poll already exists")
+	ErrPollNotOpen       = errors.New("poll is not open")
+	ErrInvalidTransition = errors.New("invalid poll state transition")
+	ErrNotOnBallot       = errors.New("choice is not on the ballot")
+	ErrInvalidPoll       = errors.New("invalid poll")
+	ErrNoVote            = errors.New("no vote to retract")
+	ErrEmptyFilter       = errors.New("retraction filter matches every vote")
+	ErrInvalidHistory    = errors.New("invalid vote history request")
+	ErrWrongBallot       = errors.New("wrong kind of ballot for poll")
+	ErrInvalidBallot     = errors.New("invalid ballot")
+	ErrStorage           = errors.New("poll storage failed")
+)
+
+// BallotMode is how votes in a poll are cast and counted.
+type BallotMode string
+
+const (
+	// BallotPlurality polls take a single choice per vote, and the choice
+	// with the most votes wins.
+	BallotPlurality BallotMode = "plurality"
+	// BallotRanked polls take a ranking of choices per vote, counted by
+	// instant runoff.
+	BallotRanked BallotMode = "ranked"
+	// BallotApproval polls take a set of approved choices per vote, up to
+	// the poll's MaxChoices, and the choice approved the most wins.
+	BallotApproval BallotMode = "approval"
+)
+
+// BallotRules say how a new poll is voted in. An empty Mode means plurality.
+// MaxChoices limits how many choices an approval ballot can pick, where zero
+// means any number.
+type BallotRules struct {
+	Mode       BallotMode
+	MaxChoices int
+}
+
+// PollState is where a poll is in its lifecycle. Polls only ever move
+// forward: draft, open, closed, archived.
+type PollState string
+
+const (
+	// PollDraft polls don't accept votes yet.
+	PollDraft PollState = "draft"
+	// PollOpen polls accept votes.
+	PollOpen PollState = "open"
+	// PollClosed polls have frozen results and don't accept votes.
+	PollClosed PollState = "closed"
+	// PollArchived polls are closed polls hidden from the list of polls.
+	PollArchived PollState = "archived"
+)
+
+var pollIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// PollInfo describes a poll. Ballot lists the shortcodes that can be voted
+// for, in the order they should be shown. A draft poll with StartsAt set
+// opens at that time, and an open poll with EndsAt set closes at that time.
+// FinalResults are the results frozen when the poll closed, as the poll's
+// storage counts them, and FinalBallots the ballots of ranked and approval
+// polls.
+type PollInfo struct {
+	ID           string         `json:"id"`
+	Title        string         `json:"title"`
+	Ballot       []string       `json:"ballot"`
+	Mode         BallotMode     `json:"mode"`
+	MaxChoices   int            `json:"max_choices,omitempty"`
+	State        PollState      `json:"state"`
+	StartsAt     time.Time      `json:"starts_at"`
+	EndsAt       time.Time      `json:"ends_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	FinalResults []*Result      `json:"final_results,omitempty"`
+	FinalBallots []*BallotCount `json:"final_ballots,omitempty"`
+}
+
+// StateAt is the state of the poll at the given time, taking its schedule
+// into account.
+func (info *PollInfo) StateAt(now time.Time) PollState {
+	state := info.State
+	if state == PollDraft && !info.StartsAt.IsZero() && !now.Before(info.StartsAt) {
+		state = PollOpen
+	}
+	if state == PollOpen && !info.EndsAt.IsZero() && !now.Before(info.EndsAt) {
+		state = PollClosed
+	}
+	return state
+}
+
+func (info *PollInfo) onBallot(choice string) bool {
+	for _, shortcode := range info.Ballot {
+		if shortcode == choice {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChoices checks the choices of a ballot are on the poll's ballot, with
+// at least one and none picked twice.
+func (info *PollInfo) checkChoices(choices []string) error {
+	if len(choices) == 0 {
+		return fmt.Errorf("%w: a ballot needs at least one choice", ErrInvalidBallot)
+	}
+	seen := make(map[string]bool, len(choices))
+	for _, choice := range choices {
+		if !info.onBallot(choice) {
+			return fmt.Errorf("%w: [%s] in poll [%s]", ErrNotOnBallot, choice, info.ID)
+		}
+		if seen[choice] {
+			return fmt.Errorf("%w: [%s] is picked twice", ErrInvalidBallot, choice)
+		}
+		seen[choice] = true
+	}
+	return nil
+}
+
+func (info *PollInfo) copy() *PollInfo {
+	c := *info
+	c.Ballot = append([]string(nil), info.Ballot...)
+	c.FinalResults = copyResults(info.FinalResults)
+	c.FinalBallots = copyBallots(info.FinalBallots)
+	return &c
+}
+
+func copyResults(results []*Result) []*Result {
+	if results == nil {
+		return nil
+	}
+	c := make([]*Result, len(results))
+	values := make([]Result, len(results))
+	for i, r := range results {
+		values[i] = *r
+		c[i] = &values[i]
+	}
+	return c
+}
+
+func copyBallots(ballots []*BallotCount) []*BallotCount {
+	if ballots == nil {
+		return nil
+	}
+	c := make([]*BallotCount, len(ballots))
+	for i, b := range ballots {
+		ballot := *b
+		ballot.Choices = append([]string(nil), b.Choices...)
+		c[i] = &ballot
+	}
+	return c
+}
+
+// Schedule says when a new poll opens and closes. A zero StartsAt opens the
+// poll straight away, unless Draft is set, and a zero EndsAt leaves it open
+// until it is closed explicitly.
+type Schedule struct {
+	StartsAt time.Time
+	EndsAt   time.Time
+	Draft    bool
+}
+
+// Store keeps the votes and the definitions of every poll, and the brackets
+// of tournaments. Open opens the votes of a poll, which are counted as its
+// ballot mode says.
+type Store interface {
+	Open(info *PollInfo) (Poll, error)
+	SavePoll(info *PollInfo) error
+	LoadPolls() ([]*PollInfo, error)
+	SaveTournament(t *Tournament) error
+	LoadTournaments() ([]*Tournament, error)
+}
+
+type namedPoll struct {
+	info    *PollInfo
+	poll    Poll
+	history *History
+	changes notifier
+	// ranking ranks its leaderboard.
+	ranking ranking
+}
+
+// Polls is the set of polls served by the voting service.
+type Polls struct {
+	sync.RWMutex
+	polls            map[string]*namedPoll
+	store            Store
+	defaultBallot    []string
+	historyMinutes   time.Duration
+	historyRetention time.Duration
+	weights          *WeightPolicy
+	tiebreak         Tiebreak
+	trendingHalfLife time.Duration
+	now              func() time.Time
+}
+
+// SetHistoryRetention changes how long the vote history of every poll keeps
+// per-minute and per-hour counts.
+func (ps *Polls) SetHistoryRetention(minutes, retention time.Duration) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	ps.historyMinutes, ps.historyRetention = minutes, retention
+	for _, p := range ps.polls {
+		p.history.SetRetention(minutes, retention)
+	}
+}
+
+// SetWeightPolicy changes how much the votes cast from now on count. A nil
+// policy counts every vote once.
+func (ps *Polls) SetWeightPolicy(policy *WeightPolicy) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	ps.weights = policy
+}
+
+// SetTiebreak changes how choices with as many votes are ordered in the
+// results of every poll.
+func (ps *Polls) SetTiebreak(tiebreak Tiebreak) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	ps.tiebreak = tiebreak
+	for _, p := range ps.polls {
+		ps.setRanking(p)
+	}
+}
+
+// SetTrendingHalfLife changes the half-life of votes on trending
+// leaderboards that don't ask for one.
+func (ps *Polls) SetTrendingHalfLife(halfLife time.Duration) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	ps.trendingHalfLife = halfLife
+}
+
+// Create adds a new poll. An empty id is derived from the title and an empty
+// ballot means every shortcode on the default ballot.
+func (ps *Polls) Create(id, title string, ballot []string, rules BallotRules, schedule Schedule) (*PollInfo, error) {
+	if id == "" {
+		id = slugify(title)
+	}
+	if !pollIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: id [%s] must be lowercase letters, digits and dashes", ErrInvalidPoll, id)
+	}
+	if title == "" {
+		title = id
+	}
+	ballot, err := ps.checkBallot(ballot)
+	if err != nil {
+		return nil, err
+	}
+	switch rules.Mode {
+	case "":
+		rules.Mode = BallotPlurality
+	case BallotPlurality, BallotRanked, BallotApproval:
+	default:
+		return nil, fmt.Errorf("%w: unknown ballot mode [%s]", ErrInvalidPoll, rules.Mode)
+	}
+	if rules.MaxChoices < 0 || (rules.MaxChoices > 0 && rules.Mode != BallotApproval) {
+		return nil, fmt.Errorf("%w: [%s] polls can't pick up to [%d] choices", ErrInvalidPoll, rules.Mode, rules.MaxChoices)
+	}
+
+	now := ps.now()
+	if !schedule.EndsAt.IsZero() {
+		if !schedule.EndsAt.After(now) {
+			return nil, fmt.Errorf("%w: end time [%v] has already passed", ErrInvalidPoll, schedule.EndsAt)
+		}
+		if !schedule.StartsAt.IsZero() && !schedule.EndsAt.After(schedule.StartsAt) {
+			return nil, fmt.Errorf("%w: end time [%v] is not after start time [%v]", ErrInvalidPoll, schedule.EndsAt, schedule.StartsAt)
+		}
+	}
+	state := PollOpen
+	if schedule.Draft || schedule.StartsAt.After(now) {
+		state = PollDraft
+	}
+
+	ps.Lock()
+	defer ps.Unlock()
+
+	if _, ok := ps.polls[id]; ok {
+		return nil, fmt.Errorf("%w: [%s]", ErrPollExists, id)
+	}
+	info := &PollInfo{
+		ID:         id,
+		Title:      title,
+		Ballot:     ballot,
+		Mode:       rules.Mode,
+		MaxChoices: rules.MaxChoices,
+		State:      state,
+		StartsAt:   schedule.StartsAt,
+		EndsAt:     schedule.EndsAt,
+		CreatedAt:  now,
+	}
+	if err := ps.add(info); err != nil {
+		return nil, err
+	}
+	log.Printf("Created [%s] [%s] poll [%s] with [%d] choices", state, rules.Mode, id, len(ballot))
+	return info.copy(), nil
+}
+
+func (ps *Polls) checkBallot(ballot []string) ([]string, error) {
+	if len(ballot) == 0 {
+		return append([]string(nil), ps.defaultBallot...), nil
+	}
+	all := &PollInfo{Ballot: ps.defaultBallot}
+	seen := make(map[string]bool, len(ballot))
+	for _, choice := range ballot {
+		if !all.onBallot(choice) {
+			return nil, fmt.Errorf("%w: unknown shortcode [%s]", ErrInvalidPoll, choice)
+		}
+		if seen[choice] {
+			return nil, fmt.Errorf("%w: shortcode [%s] is listed twice", ErrInvalidPoll, choice)
+		}
+		seen[choice] = true
+	}
+	return append([]string(nil), ballot...), nil
+}
+
+// add opens the storage for a poll and registers it. Callers must hold the
+// write lock.
+func (ps *Polls) add(info *PollInfo) error {
+	poll, err := ps.store.Open(info)
+	if err != nil {
+		return err
+	}
+	if err := ps.store.SavePoll(info); err != nil {
+		return err
+	}
+	shards := 1
+	h, ok := poll.(historian)
+	if ok {
+		shards = h.historyShards()
+	}
+	history := newHistory(shards, ps.historyMinutes, ps.historyRetention)
+	history.now = func() time.Time { return ps.now() }
+	if ok {
+		h.setHistory(history, history.now)
+	}
+	p := &namedPoll{info: info, poll: poll, history: history}
+	ps.setRanking(p)
+	ps.polls[info.ID] = p
+	return nil
+}
+
+// setRanking has the leaderboard of a poll ranked by the tiebreak. Callers
+// must hold the write lock.
+func (ps *Polls) setRanking(p *namedPoll) {
+	p.ranking = newRanking(ps.tiebreak, p.info.Ballot)
+	if r, ok := p.poll.(ranker); ok {
+		r.setRanking(ps.tiebreak, p.info.Ballot)
+	}
+}
+
+func (ps *Polls) get(id string) (*namedPoll, error) {
+	if id == "" {
+		id = DefaultPollID
+	}
+	p, ok := ps.polls[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: [%s]", ErrPollNotFound, id)
+	}
+	return p, nil
+}
+
+// settle applies the scheduled transitions of a poll that are due. Callers
+// must hold the write lock.
+func (ps *Polls) settle(p *namedPoll) error {
+	now := ps.now()
+	switch p.info.StateAt(now) {
+	case p.info.State:
+		return nil
+	case PollOpen:
+		return ps.transition(p, PollOpen, now)
+	default:
+		return ps.transition(p, PollClosed, now)
+	}
+}
+
+// settleDue settles the poll with the given ID if any of its transitions are
+// due, so readers only take the write lock when they have to.
+func (ps *Polls) settleDue(id string) error {
+	ps.RLock()
+	p, err := ps.get(id)
+	due := err == nil && p.info.StateAt(ps.now()) != p.info.State
+	ps.RUnlock()
+	if err != nil || !due {
+		return err
+	}
+
+	ps.Lock()
+	defer ps.Unlock()
+	return ps.settle(p)
+}
+
+// transition moves a poll to the given state as of now, freezing its results
+// when it closes. Callers must hold the write lock.
+func (ps *Polls) transition(p *namedPoll, to PollState, now time.Time) error {
+	updated := p.info.copy()
+	updated.State = to
+
+	switch to {
+	case PollOpen:
+		if updated.StartsAt.IsZero() || updated.StartsAt.After(now) {
+			updated.StartsAt = now
+		}
+	case PollClosed:
+		if updated.EndsAt.IsZero() || updated.EndsAt.After(now) {
+			updated.EndsAt = now
+		}
+		results, err := p.poll.Results()
+		if err != nil {
+			return err
+		}
+		updated.FinalResults = results
+		if updated.Mode != BallotPlurality {
+			ballots, err := p.poll.Ballots()
+			if err != nil {
+				return err
+			}
+			updated.FinalBallots = ballots
+		}
+	}
+
+	if err := ps.store.SavePoll(updated); err != nil {
+		return err
+	}
+	p.info = updated
+	p.changes.notify()
+	log.Printf("Poll [%s] is now [%s]", p.info.ID, p.info.State)
+	return nil
+}
+
+// move settles a poll and then moves it from one state to the next. Moving
+// a poll to the state it is already in does nothing. The default poll stays
+// open, as requests without a poll ID vote in it.
+func (ps *Polls) move(id string, from, to PollState) (*PollInfo, error) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	p, err := ps.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.info.ID == DefaultPollID && to != PollOpen {
+		return nil, fmt.Errorf("%w: the default poll can't be [%s]", ErrInvalidTransition, to)
+	}
+	if err := ps.settle(p); err != nil {
+		return nil, err
+	}
+
+	switch p.info.State {
+	case to:
+	case from:
+		if err := ps.transition(p, to, ps.now()); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: poll [%s] is [%s], not [%s]", ErrInvalidTransition, p.info.ID, p.info.State, from)
+	}
+	return p.info.copy(), nil
+}
+
+// Get describes the poll with the given ID. An empty ID means the default poll.
+func (ps *Polls) Get(id string) (*PollInfo, error) {
+	if err := ps.settleDue(id); err != nil {
+		return nil, err
+	}
+
+	ps.RLock()
+	defer ps.RUnlock()
+
+	p, err := ps.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return p.info.copy(), nil
+}
+
+// List describes every poll, oldest first. Archived polls are only included
+// when asked for.
+func (ps *Polls) List(includeArchived bool) []*PollInfo {
+	ps.Lock()
+	defer ps.Unlock()
+
+	infos := make([]*PollInfo, 0, len(ps.polls))
+	for _, p := range ps.polls {
+		if err := ps.settle(p); err != nil {
+			log.Printf("Failed to settle poll [%s]: %v", p.info.ID, err)
+		}
+		if p.info.State == PollArchived && !includeArchived {
+			continue
+		}
+		infos = append(infos, p.info.copy())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].CreatedAt.Equal(infos[j].CreatedAt) {
+			return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// Open starts a draft poll ahead of its schedule.
+func (ps *Polls) Open(id string) (*PollInfo, error) {
+	return ps.move(id, PollDraft, PollOpen)
+}
+
+// Close stops an open poll from accepting votes and freezes its results.
+func (ps *Polls) Close(id string) (*PollInfo, error) {
+	return ps.move(id, PollOpen, PollClosed)
+}
+
+// Archive hides a closed poll from the list of polls.
+func (ps *Polls) Archive(id string) (*PollInfo, error) {
+	return ps.move(id, PollClosed, PollArchived)
+}
+
+// Vote records a vote in the given poll, checking it is open and that the
+// choice is on its ballot.
+func (ps *Polls) Vote(id string, voter Voter, choice string) error {
+	ps.RLock()
+	defer ps.RUnlock()
+
+	p, err := ps.openPoll(id)
+	if err != nil {
+		return err
+	}
+	if p.info.Mode != BallotPlurality {
+		return fmt.Errorf("%w: poll [%s] takes [%s] ballots", ErrWrongBallot, p.info.ID, p.info.Mode)
+	}
+	if !p.info.onBallot(choice) {
+		return fmt.Errorf("%w: [%s] in poll [%s]", ErrNotOnBallot, choice, p.info.ID)
+	}
+	if err := p.poll.VoteAs(voter, Ballot{Choices: []string{choice}, Weight: ps.weights.Weight(voter)}); err != nil {
+		return err
+	}
+	p.changes.notify()
+	return nil
+}
+
+// VoteRanked records a ranked ballot, most preferred choice first, in the
+// given ranked poll. Every choice must be on the poll's ballot, and can only
+// be ranked once.
+func (ps *Polls) VoteRanked(id string, voter Voter, ranking []string) error {
+	ps.RLock()
+	defer ps.RUnlock()
+
+	p, err := ps.openPoll(id)
+	if err != nil {
+		return err
+	}
+	if p.info.Mode != BallotRanked {
+		return fmt.Errorf("%w: poll [%s] takes [%s] ballots", ErrWrongBallot, p.info.ID, p.info.Mode)
+	}
+	if err := p.info.checkChoices(ranking); err != nil {
+		return err
+	}
+	if err := p.poll.VoteAs(voter, Ballot{Choices: append([]string(nil), ranking...), Weight: ps.weights.Weight(voter)}); err != nil {
+		return err
+	}
+	p.changes.notify()
+	return nil
+}
+
+// VoteApproval records an approval ballot in the given approval poll. Every
+// choice must be on the poll's ballot, and can only be picked once, up to
+// the poll's MaxChoices.
+func (ps *Polls) VoteApproval(id string, voter Voter, choices []string) error {
+	ps.RLock()
+	defer ps.RUnlock()
+
+	p, err := ps.openPoll(id)
+	if err != nil {
+		return err
+	}
+	if p.info.Mode != BallotApproval {
+		return fmt.Errorf("%w: poll [%s] takes [%s] ballots", ErrWrongBallot, p.info.ID, p.info.Mode)
+	}
+	if max := p.info.MaxChoices; max > 0 && len(choices) > max {
+		return fmt.Errorf("%w: [%d] choices picked, poll [%s] allows up to [%d]", ErrInvalidBallot, len(choices), p.info.ID, max)
+	}
+	if err := p.info.checkChoices(choices); err != nil {
+		return err
+	}
+	if err := p.poll.VoteAs(voter, Ballot{Choices: approvalChoices(choices), Weight: ps.weights.Weight(voter)}); err != nil {
+		return err
+	}
+	p.changes.notify()
+	return nil
+}
+
+// Retract takes back a vote in the given poll, which must be open. See
+// Poll.Retract. The choices of an approval ballot can be given in any order.
+func (ps *Polls) Retract(id string, voter Voter, choices []string) error {
+	ps.RLock()
+	defer ps.RUnlock()
+
+	p, err := ps.openPoll(id)
+	if err != nil {
+		return err
+	}
+	if len(choices) == 0 && voter.ID == "" {
+		return fmt.Errorf("%w: a vote cast without a voter ID is retracted by its choices", ErrInvalidBallot)
+	}
+	if p.info.Mode == BallotApproval {
+		choices = approvalChoices(choices)
+	}
+	if err := p.poll.Retract(voter, choices); err != nil {
+		return err
+	}
+	p.changes.notify()
+	return nil
+}
+
+// RetractVotes takes back every vote in the given poll matching filter, for
+// cleaning up after misbehaving clients. The poll must be open, and the
+// filter can't be empty.
+func (ps *Polls) RetractVotes(id string, filter RetractFilter) (int, error) {
+	if filter.IsEmpty() {
+		return 0, ErrEmptyFilter
+	}
+
+	ps.RLock()
+	defer ps.RUnlock()
+
+	p, err := ps.openPoll(id)
+	if err != nil {
+		return 0, err
+	}
+	retracted, err := p.poll.RetractVotes(filter)
+	if retracted > 0 {
+		p.changes.notify()
+	}
+	return retracted, err
+}
+
+// Watch returns a channel that receives a value after the results of the
+// given poll change. Changes made while the last value is still waiting to
+// be received don't add another. Call stop to stop watching.
+func (ps *Polls) Watch(id string) (changed <-chan struct{}, stop func(), err error) {
+	ps.RLock()
+	defer ps.RUnlock()
+
+	p, err := ps.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	changed, stop = p.changes.watch()
+	return changed, stop, nil
+}
+
+// openPoll finds a poll and checks it is open. Callers must hold a lock.
+func (ps *Polls) openPoll(id string) (*namedPoll, error) {
+	p, err := ps.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if state := p.info.StateAt(ps.now()); state != PollOpen {
+		return nil, fmt.Errorf("%w: poll [%s] is [%s]", ErrPollNotOpen, p.info.ID, state)
+	}
+	return p, nil
+}
+
+// Results returns the live results of an open poll, or the results frozen
+// when it closed. The results of ranked polls count first preferences, and
+// those of approval polls count approvals.
+func (ps *Polls) Results(id string) ([]*Result, error) {
+	results, _, err := ps.Count(id)
+	return results, err
+}
+
+// Count returns the results of a poll, as Results does, along with the
+// number of ballots cast, which is less than the number of votes counted
+// when approval ballots pick more than one choice. Results count every vote
+// once in NumVotes and by its weight in Weighted, and are ranked by votes,
+// with ties broken by the tiebreak. Their shares are of the ballots cast.
+func (ps *Polls) Count(id string) ([]*Result, int, error) {
+	info, results, err := ps.results(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	ballots := 0
+	if info.Mode == BallotApproval {
+		_, cast, err := ps.ballots(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, b := range cast {
+			ballots += b.NumVotes
+		}
+	} else {
+		for _, r := range results {
+			ballots += r.NumVotes
+		}
+	}
+	ps.rank(info, results)
+	share(results, ballots)
+	return results, ballots, nil
+}
+
+// rank orders the results of a poll for its leaderboard. See rank.
+func (ps *Polls) rank(info *PollInfo, results []*Result) {
+	ps.RLock()
+	p, err := ps.get(info.ID)
+	ps.RUnlock()
+	if err != nil {
+		return
+	}
+
+	rank(results, p.ranking)
+}
+
+// RankedResults counts the ballots of a ranked poll by instant runoff.
+func (ps *Polls) RankedResults(id string) (*RunoffResult, error) {
+	info, ballots, err := ps.ballots(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode != BallotRanked {
+		return nil, fmt.Errorf("%w: poll [%s] takes [%s] ballots", ErrWrongBallot, info.ID, info.Mode)
+	}
+	return InstantRunoff(info.Ballot, rankedBallots(ballots)), nil
+}
+
+// ballots returns a poll and the ballots cast in it.
+func (ps *Polls) ballots(id string) (*PollInfo, []*BallotCount, error) {
+	if err := ps.settleDue(id); err != nil {
+		return nil, nil, err
+	}
+
+	ps.RLock()
+	defer ps.RUnlock()
+
+	p, err := ps.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.info.State == PollClosed || p.info.State == PollArchived {
+		return p.info, copyBallots(p.info.FinalBallots), nil
+	}
+	ballots, err := p.poll.Ballots()
+	return p.info, ballots, err
+}
+
+// results returns a poll and its results as its storage counts them.
+func (ps *Polls) results(id string) (*PollInfo, []*Result, error) {
+	if err := ps.settleDue(id); err != nil {
+		return nil, nil, err
+	}
+
+	ps.RLock()
+	defer ps.RUnlock()
+
+	p, err := ps.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.info.State == PollClosed || p.info.State == PollArchived {
+		return p.info, copyResults(p.info.FinalResults), nil
+	}
+	results, err := p.poll.Results()
+	return p.info, results, err
+}
+
+// History returns the votes cast in the given poll over time. See
+// History.Range. Votes are counted when they were cast for as long as they
+// count, so moved and retracted votes are taken back out. History starts
+// afresh when the service restarts.
+func (ps *Polls) History(id string, shortcodes []string, from, to time.Time, step time.Duration) (*HistoryRange, error) {
+	ps.RLock()
+	p, err := ps.get(id)
+	ps.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return p.history.Range(shortcodes, from, to, step)
+}
+
+// Trending ranks the choices of the given poll by the votes recently cast
+// for them. See History.Trending. A zero half-life means the default one,
+// and a zero window the half-life. Like history, trends leave out moved and
+// retracted votes, and start afresh when the service restarts.
+func (ps *Polls) Trending(id string, halfLife, window time.Duration) (*Trends, error) {
+	ps.RLock()
+	p, err := ps.get(id)
+	if halfLife == 0 {
+		halfLife = ps.trendingHalfLife
+	}
+	ps.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if window == 0 {
+		window = halfLife
+	}
+
+	trends, err := p.history.Trending(halfLife, window)
+	if err != nil {
+		return nil, err
+	}
+	results, err := ps.Results(id)
+	if err != nil {
+		return nil, err
+	}
+	numVotes := make(map[string]int, len(results))
+	for _, r := range results {
+		numVotes[r.Shortcode] = r.NumVotes
+	}
+	for _, t := range trends.Trends {
+		t.NumVotes = numVotes[t.Shortcode]
+	}
+	return trends, nil
+}
+
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case b.Len() > 0 && !dash:
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// NewPolls loads the polls kept in store and makes sure the default poll
+// exists. defaultBallot is the ballot of the default poll, and the set of
+// shortcodes other polls can pick from.
+func NewPolls(store Store, defaultBallot []string) (*Polls, error) {
+	ps := &Polls{
+		polls:            make(map[string]*namedPoll),
+		store:            store,
+		defaultBallot:    append([]string(nil), defaultBallot...),
+		historyMinutes:   DefaultHistoryMinutes,
+		historyRetention: DefaultHistoryRetention,
+		tiebreak:         TiebreakFirst,
+		trendingHalfLife: DefaultTrendingHalfLife,
+		now:              time.Now,
+	}
+
+	infos, err := store.LoadPolls()
+	if err != nil {
+		return nil, fmt.Errorf("loading polls: %v", err)
+	}
+	for _, info := range infos {
+		if info.State == "" {
+			info.State = PollOpen
+		}
+		if info.Mode == "" {
+			info.Mode = BallotPlurality
+		}
+		if info.ID == DefaultPollID {
+			// The default ballot follows the catalog the service starts with,
+			// and the default poll is always open, even one an older version
+			// let be closed.
+			info.Ballot = ps.defaultBallot
+			if info.State != PollOpen {
+				info.State, info.EndsAt, info.FinalResults, info.FinalBallots = PollOpen, time.Time{}, nil, nil
+			}
+		}
+		if err := ps.add(info); err != nil {
+			return nil, fmt.Errorf("opening poll [%s]: %v", info.ID, err)
+		}
+	}
+
+	if _, ok := ps.polls[DefaultPollID]; !ok {
+		info := &PollInfo{
+			ID:     DefaultPollID,
+			Title:  "Emoji Vote",
+			Ballot: ps.defaultBallot,
+			Mode:   BallotPlurality,
+			State:  PollOpen,
+		}
+		if err := ps.add(info); err != nil {
+			return nil, fmt.Errorf("opening default poll: %v", err)
+		}
+	}
+	return ps, nil
+}
+
+type memoryStore struct{}
+
+func (memoryStore) Open(info *PollInfo) (Poll, error) {
+	return newStripedPoll(DefaultStripes, info.Mode), nil
+}
+func (memoryStore) SavePoll(*PollInfo) error                { return nil }
+func (memoryStore) LoadPolls() ([]*PollInfo, error)         { return nil, nil }
+func (memoryStore) SaveTournament(*Tournament) error        { return nil }
+func (memoryStore) LoadTournaments() ([]*Tournament, error) { return nil, nil }
+
+// NewMemoryStore keeps every poll in memory, striped for concurrent voters.
+func NewMemoryStore() Store {
+	return memoryStore{}
+}
@@ -0,0 +1,571 @@
+package voting
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testBallot = []string{":joy:", ":ghost:", ":pizza:", ":taco:"}
+
+func TestPolls(t *testing.T) {
+	t.Run("Always has a default poll", func(t *testing.T) {
+		polls, err := NewPolls(NewMemoryStore(), testBallot)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := polls.Get("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ID != DefaultPollID || len(info.Ballot) != len(testBallot) {
+			t.Fatalf("Expected the default poll with the whole ballot, got [%v]", info)
+		}
+	})
+
+	t.Run("Validates new polls", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+
+		if _, err := polls.Create("Not/Valid", "", nil, BallotRules{}, Schedule{}); !errors.Is(err, ErrInvalidPoll) {
+			t.Fatalf("Expected an invalid poll ID to be rejected, got [%v]", err)
+		}
+		if _, err := polls.Create("lunch", "", []string{":unknown:"}, BallotRules{}, Schedule{}); !errors.Is(err, ErrInvalidPoll) {
+			t.Fatalf("Expected an unknown shortcode to be rejected, got [%v]", err)
+		}
+		if _, err := polls.Create("lunch", "", []string{":taco:", ":taco:"}, BallotRules{}, Schedule{}); !errors.Is(err, ErrInvalidPoll) {
+			t.Fatalf("Expected a duplicate shortcode to be rejected, got [%v]", err)
+		}
+		if _, err := polls.Create("lunch", "", nil, BallotRules{}, Schedule{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := polls.Create("lunch", "", nil, BallotRules{}, Schedule{}); !errors.Is(err, ErrPollExists) {
+			t.Fatalf("Expected a duplicate poll to be rejected, got [%v]", err)
+		}
+	})
+
+	t.Run("Derives IDs from titles", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+
+		info, err := polls.Create("", "Team Mascot!", nil, BallotRules{}, Schedule{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ID != "team-mascot" {
+			t.Fatalf("Expected ID [team-mascot], got [%s]", info.ID)
+		}
+	})
+
+	t.Run("Stops accepting votes once closed", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		polls.Create("lunch", "Lunch", []string{":pizza:", ":taco:"}, BallotRules{}, Schedule{})
+
+		if err := polls.Vote("lunch", Voter{}, ":pizza:"); err != nil {
+			t.Fatal(err)
+		}
+		if err := polls.Vote("lunch", Voter{}, ":joy:"); !errors.Is(err, ErrNotOnBallot) {
+			t.Fatalf("Expected a vote off the ballot to be rejected, got [%v]", err)
+		}
+
+		polls.Close("lunch")
+		if err := polls.Vote("lunch", Voter{}, ":pizza:"); !errors.Is(err, ErrPollNotOpen) {
+			t.Fatalf("Expected a vote in a closed poll to be rejected, got [%v]", err)
+		}
+		if err := polls.Retract("lunch", Voter{}, []string{":pizza:"}); !errors.Is(err, ErrPollNotOpen) {
+			t.Fatalf("Expected a retraction in a closed poll to be rejected, got [%v]", err)
+		}
+
+		results, _ := polls.Results("lunch")
+		if len(results) != 1 || results[0].NumVotes != 1 {
+			t.Fatalf("Expected closed poll to keep its results, got [%v]", results)
+		}
+	})
+
+	t.Run("Keeps a vote history per poll", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		polls.Create("lunch", "", nil, BallotRules{}, Schedule{})
+		polls.Vote("lunch", Voter{}, ":taco:")
+		polls.Vote("", Voter{}, ":joy:")
+
+		h, err := polls.History("lunch", nil, time.Now().Add(-time.Hour), time.Time{}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(h.Series) != 1 || h.Series[0].Shortcode != ":taco:" || h.Series[0].Counts[len(h.Series[0].Counts)-1] != 1 {
+			t.Fatalf("Expected a single vote for :taco: in the last minute, got [%v]", h.Series)
+		}
+	})
+
+	t.Run("Wakes up watchers once for a burst of votes", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		changed, stop, err := polls.Watch("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+
+		for i := 0; i < 3; i++ {
+			polls.Vote("", Voter{}, ":joy:")
+		}
+		select {
+		case <-changed:
+		default:
+			t.Fatal("Expected watchers to be woken up by a vote")
+		}
+		select {
+		case <-changed:
+			t.Fatal("Expected a burst of votes to wake watchers up once")
+		default:
+		}
+
+		if _, _, err := polls.Watch("dinner"); !errors.Is(err, ErrPollNotFound) {
+			t.Fatalf("Expected watching an unknown poll to fail, got [%v]", err)
+		}
+	})
+
+	t.Run("Refuses to retract every vote at once", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+
+		if _, err := polls.RetractVotes("", RetractFilter{}); !errors.Is(err, ErrEmptyFilter) {
+			t.Fatalf("Expected an empty filter to be rejected, got [%v]", err)
+		}
+	})
+
+	t.Run("Takes ranked ballots in ranked polls", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		if _, err := polls.Create("mascot", "", nil, BallotRules{Mode: "borda"}, Schedule{}); !errors.Is(err, ErrInvalidPoll) {
+			t.Fatalf("Expected an unknown mode to be rejected, got [%v]", err)
+		}
+		info, _ := polls.Create("mascot", "", []string{":joy:", ":ghost:", ":taco:"}, BallotRules{Mode: BallotRanked}, Schedule{})
+		if info.Mode != BallotRanked {
+			t.Fatalf("Expected a ranked poll, got [%v]", info.Mode)
+		}
+
+		if err := polls.Vote("mascot", Voter{}, ":joy:"); !errors.Is(err, ErrWrongBallot) {
+			t.Fatalf("Expected a single choice to be rejected, got [%v]", err)
+		}
+		if err := polls.VoteRanked("", Voter{}, []string{":joy:"}); !errors.Is(err, ErrWrongBallot) {
+			t.Fatalf("Expected a ranking in the default poll to be rejected, got [%v]", err)
+		}
+		if err := polls.VoteRanked("mascot", Voter{}, nil); !errors.Is(err, ErrInvalidBallot) {
+			t.Fatalf("Expected an empty ranking to be rejected, got [%v]", err)
+		}
+		if err := polls.VoteRanked("mascot", Voter{}, []string{":joy:", ":joy:"}); !errors.Is(err, ErrInvalidBallot) {
+			t.Fatalf("Expected a choice ranked twice to be rejected, got [%v]", err)
+		}
+		if err := polls.VoteRanked("mascot", Voter{}, []string{":joy:", ":pizza:"}); !errors.Is(err, ErrNotOnBallot) {
+			t.Fatalf("Expected a choice off the ballot to be rejected, got [%v]", err)
+		}
+
+		polls.VoteRanked("mascot", Voter{}, []string{":joy:", ":taco:"})
+		polls.VoteRanked("mascot", Voter{}, []string{":ghost:"})
+		polls.VoteRanked("mascot", Voter{ID: "alice"}, []string{":joy:"})
+		polls.VoteRanked("mascot", Voter{ID: "alice"}, []string{":taco:", ":ghost:"})
+		polls.VoteRanked("mascot", Voter{}, []string{":taco:", ":ghost:"})
+
+		results, _ := polls.Results("mascot")
+		if len(results) != 3 || results[0].Shortcode != ":taco:" || results[0].NumVotes != 2 {
+			t.Fatalf("Expected first preferences led by [:taco:], got [%v]", results)
+		}
+		polls.Close("mascot")
+		runoff, err := polls.RankedResults("mascot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runoff.Ballots != 4 || runoff.Winner != ":taco:" || len(runoff.Rounds) != 2 {
+			t.Fatalf("Expected [:taco:] to win the second round, got [%v]", runoff)
+		}
+		if _, err := polls.RankedResults(""); !errors.Is(err, ErrWrongBallot) {
+			t.Fatalf("Expected no runoff for the default poll, got [%v]", err)
+		}
+	})
+
+	t.Run("Takes approval ballots up to the poll's limit", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		if _, err := polls.Create("lunch", "", nil, BallotRules{Mode: BallotRanked, MaxChoices: 2}, Schedule{}); !errors.Is(err, ErrInvalidPoll) {
+			t.Fatalf("Expected a limit on a ranked poll to be rejected, got [%v]", err)
+		}
+		if _, err := polls.Create("lunch", "", nil, BallotRules{Mode: BallotApproval, MaxChoices: -1}, Schedule{}); !errors.Is(err, ErrInvalidPoll) {
+			t.Fatalf("Expected a negative limit to be rejected, got [%v]", err)
+		}
+		info, _ := polls.Create("lunch", "", nil, BallotRules{Mode: BallotApproval, MaxChoices: 2}, Schedule{})
+		if info.Mode != BallotApproval || info.MaxChoices != 2 {
+			t.Fatalf("Expected an approval poll picking up to 2 choices, got [%v]", info)
+		}
+
+		if err := polls.VoteApproval("lunch", Voter{}, []string{":pizza:", ":taco:", ":joy:"}); !errors.Is(err, ErrInvalidBallot) {
+			t.Fatalf("Expected too many choices to be rejected, got [%v]", err)
+		}
+		if err := polls.VoteApproval("lunch", Voter{}, []string{":pizza:", ":pizza:"}); !errors.Is(err, ErrInvalidBallot) {
+			t.Fatalf("Expected a choice picked twice to be rejected, got [%v]", err)
+		}
+		if err := polls.VoteApproval("lunch", Voter{}, nil); !errors.Is(err, ErrInvalidBallot) {
+			t.Fatalf("Expected an empty ballot to be rejected, got [%v]", err)
+		}
+		if err := polls.VoteRanked("lunch", Voter{}, []string{":pizza:"}); !errors.Is(err, ErrWrongBallot) {
+			t.Fatalf("Expected a ranking to be rejected, got [%v]", err)
+		}
+
+		polls.VoteApproval("lunch", Voter{}, []string{":pizza:", ":taco:"})
+		polls.VoteApproval("lunch", Voter{}, []string{":taco:", ":pizza:"})
+		polls.VoteApproval("lunch", Voter{ID: "alice"}, []string{":joy:"})
+		polls.VoteApproval("lunch", Voter{ID: "alice"}, []string{":taco:"})
+
+		results, ballots, err := polls.Count("lunch")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ballots != 3 {
+			t.Fatalf("Expected [3] ballots, got [%d]", ballots)
+		}
+		if len(results) != 2 || results[0].Shortcode != ":taco:" || results[0].NumVotes != 3 || results[1].NumVotes != 2 {
+			t.Fatalf("Expected [:taco:] approved [3] times and [:pizza:] [2], got [%v]", results)
+		}
+
+		unlimited, _ := polls.Create("dinner", "", nil, BallotRules{Mode: BallotApproval}, Schedule{})
+		if err := polls.VoteApproval(unlimited.ID, Voter{}, testBallot); err != nil {
+			t.Fatalf("Expected every choice to be approved without a limit, got [%v]", err)
+		}
+	})
+
+	t.Run("Weighs votes by the weight policy", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		polls.SetWeightPolicy(&WeightPolicy{Default: 1, Rules: []WeightRule{
+			{Match: map[string]string{"voter-class": "employee"}, Weight: 2.5},
+			{Match: map[string]string{"voter-user-agent": "emojivoto-vote-bot*"}, Weight: 0},
+		}})
+		employee := Voter{ID: "alice", Metadata: map[string]string{"voter-class": "employee"}}
+		bot := Voter{Metadata: map[string]string{"voter-user-agent": "emojivoto-vote-bot/1.0"}}
+
+		polls.Vote("", employee, ":taco:")
+		polls.Vote("", Voter{}, ":taco:")
+		polls.Vote("", bot, ":pizza:")
+		polls.Vote("", bot, ":pizza:")
+		polls.Vote("", bot, ":pizza:")
+
+		results, _ := polls.Results("")
+		if len(results) != 2 || results[0].Shortcode != ":pizza:" || results[0].NumVotes != 3 || results[0].Weighted != 0 {
+			t.Fatalf("Expected [3] votes weighing nothing for [:pizza:], got [%v]", results)
+		}
+		if results[1].NumVotes != 2 || results[1].Weighted != 3.5 {
+			t.Fatalf("Expected [2] votes weighing [3.5] for [:taco:], got [%v]", results[1])
+		}
+
+		// Retracting finds the vote even if its weight changed since.
+		polls.SetWeightPolicy(nil)
+		if err := polls.Retract("", employee, []string{":taco:"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := polls.Retract("", Voter{}, []string{":pizza:"}); err != nil {
+			t.Fatal(err)
+		}
+		results, _ = polls.Results("")
+		if results[0].NumVotes != 2 || results[1].NumVotes != 1 || results[1].Weighted != 1 {
+			t.Fatalf("Expected one vote each retracted, got [%v] [%v]", results[0], results[1])
+		}
+	})
+
+	t.Run("Opens and closes on schedule", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+		polls.now = func() time.Time { return now }
+
+		info, err := polls.Create("lunch", "Lunch", nil, BallotRules{}, Schedule{
+			StartsAt: now.Add(time.Hour),
+			EndsAt:   now.Add(2 * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.State != PollDraft {
+			t.Fatalf("Expected a poll starting later to be a draft, got [%s]", info.State)
+		}
+		if err := polls.Vote("lunch", Voter{}, ":taco:"); !errors.Is(err, ErrPollNotOpen) {
+			t.Fatalf("Expected a vote before the poll starts to be rejected, got [%v]", err)
+		}
+
+		now = now.Add(time.Hour)
+		if err := polls.Vote("lunch", Voter{}, ":taco:"); err != nil {
+			t.Fatalf("Expected a vote once the poll starts to be accepted, got [%v]", err)
+		}
+		if info, _ := polls.Get("lunch"); info.State != PollOpen {
+			t.Fatalf("Expected the poll to be open, got [%s]", info.State)
+		}
+
+		now = now.Add(time.Hour)
+		if err := polls.Vote("lunch", Voter{}, ":taco:"); !errors.Is(err, ErrPollNotOpen) {
+			t.Fatalf("Expected a vote after the poll ends to be rejected, got [%v]", err)
+		}
+		info, _ = polls.Get("lunch")
+		if info.State != PollClosed || len(info.FinalResults) != 1 || info.FinalResults[0].NumVotes != 1 {
+			t.Fatalf("Expected the poll to be closed with frozen results, got [%v]", info)
+		}
+	})
+
+	t.Run("Validates schedules", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+		polls.now = func() time.Time { return now }
+
+		_, err := polls.Create("lunch", "", nil, BallotRules{}, Schedule{EndsAt: now.Add(-time.Minute)})
+		if !errors.Is(err, ErrInvalidPoll) {
+			t.Fatalf("Expected an end time in the past to be rejected, got [%v]", err)
+		}
+		_, err = polls.Create("lunch", "", nil, BallotRules{}, Schedule{StartsAt: now.Add(2 * time.Hour), EndsAt: now.Add(time.Hour)})
+		if !errors.Is(err, ErrInvalidPoll) {
+			t.Fatalf("Expected an end time before the start time to be rejected, got [%v]", err)
+		}
+	})
+
+	t.Run("Only moves polls forward", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		polls.Create("lunch", "", nil, BallotRules{}, Schedule{Draft: true})
+
+		if _, err := polls.Close("lunch"); !errors.Is(err, ErrInvalidTransition) {
+			t.Fatalf("Expected closing a draft to be rejected, got [%v]", err)
+		}
+		if _, err := polls.Archive("lunch"); !errors.Is(err, ErrInvalidTransition) {
+			t.Fatalf("Expected archiving a draft to be rejected, got [%v]", err)
+		}
+		for _, step := range []func(string) (*PollInfo, error){polls.Open, polls.Open, polls.Close, polls.Close, polls.Archive} {
+			if _, err := step("lunch"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := polls.Open("lunch"); !errors.Is(err, ErrInvalidTransition) {
+			t.Fatalf("Expected reopening an archived poll to be rejected, got [%v]", err)
+		}
+		if _, err := polls.Archive(DefaultPollID); !errors.Is(err, ErrInvalidTransition) {
+			t.Fatalf("Expected archiving the default poll to be rejected, got [%v]", err)
+		}
+		if _, err := polls.Close(""); !errors.Is(err, ErrInvalidTransition) {
+			t.Fatalf("Expected closing the default poll to be rejected, got [%v]", err)
+		}
+		if err := polls.Vote("", Voter{}, ":joy:"); err != nil {
+			t.Fatalf("Expected the default poll to stay open, got [%v]", err)
+		}
+
+		if infos := polls.List(false); len(infos) != 1 || infos[0].ID != DefaultPollID {
+			t.Fatalf("Expected archived polls to be hidden, got [%v]", infos)
+		}
+		if infos := polls.List(true); len(infos) != 2 {
+			t.Fatalf("Expected archived polls to be listed when asked for, got [%v]", infos)
+		}
+	})
+}
+
+// durableStores open the stores that keep polls in dir.
+var durableStores = map[string]func(dir string) (Store, error){
+	"file": func(dir string) (Store, error) {
+		return NewFileStore(dir, 0), nil
+	},
+	"sql": func(dir string) (Store, error) {
+		return NewSQLStore("sqlite", filepath.Join(dir, "votes.db"))
+	},
+}
+
+func TestPollStores(t *testing.T) {
+	for name, newStore := range durableStores {
+		t.Run("Restores polls and their votes with the "+name+" store", func(t *testing.T) {
+			dir := tempPollDir(t)
+			defer os.RemoveAll(dir)
+
+			store, err := newStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			polls, err := NewPolls(store, testBallot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			polls.Create("lunch", "Lunch", []string{":pizza:", ":taco:"}, BallotRules{}, Schedule{})
+			polls.Vote("lunch", Voter{}, ":taco:")
+			polls.SetWeightPolicy(&WeightPolicy{Default: 0.5})
+			polls.Vote("", Voter{}, ":joy:")
+			polls.Close("lunch")
+			polls.Create("mascot", "Mascot", nil, BallotRules{Mode: BallotRanked}, Schedule{})
+			polls.VoteRanked("mascot", Voter{ID: "alice"}, []string{":ghost:", ":joy:"})
+			polls.Create("snacks", "Snacks", nil, BallotRules{Mode: BallotApproval}, Schedule{})
+			polls.VoteApproval("snacks", Voter{}, []string{":taco:", ":pizza:"})
+			polls.VoteApproval("snacks", Voter{ID: "alice"}, []string{":pizza:"})
+			polls.Close("snacks")
+
+			store, err = newStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reopened, err := NewPolls(store, testBallot)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := reopened.Get("lunch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Title != "Lunch" || info.State != PollClosed || len(info.Ballot) != 2 {
+				t.Fatalf("Expected the lunch poll to be restored, got [%v]", info)
+			}
+
+			lunch, _ := reopened.Results("lunch")
+			if len(lunch) != 1 || lunch[0].Shortcode != ":taco:" {
+				t.Fatalf("Expected lunch results to only contain [:taco:], got [%v]", lunch)
+			}
+			defaultResults, _ := reopened.Results("")
+			if len(defaultResults) != 1 || defaultResults[0].Shortcode != ":joy:" || defaultResults[0].Weighted != 0.5 {
+				t.Fatalf("Expected default results to only contain [:joy:] weighing [0.5], got [%v]", defaultResults)
+			}
+			mascot, err := reopened.RankedResults("mascot")
+			if err != nil || mascot.Winner != ":ghost:" || mascot.Ballots != 1 {
+				t.Fatalf("Expected the ranked ballot for [:ghost:] to be restored, got [%v] [%v]", mascot, err)
+			}
+			snacks, ballots, err := reopened.Count("snacks")
+			if err != nil || ballots != 2 || len(snacks) != 2 || snacks[0].Shortcode != ":pizza:" || snacks[0].NumVotes != 2 {
+				t.Fatalf("Expected [2] approval ballots approving [:pizza:] twice, got [%v] [%d] [%v]", snacks, ballots, err)
+			}
+		})
+	}
+}
+
+func TestDefaultPollStaysOpen(t *testing.T) {
+	for name, newStore := range durableStores {
+		t.Run("Reopens a closed default poll with the "+name+" store", func(t *testing.T) {
+			dir := tempPollDir(t)
+			defer os.RemoveAll(dir)
+
+			store, err := newStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewPolls(store, testBallot); err != nil {
+				t.Fatal(err)
+			}
+			// As older versions let the default poll be closed.
+			closed := &PollInfo{ID: DefaultPollID, Title: "Emoji Vote", Mode: BallotPlurality, State: PollClosed, EndsAt: time.Now()}
+			if err := store.SavePoll(closed); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err = newStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			polls, err := NewPolls(store, testBallot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := polls.Vote("", Voter{}, ":joy:"); err != nil {
+				t.Fatalf("Expected the default poll to be open again, got [%v]", err)
+			}
+			if info, _ := polls.Get(""); info.State != PollOpen || !info.EndsAt.IsZero() {
+				t.Fatalf("Expected the default poll to be open without an end, got [%v]", info)
+			}
+		})
+	}
+}
+
+// allStores opens every kind of store, keeping polls in dir if they can.
+func allStores() map[string]func(dir string) (Store, error) {
+	stores := map[string]func(dir string) (Store, error){
+		"memory": func(string) (Store, error) { return NewMemoryStore(), nil },
+	}
+	for name, newStore := range durableStores {
+		stores[name] = newStore
+	}
+	return stores
+}
+
+func TestRetractBallots(t *testing.T) {
+	for name, newStore := range allStores() {
+		t.Run("Retracts ranked and approval votes with the "+name+" store", func(t *testing.T) {
+			dir := tempPollDir(t)
+			defer os.RemoveAll(dir)
+
+			store, err := newStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			polls, err := NewPolls(store, testBallot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			polls.Create("mascot", "", nil, BallotRules{Mode: BallotRanked}, Schedule{})
+			polls.Create("lunch", "", nil, BallotRules{Mode: BallotApproval}, Schedule{})
+			polls.VoteRanked("mascot", Voter{}, []string{":joy:", ":ghost:"})
+			polls.VoteRanked("mascot", Voter{ID: "alice"}, []string{":ghost:", ":joy:"})
+			polls.VoteApproval("lunch", Voter{}, []string{":taco:", ":pizza:"})
+
+			if err := polls.Retract("mascot", Voter{}, []string{":ghost:", ":joy:"}); !errors.Is(err, ErrNoVote) {
+				t.Fatalf("Expected a ranking in another order not to match, got [%v]", err)
+			}
+			if err := polls.Retract("mascot", Voter{}, []string{":joy:"}); !errors.Is(err, ErrNoVote) {
+				t.Fatalf("Expected a first preference alone not to match, got [%v]", err)
+			}
+			if err := polls.Retract("mascot", Voter{}, nil); !errors.Is(err, ErrInvalidBallot) {
+				t.Fatalf("Expected an anonymous retraction without choices to be rejected, got [%v]", err)
+			}
+			if err := polls.Retract("mascot", Voter{}, []string{":joy:", ":ghost:"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := polls.Retract("mascot", Voter{ID: "alice"}, nil); err != nil {
+				t.Fatalf("Expected a voter to retract their vote without its choices, got [%v]", err)
+			}
+			if runoff, _ := polls.RankedResults("mascot"); runoff.Ballots != 0 {
+				t.Fatalf("Expected no ranked ballots to be left, got [%v]", runoff)
+			}
+
+			if err := polls.Retract("lunch", Voter{}, []string{":pizza:", ":taco:"}); err != nil {
+				t.Fatalf("Expected approved choices to match in any order, got [%v]", err)
+			}
+			if results, ballots, _ := polls.Count("lunch"); ballots != 0 || len(results) != 0 {
+				t.Fatalf("Expected no approval ballots to be left, got [%v] [%d]", results, ballots)
+			}
+		})
+	}
+}
+
+func TestPollHistory(t *testing.T) {
+	for name, newStore := range allStores() {
+		t.Run("Takes moved and retracted votes out of the history with the "+name+" store", func(t *testing.T) {
+			dir := tempPollDir(t)
+			defer os.RemoveAll(dir)
+
+			store, err := newStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			polls, err := NewPolls(store, testBallot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+			now := start
+			polls.now = func() time.Time { return now }
+
+			polls.Vote("", Voter{ID: "alice"}, ":joy:")
+			polls.Vote("", Voter{ID: "alice"}, ":joy:")
+			polls.Vote("", Voter{ID: "bob"}, ":joy:")
+			now = now.Add(time.Minute)
+			polls.Vote("", Voter{ID: "alice"}, ":ghost:")
+			polls.Vote("", Voter{}, ":taco:")
+			polls.Vote("", Voter{}, ":taco:")
+			polls.Retract("", Voter{}, []string{":taco:"})
+			polls.RetractVotes("", RetractFilter{VoterID: "bob"})
+
+			h, err := polls.History("", nil, start, now.Add(time.Minute), time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(h.Series) != 2 {
+				t.Fatalf("Expected only [:ghost:] and [:taco:] to be left, got [%v]", h.Series)
+			}
+			for _, s := range h.Series {
+				if s.Counts[0] != 0 || s.Counts[1] != 1 {
+					t.Fatalf("Expected a single vote for [%s] in the second minute, got [%v]", s.Shortcode, s.Counts)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package voting
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Tiebreak decides the order of choices with as many votes on a leaderboard.
+type Tiebreak string
+
+const (
+	// TiebreakFirst puts the choice that reached its count first ahead.
+	TiebreakFirst Tiebreak = "first"
+	// TiebreakAlphabetical puts choices in the order of their shortcodes.
+	TiebreakAlphabetical Tiebreak = "alphabetical"
+	// TiebreakCatalog puts choices in the order of the poll's ballot.
+	TiebreakCatalog Tiebreak = "catalog"
+)
+
+var ErrInvalidTiebreak = errors.New("invalid tiebreak")
+
+// ParseTiebreak reads a tiebreak by name. An empty name means TiebreakFirst.
+func ParseTiebreak(name string) (Tiebreak, error) {
+	switch tiebreak := Tiebreak(name); tiebreak {
+	case "":
+		return TiebreakFirst, nil
+	case TiebreakFirst, TiebreakAlphabetical, TiebreakCatalog:
+		return tiebreak, nil
+	default:
+		return "", fmt.Errorf("%w: [%s], pick one of [%s], [%s] or [%s]", ErrInvalidTiebreak, name, TiebreakFirst, TiebreakAlphabetical, TiebreakCatalog)
+	}
+}
+
+// ranker is implemented by polls that keep their results ranked for the
+// leaderboard, so that ranking them again only numbers them.
+type ranker interface {
+	setRanking(tiebreak Tiebreak, ballot []string)
+}
+
+// ranking orders results by votes, breaking ties by its tiebreak. Ties the
+// tiebreak leaves open go by the ballot, and then by shortcode, so the order
+// is always the same.
+type ranking struct {
+	tiebreak Tiebreak
+	position map[string]int
+}
+
+func newRanking(tiebreak Tiebreak, ballot []string) ranking {
+	position := make(map[string]int, len(ballot))
+	for i, shortcode := range ballot {
+		position[shortcode] = i
+	}
+	return ranking{tiebreak: tiebreak, position: position}
+}
+
+// onBallot is the place of a choice on the ballot, with choices that aren't
+// on it after the rest.
+func (r ranking) onBallot(shortcode string) int {
+	if i, ok := r.position[shortcode]; ok {
+		return i
+	}
+	return len(r.position)
+}
+
+// less reports whether a goes ahead of b.
+func (r ranking) less(a, b *Result) bool {
+	if a.NumVotes != b.NumVotes {
+		return a.NumVotes > b.NumVotes
+	}
+	switch r.tiebreak {
+	case TiebreakFirst:
+		if a.Since != b.Since {
+			return a.Since < b.Since
+		}
+	case TiebreakAlphabetical:
+		return a.Shortcode < b.Shortcode
+	}
+	if r.onBallot(a.Shortcode) != r.onBallot(b.Shortcode) {
+		return r.onBallot(a.Shortcode) < r.onBallot(b.Shortcode)
+	}
+	return a.Shortcode < b.Shortcode
+}
+
+// rank orders results as the ranking does, and numbers them. Results a
+// ranker already keeps in order are only numbered.
+func rank(results []*Result, r ranking) {
+	less := func(i, j int) bool { return r.less(results[i], results[j]) }
+	if !sort.SliceIsSorted(results, less) {
+		sort.Slice(results, less)
+	}
+	number(results)
+}
+
+// number numbers ranked results with their competition rank, where tied
+// choices share a rank and leave a gap after them (1, 2, 2, 4), and their
+// dense rank, which leaves no gap (1, 2, 2, 3).
+func number(results []*Result) {
+	for i, r := range results {
+		switch {
+		case i == 0:
+			r.Rank, r.DenseRank = 1, 1
+		case r.NumVotes == results[i-1].NumVotes:
+			r.Rank, r.DenseRank = results[i-1].Rank, results[i-1].DenseRank
+		default:
+			r.Rank, r.DenseRank = i+1, results[i-1].DenseRank+1
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package voting
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestRanking(t *testing.T) {
+	t.Run("Breaks ties by the tiebreak", func(t *testing.T) {
+		for _, test := range []struct {
+			tiebreak Tiebreak
+			expected []string
+		}{
+			{TiebreakFirst, []string{":taco:", ":pizza:", ":ghost:", ":joy:"}},
+			{TiebreakAlphabetical, []string{":taco:", ":ghost:", ":joy:", ":pizza:"}},
+			{TiebreakCatalog, []string{":taco:", ":joy:", ":ghost:", ":pizza:"}},
+		} {
+			polls, _ := NewPolls(NewMemoryStore(), testBallot)
+			polls.SetTiebreak(test.tiebreak)
+
+			polls.Vote("", Voter{}, ":taco:")
+			polls.Vote("", Voter{}, ":taco:")
+			polls.Vote("", Voter{}, ":pizza:")
+			polls.Vote("", Voter{}, ":ghost:")
+			polls.Vote("", Voter{}, ":joy:")
+
+			for i := 0; i < 3; i++ {
+				results, _ := polls.Results("")
+				for j, shortcode := range test.expected {
+					if results[j].Shortcode != shortcode {
+						t.Fatalf("Expected [%s] in place [%d] by [%s], got [%v]", shortcode, j, test.tiebreak, results[j])
+					}
+				}
+			}
+		}
+	})
+
+	stores := allStores()
+	stores["file with snapshots"] = func(dir string) (Store, error) {
+		return NewFileStore(dir, 1), nil
+	}
+	for name, newStore := range stores {
+		t.Run("Puts the choice that has held its count the longest first with the "+name+" store", func(t *testing.T) {
+			dir := tempPollDir(t)
+			defer os.RemoveAll(dir)
+
+			store, err := newStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			polls, err := NewPolls(store, testBallot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			polls.Create("lunch", "", nil, BallotRules{Mode: BallotApproval}, Schedule{})
+			polls.Vote("", Voter{}, ":joy:")
+			polls.Vote("", Voter{ID: "alice"}, ":ghost:")
+			polls.Vote("", Voter{}, ":joy:")
+			polls.Vote("", Voter{ID: "bob"}, ":taco:")
+			polls.Vote("", Voter{ID: "alice"}, ":pizza:")
+			polls.Retract("", Voter{}, []string{":joy:"})
+			polls.VoteApproval("lunch", Voter{}, []string{":taco:", ":pizza:"})
+			polls.VoteApproval("lunch", Voter{}, []string{":joy:"})
+
+			check := func(polls *Polls) {
+				results, _ := polls.Results("")
+				for i, shortcode := range []string{":taco:", ":pizza:", ":joy:"} {
+					if results[i].Shortcode != shortcode {
+						t.Fatalf("Expected [%s] in place [%d], got [%v]", shortcode, i, results[i])
+					}
+				}
+				results, _ = polls.Results("lunch")
+				for i, shortcode := range []string{":pizza:", ":taco:", ":joy:"} {
+					if results[i].Shortcode != shortcode {
+						t.Fatalf("Expected [%s] in place [%d] of the approval poll, got [%v]", shortcode, i, results[i])
+					}
+				}
+			}
+			check(polls)
+
+			if name == "memory" {
+				return
+			}
+			store, err = newStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reopened, err := NewPolls(store, testBallot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check(reopened)
+		})
+	}
+
+	t.Run("Numbers competition and dense ranks", func(t *testing.T) {
+		results := []*Result{
+			{Shortcode: ":pizza:", NumVotes: 1},
+			{Shortcode: ":joy:", NumVotes: 5},
+			{Shortcode: ":ghost:", NumVotes: 3},
+			{Shortcode: ":taco:", NumVotes: 3},
+		}
+		rank(results, newRanking(TiebreakCatalog, testBallot))
+
+		expected := []struct {
+			shortcode       string
+			rank, denseRank int
+		}{{":joy:", 1, 1}, {":ghost:", 2, 2}, {":taco:", 2, 2}, {":pizza:", 4, 3}}
+		for i, e := range expected {
+			if r := results[i]; r.Shortcode != e.shortcode || r.Rank != e.rank || r.DenseRank != e.denseRank {
+				t.Fatalf("Expected [%s] ranked [%d] and [%d], got [%v]", e.shortcode, e.rank, e.denseRank, r)
+			}
+		}
+	})
+
+	t.Run("Parses tiebreaks", func(t *testing.T) {
+		if tiebreak, err := ParseTiebreak(""); err != nil || tiebreak != TiebreakFirst {
+			t.Fatalf("Expected [%s] by default, got [%s] [%v]", TiebreakFirst, tiebreak, err)
+		}
+		if _, err := ParseTiebreak("random"); !errors.Is(err, ErrInvalidTiebreak) {
+			t.Fatalf("Expected an invalid tiebreak, got [%v]", err)
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package voting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// InitialRating is the rating of an emoji that was never compared.
+	InitialRating = 1500.0
+	// InitialDeviation is how uncertain a rating that was never compared is.
+	InitialDeviation = 350.0
+
+	minDeviation = 30.0
+	// deviationGrowth is how much uncertainty a rating regains per hour
+	// without comparisons, so that it takes about a month to go from well
+	// known back to unknown.
+	deviationGrowth = 13.0
+)
+
+var ErrInvalidComparison = errors.New("invalid comparison")
+
+// glickoQ is the scale factor of the Glicko rating system.
+var glickoQ = math.Ln10 / 400
+
+// Rating is how an emoji fares when compared head to head, on the Glicko
+// scale: Rating is its strength and Deviation how uncertain that is, so the
+// true strength is most likely within two deviations of the rating.
+type Rating struct {
+	Shortcode   string
+	Rating      float64
+	Deviation   float64
+	Comparisons int
+}
+
+// Conservative is the rating the emoji is most likely to be at least, which
+// keeps emoji with few comparisons from topping the leaderboard by luck.
+func (r Rating) Conservative() float64 {
+	return r.Rating - 2*r.Deviation
+}
+
+type rating struct {
+	rating      float64
+	deviation   float64
+	comparisons int
+	updated     time.Time
+}
+
+// Ratings rates the emoji on the default ballot from pairwise comparisons,
+// using the Glicko rating system, and picks the pairs to compare next.
+type Ratings struct {
+	sync.Mutex
+	ballot  []string
+	ratings map[string]*rating
+	now     func() time.Time
+	rand    *rand.Rand
+}
+
+// deviation is how uncertain a rating is now, having grown since it was last
+// updated. Callers must hold the lock.
+func (rs *Ratings) deviation(r *rating) float64 {
+	hours := rs.now().Sub(r.updated).Hours()
+	if r.comparisons == 0 || hours <= 0 {
+		return r.deviation
+	}
+	return math.Min(math.Sqrt(r.deviation*r.deviation+deviationGrowth*deviationGrowth*hours), InitialDeviation)
+}
+
+func (rs *Ratings) get(shortcode string) Rating {
+	r := rs.ratings[shortcode]
+	return Rating{Shortcode: shortcode, Rating: r.rating, Deviation: rs.deviation(r), Comparisons: r.comparisons}
+}
+
+// Pair picks two emoji to compare, favouring emoji whose ratings are the
+// most uncertain, and opponents close enough in rating that the outcome is
+// in doubt.
+func (rs *Ratings) Pair() (string, string, error) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	if len(rs.ballot) < 2 {
+		return "", "", fmt.Errorf("%w: [%d] emoji can't be paired", ErrInvalidComparison, len(rs.ballot))
+	}
+
+	weights := make([]float64, len(rs.ballot))
+	for i, shortcode := range rs.ballot {
+		d := rs.get(shortcode).Deviation
+		weights[i] = d * d
+	}
+	first := rs.pick(weights)
+
+	a := rs.get(rs.ballot[first])
+	for i, shortcode := range rs.ballot {
+		if i == first {
+			weights[i] = 0
+			continue
+		}
+		// An uncertain opponent close in rating teaches us the most.
+		b := rs.get(shortcode)
+		e := expectedScore(a.Rating, b.Rating, b.Deviation)
+		weights[i] = b.Deviation * b.Deviation * e * (1 - e)
+	}
+	second := rs.pick(weights)
+
+	return rs.ballot[first], rs.ballot[second], nil
+}
+
+// pick picks an index at random in proportion to its weight. Callers must
+// hold the lock.
+func (rs *Ratings) pick(weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	x := rs.rand.Float64() * total
+	last := 0
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if x < w {
+			return i
+		}
+		x -= w
+		last = i
+	}
+	return last
+}
+
+// Compare records that winner was preferred to loser, and returns both their
+// new ratings.
+func (rs *Ratings) Compare(winner, loser string) (Rating, Rating, error) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	for _, shortcode := range []string{winner, loser} {
+		if _, ok := rs.ratings[shortcode]; !ok {
+			return Rating{}, Rating{}, fmt.Errorf("%w: [%s] is not on the ballot", ErrInvalidComparison, shortcode)
+		}
+	}
+	if winner == loser {
+		return Rating{}, Rating{}, fmt.Errorf("%w: [%s] can't be compared with itself", ErrInvalidComparison, winner)
+	}
+
+	w, l := rs.get(winner), rs.get(loser)
+	now := rs.now()
+	rs.update(rs.ratings[winner], w, l, 1, now)
+	rs.update(rs.ratings[loser], l, w, 0, now)
+	return rs.get(winner), rs.get(loser), nil
+}
+
+// update applies the Glicko update for a single game with score against
+// opponent. Callers must hold the lock.
+func (rs *Ratings) update(r *rating, player, opponent Rating, score float64, now time.Time) {
+	g := glickoG(opponent.Deviation)
+	e := expectedScore(player.Rating, opponent.Rating, opponent.Deviation)
+	dSquared := 1 / (glickoQ * glickoQ * g * g * e * (1 - e))
+	variance := 1 / (1/(player.Deviation*player.Deviation) + 1/dSquared)
+
+	r.rating = player.Rating + glickoQ*variance*g*(score-e)
+	r.deviation = math.Max(math.Sqrt(variance), minDeviation)
+	r.comparisons++
+	r.updated = now
+}
+
+func glickoG(deviation float64) float64 {
+	return 1 / math.Sqrt(1+3*glickoQ*glickoQ*deviation*deviation/(math.Pi*math.Pi))
+}
+
+// expectedScore is the chance a player rated rating beats an opponent rated
+// opponent, with opponentDeviation.
+func expectedScore(rating, opponent, opponentDeviation float64) float64 {
+	return 1 / (1 + math.Pow(10, -glickoG(opponentDeviation)*(rating-opponent)/400))
+}
+
+// Leaderboard returns the rating of every emoji on the ballot, best first by
+// their conservative rating.
+func (rs *Ratings) Leaderboard() []Rating {
+	rs.Lock()
+	defer rs.Unlock()
+
+	leaderboard := make([]Rating, 0, len(rs.ballot))
+	for _, shortcode := range rs.ballot {
+		leaderboard = append(leaderboard, rs.get(shortcode))
+	}
+	sort.SliceStable(leaderboard, func(i, j int) bool {
+		return leaderboard[i].Conservative() > leaderboard[j].Conservative()
+	})
+	return leaderboard
+}
+
+// NewRatings rates the emoji on the default ballot of polls, which all start
+// unrated. Ratings are kept in memory.
+func NewRatings(polls *Polls) *Ratings {
+	ballot := polls.defaultBallot
+	rs := &Ratings{
+		ballot:  append([]string(nil), ballot...),
+		ratings: make(map[string]*rating, len(ballot)),
+		now:     time.Now,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, shortcode := range ballot {
+		rs.ratings[shortcode] = &rating{rating: InitialRating, deviation: InitialDeviation}
+	}
+	return rs
+}
@@ -0,0 +1,96 @@
+package voting
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestRatings(t *testing.T) {
+	start := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	newRatings := func() (*Ratings, *time.Time) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		rs := NewRatings(polls)
+		now := start
+		rs.now = func() time.Time { return now }
+		rs.rand = rand.New(rand.NewSource(1))
+		return rs, &now
+	}
+
+	t.Run("Rates the winner up and the loser down", func(t *testing.T) {
+		rs, _ := newRatings()
+
+		winner, loser, err := rs.Compare(":joy:", ":ghost:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Glickman's example numbers for two unrated players.
+		if math.Abs(winner.Rating-1662.3) > 0.1 || math.Abs(loser.Rating-1337.7) > 0.1 {
+			t.Fatalf("Expected ratings of about 1662.3 and 1337.7, got [%f] and [%f]", winner.Rating, loser.Rating)
+		}
+		if math.Abs(winner.Deviation-290.3) > 0.1 || winner.Comparisons != 1 {
+			t.Fatalf("Expected a deviation of about 290.3 after one comparison, got [%v]", winner)
+		}
+
+		leaderboard := rs.Leaderboard()
+		if len(leaderboard) != len(testBallot) || leaderboard[0].Shortcode != ":joy:" || leaderboard[len(leaderboard)-1].Shortcode != ":ghost:" {
+			t.Fatalf("Expected [:joy:] first and [:ghost:] last, got [%v]", leaderboard)
+		}
+	})
+
+	t.Run("Rejects invalid comparisons", func(t *testing.T) {
+		rs, _ := newRatings()
+
+		if _, _, err := rs.Compare(":joy:", ":unknown:"); !errors.Is(err, ErrInvalidComparison) {
+			t.Fatalf("Expected an unknown emoji to be rejected, got [%v]", err)
+		}
+		if _, _, err := rs.Compare(":joy:", ":joy:"); !errors.Is(err, ErrInvalidComparison) {
+			t.Fatalf("Expected an emoji compared with itself to be rejected, got [%v]", err)
+		}
+	})
+
+	t.Run("Grows uncertainty without comparisons", func(t *testing.T) {
+		rs, now := newRatings()
+		for i := 0; i < 20; i++ {
+			rs.Compare(":joy:", ":ghost:")
+		}
+		settled := rs.Leaderboard()[0].Deviation
+
+		*now = now.Add(24 * time.Hour)
+		if d := rs.Leaderboard()[0].Deviation; d <= settled || d >= InitialDeviation {
+			t.Fatalf("Expected the deviation to grow from [%f] after a day, got [%f]", settled, d)
+		}
+		*now = now.Add(365 * 24 * time.Hour)
+		if d := rs.Leaderboard()[0].Deviation; d != InitialDeviation {
+			t.Fatalf("Expected the deviation to grow back to [%f], got [%f]", InitialDeviation, d)
+		}
+	})
+
+	t.Run("Pairs uncertain ratings more often", func(t *testing.T) {
+		rs, _ := newRatings()
+		for i := 0; i < 50; i++ {
+			rs.Compare(":joy:", ":ghost:")
+			rs.Compare(":pizza:", ":ghost:")
+		}
+
+		picked := make(map[string]int)
+		for i := 0; i < 1000; i++ {
+			first, second, err := rs.Pair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first == second {
+				t.Fatalf("Expected two different emoji, got [%s] twice", first)
+			}
+			picked[first]++
+			picked[second]++
+		}
+		for _, settled := range []string{":joy:", ":ghost:", ":pizza:"} {
+			if picked[settled] >= picked[":taco:"] {
+				t.Fatalf("Expected unrated [:taco:] to be picked more than [%s], got [%v]", settled, picked)
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package voting
+
+import "sort"
+
+// RankedBallot is a ranking of choices, most preferred first, and how many
+// voters cast it.
+type RankedBallot struct {
+	Ranking []string
+	Count   int
+}
+
+// RunoffRound is one round of an instant-runoff count. Tallies has the votes
+// of every choice still in the running, most first. Exhausted counts the
+// ballots that rank none of them. Eliminated lists the choices knocked out
+// at the end of the round, and is empty in the last round.
+type RunoffRound struct {
+	Tallies    []*Result
+	Exhausted  int
+	Eliminated []string
+}
+
+// RunoffResult is the outcome of an instant-runoff count of Ballots ballots.
+// Winner is empty when there were no votes, or when the last choices in the
+// running were tied, in which case they are listed in Tied.
+type RunoffResult struct {
+	Ballots int
+	Rounds  []*RunoffRound
+	Winner  string
+	Tied    []string
+}
+
+// InstantRunoff counts ranked ballots for candidates. Each round, every
+// ballot counts for its highest ranked candidate still in the running, and
+// a candidate with a majority of those votes wins. Otherwise the candidate
+// with the fewest votes is eliminated, or every candidate without a vote at
+// once. A tie for fewest votes is broken by the latest earlier round in which
+// the tied candidates had different votes, and then by eliminating the
+// candidate listed last. Rankings may skip candidates; choices that aren't
+// candidates and repeats are ignored.
+func InstantRunoff(candidates []string, ballots []RankedBallot) *RunoffResult {
+	result := &RunoffResult{Rounds: make([]*RunoffRound, 0)}
+	for _, b := range ballots {
+		result.Ballots += b.Count
+	}
+
+	position := make(map[string]int, len(candidates))
+	running := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		if _, ok := position[c]; !ok {
+			position[c] = len(running)
+			running = append(running, c)
+		}
+	}
+
+	var history []map[string]int
+	for len(running) > 0 {
+		counts := make(map[string]int, len(running))
+		for _, c := range running {
+			counts[c] = 0
+		}
+		round := &RunoffRound{Tallies: make([]*Result, 0, len(running)), Eliminated: make([]string, 0)}
+		for _, b := range ballots {
+			if c, ok := topChoice(b.Ranking, counts); ok {
+				counts[c] += b.Count
+			} else {
+				round.Exhausted += b.Count
+			}
+		}
+		for _, c := range running {
+			round.Tallies = append(round.Tallies, &Result{Shortcode: c, NumVotes: counts[c]})
+		}
+		sort.SliceStable(round.Tallies, func(i, j int) bool {
+			return round.Tallies[i].NumVotes > round.Tallies[j].NumVotes
+		})
+		result.Rounds = append(result.Rounds, round)
+		history = append(history, counts)
+
+		continuing := result.Ballots - round.Exhausted
+		if continuing == 0 {
+			break
+		}
+		if leader := round.Tallies[0]; leader.NumVotes*2 > continuing {
+			result.Winner = leader.Shortcode
+			break
+		}
+
+		fewest := round.Tallies[len(round.Tallies)-1].NumVotes
+		var last []string
+		for _, c := range running {
+			if counts[c] == fewest {
+				last = append(last, c)
+			}
+		}
+		switch {
+		case fewest == 0:
+			round.Eliminated = last
+		case len(last) == len(running):
+			result.Tied = last
+		default:
+			round.Eliminated = []string{breakTie(last, history, position)}
+		}
+		if len(round.Eliminated) == 0 {
+			break
+		}
+
+		out := make(map[string]bool, len(round.Eliminated))
+		for _, c := range round.Eliminated {
+			out[c] = true
+		}
+		next := running[:0:0]
+		for _, c := range running {
+			if !out[c] {
+				next = append(next, c)
+			}
+		}
+		running = next
+	}
+	return result
+}
+
+// topChoice is the highest ranked choice that is still in the running.
+func topChoice(ranking []string, running map[string]int) (string, bool) {
+	for _, c := range ranking {
+		if _, ok := running[c]; ok {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// breakTie picks which of the tied candidates to eliminate, looking back
+// through earlier rounds for the one with the fewest votes.
+func breakTie(tied []string, history []map[string]int, position map[string]int) string {
+	for i := len(history) - 2; i >= 0 && len(tied) > 1; i-- {
+		fewest := -1
+		for _, c := range tied {
+			if fewest < 0 || history[i][c] < fewest {
+				fewest = history[i][c]
+			}
+		}
+		var still []string
+		for _, c := range tied {
+			if history[i][c] == fewest {
+				still = append(still, c)
+			}
+		}
+		tied = still
+	}
+	sort.Slice(tied, func(i, j int) bool { return position[tied[i]] > position[tied[j]] })
+	return tied[0]
+}
+
+// rankedBallots turns the ballots of a ranked poll into rankings.
+func rankedBallots(ballots []*BallotCount) []RankedBallot {
+	ranked := make([]RankedBallot, 0, len(ballots))
+	for _, b := range ballots {
+		if b.NumVotes > 0 {
+			ranked = append(ranked, RankedBallot{Ranking: b.Choices, Count: b.NumVotes})
+		}
+	}
+	return ranked
+}
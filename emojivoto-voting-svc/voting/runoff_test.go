@@ -0,0 +1,155 @@
+package voting
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInstantRunoff(t *testing.T) {
+	candidates := []string{":joy:", ":ghost:", ":pizza:", ":taco:"}
+
+	tallies := func(round *RunoffRound) map[string]int {
+		votes := make(map[string]int)
+		for _, r := range round.Tallies {
+			votes[r.Shortcode] = r.NumVotes
+		}
+		return votes
+	}
+
+	t.Run("Declares a first round majority the winner", func(t *testing.T) {
+		result := InstantRunoff(candidates, []RankedBallot{
+			{Ranking: []string{":joy:"}, Count: 3},
+			{Ranking: []string{":ghost:", ":joy:"}, Count: 2},
+		})
+
+		if result.Winner != ":joy:" || result.Ballots != 5 || len(result.Rounds) != 1 {
+			t.Fatalf("Expected [:joy:] to win in one round, got [%v]", result)
+		}
+		if len(result.Rounds[0].Eliminated) != 0 {
+			t.Fatalf("Expected nobody to be eliminated in the last round, got [%v]", result.Rounds[0].Eliminated)
+		}
+		if first := result.Rounds[0].Tallies[0]; first.Shortcode != ":joy:" || first.NumVotes != 3 {
+			t.Fatalf("Expected tallies to be sorted by votes, got [%v]", result.Rounds[0].Tallies)
+		}
+	})
+
+	t.Run("Transfers votes of eliminated choices to the next preference", func(t *testing.T) {
+		result := InstantRunoff(candidates, []RankedBallot{
+			{Ranking: []string{":joy:"}, Count: 4},
+			{Ranking: []string{":ghost:", ":pizza:"}, Count: 3},
+			{Ranking: []string{":pizza:", ":ghost:"}, Count: 2},
+			{Ranking: []string{":taco:", ":pizza:", ":ghost:"}, Count: 1},
+		})
+
+		if result.Winner != ":ghost:" || len(result.Rounds) != 3 {
+			t.Fatalf("Expected [:ghost:] to win in three rounds, got [%v]", result)
+		}
+		expected := [][]string{{":taco:"}, {":pizza:"}, {}}
+		for i, round := range result.Rounds {
+			if !reflect.DeepEqual(round.Eliminated, expected[i]) {
+				t.Fatalf("Expected round [%d] to eliminate [%v], got [%v]", i+1, expected[i], round.Eliminated)
+			}
+		}
+		if votes := tallies(result.Rounds[1]); votes[":pizza:"] != 3 || len(votes) != 3 {
+			t.Fatalf("Expected [:taco:]'s vote to go to [:pizza:], got [%v]", votes)
+		}
+		if votes := tallies(result.Rounds[2]); votes[":ghost:"] != 6 || votes[":joy:"] != 4 {
+			t.Fatalf("Expected [:ghost:] to beat [:joy:] 6 to 4, got [%v]", votes)
+		}
+	})
+
+	t.Run("Counts exhausted ballots out of the majority", func(t *testing.T) {
+		result := InstantRunoff(candidates, []RankedBallot{
+			{Ranking: []string{":joy:"}, Count: 3},
+			{Ranking: []string{":ghost:"}, Count: 1},
+			{Ranking: []string{":taco:"}, Count: 2},
+			{Ranking: []string{":pizza:", ":ghost:"}, Count: 1},
+		})
+
+		if result.Winner != ":joy:" {
+			t.Fatalf("Expected [:joy:] to win, got [%v]", result)
+		}
+		last := result.Rounds[len(result.Rounds)-1]
+		if last.Exhausted != 2 || tallies(last)[":joy:"] != 3 {
+			t.Fatalf("Expected [:joy:] to win 3 of 5 continuing ballots with 2 exhausted, got [%v] exhausted and [%v]", last.Exhausted, tallies(last))
+		}
+	})
+
+	t.Run("Eliminates every choice without votes at once", func(t *testing.T) {
+		result := InstantRunoff(candidates, []RankedBallot{
+			{Ranking: []string{":joy:"}, Count: 2},
+			{Ranking: []string{":ghost:"}, Count: 2},
+			{Ranking: []string{":taco:", ":ghost:"}, Count: 1},
+		})
+
+		if !reflect.DeepEqual(result.Rounds[0].Eliminated, []string{":pizza:"}) || result.Winner != ":ghost:" {
+			t.Fatalf("Expected only [:pizza:] to go first and [:ghost:] to win, got [%v]", result)
+		}
+
+		result = InstantRunoff(candidates, []RankedBallot{
+			{Ranking: []string{":joy:"}, Count: 2},
+			{Ranking: []string{":ghost:"}, Count: 2},
+		})
+		if !reflect.DeepEqual(result.Rounds[0].Eliminated, []string{":pizza:", ":taco:"}) {
+			t.Fatalf("Expected [:pizza:] and [:taco:] to go together, got [%v]", result.Rounds[0].Eliminated)
+		}
+	})
+
+	t.Run("Breaks ties for last place by earlier rounds and then ballot order", func(t *testing.T) {
+		// :pizza: and :taco: tie in the second round, but :taco: had fewer
+		// votes in the first.
+		result := InstantRunoff(candidates, []RankedBallot{
+			{Ranking: []string{":joy:"}, Count: 5},
+			{Ranking: []string{":ghost:"}, Count: 4},
+			{Ranking: []string{":pizza:"}, Count: 3},
+			{Ranking: []string{":taco:"}, Count: 2},
+			{Ranking: []string{":ghost:", ":taco:"}, Count: 1},
+		})
+		if !reflect.DeepEqual(result.Rounds[0].Eliminated, []string{":taco:"}) {
+			t.Fatalf("Expected [:taco:] to be eliminated first, got [%v]", result.Rounds[0].Eliminated)
+		}
+
+		result = InstantRunoff(candidates, []RankedBallot{
+			{Ranking: []string{":joy:"}, Count: 3},
+			{Ranking: []string{":ghost:"}, Count: 3},
+			{Ranking: []string{":pizza:", ":joy:"}, Count: 1},
+			{Ranking: []string{":taco:", ":joy:"}, Count: 1},
+		})
+		if !reflect.DeepEqual(result.Rounds[0].Eliminated, []string{":taco:"}) || result.Winner != ":joy:" {
+			t.Fatalf("Expected [:taco:], listed last, to be eliminated and [:joy:] to win, got [%v]", result)
+		}
+	})
+
+	t.Run("Reports a tie between the last choices", func(t *testing.T) {
+		result := InstantRunoff(candidates, []RankedBallot{
+			{Ranking: []string{":joy:"}, Count: 2},
+			{Ranking: []string{":ghost:"}, Count: 2},
+		})
+
+		if result.Winner != "" || !reflect.DeepEqual(result.Tied, []string{":joy:", ":ghost:"}) {
+			t.Fatalf("Expected [:joy:] and [:ghost:] to tie, got [%v]", result)
+		}
+	})
+
+	t.Run("Ignores unknown and repeated choices", func(t *testing.T) {
+		result := InstantRunoff(candidates, []RankedBallot{
+			{Ranking: []string{":unknown:", ":joy:", ":joy:"}, Count: 1},
+			{Ranking: []string{":unknown:"}, Count: 1},
+		})
+
+		if result.Winner != ":joy:" || result.Rounds[0].Exhausted != 1 {
+			t.Fatalf("Expected [:joy:] to win with one ballot exhausted, got [%v]", result)
+		}
+	})
+
+	t.Run("Has no winner without votes", func(t *testing.T) {
+		result := InstantRunoff(candidates, nil)
+
+		if result.Winner != "" || len(result.Tied) != 0 || len(result.Rounds) != 1 {
+			t.Fatalf("Expected a single round without a winner, got [%v]", result)
+		}
+		if votes := tallies(result.Rounds[0]); len(votes) != len(candidates) {
+			t.Fatalf("Expected every choice to be listed, got [%v]", votes)
+		}
+	})
+}
@@ -0,0 +1,608 @@
+package voting
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sqlTimeFormat is fixed width and always UTC, so stored timestamps sort and
+// compare correctly as plain text.
+const sqlTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// migrations are applied in order, each exactly once, and recorded in the
+// schema_migrations table. Only ever append to this list.
+var migrations = []string{
+	`CREATE TABLE votes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		shortcode TEXT NOT NULL,
+		voted_at TEXT NOT NULL,
+		voter_address TEXT NOT NULL DEFAULT '',
+		voter_user_agent TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX votes_shortcode ON votes (shortcode)`,
+	`ALTER TABLE votes ADD COLUMN poll_id TEXT NOT NULL DEFAULT 'default'`,
+	`CREATE INDEX votes_poll_shortcode ON votes (poll_id, shortcode)`,
+	`CREATE TABLE polls (
+		id TEXT PRIMARY KEY,
+		title TEXT NOT NULL,
+		definition TEXT NOT NULL
+	)`,
+	`ALTER TABLE votes ADD COLUMN voter_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE votes ADD COLUMN superseded INTEGER NOT NULL DEFAULT 0`,
+	`CREATE INDEX votes_poll_voter ON votes (poll_id, voter_id)`,
+	`ALTER TABLE votes ADD COLUMN retracted INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE votes ADD COLUMN weight REAL NOT NULL DEFAULT 1`,
+	`CREATE TABLE vote_choices (
+		vote_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		shortcode TEXT NOT NULL,
+		PRIMARY KEY (vote_id, position)
+	)`,
+	`INSERT INTO vote_choices (vote_id, position, shortcode) SELECT id, 0, shortcode FROM votes`,
+	`ALTER TABLE votes ADD COLUMN changed INTEGER NOT NULL DEFAULT 0`,
+	`UPDATE votes SET changed = id`,
+	`CREATE INDEX votes_changed ON votes (changed)`,
+	`CREATE TABLE tournaments (
+		id TEXT PRIMARY KEY,
+		definition TEXT NOT NULL
+	)`,
+}
+
+// currentVotes selects the votes that count: not moved and not retracted.
+const currentVotes = `superseded = 0 AND retracted = 0`
+
+// sqlPoll stores one row per vote, so the raw votes can be queried directly,
+// and computes results with SQL aggregation. The choices of each vote are
+// stored in order in vote_choices, and its first choice in votes too. When a
+// voter with an ID votes again, their earlier rows are marked superseded
+// rather than deleted, and retracted votes are marked retracted. Every row
+// records the latest change to it, cast or taken back, in changed, which
+// counts the changes to every poll in one sequence, so Results can tell
+// when each choice reached its count.
+type sqlPoll struct {
+	db      *sql.DB
+	pollID  string
+	mode    BallotMode
+	history *History
+	counter *emojiCounter
+	now     func() time.Time
+}
+
+func (p *sqlPoll) Vote(choice string) error {
+	return p.VoteAs(Voter{}, Ballot{Choices: []string{choice}, Weight: 1})
+}
+
+func (p *sqlPoll) VoteAs(voter Voter, ballot Ballot) error {
+	if err := ballot.check(); err != nil {
+		return err
+	}
+	now := p.now()
+	moved, cast, err := p.insert(voter, ballot, now)
+	if err != nil {
+		return fmt.Errorf("%w: recording vote for [%s]: %v", ErrStorage, ballot, err)
+	}
+	if !cast {
+		return nil
+	}
+	p.forget(moved)
+	p.history.add(ballot.counted(p.mode), now, 1)
+	p.counter.vote(ballot, p.mode)
+	log.Printf("Voted for [%s]", ballot)
+	return nil
+}
+
+// insert adds a row for a vote cast at the given time, superseding the
+// voter's current vote, which it returns as counted. It adds nothing when the
+// voter votes for the same ballot again.
+func (p *sqlPoll) insert(voter Voter, ballot Ballot, now time.Time) ([]countedVote, bool, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	var moved []countedVote
+	if voter.ID != "" {
+		current, err := p.current(tx, voter.ID)
+		switch {
+		case err == nil && current.equal(ballot):
+			return nil, false, tx.Rollback()
+		case err != nil && err != sql.ErrNoRows:
+			tx.Rollback()
+			return nil, false, err
+		}
+		where := `poll_id = ? AND voter_id = ? AND ` + currentVotes
+		args := []interface{}{p.pollID, voter.ID}
+		moved, err = p.counted(tx, where, args)
+		if err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+		change, err := nextChange(tx)
+		if err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+		if _, err := tx.Exec(`UPDATE votes SET superseded = 1, changed = ? WHERE `+where, append([]interface{}{change}, args...)...); err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+	}
+	change, err := nextChange(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+	res, err := tx.Exec(
+		`INSERT INTO votes (poll_id, shortcode, weight, voted_at, voter_id, voter_address, voter_user_agent, changed) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.pollID, ballot.Choices[0], ballot.Weight, now.UTC().Format(sqlTimeFormat), voter.ID, voter.Address, voter.UserAgent, change)
+	if err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+	for position, choice := range ballot.Choices {
+		_, err := tx.Exec(`INSERT INTO vote_choices (vote_id, position, shortcode) VALUES (?, ?, ?)`, id, position, choice)
+		if err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+	}
+	return moved, true, tx.Commit()
+}
+
+// countedVote is a choice a stored vote counts for, and when it was cast.
+type countedVote struct {
+	choice  string
+	votedAt time.Time
+}
+
+// counted reads what the votes matching where count for, as Results counts
+// them, so they can be taken back out of the history. It reads nothing
+// without a history.
+func (p *sqlPoll) counted(tx *sql.Tx, where string, args []interface{}) ([]countedVote, error) {
+	if p.history == nil {
+		return nil, nil
+	}
+	query := `SELECT shortcode, voted_at FROM votes WHERE ` + where
+	if p.mode == BallotApproval {
+		query = `SELECT c.shortcode, votes.voted_at FROM votes JOIN vote_choices c ON c.vote_id = votes.id WHERE ` + where
+	}
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counted []countedVote
+	for rows.Next() {
+		var v countedVote
+		var votedAt string
+		if err := rows.Scan(&v.choice, &votedAt); err != nil {
+			return nil, err
+		}
+		if v.votedAt, err = time.Parse(sqlTimeFormat, votedAt); err != nil {
+			return nil, err
+		}
+		counted = append(counted, v)
+	}
+	return counted, rows.Err()
+}
+
+// forget takes votes back out of the history.
+func (p *sqlPoll) forget(votes []countedVote) {
+	for _, v := range votes {
+		p.history.remove([]string{v.choice}, v.votedAt, time.Minute, 1)
+	}
+}
+
+// retract marks the current votes matching where retracted, and returns how
+// many it marked and what they counted for.
+func (p *sqlPoll) retract(where string, args []interface{}) (int64, []countedVote, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	taken, err := p.counted(tx, where, args)
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	change, err := nextChange(tx)
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	res, err := tx.Exec(`UPDATE votes SET retracted = 1, changed = ? WHERE `+where, append([]interface{}{change}, args...)...)
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	retracted, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	return retracted, taken, tx.Commit()
+}
+
+// nextChange numbers the next change to the votes. It relies on the database
+// serializing write transactions, as SQLite does, so that no other
+// transaction takes the same number.
+func nextChange(tx *sql.Tx) (int64, error) {
+	var change int64
+	err := tx.QueryRow(`SELECT COALESCE(MAX(changed), 0) + 1 FROM votes`).Scan(&change)
+	return change, err
+}
+
+// setHistory has the votes cast and taken back from now on kept in history,
+// timed by now.
+func (p *sqlPoll) historyShards() int {
+	return 1
+}
+
+func (p *sqlPoll) setHistory(history *History, now func() time.Time) {
+	p.history, p.now = history, now
+}
+
+// current reads the current vote of a voter, or fails with sql.ErrNoRows if
+// they have none.
+func (p *sqlPoll) current(tx *sql.Tx, voterID string) (Ballot, error) {
+	var id int64
+	var ballot Ballot
+	err := tx.QueryRow(`SELECT id, weight FROM votes WHERE poll_id = ? AND voter_id = ? AND `+currentVotes, p.pollID, voterID).Scan(&id, &ballot.Weight)
+	if err != nil {
+		return ballot, err
+	}
+	rows, err := tx.Query(`SELECT shortcode FROM vote_choices WHERE vote_id = ? ORDER BY position`, id)
+	if err != nil {
+		return ballot, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var choice string
+		if err := rows.Scan(&choice); err != nil {
+			return ballot, err
+		}
+		ballot.Choices = append(ballot.Choices, choice)
+	}
+	return ballot, rows.Err()
+}
+
+// castWith is a condition that holds for votes cast with exactly the given
+// choices, in order, and its arguments.
+func castWith(choices []string) (string, []interface{}) {
+	positions := make([]string, len(choices))
+	args := []interface{}{len(choices)}
+	for i, choice := range choices {
+		positions[i] = `(position = ? AND shortcode = ?)`
+		args = append(args, i, choice)
+	}
+	args = append(args, len(choices))
+	return `(SELECT COUNT(*) FROM vote_choices WHERE vote_id = votes.id) = ? AND
+		(SELECT COUNT(*) FROM vote_choices WHERE vote_id = votes.id AND (` + strings.Join(positions, ` OR `) + `)) = ?`, args
+}
+
+func (p *sqlPoll) Retract(voter Voter, choices []string) error {
+	if voter.ID == "" && len(choices) == 0 {
+		return fmt.Errorf("%w: votes cast without a voter ID are only retracted by their choices", ErrNoVote)
+	}
+	// Without a voter ID, take back the newest vote cast without one.
+	query := `SELECT id FROM votes WHERE poll_id = ? AND voter_id = ? AND ` + currentVotes
+	args := []interface{}{p.pollID, voter.ID}
+	if len(choices) > 0 {
+		matching, matchArgs := castWith(choices)
+		query += ` AND ` + matching
+		args = append(args, matchArgs...)
+	}
+	retracted, taken, err := p.retract(`votes.id = (`+query+` ORDER BY id DESC LIMIT 1)`, args)
+	if err != nil {
+		return fmt.Errorf("%w: retracting vote for [%s]: %v", ErrStorage, strings.Join(choices, ", "), err)
+	}
+	if retracted == 0 {
+		switch {
+		case voter.ID != "" && len(choices) == 0:
+			return fmt.Errorf("%w: voter [%s] has no vote", ErrNoVote, voter.ID)
+		case voter.ID != "":
+			return fmt.Errorf("%w: voter [%s] has no vote for [%s]", ErrNoVote, voter.ID, strings.Join(choices, ", "))
+		}
+		return fmt.Errorf("%w: [%s] has no votes cast without a voter ID", ErrNoVote, strings.Join(choices, ", "))
+	}
+	p.forget(taken)
+	log.Printf("Retracted vote for [%s]", strings.Join(choices, ", "))
+	return nil
+}
+
+func (p *sqlPoll) RetractVotes(filter RetractFilter) (int, error) {
+	where := `poll_id = ? AND ` + currentVotes
+	args := []interface{}{p.pollID}
+	if filter.VoterID != "" {
+		where += ` AND voter_id = ?`
+		args = append(args, filter.VoterID)
+	}
+	if !filter.Since.IsZero() {
+		where += ` AND voted_at >= ?`
+		args = append(args, filter.Since.UTC().Format(sqlTimeFormat))
+	}
+	if !filter.Until.IsZero() {
+		where += ` AND voted_at < ?`
+		args = append(args, filter.Until.UTC().Format(sqlTimeFormat))
+	}
+
+	retracted, taken, err := p.retract(where, args)
+	if err != nil {
+		return 0, fmt.Errorf("%w: retracting votes: %v", ErrStorage, err)
+	}
+	p.forget(taken)
+	log.Printf("Retracted [%d] votes", retracted)
+	return int(retracted), nil
+}
+
+// Results counts votes by their first choice, which is stored with them,
+// and approval votes by every choice. A choice reached its count at the
+// latest change to any of its rows, whether they still count or not.
+func (p *sqlPoll) Results() ([]*Result, error) {
+	const counts = `SUM(CASE WHEN ` + currentVotes + ` THEN 1 ELSE 0 END)`
+	const weights = `SUM(CASE WHEN ` + currentVotes + ` THEN weight ELSE 0 END)`
+	query := `SELECT shortcode, ` + counts + `, ` + weights + `, MAX(changed) FROM votes
+		WHERE poll_id = ? GROUP BY shortcode HAVING ` + counts + ` > 0`
+	if p.mode == BallotApproval {
+		query = `SELECT c.shortcode, ` + counts + `, ` + weights + `, MAX(changed) FROM vote_choices c JOIN votes v ON v.id = c.vote_id
+			WHERE v.poll_id = ? GROUP BY c.shortcode HAVING ` + counts + ` > 0`
+	}
+	rows, err := p.db.Query(query, p.pollID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: counting votes: %v", ErrStorage, err)
+	}
+	defer rows.Close()
+
+	results := make([]*Result, 0)
+	for rows.Next() {
+		result := &Result{}
+		if err := rows.Scan(&result.Shortcode, &result.NumVotes, &result.Weighted, &result.Since); err != nil {
+			return nil, fmt.Errorf("%w: counting votes: %v", ErrStorage, err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: counting votes: %v", ErrStorage, err)
+	}
+
+	sort.Sort(ByVotes(results))
+
+	return results, nil
+}
+
+func (p *sqlPoll) Ballots() ([]*BallotCount, error) {
+	rows, err := p.db.Query(`SELECT v.id, v.weight, c.shortcode FROM votes v JOIN vote_choices c ON c.vote_id = v.id
+		WHERE v.poll_id = ? AND `+currentVotes+` ORDER BY v.id, c.position`, p.pollID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: counting ballots: %v", ErrStorage, err)
+	}
+	defer rows.Close()
+
+	counts := make(ballotCounts)
+	var ballot Ballot
+	var last int64
+	for rows.Next() {
+		var id int64
+		var weight float64
+		var choice string
+		if err := rows.Scan(&id, &weight, &choice); err != nil {
+			return nil, fmt.Errorf("%w: counting ballots: %v", ErrStorage, err)
+		}
+		if id != last && last != 0 {
+			counts.add(ballot.Choices, 1, ballot.Weight)
+			ballot = Ballot{}
+		}
+		last = id
+		ballot.Weight = weight
+		ballot.Choices = append(ballot.Choices, choice)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: counting ballots: %v", ErrStorage, err)
+	}
+	if last != 0 {
+		counts.add(ballot.Choices, 1, ballot.Weight)
+	}
+	return counts.sorted(), nil
+}
+
+// migrate brings the schema up to date, one transaction per migration.
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("applying migration [%d]: %v", version, err)
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			version, time.Now().UTC().Format(sqlTimeFormat))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Applied schema migration [%d]", version)
+	}
+	return nil
+}
+
+func openSQL(driverName, dataSourceName string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	if driverName == "sqlite" {
+		// SQLite allows a single writer, and every connection to ":memory:"
+		// opens a separate database.
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewSQLPoll opens the default poll stored in the database described by
+// driverName and dataSourceName, migrating its schema if needed. The driver
+// must already be registered with database/sql, e.g. by importing
+// modernc.org/sqlite.
+func NewSQLPoll(driverName, dataSourceName string) (Poll, error) {
+	db, err := openSQL(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return newSQLPoll(db, DefaultPollID, BallotPlurality), nil
+}
+
+func newSQLPoll(db *sql.DB, pollID string, mode BallotMode) *sqlPoll {
+	return &sqlPoll{
+		db:      db,
+		pollID:  pollID,
+		mode:    mode,
+		counter: counter,
+		now:     time.Now,
+	}
+}
+
+// sqlStore keeps the votes of every poll in one votes table, and their
+// definitions as JSON in the polls table.
+type sqlStore struct {
+	db *sql.DB
+}
+
+func (s *sqlStore) Open(info *PollInfo) (Poll, error) {
+	return newSQLPoll(s.db, info.ID, info.Mode), nil
+}
+
+func (s *sqlStore) SavePoll(info *PollInfo) error {
+	definition, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`UPDATE polls SET title = ?, definition = ? WHERE id = ?`, info.Title, string(definition), info.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		_, err := tx.Exec(`INSERT INTO polls (id, title, definition) VALUES (?, ?, ?)`, info.ID, info.Title, string(definition))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) LoadPolls() ([]*PollInfo, error) {
+	rows, err := s.db.Query(`SELECT definition FROM polls`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	infos := make([]*PollInfo, 0)
+	for rows.Next() {
+		var definition string
+		if err := rows.Scan(&definition); err != nil {
+			return nil, err
+		}
+		info := &PollInfo{}
+		if err := json.Unmarshal([]byte(definition), info); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, rows.Err()
+}
+
+func (s *sqlStore) SaveTournament(t *Tournament) error {
+	definition, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`UPDATE tournaments SET definition = ? WHERE id = ?`, string(definition), t.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		_, err := tx.Exec(`INSERT INTO tournaments (id, definition) VALUES (?, ?)`, t.ID, string(definition))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) LoadTournaments() ([]*Tournament, error) {
+	rows, err := s.db.Query(`SELECT definition FROM tournaments`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tournaments := make([]*Tournament, 0)
+	for rows.Next() {
+		var definition string
+		if err := rows.Scan(&definition); err != nil {
+			return nil, err
+		}
+		t := &Tournament{}
+		if err := json.Unmarshal([]byte(definition), t); err != nil {
+			return nil, err
+		}
+		tournaments = append(tournaments, t)
+	}
+	return tournaments, rows.Err()
+}
+
+// NewSQLStore keeps every poll in the database described by driverName and
+// dataSourceName. See NewSQLPoll.
+func NewSQLStore(driverName, dataSourceName string) (Store, error) {
+	db, err := openSQL(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStore{db: db}, nil
+}
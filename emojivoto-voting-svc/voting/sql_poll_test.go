@@ -0,0 +1,193 @@
+package voting
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLPoll(t *testing.T) {
+	t.Run("Stores one row per vote with voter metadata", func(t *testing.T) {
+		poll, err := NewSQLPoll("sqlite", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		poll.VoteAs(Voter{Address: "10.0.0.1", UserAgent: "vote-bot"}, ballotFor(":joy:"))
+		poll.Vote(":joy:")
+
+		var rows int
+		var userAgent string
+		db := poll.(*sqlPoll).db
+		db.QueryRow(`SELECT COUNT(*) FROM votes`).Scan(&rows)
+		db.QueryRow(`SELECT voter_user_agent FROM votes ORDER BY id LIMIT 1`).Scan(&userAgent)
+
+		if rows != 2 {
+			t.Fatalf("Expected [2] vote rows, got [%d]", rows)
+		}
+		if userAgent != "vote-bot" {
+			t.Fatalf("Expected voter user agent to be [vote-bot], got [%s]", userAgent)
+		}
+	})
+
+	t.Run("Keeps the weight of a vote apart from its choice", func(t *testing.T) {
+		poll, err := NewSQLPoll("sqlite", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		poll.VoteAs(Voter{}, Ballot{Choices: []string{":joy:"}, Weight: 2.5})
+		poll.VoteAs(Voter{}, Ballot{Choices: []string{":joy:"}, Weight: 0})
+
+		var shortcode string
+		var weight float64
+		poll.(*sqlPoll).db.QueryRow(`SELECT shortcode, weight FROM votes ORDER BY id LIMIT 1`).Scan(&shortcode, &weight)
+		if shortcode != ":joy:" || weight != 2.5 {
+			t.Fatalf("Expected a row for [:joy:] weighing [2.5], got [%s] weighing [%v]", shortcode, weight)
+		}
+
+		results, _ := poll.Results()
+		if len(results) != 1 || results[0].NumVotes != 2 || results[0].Weighted != 2.5 {
+			t.Fatalf("Expected [2] votes for [:joy:] weighing [2.5], got [%v]", results)
+		}
+		if err := poll.Retract(Voter{}, []string{":joy:"}); err != nil {
+			t.Fatal(err)
+		}
+		if results, _ = poll.Results(); results[0].Weighted != 2.5 {
+			t.Fatalf("Expected the newest vote to be retracted, got [%v]", results[0])
+		}
+	})
+
+	t.Run("Sorts results by number of votes", func(t *testing.T) {
+		poll, err := NewSQLPoll("sqlite", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		poll.Vote(":2:")
+		poll.Vote(":1:")
+		poll.Vote(":1:")
+		poll.Vote(":2:")
+		poll.Vote(":1:")
+
+		results, err := poll.Results()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("Expected [2] results, got [%d]", len(results))
+		}
+		if results[0].Shortcode != ":1:" || results[0].NumVotes != 3 {
+			t.Fatalf("Expected 1st place to be [:1:] with [3] votes, got [%v]", results[0])
+		}
+		if results[1].Shortcode != ":2:" || results[1].NumVotes != 2 {
+			t.Fatalf("Expected 2nd place to be [:2:] with [2] votes, got [%v]", results[1])
+		}
+	})
+
+	t.Run("Supersedes earlier votes of a voter with an ID", func(t *testing.T) {
+		poll, err := NewSQLPoll("sqlite", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		poll.VoteAs(Voter{ID: "voter-1"}, ballotFor(":joy:"))
+		poll.VoteAs(Voter{ID: "voter-1"}, ballotFor(":ghost:"))
+		poll.Vote(":joy:")
+
+		if votes := votesFor(t, poll, ":joy:"); votes != 1 {
+			t.Fatalf("Expected [1] vote for :joy:, got [%d]", votes)
+		}
+		if votes := votesFor(t, poll, ":ghost:"); votes != 1 {
+			t.Fatalf("Expected [1] vote for :ghost:, got [%d]", votes)
+		}
+
+		var rows int
+		poll.(*sqlPoll).db.QueryRow(`SELECT COUNT(*) FROM votes`).Scan(&rows)
+		if rows != 3 {
+			t.Fatalf("Expected superseded votes to be kept, got [%d] rows", rows)
+		}
+	})
+
+	t.Run("Retracts votes singly and in bulk", func(t *testing.T) {
+		poll, err := NewSQLPoll("sqlite", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+		poll.(*sqlPoll).now = func() time.Time { return now }
+
+		poll.Vote(":joy:")
+		poll.VoteAs(Voter{ID: "voter-1"}, ballotFor(":ghost:"))
+		now = now.Add(time.Hour)
+		poll.Vote(":robot:")
+		poll.Vote(":robot:")
+
+		if err := poll.Retract(Voter{}, []string{":ghost:"}); !errors.Is(err, ErrNoVote) {
+			t.Fatalf("Expected anonymous retraction to leave the voter's vote alone, got [%v]", err)
+		}
+		if err := poll.Retract(Voter{ID: "voter-1"}, []string{":ghost:"}); err != nil {
+			t.Fatal(err)
+		}
+		retracted, err := poll.RetractVotes(RetractFilter{Since: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retracted != 2 {
+			t.Fatalf("Expected [2] recent votes retracted, got [%d]", retracted)
+		}
+
+		results, _ := poll.Results()
+		if len(results) != 1 || results[0].Shortcode != ":joy:" {
+			t.Fatalf("Expected only the vote for :joy: to be left, got [%v]", results)
+		}
+	})
+
+	t.Run("Reports storage errors as such", func(t *testing.T) {
+		poll, err := NewSQLPoll("sqlite", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll.(*sqlPoll).db.Close()
+
+		if _, err := poll.Results(); !errors.Is(err, ErrStorage) {
+			t.Fatalf("Expected results from a closed database to fail with [%v], got [%v]", ErrStorage, err)
+		}
+		if err := poll.Vote(":joy:"); !errors.Is(err, ErrStorage) {
+			t.Fatalf("Expected a vote in a closed database to fail with [%v], got [%v]", ErrStorage, err)
+		}
+	})
+
+	t.Run("Keeps votes and migrates once across reopens", func(t *testing.T) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+		dsn := filepath.Join(dir, "votes.db")
+
+		poll, err := NewSQLPoll("sqlite", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll.Vote(":joy:")
+		poll.(*sqlPoll).db.Close()
+
+		reopened, err := NewSQLPoll("sqlite", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.(*sqlPoll).db.Close()
+
+		if votes := votesFor(t, reopened, ":joy:"); votes != 1 {
+			t.Fatalf("Expected [1] vote for :joy: after reopening, got [%d]", votes)
+		}
+
+		var applied int
+		reopened.(*sqlPoll).db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied)
+		if applied != len(migrations) {
+			t.Fatalf("Expected [%d] applied migrations, got [%d]", len(migrations), applied)
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package voting
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStripes is how many stripes the polls of the memory store spread
+// their votes over.
+const DefaultStripes = 32
+
+// stripedPoll keeps the same tally as inMemoryPoll, split over stripes that
+// each have their own lock, so votes only contend with the votes that land
+// on the same stripe. Votes with a voter ID always land on the stripe of
+// that ID, so the voter's current vote is kept in one place, and anonymous
+// votes are dealt out over every stripe in turn. Votes aren't logged one by
+// one.
+//
+// The leaderboard is kept between calls to Results, ranked as the Polls it
+// belongs to rank it, and only the choices whose votes changed in between are
+// added up again. As it rarely changes much in between, re-ranking the
+// previous order is close to linear, and Polls find it in order already.
+type stripedPoll struct {
+	stripes []*inMemoryPoll
+	mode    BallotMode
+	// next is the stripe the next anonymous vote lands on.
+	next uint32
+	// version counts every change to the tally. The stripes count their
+	// changes in it.
+	version uint64
+	counter *emojiCounter
+	board   leaderboard
+}
+
+// leaderboard is the ranking of a stripedPoll as of a version of its tally.
+type leaderboard struct {
+	sync.Mutex
+	ranking ranking
+	version uint64
+	results []*Result
+}
+
+func (p *stripedPoll) Vote(choice string) error {
+	return p.VoteAs(Voter{}, Ballot{Choices: []string{choice}, Weight: 1})
+}
+
+func (p *stripedPoll) VoteAs(voter Voter, ballot Ballot) error {
+	if err := ballot.check(); err != nil {
+		return err
+	}
+	s := p.stripe(voter.ID)
+	s.Lock()
+	previous, moved := s.tally(voter.ID, ballot, s.minute())
+	s.Unlock()
+	if moved && previous.equal(ballot) {
+		return nil
+	}
+	p.counter.vote(ballot, p.mode)
+	return nil
+}
+
+// stripe is the stripe a vote lands on.
+func (p *stripedPoll) stripe(voterID string) *inMemoryPoll {
+	if voterID == "" {
+		return p.stripes[atomic.AddUint32(&p.next, 1)%uint32(len(p.stripes))]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(voterID))
+	return p.stripes[h.Sum32()%uint32(len(p.stripes))]
+}
+
+// Retract takes back the vote of a voter with an ID from their stripe, and
+// an anonymous vote from the first stripe that has one.
+func (p *stripedPoll) Retract(voter Voter, choices []string) error {
+	stripes := p.stripes
+	if voter.ID != "" {
+		stripes = []*inMemoryPoll{p.stripe(voter.ID)}
+	}
+
+	var err error
+	for _, s := range stripes {
+		var ballot Ballot
+		s.Lock()
+		if err = s.retractable(voter.ID, choices); err == nil {
+			ballot = s.takeBack(voter.ID, choices)
+		}
+		s.Unlock()
+		if err == nil {
+			log.Printf("Retracted vote for [%s]", ballot)
+			return nil
+		}
+	}
+	return err
+}
+
+func (p *stripedPoll) RetractVotes(filter RetractFilter) (int, error) {
+	retracted := 0
+	for _, s := range p.stripes {
+		s.Lock()
+		retracted += s.retractVotes(filter)
+		s.Unlock()
+	}
+	log.Printf("Retracted [%d] votes", retracted)
+	return retracted, nil
+}
+
+func (p *stripedPoll) Results() ([]*Result, error) {
+	version := atomic.LoadUint64(&p.version)
+
+	p.board.Lock()
+	defer p.board.Unlock()
+
+	if p.board.results == nil || p.board.version != version {
+		p.board.rerank(p.totals(p.changed(p.board.results == nil)))
+		p.board.version = version
+	}
+	return copyResults(p.board.results), nil
+}
+
+func (p *stripedPoll) Ballots() ([]*BallotCount, error) {
+	counts := make(ballotCounts)
+	for _, s := range p.stripes {
+		s.RLock()
+		s.addBallots(counts)
+		s.RUnlock()
+	}
+	return counts.sorted(), nil
+}
+
+// setRanking has the leaderboard ranked from now on as Polls rank it.
+func (p *stripedPoll) setRanking(tiebreak Tiebreak, ballot []string) {
+	p.board.Lock()
+	defer p.board.Unlock()
+
+	p.board.ranking = newRanking(tiebreak, ballot)
+	p.board.results = nil
+}
+
+// changed takes the choices whose counts changed on any stripe since it was
+// last called, or every choice ever counted if all is set.
+func (p *stripedPoll) changed(all bool) map[string]bool {
+	changed := make(map[string]bool)
+	for _, s := range p.stripes {
+		s.Lock()
+		for choice := range s.changed {
+			changed[choice] = true
+		}
+		if all {
+			for choice := range s.since {
+				changed[choice] = true
+			}
+		}
+		s.changed = make(map[string]bool)
+		s.Unlock()
+	}
+	return changed
+}
+
+// totals adds up the votes for the given choices on every stripe. A choice
+// reached its total at the latest change to it on any stripe.
+func (p *stripedPoll) totals(choices map[string]bool) map[string]*Result {
+	totals := make(map[string]*Result, len(choices))
+	for choice := range choices {
+		totals[choice] = &Result{Shortcode: choice}
+	}
+	for _, s := range p.stripes {
+		s.RLock()
+		for choice, t := range totals {
+			t.NumVotes += s.votes[choice]
+			t.Weighted += s.weighted[choice]
+			if s.since[choice] > t.Since {
+				t.Since = s.since[choice]
+			}
+		}
+		s.RUnlock()
+	}
+	return totals
+}
+
+// rerank updates the leaderboard to the new totals of the choices that
+// changed, re-ranking it from its previous order, and numbers it. Choices
+// left without votes drop off it.
+func (b *leaderboard) rerank(totals map[string]*Result) {
+	results := make([]*Result, 0, len(b.results)+len(totals))
+	for _, r := range b.results {
+		if t, ok := totals[r.Shortcode]; ok {
+			r = t
+			delete(totals, t.Shortcode)
+		}
+		if r.NumVotes > 0 {
+			results = append(results, r)
+		}
+	}
+	for _, t := range totals {
+		if t.NumVotes > 0 {
+			results = append(results, t)
+		}
+	}
+
+	// An insertion sort, which is quick on a leaderboard that is nearly in
+	// order already.
+	for i := 1; i < len(results); i++ {
+		for j := i; j > 0 && b.ranking.less(results[j], results[j-1]); j-- {
+			results[j], results[j-1] = results[j-1], results[j]
+		}
+	}
+	number(results)
+	b.results = results
+}
+
+// historyShards gives each stripe a shard of the history of its own, so
+// votes only contend over the history with the votes on the same stripe.
+func (p *stripedPoll) historyShards() int {
+	return len(p.stripes)
+}
+
+func (p *stripedPoll) setHistory(history *History, now func() time.Time) {
+	for i, s := range p.stripes {
+		s.setHistoryShard(history.shard(i), now)
+	}
+}
+
+func (p *stripedPoll) setNow(now func() time.Time) {
+	for _, s := range p.stripes {
+		s.now = now
+	}
+}
+
+func newStripedPoll(stripes int, mode BallotMode) *stripedPoll {
+	if stripes < 1 {
+		stripes = 1
+	}
+	p := &stripedPoll{
+		stripes: make([]*inMemoryPoll, stripes),
+		mode:    mode,
+		counter: counter,
+	}
+	p.board.ranking = newRanking(TiebreakFirst, nil)
+	for i := range p.stripes {
+		p.stripes[i] = newInMemoryPoll(mode)
+		p.stripes[i].changes = &p.version
+		p.stripes[i].changed = make(map[string]bool)
+	}
+	return p
+}
+
+// NewStripedPoll returns an in-memory poll for many concurrent voters, which
+// spreads its votes over the given number of stripes.
+func NewStripedPoll(stripes int) Poll {
+	return newStripedPoll(stripes, BallotPlurality)
+}
@@ -0,0 +1,217 @@
+package voting
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStripedPoll(t *testing.T) {
+	t.Run("Counts concurrent votes", func(t *testing.T) {
+		poll := newStripedPoll(8, BallotPlurality)
+
+		var wg sync.WaitGroup
+		for g := 0; g < 16; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					poll.Vote(":joy:")
+					if g%2 == 0 {
+						poll.VoteAs(Voter{ID: fmt.Sprintf("voter-%d", g)}, ballotFor(fmt.Sprintf(":%d:", i)))
+					}
+				}
+				poll.Results()
+			}(g)
+		}
+		wg.Wait()
+
+		results, _ := poll.Results()
+		if len(results) != 2 || results[0].Shortcode != ":joy:" || results[0].NumVotes != 1600 {
+			t.Fatalf("Expected [1600] votes for [:joy:] and each voter's last vote, got [%v] [%v]", len(results), results[0])
+		}
+		if results[1].Shortcode != ":99:" || results[1].NumVotes != 8 {
+			t.Fatalf("Expected the [8] voters to have moved their vote to [:99:], got [%v]", results[1])
+		}
+	})
+
+	t.Run("Keeps the leaderboard in order", func(t *testing.T) {
+		poll := newStripedPoll(4, BallotPlurality)
+
+		poll.Vote(":ghost:")
+		poll.Results()
+		poll.Vote(":joy:")
+		results, _ := poll.Results()
+		if results[0].Shortcode != ":ghost:" || results[1].Shortcode != ":joy:" {
+			t.Fatalf("Expected a tie to keep [:ghost:] first, got [%v] [%v]", results[0], results[1])
+		}
+
+		results[0].NumVotes = 100
+		poll.Vote(":joy:")
+		results, _ = poll.Results()
+		if results[0].Shortcode != ":joy:" || results[0].NumVotes != 2 || results[1].NumVotes != 1 {
+			t.Fatalf("Expected [:joy:] to overtake [:ghost:], got [%v] [%v]", results[0], results[1])
+		}
+
+		poll.Retract(Voter{}, []string{":joy:"})
+		poll.Retract(Voter{}, []string{":joy:"})
+		results, _ = poll.Results()
+		if len(results) != 1 || results[0].Shortcode != ":ghost:" {
+			t.Fatalf("Expected only [:ghost:] left, got [%v]", results)
+		}
+	})
+
+	t.Run("Retracts votes from any stripe", func(t *testing.T) {
+		poll := newStripedPoll(4, BallotPlurality)
+		now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+		poll.setNow(func() time.Time { return now })
+
+		poll.Vote(":joy:")
+		poll.VoteAs(Voter{ID: "voter-1"}, ballotFor(":joy:"))
+		now = now.Add(10 * time.Minute)
+		for i := 0; i < 5; i++ {
+			poll.Vote(":robot:")
+		}
+
+		if err := poll.Retract(Voter{}, []string{":joy:"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := poll.Retract(Voter{}, []string{":joy:"}); !errors.Is(err, ErrNoVote) {
+			t.Fatalf("Expected anonymous retraction to leave the voter's vote alone, got [%v]", err)
+		}
+		if err := poll.Retract(Voter{ID: "voter-2"}, []string{":joy:"}); !errors.Is(err, ErrNoVote) {
+			t.Fatalf("Expected retraction by another voter to be rejected, got [%v]", err)
+		}
+
+		retracted, _ := poll.RetractVotes(RetractFilter{Since: now.Add(-time.Minute)})
+		if retracted != 5 {
+			t.Fatalf("Expected [5] recent votes retracted, got [%d]", retracted)
+		}
+		results, _ := poll.Results()
+		if len(results) != 1 || results[0].Shortcode != ":joy:" || results[0].NumVotes != 1 {
+			t.Fatalf("Expected only voter-1's vote left, got [%v]", results)
+		}
+	})
+}
+
+// BenchmarkPolls compares how many votes a second Polls take in the
+// in-memory polls, history and live updates included, from 1, 8 and 64
+// goroutines, with a watcher reading the leaderboard as soon as it changes,
+// at most every millisecond.
+func BenchmarkPolls(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	stores := []struct {
+		name  string
+		store Store
+	}{
+		{"locked", lockedStore{}},
+		{"striped", NewMemoryStore()},
+	}
+	for _, s := range stores {
+		for _, goroutines := range []int{1, 8, 64} {
+			b.Run(fmt.Sprintf("%s/goroutines=%d", s.name, goroutines), func(b *testing.B) {
+				ballot := make([]string, 100)
+				for i := range ballot {
+					ballot[i] = fmt.Sprintf(":%d:", i)
+				}
+				polls, err := NewPolls(s.store, ballot)
+				if err != nil {
+					b.Fatal(err)
+				}
+				changed, stop, err := polls.Watch("")
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer stop()
+
+				done := make(chan struct{})
+				go func() {
+					for {
+						select {
+						case <-done:
+							return
+						case <-changed:
+							polls.Results("")
+							time.Sleep(time.Millisecond)
+						}
+					}
+				}()
+				defer close(done)
+
+				var wg sync.WaitGroup
+				start := time.Now()
+				b.ResetTimer()
+				for g := 0; g < goroutines; g++ {
+					wg.Add(1)
+					go func(g int) {
+						defer wg.Done()
+						for i := g; i < b.N; i += goroutines {
+							polls.Vote("", Voter{}, ballot[i%len(ballot)])
+						}
+					}(g)
+				}
+				wg.Wait()
+				b.StopTimer()
+				b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "votes/s")
+			})
+		}
+	}
+}
+
+// lockedStore keeps polls in memory behind a single lock, for comparison.
+type lockedStore struct{ memoryStore }
+
+func (lockedStore) Open(info *PollInfo) (Poll, error) {
+	return newInMemoryPoll(info.Mode), nil
+}
+
+// BenchmarkPollsResults reads the leaderboard of a poll with 1000 choices
+// through Polls, which rank it, with no votes and with 10 votes cast between
+// reads.
+func BenchmarkPollsResults(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	stores := []struct {
+		name  string
+		store Store
+	}{
+		{"locked", lockedStore{}},
+		{"striped", NewMemoryStore()},
+	}
+	for _, s := range stores {
+		for _, votes := range []int{0, 10} {
+			b.Run(fmt.Sprintf("%s/votes=%d", s.name, votes), func(b *testing.B) {
+				ballot := make([]string, 1000)
+				for i := range ballot {
+					ballot[i] = fmt.Sprintf(":%d:", i)
+				}
+				polls, _ := NewPolls(s.store, ballot)
+				for i, choice := range ballot {
+					for j := 0; j < i%10; j++ {
+						polls.Vote("", Voter{}, choice)
+					}
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					for j := 0; j < votes; j++ {
+						if err := polls.Vote("", Voter{}, ballot[(i*votes+j)%len(ballot)]); err != nil {
+							b.Fatal(err)
+						}
+					}
+					if _, err := polls.Results(""); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package voting
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTournamentSize is how many emoji a tournament seeds from the
+	// catalog when it isn't given its entrants.
+	DefaultTournamentSize = 8
+	// DefaultMatchupDuration is how long each matchup is open for votes.
+	DefaultMatchupDuration = time.Minute
+
+	maxTournamentSize = 64
+	// maxTournamentIDLength leaves room in poll IDs for the matchup suffix.
+	maxTournamentIDLength = 48
+)
+
+var (
+	ErrTournamentNotFound = errors.New("tournament not found")
+	ErrTournamentExists   = errors.New("tournament already exists")
+	ErrInvalidTournament  = errors.New("invalid tournament")
+	ErrTournamentOver     = errors.New("tournament is over")
+)
+
+// MatchupState is where a matchup is in a tournament.
+type MatchupState string
+
+const (
+	// MatchupPending matchups wait for their entrants or their turn.
+	MatchupPending MatchupState = "pending"
+	// MatchupOpen is the matchup being voted on.
+	MatchupOpen MatchupState = "open"
+	// MatchupDecided matchups have a winner.
+	MatchupDecided MatchupState = "decided"
+)
+
+// Entrant is an emoji in a matchup, with its seed in the tournament and the
+// votes it got in the matchup. Entrants of matchups waiting on an earlier
+// round have an empty Shortcode.
+type Entrant struct {
+	Shortcode string `json:"shortcode"`
+	Seed      int    `json:"seed"`
+	Votes     int    `json:"votes"`
+}
+
+// Matchup is one head-to-head vote of a tournament, held as a poll of its
+// own with ID PollID. Rounds count from 1 and Index from 0 within a round.
+type Matchup struct {
+	Round    int          `json:"round"`
+	Index    int          `json:"index"`
+	PollID   string       `json:"poll_id"`
+	State    MatchupState `json:"state"`
+	Entrants [2]Entrant   `json:"entrants"`
+	Winner   string       `json:"winner,omitempty"`
+	StartsAt time.Time    `json:"starts_at"`
+	EndsAt   time.Time    `json:"ends_at"`
+}
+
+// Tournament is a single-elimination bracket of emoji. Seeds lists the
+// entrants, best seed first. Rounds holds every matchup of the bracket, the
+// final last, including those still waiting for their entrants.
+type Tournament struct {
+	ID              string        `json:"id"`
+	Title           string        `json:"title"`
+	Seeds           []string      `json:"seeds"`
+	MatchupDuration time.Duration `json:"matchup_duration"`
+	Rounds          [][]Matchup   `json:"rounds"`
+	Champion        string        `json:"champion,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+}
+
+// Current is the matchup being voted on, or nil once the tournament is over.
+func (t *Tournament) Current() *Matchup {
+	for r := range t.Rounds {
+		for i := range t.Rounds[r] {
+			if t.Rounds[r][i].State == MatchupOpen {
+				return &t.Rounds[r][i]
+			}
+		}
+	}
+	return nil
+}
+
+// next is the first matchup, in bracket order, ready to be voted on.
+func (t *Tournament) next() *Matchup {
+	for r := range t.Rounds {
+		for i := range t.Rounds[r] {
+			m := &t.Rounds[r][i]
+			if m.State == MatchupPending && m.Entrants[0].Shortcode != "" && m.Entrants[1].Shortcode != "" {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Tournament) copy() *Tournament {
+	c := *t
+	c.Seeds = append([]string(nil), t.Seeds...)
+	c.Rounds = make([][]Matchup, len(t.Rounds))
+	for r, round := range t.Rounds {
+		c.Rounds[r] = append([]Matchup(nil), round...)
+	}
+	return &c
+}
+
+// Tournaments runs tournaments whose matchups are polls in polls, one matchup
+// at a time. A matchup closes when its time is up or when the tournament is
+// advanced; its winner moves on and the next matchup opens the next time the
+// tournament is looked at, all on the clock of polls. Brackets are kept in
+// the store of polls, alongside the polls of their matchups.
+type Tournaments struct {
+	sync.Mutex
+	polls       *Polls
+	tournaments map[string]*Tournament
+}
+
+// Create seeds a tournament from entrants, best seed first, or from the first
+// size shortcodes of the default ballot when there are none, and opens its
+// first matchup. The number of entrants must be a power of two. Matchups last
+// matchupDuration, or DefaultMatchupDuration when zero.
+func (ts *Tournaments) Create(id, title string, size int, entrants []string, matchupDuration time.Duration) (*Tournament, error) {
+	if !pollIDPattern.MatchString(id) || len(id) > maxTournamentIDLength {
+		return nil, fmt.Errorf("%w: id [%s] must be up to [%d] lowercase letters, digits and dashes", ErrInvalidTournament, id, maxTournamentIDLength)
+	}
+	if title == "" {
+		title = id
+	}
+	if len(entrants) == 0 {
+		if size == 0 {
+			size = DefaultTournamentSize
+		}
+		if size > len(ts.polls.defaultBallot) {
+			return nil, fmt.Errorf("%w: only [%d] emoji to seed [%d] entrants from", ErrInvalidTournament, len(ts.polls.defaultBallot), size)
+		}
+		entrants = ts.polls.defaultBallot[:size]
+	} else if size != 0 && size != len(entrants) {
+		return nil, fmt.Errorf("%w: [%d] entrants for a tournament of [%d]", ErrInvalidTournament, len(entrants), size)
+	}
+	size = len(entrants)
+	if size < 2 || size > maxTournamentSize || size&(size-1) != 0 {
+		return nil, fmt.Errorf("%w: [%d] entrants is not a power of two from 2 to [%d]", ErrInvalidTournament, size, maxTournamentSize)
+	}
+	entrants, err := ts.polls.checkBallot(entrants)
+	if err != nil {
+		return nil, err
+	}
+	if matchupDuration < 0 {
+		return nil, fmt.Errorf("%w: matchups can't last [%v]", ErrInvalidTournament, matchupDuration)
+	}
+	if matchupDuration == 0 {
+		matchupDuration = DefaultMatchupDuration
+	}
+
+	ts.Lock()
+	defer ts.Unlock()
+
+	if _, ok := ts.tournaments[id]; ok {
+		return nil, fmt.Errorf("%w: [%s]", ErrTournamentExists, id)
+	}
+	t := &Tournament{
+		ID:              id,
+		Title:           title,
+		Seeds:           entrants,
+		MatchupDuration: matchupDuration,
+		CreatedAt:       ts.polls.now(),
+	}
+	for matchups := size / 2; matchups > 0; matchups /= 2 {
+		round := make([]Matchup, matchups)
+		for i := range round {
+			round[i] = Matchup{
+				Round:  len(t.Rounds) + 1,
+				Index:  i,
+				PollID: fmt.Sprintf("%s-r%d-m%d", id, len(t.Rounds)+1, i+1),
+				State:  MatchupPending,
+			}
+		}
+		t.Rounds = append(t.Rounds, round)
+	}
+	order := seedOrder(size)
+	for i := range t.Rounds[0] {
+		for j := 0; j < 2; j++ {
+			seed := order[2*i+j]
+			t.Rounds[0][i].Entrants[j] = Entrant{Shortcode: entrants[seed-1], Seed: seed}
+		}
+	}
+
+	if err := ts.open(t, &t.Rounds[0][0]); err != nil {
+		return nil, err
+	}
+	if err := ts.polls.store.SaveTournament(t); err != nil {
+		return nil, fmt.Errorf("%w: saving tournament [%s]: %v", ErrStorage, id, err)
+	}
+	ts.tournaments[id] = t
+	log.Printf("Created tournament [%s] with [%d] entrants", id, size)
+	return t.copy(), nil
+}
+
+// seedOrder lists seeds 1 to size in bracket order, so that consecutive
+// seeds are paired in the first round and the best seeds can only meet late.
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// open creates the poll of a matchup. A poll already created for the same
+// matchup, before the bracket could be saved, is taken up again. Callers must
+// hold the lock.
+func (ts *Tournaments) open(t *Tournament, m *Matchup) error {
+	now := ts.polls.now()
+	title := fmt.Sprintf("%s: round %d, matchup %d", t.Title, m.Round, m.Index+1)
+	ballot := []string{m.Entrants[0].Shortcode, m.Entrants[1].Shortcode}
+	info, err := ts.polls.Create(m.PollID, title, ballot, BallotRules{}, Schedule{EndsAt: now.Add(t.MatchupDuration)})
+	if errors.Is(err, ErrPollExists) {
+		if existing, getErr := ts.polls.Get(m.PollID); getErr == nil && reflect.DeepEqual(existing.Ballot, ballot) {
+			info, err = existing, nil
+			now = existing.CreatedAt
+		}
+	}
+	if err != nil {
+		return err
+	}
+	m.State = MatchupOpen
+	m.StartsAt, m.EndsAt = now, info.EndsAt
+	return nil
+}
+
+// settle decides every matchup whose poll has closed, moving winners on and
+// opening the next matchup, and saves the bracket if it changed. Callers
+// must hold the lock.
+func (ts *Tournaments) settle(t *Tournament) error {
+	settled := false
+	for {
+		m := t.Current()
+		if m == nil {
+			return ts.saveIf(t, settled)
+		}
+		info, err := ts.polls.Get(m.PollID)
+		if err != nil {
+			return err
+		}
+		if info.State == PollOpen {
+			return ts.saveIf(t, settled)
+		}
+		settled = true
+		results, err := ts.polls.Results(m.PollID)
+		if err != nil {
+			return err
+		}
+		decide(m, results)
+		m.EndsAt = info.EndsAt
+		log.Printf("[%s] won matchup [%s]", m.Winner, m.PollID)
+
+		winner := m.Entrants[0]
+		if m.Winner != winner.Shortcode {
+			winner = m.Entrants[1]
+		}
+		winner.Votes = 0
+		if m.Round == len(t.Rounds) {
+			t.Champion = m.Winner
+			log.Printf("[%s] won tournament [%s]", t.Champion, t.ID)
+			return ts.saveIf(t, settled)
+		}
+		t.Rounds[m.Round][m.Index/2].Entrants[m.Index%2] = winner
+		if next := t.next(); next != nil {
+			if err := ts.open(t, next); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// saveIf saves the bracket of a tournament if changed is set. Callers must
+// hold the lock.
+func (ts *Tournaments) saveIf(t *Tournament, changed bool) error {
+	if !changed {
+		return nil
+	}
+	if err := ts.polls.store.SaveTournament(t); err != nil {
+		return fmt.Errorf("%w: saving tournament [%s]: %v", ErrStorage, t.ID, err)
+	}
+	return nil
+}
+
+// decide picks the winner of a matchup from the results of its poll. A tie
+// goes to the better seed.
+func decide(m *Matchup, results []*Result) {
+	for _, r := range results {
+		for j := range m.Entrants {
+			if m.Entrants[j].Shortcode == r.Shortcode {
+				m.Entrants[j].Votes = r.NumVotes
+			}
+		}
+	}
+	a, b := m.Entrants[0], m.Entrants[1]
+	m.Winner = a.Shortcode
+	if b.Votes > a.Votes || (b.Votes == a.Votes && b.Seed < a.Seed) {
+		m.Winner = b.Shortcode
+	}
+	m.State = MatchupDecided
+}
+
+// snapshot copies a settled tournament, with the live votes of its current
+// matchup. Callers must hold the lock.
+func (ts *Tournaments) snapshot(t *Tournament) (*Tournament, error) {
+	c := t.copy()
+	if m := c.Current(); m != nil {
+		results, err := ts.polls.Results(m.PollID)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			for j := range m.Entrants {
+				if m.Entrants[j].Shortcode == r.Shortcode {
+					m.Entrants[j].Votes = r.NumVotes
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+func (ts *Tournaments) get(id string) (*Tournament, error) {
+	t, ok := ts.tournaments[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: [%s]", ErrTournamentNotFound, id)
+	}
+	return t, ts.settle(t)
+}
+
+// Get returns the whole bracket of a tournament.
+func (ts *Tournaments) Get(id string) (*Tournament, error) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	t, err := ts.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return ts.snapshot(t)
+}
+
+// List returns every tournament, oldest first.
+func (ts *Tournaments) List() ([]*Tournament, error) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	tournaments := make([]*Tournament, 0, len(ts.tournaments))
+	for id := range ts.tournaments {
+		t, err := ts.get(id)
+		if err != nil {
+			return nil, err
+		}
+		if t, err = ts.snapshot(t); err != nil {
+			return nil, err
+		}
+		tournaments = append(tournaments, t)
+	}
+	sort.Slice(tournaments, func(i, j int) bool {
+		if !tournaments[i].CreatedAt.Equal(tournaments[j].CreatedAt) {
+			return tournaments[i].CreatedAt.Before(tournaments[j].CreatedAt)
+		}
+		return tournaments[i].ID < tournaments[j].ID
+	})
+	return tournaments, nil
+}
+
+// Advance closes the current matchup of a tournament ahead of time, moves its
+// winner on and opens the next matchup.
+func (ts *Tournaments) Advance(id string) (*Tournament, error) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	t, err := ts.get(id)
+	if err != nil {
+		return nil, err
+	}
+	m := t.Current()
+	if m == nil {
+		return nil, fmt.Errorf("%w: [%s] won tournament [%s]", ErrTournamentOver, t.Champion, t.ID)
+	}
+	if _, err := ts.polls.Close(m.PollID); err != nil {
+		return nil, err
+	}
+	if err := ts.settle(t); err != nil {
+		return nil, err
+	}
+	return ts.snapshot(t)
+}
+
+// NewTournaments loads the tournaments kept in the store of polls, and runs
+// them and new ones in polls, seeding them from its default ballot.
+func NewTournaments(polls *Polls) (*Tournaments, error) {
+	ts := &Tournaments{
+		polls:       polls,
+		tournaments: make(map[string]*Tournament),
+	}
+	tournaments, err := polls.store.LoadTournaments()
+	if err != nil {
+		return nil, fmt.Errorf("loading tournaments: %v", err)
+	}
+	for _, t := range tournaments {
+		ts.tournaments[t.ID] = t
+	}
+	return ts, nil
+}
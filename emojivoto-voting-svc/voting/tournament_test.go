@@ -0,0 +1,228 @@
+package voting
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTournaments(t *testing.T) {
+	start := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	newTournaments := func() (*Tournaments, *time.Time) {
+		polls, _ := NewPolls(NewMemoryStore(), append(testBallot, ":100:", ":fire:", ":cat:", ":dog:"))
+		now := start
+		polls.now = func() time.Time { return now }
+		ts, _ := NewTournaments(polls)
+		return ts, &now
+	}
+
+	t.Run("Seeds the bracket from the default ballot", func(t *testing.T) {
+		ts, _ := newTournaments()
+
+		tournament, err := ts.Create("cup", "", 0, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tournament.Seeds) != DefaultTournamentSize || tournament.Seeds[0] != ":joy:" || tournament.MatchupDuration != DefaultMatchupDuration {
+			t.Fatalf("Expected the first [%d] emoji as seeds, got [%v]", DefaultTournamentSize, tournament.Seeds)
+		}
+		if len(tournament.Rounds) != 3 || len(tournament.Rounds[0]) != 4 || len(tournament.Rounds[2]) != 1 {
+			t.Fatalf("Expected rounds of 4, 2 and 1 matchups, got [%v]", tournament.Rounds)
+		}
+		seeds := make([]int, 0)
+		for _, m := range tournament.Rounds[0] {
+			seeds = append(seeds, m.Entrants[0].Seed, m.Entrants[1].Seed)
+		}
+		if expected := []int{1, 8, 4, 5, 2, 7, 3, 6}; !reflect.DeepEqual(seeds, expected) {
+			t.Fatalf("Expected seeds paired as [%v], got [%v]", expected, seeds)
+		}
+		current := tournament.Current()
+		if current == nil || current.PollID != "cup-r1-m1" || !current.EndsAt.Equal(start.Add(time.Minute)) {
+			t.Fatalf("Expected the first matchup to be open for a minute, got [%v]", current)
+		}
+		if next := tournament.Rounds[1][0]; next.State != MatchupPending || next.Entrants[0].Shortcode != "" {
+			t.Fatalf("Expected later rounds to wait for their entrants, got [%v]", next)
+		}
+	})
+
+	t.Run("Validates new tournaments", func(t *testing.T) {
+		ts, _ := newTournaments()
+
+		if _, err := ts.Create("cup", "", 6, nil, 0); !errors.Is(err, ErrInvalidTournament) {
+			t.Fatalf("Expected a bracket of 6 to be rejected, got [%v]", err)
+		}
+		if _, err := ts.Create("cup", "", 16, nil, 0); !errors.Is(err, ErrInvalidTournament) {
+			t.Fatalf("Expected more entrants than emoji to be rejected, got [%v]", err)
+		}
+		if _, err := ts.Create("cup", "", 0, []string{":joy:", ":unknown:"}, 0); !errors.Is(err, ErrInvalidPoll) {
+			t.Fatalf("Expected an unknown entrant to be rejected, got [%v]", err)
+		}
+		if _, err := ts.Create("Cup!", "", 0, nil, 0); !errors.Is(err, ErrInvalidTournament) {
+			t.Fatalf("Expected an invalid id to be rejected, got [%v]", err)
+		}
+		if _, err := ts.Create("cup", "", 2, nil, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ts.Create("cup", "", 2, nil, 0); !errors.Is(err, ErrTournamentExists) {
+			t.Fatalf("Expected a second [cup] to be rejected, got [%v]", err)
+		}
+		if _, err := ts.Get("bowl"); !errors.Is(err, ErrTournamentNotFound) {
+			t.Fatalf("Expected an unknown tournament not to be found, got [%v]", err)
+		}
+	})
+
+	t.Run("Runs matchups on the clock and advances the winners", func(t *testing.T) {
+		ts, now := newTournaments()
+		entrants := []string{":joy:", ":ghost:", ":pizza:", ":taco:"}
+		ts.Create("cup", "Cup", 0, entrants, 10*time.Minute)
+
+		// Seed 4 beats seed 1, and seeds 2 and 3 tie.
+		ts.polls.Vote("cup-r1-m1", Voter{}, ":taco:")
+		*now = now.Add(10 * time.Minute)
+
+		tournament, _ := ts.Get("cup")
+		first := tournament.Rounds[0][0]
+		if first.State != MatchupDecided || first.Winner != ":taco:" || first.Entrants[1].Votes != 1 {
+			t.Fatalf("Expected [:taco:] to win the first matchup, got [%v]", first)
+		}
+		current := tournament.Current()
+		if current == nil || current.PollID != "cup-r1-m2" || !current.StartsAt.Equal(*now) {
+			t.Fatalf("Expected the second matchup to open now, got [%v]", current)
+		}
+		if err := ts.polls.Vote("cup-r1-m1", Voter{}, ":joy:"); !errors.Is(err, ErrPollNotOpen) {
+			t.Fatalf("Expected the first matchup to be closed, got [%v]", err)
+		}
+
+		ts.polls.Vote("cup-r1-m2", Voter{}, ":ghost:")
+		ts.polls.Vote("cup-r1-m2", Voter{}, ":pizza:")
+		tournament, _ = ts.Get("cup")
+		if votes := tournament.Current().Entrants; votes[0].Votes != 1 || votes[1].Votes != 1 {
+			t.Fatalf("Expected live votes for the current matchup, got [%v]", votes)
+		}
+		*now = now.Add(10 * time.Minute)
+
+		tournament, _ = ts.Get("cup")
+		if winner := tournament.Rounds[0][1].Winner; winner != ":ghost:" {
+			t.Fatalf("Expected the tie to go to seed 2 [:ghost:], got [%s]", winner)
+		}
+		final := tournament.Current()
+		if final == nil || final.Round != 2 || final.Entrants[0].Shortcode != ":taco:" || final.Entrants[1].Shortcode != ":ghost:" {
+			t.Fatalf("Expected a final between [:taco:] and [:ghost:], got [%v]", final)
+		}
+
+		ts.polls.Vote("cup-r2-m1", Voter{}, ":taco:")
+		tournament, err := ts.Advance("cup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tournament.Champion != ":taco:" || tournament.Current() != nil {
+			t.Fatalf("Expected [:taco:] to be champion, got [%v]", tournament)
+		}
+		if !tournament.Rounds[1][0].EndsAt.Equal(*now) {
+			t.Fatalf("Expected the final to end when advanced, got [%v]", tournament.Rounds[1][0].EndsAt)
+		}
+		if _, err := ts.Advance("cup"); !errors.Is(err, ErrTournamentOver) {
+			t.Fatalf("Expected no more matchups, got [%v]", err)
+		}
+	})
+
+	t.Run("Catches up on every matchup that ended", func(t *testing.T) {
+		ts, now := newTournaments()
+		ts.Create("cup", "", 4, nil, time.Minute)
+		ts.Create("bowl", "", 2, nil, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			*now = now.Add(time.Minute)
+			ts.Get("cup")
+		}
+
+		tournaments, err := ts.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tournaments) != 2 || tournaments[0].Champion != ":joy:" || tournaments[1].Champion != ":joy:" {
+			t.Fatalf("Expected top seed [:joy:] to win both tournaments without votes, got [%v]", tournaments)
+		}
+	})
+
+	t.Run("Takes up the poll of a matchup left from before", func(t *testing.T) {
+		ts, _ := newTournaments()
+		ts.polls.Create("cup-r1-m1", "", []string{":joy:", ":ghost:"}, BallotRules{}, Schedule{})
+		ts.polls.Vote("cup-r1-m1", Voter{}, ":ghost:")
+
+		tournament, err := ts.Create("cup", "", 2, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if votes := tournament.Current().Entrants[1].Votes; votes != 0 {
+			t.Fatalf("Expected no live votes until the tournament is looked at again, got [%d]", votes)
+		}
+		tournament, _ = ts.Get("cup")
+		if votes := tournament.Current().Entrants[1].Votes; votes != 1 {
+			t.Fatalf("Expected the vote cast in the poll left from before, got [%d]", votes)
+		}
+		if _, err := ts.Create("bowl", "", 0, []string{":pizza:", ":taco:"}, 0); err != nil {
+			t.Fatal(err)
+		}
+		ts.polls.Create("dish-r1-m1", "", []string{":pizza:", ":joy:"}, BallotRules{}, Schedule{})
+		if _, err := ts.Create("dish", "", 0, []string{":pizza:", ":taco:"}, 0); !errors.Is(err, ErrPollExists) {
+			t.Fatalf("Expected a poll for other entrants to be left alone, got [%v]", err)
+		}
+	})
+
+	for name, newStore := range durableStores {
+		t.Run("Restores tournaments with the "+name+" store", func(t *testing.T) {
+			dir := tempPollDir(t)
+			defer os.RemoveAll(dir)
+
+			now := start
+			open := func() *Tournaments {
+				store, err := newStore(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				polls, err := NewPolls(store, testBallot)
+				if err != nil {
+					t.Fatal(err)
+				}
+				polls.now = func() time.Time { return now }
+				ts, err := NewTournaments(polls)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ts
+			}
+
+			ts := open()
+			ts.Create("cup", "Cup", 4, nil, time.Minute)
+			ts.polls.Vote("cup-r1-m1", Voter{}, ":taco:")
+			ts.Advance("cup")
+			created, _ := ts.Get("cup")
+
+			ts = open()
+			restored, err := ts.Get("cup")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(restored, created) {
+				t.Fatalf("Expected the bracket [%v] to be restored, got [%v]", created, restored)
+			}
+			if _, err := ts.Create("cup", "", 2, nil, 0); !errors.Is(err, ErrTournamentExists) {
+				t.Fatalf("Expected a second [cup] to be rejected, got [%v]", err)
+			}
+
+			now = now.Add(time.Minute)
+			ts.Get("cup")
+			ts = open()
+			ts.polls.Vote("cup-r2-m1", Voter{}, ":taco:")
+			now = now.Add(time.Minute)
+			ts = open()
+			tournament, _ := ts.Get("cup")
+			if tournament.Champion != ":taco:" {
+				t.Fatalf("Expected [:taco:] to have won after a restart, got [%v]", tournament)
+			}
+		})
+	}
+}
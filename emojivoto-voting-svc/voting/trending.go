@@ -0,0 +1,143 @@
+package voting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultTrendingHalfLife is how long it takes a vote to count for half
+	// as much on the trending leaderboard.
+	DefaultTrendingHalfLife = 10 * time.Minute
+
+	// minTrendingScore is the score below which a choice drops off the
+	// trending leaderboard: that of a single vote some seven half-lives old.
+	minTrendingScore = 0.01
+)
+
+var ErrInvalidTrending = errors.New("invalid trending request")
+
+// Movement is how a choice moved on the trending leaderboard over a window.
+type Movement string
+
+const (
+	// Rising choices went up the leaderboard, or onto it.
+	Rising Movement = "rising"
+	// Falling choices went down the leaderboard.
+	Falling Movement = "falling"
+	// Steady choices kept their place.
+	Steady Movement = "steady"
+)
+
+// Trend is a choice on the trending leaderboard. Score counts the votes
+// cast for it, each halved for every half-life since it was cast, and
+// NumVotes counts every vote it has. Rank is its place by score now, and
+// PreviousRank its place a window ago, or zero if it wasn't on the
+// leaderboard then.
+type Trend struct {
+	Shortcode    string
+	Score        float64
+	NumVotes     int
+	Rank         int
+	PreviousRank int
+	Movement     Movement
+}
+
+// Trends is a trending leaderboard, with the half-life and window it was
+// drawn up with.
+type Trends struct {
+	HalfLife time.Duration
+	Window   time.Duration
+	Trends   []*Trend
+}
+
+// Trending ranks the choices with votes in the history by score, decaying
+// votes with the given half-life, and tells how each moved since a window
+// ago. Votes count from the middle of the minute they were cast in, or of
+// the hour once per-minute counts are no longer kept.
+func (h *History) Trending(halfLife, window time.Duration) (*Trends, error) {
+	if halfLife <= 0 || window <= 0 {
+		return nil, fmt.Errorf("%w: half-life [%v] and window [%v] must be positive", ErrInvalidTrending, halfLife, window)
+	}
+
+	now := h.now()
+	c := h.counts(now)
+	if window > c.retention {
+		return nil, fmt.Errorf("%w: window [%v] is longer than the history kept, [%v]", ErrInvalidTrending, window, c.retention)
+	}
+
+	trends := rankTrends(c.scores(now, now, halfLife))
+	previous := make(map[string]int)
+	for _, t := range rankTrends(c.scores(now, now.Add(-window), halfLife)) {
+		previous[t.Shortcode] = t.Rank
+	}
+	for _, t := range trends {
+		t.PreviousRank = previous[t.Shortcode]
+		switch {
+		case t.PreviousRank == 0 || t.Rank < t.PreviousRank:
+			t.Movement = Rising
+		case t.Rank > t.PreviousRank:
+			t.Movement = Falling
+		default:
+			t.Movement = Steady
+		}
+	}
+	return &Trends{HalfLife: halfLife, Window: window, Trends: trends}, nil
+}
+
+// scores adds up the decayed votes cast for each choice as of at. Votes are
+// counted by the minute from the first hour that per-minute counts still
+// cover in full, and by the hour before that.
+func (c *historyCounts) scores(now, at time.Time, halfLife time.Duration) map[string]float64 {
+	byMinute := now.Add(-c.minuteRetention).Truncate(time.Hour).Add(time.Hour)
+
+	scores := make(map[string]float64)
+	add := func(start time.Time, step time.Duration, counts map[string]int) {
+		if start.After(at) {
+			return
+		}
+		cast := start.Add(step / 2)
+		if cast.After(at) {
+			cast = at
+		}
+		weight := math.Exp2(-at.Sub(cast).Seconds() / halfLife.Seconds())
+		for choice, numVotes := range counts {
+			scores[choice] += float64(numVotes) * weight
+		}
+	}
+	for minute, counts := range c.minutes {
+		if start := time.Unix(minute, 0); !start.Before(byMinute) {
+			add(start, time.Minute, counts)
+		}
+	}
+	for hour, counts := range c.hours {
+		if start := time.Unix(hour, 0); start.Before(byMinute) {
+			add(start, time.Hour, counts)
+		}
+	}
+	return scores
+}
+
+// rankTrends ranks the choices scoring enough to be on the leaderboard,
+// highest first, and by shortcode when they score the same.
+func rankTrends(scores map[string]float64) []*Trend {
+	trends := make([]*Trend, 0, len(scores))
+	for choice, score := range scores {
+		if score >= minTrendingScore {
+			trends = append(trends, &Trend{Shortcode: choice, Score: score})
+		}
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Score != trends[j].Score {
+			return trends[i].Score > trends[j].Score
+		}
+		return trends[i].Shortcode < trends[j].Shortcode
+	})
+	for i, t := range trends {
+		t.Rank = i + 1
+	}
+	return trends
+}
@@ -0,0 +1,103 @@
+package voting
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrending(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Decays votes and tells what is rising and falling", func(t *testing.T) {
+		history := NewHistory(time.Hour, 24*time.Hour)
+		now := start
+		history.now = func() time.Time { return now }
+
+		for i := 0; i < 8; i++ {
+			history.Record(":joy:")
+		}
+		now = now.Add(30 * time.Minute)
+		for i := 0; i < 4; i++ {
+			history.Record(":doughnut:")
+		}
+		now = now.Add(30 * time.Second)
+
+		board, err := history.Trending(10*time.Minute, 10*time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trends := board.Trends
+		if len(trends) != 2 {
+			t.Fatalf("Expected [2] trends, got [%d]", len(trends))
+		}
+		doughnut, joy := trends[0], trends[1]
+		if doughnut.Shortcode != ":doughnut:" || doughnut.Score != 4 || doughnut.Rank != 1 || doughnut.PreviousRank != 0 || doughnut.Movement != Rising {
+			t.Fatalf("Expected [:doughnut:] to rise to the top with [4], got [%v]", doughnut)
+		}
+		if joy.Shortcode != ":joy:" || joy.Score != 1 || joy.Rank != 2 || joy.PreviousRank != 1 || joy.Movement != Falling {
+			t.Fatalf("Expected [:joy:] to fall to [2] with [8] votes three half-lives old, got [%v]", joy)
+		}
+	})
+
+	t.Run("Counts older votes by the hour and drops stale ones", func(t *testing.T) {
+		history := NewHistory(time.Hour, 24*time.Hour)
+		now := start
+		history.now = func() time.Time { return now }
+
+		for i := 0; i < 8; i++ {
+			history.Record(":joy:")
+		}
+		now = now.Add(30 * time.Minute)
+		for i := 0; i < 4; i++ {
+			history.Record(":doughnut:")
+		}
+		now = now.Add(2 * time.Hour)
+
+		trends := trendsOf(history.Trending(time.Hour, time.Hour))
+		if len(trends) != 2 || math.Abs(trends[0].Score-2) > 1e-9 || math.Abs(trends[1].Score-1) > 1e-9 {
+			t.Fatalf("Expected scores of [2] and [1] from the hourly rollup, got [%d] trends", len(trends))
+		}
+		if trends[0].Movement != Steady {
+			t.Fatalf("Expected [:joy:] to hold its place, got [%v]", trends[0])
+		}
+		if trends := trendsOf(history.Trending(10*time.Minute, 10*time.Minute)); len(trends) != 0 {
+			t.Fatalf("Expected votes twelve half-lives old to drop off, got [%v]", trends[0])
+		}
+	})
+
+	t.Run("Adds all-time votes in polls", func(t *testing.T) {
+		polls, _ := NewPolls(NewMemoryStore(), testBallot)
+		now := start
+		polls.now = func() time.Time { return now }
+
+		polls.Vote("", Voter{}, ":taco:")
+		polls.Vote("", Voter{}, ":taco:")
+		board, err := polls.Trending("", 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if board.HalfLife != DefaultTrendingHalfLife || board.Window != DefaultTrendingHalfLife {
+			t.Fatalf("Expected the default half-life and window, got [%v] and [%v]", board.HalfLife, board.Window)
+		}
+		trends := board.Trends
+		if len(trends) != 1 || trends[0].NumVotes != 2 || trends[0].Score != 2 {
+			t.Fatalf("Expected [:taco:] trending with [2] votes, got [%d] trends", len(trends))
+		}
+
+		if _, err := polls.Trending("", -time.Minute, 0); !errors.Is(err, ErrInvalidTrending) {
+			t.Fatalf("Expected a negative half-life to be invalid, got [%v]", err)
+		}
+		if _, err := polls.Trending("", 0, 30*24*time.Hour); !errors.Is(err, ErrInvalidTrending) {
+			t.Fatalf("Expected a window beyond the history to be invalid, got [%v]", err)
+		}
+	})
+}
+
+func trendsOf(board *Trends, err error) []*Trend {
+	if err != nil {
+		return nil
+	}
+	return board.Trends
+}
@@ -0,0 +1,124 @@
+package voting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+var ErrInvalidWeightPolicy = errors.New("invalid weight policy")
+
+// WeightMetadata lists the metadata keys weight rules can match, which are
+// the only request metadata kept with a Voter. Callers can't be trusted with
+// any other, and voter-class is only set by the web service, from an API key
+// it knows or a header set by a trusted proxy.
+var WeightMetadata = []string{"voter-address", "voter-class", "voter-user-agent"}
+
+// WeightRule gives votes a weight when every key in Match has a matching
+// value in the voter's metadata. A pattern ending in * matches any value
+// starting with the rest of it, and other patterns match exactly.
+type WeightRule struct {
+	Match  map[string]string `json:"match"`
+	Weight float64           `json:"weight"`
+}
+
+func (r *WeightRule) matches(metadata map[string]string) bool {
+	for key, pattern := range r.Match {
+		value, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		if strings.HasSuffix(pattern, "*") {
+			if !strings.HasPrefix(value, strings.TrimSuffix(pattern, "*")) {
+				return false
+			}
+		} else if value != pattern {
+			return false
+		}
+	}
+	return true
+}
+
+// WeightPolicy decides how much each vote counts, from the metadata of the
+// request that cast it. The first rule that matches wins, and votes that
+// match no rule count Default.
+type WeightPolicy struct {
+	Default float64      `json:"default"`
+	Rules   []WeightRule `json:"rules"`
+}
+
+// Weight is how much a vote from voter counts.
+func (wp *WeightPolicy) Weight(voter Voter) float64 {
+	if wp == nil {
+		return 1
+	}
+	for i := range wp.Rules {
+		if wp.Rules[i].matches(voter.Metadata) {
+			return wp.Rules[i].Weight
+		}
+	}
+	return wp.Default
+}
+
+// LoadWeightPolicy reads a weight policy from a JSON file. Default is 1 when
+// the file leaves it out. Metadata keys are lowercase, as gRPC sends them, and
+// must be among WeightMetadata.
+func LoadWeightPolicy(path string) (*WeightPolicy, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Default *float64     `json:"default"`
+		Rules   []WeightRule `json:"rules"`
+	}
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return nil, fmt.Errorf("%w: [%s]: %v", ErrInvalidWeightPolicy, path, err)
+	}
+
+	policy := &WeightPolicy{Default: 1, Rules: make([]WeightRule, 0, len(file.Rules))}
+	if file.Default != nil {
+		policy.Default = *file.Default
+	}
+	if err := checkWeight(policy.Default); err != nil {
+		return nil, fmt.Errorf("%w: default: %v", ErrInvalidWeightPolicy, err)
+	}
+	for i, rule := range file.Rules {
+		if len(rule.Match) == 0 {
+			return nil, fmt.Errorf("%w: rule [%d] matches nothing", ErrInvalidWeightPolicy, i)
+		}
+		if err := checkWeight(rule.Weight); err != nil {
+			return nil, fmt.Errorf("%w: rule [%d]: %v", ErrInvalidWeightPolicy, i, err)
+		}
+		match := make(map[string]string, len(rule.Match))
+		for key, pattern := range rule.Match {
+			key = strings.ToLower(key)
+			if !isWeightMetadata(key) {
+				return nil, fmt.Errorf("%w: rule [%d] matches [%s], which is none of [%s]", ErrInvalidWeightPolicy, i, key, strings.Join(WeightMetadata, ", "))
+			}
+			match[key] = pattern
+		}
+		policy.Rules = append(policy.Rules, WeightRule{Match: match, Weight: rule.Weight})
+	}
+	return policy, nil
+}
+
+func isWeightMetadata(key string) bool {
+	for _, k := range WeightMetadata {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func checkWeight(weight float64) error {
+	if weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+		return fmt.Errorf("weight [%v] must be a number of at least 0", weight)
+	}
+	return nil
+}
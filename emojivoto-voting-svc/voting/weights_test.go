@@ -0,0 +1,69 @@
+package voting
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadWeightPolicy(t *testing.T) {
+	load := func(t *testing.T, policy string) (*WeightPolicy, error) {
+		dir := tempPollDir(t)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "weights.json")
+		if err := ioutil.WriteFile(path, []byte(policy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return LoadWeightPolicy(path)
+	}
+
+	t.Run("Loads rules in order", func(t *testing.T) {
+		policy, err := load(t, `{"rules": [
+			{"match": {"Voter-Class": "partner"}, "weight": 5},
+			{"match": {"voter-class": "employee", "voter-user-agent": "Mozilla/*"}, "weight": 2}
+		]}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		voters := []struct {
+			metadata map[string]string
+			weight   float64
+		}{
+			{nil, 1},
+			{map[string]string{"voter-class": "partner", "voter-user-agent": "Mozilla/5.0"}, 5},
+			{map[string]string{"voter-class": "employee", "voter-user-agent": "Mozilla/5.0"}, 2},
+			{map[string]string{"voter-class": "employee", "voter-user-agent": "curl/7.0"}, 1},
+			{map[string]string{"voter-class": "partners"}, 1},
+		}
+		for _, v := range voters {
+			if weight := policy.Weight(Voter{Metadata: v.metadata}); weight != v.weight {
+				t.Fatalf("Expected [%v] to weigh [%v], got [%v]", v.metadata, v.weight, weight)
+			}
+		}
+	})
+
+	t.Run("Rejects invalid policies", func(t *testing.T) {
+		for _, policy := range []string{
+			`{"default": -1}`,
+			`{"rules": [{"match": {}, "weight": 2}]}`,
+			`{"rules": [{"match": {"voter-class": "bot"}, "weight": -2}]}`,
+			`{"rules": [{"match": {"api-key": "s3cr3t"}, "weight": 5}]}`,
+			`{"rules": `,
+		} {
+			if _, err := load(t, policy); !errors.Is(err, ErrInvalidWeightPolicy) {
+				t.Fatalf("Expected [%s] to be rejected, got [%v]", policy, err)
+			}
+		}
+	})
+
+	t.Run("Counts every vote once without a policy", func(t *testing.T) {
+		var policy *WeightPolicy
+		if weight := policy.Weight(Voter{}); weight != 1 {
+			t.Fatalf("Expected a weight of [1], got [%v]", weight)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -30,6 +31,9 @@ var (
 	ocagentHost = os.Getenv("OC_AGENT_HOST")
 )
 
+// userAgent identifies VoteBot's votes, so a weight policy can discount them.
+const userAgent = "emojivoto-vote-bot"
+
 type emoji struct {
 	Shortcode string
 }
@@ -58,6 +62,13 @@ func main() {
 		requestRate = 1
 	}
 
+	// setting the batch size is optional too; batches of more than one vote
+	// are cast with a single request
+	batchSize, _ := strconv.Atoi(os.Getenv("BATCH_SIZE"))
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
 	oce, err := ocagent.NewExporter(
 		ocagent.WithInsecure(),
 		ocagent.WithReconnectionPeriod(5*time.Second),
@@ -90,14 +101,21 @@ func main() {
 			continue
 		}
 
-		// Cast a vote
-		probability := rand.Float32()
-		switch {
-		case probability < 0.15:
-			err = vote(webURL, hostOverride, ":doughnut:")
-		default:
-			random := shortcodes[rand.Intn(len(shortcodes))]
-			err = vote(webURL, hostOverride, random)
+		// Cast a vote, or a batch of them
+		choices := make([]string, batchSize)
+		for i := range choices {
+			probability := rand.Float32()
+			switch {
+			case probability < 0.15:
+				choices[i] = ":doughnut:"
+			default:
+				choices[i] = shortcodes[rand.Intn(len(shortcodes))]
+			}
+		}
+		if batchSize == 1 {
+			err = vote(webURL, hostOverride, choices[0])
+		} else {
+			err = voteBatch(webURL, hostOverride, choices)
 		}
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
@@ -108,6 +126,7 @@ func main() {
 func shortcodes(webURL string, hostOverride string) ([]string, error) {
 	url := fmt.Sprintf("%s/api/list", webURL)
 	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("User-Agent", userAgent)
 	if hostOverride != "" {
 		req.Host = hostOverride
 	}
@@ -141,6 +160,7 @@ func vote(webURL string, hostOverride string, shortcode string) error {
 
 	url := fmt.Sprintf("%s/api/vote?choice=%s", webURL, shortcode)
 	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("User-Agent", userAgent)
 	if hostOverride != "" {
 		req.Host = hostOverride
 	}
@@ -152,3 +172,34 @@ func vote(webURL string, hostOverride string, shortcode string) error {
 
 	return nil
 }
+
+func voteBatch(webURL string, hostOverride string, shortcodes []string) error {
+	fmt.Printf("✔ Voting for %d emoji\n", len(shortcodes))
+
+	votes := make([]map[string]string, len(shortcodes))
+	for i, shortcode := range shortcodes {
+		votes[i] = map[string]string{"choice": shortcode}
+	}
+	body, err := json.Marshal(votes)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/api/votes:batch", webURL)
+	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", "application/json")
+	if hostOverride != "" {
+		req.Host = hostOverride
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("batch of %d votes failed: %s", len(shortcodes), resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// admin guards an admin endpoint, which manages polls or tournaments, takes
+// back votes or swaps the fault rules, behind the admin token: requests must
+// carry it as a bearer token, as in "Authorization: Bearer <token>". Without
+// an admin token, the admin endpoints are off.
+func (app *WebApp) admin(h func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.adminToken == "" {
+			writeError(errors.New("The admin API is off until ADMIN_TOKEN is set"), w, r, http.StatusForbidden)
+			return
+		}
+		authorization := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if token == authorization || subtle.ConstantTimeCompare([]byte(token), []byte(app.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(errors.New("A valid admin token is required"), w, r, http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
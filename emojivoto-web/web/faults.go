@@ -0,0 +1,228 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/buoyantio/emojivoto/fault"
+	"go.opencensus.io/trace"
+)
+
+// faultsAdminPath is where the fault rules are swapped. Faults are never
+// injected into it, so bad rules can always be taken back.
+const faultsAdminPath = "/api/admin/faults"
+
+var errInvalidFaultRule = errors.New("invalid fault rule")
+
+// httpFaultRule injects a fault into the requests it matches, at the given
+// probability: it delays them, by Delay or by a delay drawn from Latency,
+// then aborts them with Status, if set. A request matches when its path and
+// the headers in Headers match the patterns, as in fault.Match, where empty
+// patterns match anything.
+type httpFaultRule struct {
+	Name        string            `json:"name,omitempty"`
+	Path        string            `json:"path,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Probability float64           `json:"probability"`
+	Status      int               `json:"status,omitempty"`
+	Delay       string            `json:"delay,omitempty"`
+	Latency     *fault.Latency    `json:"latency,omitempty"`
+	delay       time.Duration
+}
+
+// check checks the rule injects something, at a probability above 0 and up
+// to 1, and parses its delay.
+func (r *httpFaultRule) check() error {
+	if r.Probability <= 0 || r.Probability > 1 {
+		return fmt.Errorf("%w: [%s]: probability [%v] must be above 0 and up to 1", errInvalidFaultRule, r.Name, r.Probability)
+	}
+	if r.Status != 0 && (r.Status < 400 || r.Status > 599) {
+		return fmt.Errorf("%w: [%s]: status [%d] must be an error, from 400 to 599", errInvalidFaultRule, r.Name, r.Status)
+	}
+	r.delay = 0
+	if r.Delay != "" {
+		delay, err := time.ParseDuration(r.Delay)
+		if err != nil || delay < 0 {
+			return fmt.Errorf("%w: [%s]: invalid delay [%s]", errInvalidFaultRule, r.Name, r.Delay)
+		}
+		r.delay = delay
+	}
+	if r.Latency != nil {
+		if r.Delay != "" {
+			return fmt.Errorf("%w: [%s]: a rule takes a delay or a latency, not both", errInvalidFaultRule, r.Name)
+		}
+		if err := r.Latency.Check(); err != nil {
+			return fmt.Errorf("%w: [%s]: %v", errInvalidFaultRule, r.Name, err)
+		}
+	}
+	if r.Status == 0 && r.delay == 0 && r.Latency == nil {
+		return fmt.Errorf("%w: [%s]: a rule needs a status, a delay or a latency", errInvalidFaultRule, r.Name)
+	}
+	return nil
+}
+
+func (r *httpFaultRule) matches(req *http.Request) bool {
+	if r.Path != "" && !fault.Match(r.Path, req.URL.Path) {
+		return false
+	}
+	for header, pattern := range r.Headers {
+		value := req.Header.Get(header)
+		if value == "" || !fault.Match(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// httpFaults injects the faults of a set of rules into requests to the web
+// app. Every rule that matches a request gets a go at it, in order: their
+// delays add up, and the first to abort the request ends it.
+type httpFaults struct {
+	sync.RWMutex
+	rules  []httpFaultRule
+	random func() float64
+	normal func() float64
+}
+
+func newHTTPFaults() *httpFaults {
+	return &httpFaults{rules: make([]httpFaultRule, 0), random: rand.Float64, normal: rand.NormFloat64}
+}
+
+// loadHTTPFaultRules reads rules from a JSON file, as in
+//
+//	{"rules": [{"path": "/api/vote", "probability": 0.1, "status": 503}]}
+func loadHTTPFaultRules(path string) ([]httpFaultRule, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []httpFaultRule `json:"rules"`
+	}
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return nil, fmt.Errorf("%w: [%s]: %v", errInvalidFaultRule, path, err)
+	}
+	return file.Rules, nil
+}
+
+// current returns the rules in force.
+func (f *httpFaults) current() []httpFaultRule {
+	f.RLock()
+	defer f.RUnlock()
+
+	rules := make([]httpFaultRule, len(f.rules))
+	copy(rules, f.rules)
+	return rules
+}
+
+// setRules checks the given rules and swaps them in for the rules in force.
+func (f *httpFaults) setRules(rules []httpFaultRule) error {
+	checked := make([]httpFaultRule, len(rules))
+	for i, rule := range rules {
+		if err := rule.check(); err != nil {
+			return err
+		}
+		checked[i] = rule
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	f.rules = checked
+	log.Printf("Injecting faults into requests with [%d] rules", len(checked))
+	return nil
+}
+
+// middleware injects faults into requests before h serves them. A nil
+// httpFaults injects nothing.
+func (f *httpFaults) middleware(h http.Handler) http.Handler {
+	if f == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != faultsAdminPath && !f.inject(w, r) {
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// inject injects the faults of the rules that match r, and tells if the
+// request is still to be served. Every fault is annotated on the span of
+// the request.
+func (f *httpFaults) inject(w http.ResponseWriter, r *http.Request) bool {
+	f.RLock()
+	rules := f.rules
+	f.RUnlock()
+
+	span := trace.FromContext(r.Context())
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.matches(r) || f.random() >= rule.Probability {
+			continue
+		}
+		delay, distribution := rule.delay, fault.Fixed
+		if rule.Latency != nil {
+			delay, distribution = rule.Latency.Sample(f.random, f.normal), rule.Latency.Distribution
+		}
+		if delay > 0 {
+			log.Printf("Delaying [%s] by [%v] for fault rule [%s]", r.URL.Path, delay, rule.Name)
+			span.Annotate([]trace.Attribute{
+				trace.StringAttribute("fault.rule", rule.Name),
+				trace.StringAttribute("fault.distribution", string(distribution)),
+				trace.Int64Attribute("fault.delay_ms", delay.Milliseconds()),
+			}, "Injected latency")
+			if err := fault.Sleep(r.Context(), delay); err != nil {
+				// The client has gone, so there is no one left to answer.
+				return false
+			}
+		}
+		if rule.Status != 0 {
+			log.Printf("Aborting [%s] with [%d] for fault rule [%s]", r.URL.Path, rule.Status, rule.Name)
+			span.Annotate([]trace.Attribute{
+				trace.StringAttribute("fault.rule", rule.Name),
+				trace.Int64Attribute("fault.status", int64(rule.Status)),
+			}, "Aborted the request")
+			writeError(fmt.Errorf("fault injected by rule [%s]", rule.Name), w, r, rule.Status)
+			return false
+		}
+	}
+	return true
+}
+
+// faultsHandler serves the fault rules in force on GET, swaps them for the
+// rules in the JSON body on PUT, and removes them on DELETE.
+func (app *WebApp) faultsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var body struct {
+			Rules []httpFaultRule `json:"rules"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&body); err != nil {
+			writeError(fmt.Errorf("Invalid fault rules: %v", err), w, r, http.StatusBadRequest)
+			return
+		}
+		if err := app.faults.setRules(body.Rules); err != nil {
+			writeError(err, w, r, http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		app.faults.setRules(nil)
+	default:
+		writeError(fmt.Errorf("Method [%s] is not allowed", r.Method), w, r, http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := writeJsonBody(w, http.StatusOK, map[string][]httpFaultRule{"rules": app.faults.current()})
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaults(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	serve := func(h http.Handler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("aborts requests by path and header", func(t *testing.T) {
+		faults := newHTTPFaults()
+		err := faults.setRules([]httpFaultRule{
+			{Name: "votes", Path: "/api/vote*", Probability: 1, Status: http.StatusServiceUnavailable},
+			{Name: "canary", Headers: map[string]string{"x-canary": "tru*"}, Probability: 1, Status: http.StatusTeapot},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler := faults.middleware(ok)
+
+		if rr := serve(handler, "POST", "/api/vote?choice=:joy:", "", nil); rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected votes to be aborted with [503], got [%d]", rr.Code)
+		}
+		if rr := serve(handler, "GET", "/api/list", "", map[string]string{"X-Canary": "true"}); rr.Code != http.StatusTeapot {
+			t.Fatalf("Expected canary requests to be aborted with [418], got [%d]", rr.Code)
+		}
+		if rr := serve(handler, "GET", "/api/list", "", nil); rr.Code != http.StatusOK {
+			t.Fatalf("Expected other requests to be served, got [%d]", rr.Code)
+		}
+	})
+
+	t.Run("delays requests at the rule's probability", func(t *testing.T) {
+		faults := newHTTPFaults()
+		faults.setRules([]httpFaultRule{{Name: "slow", Path: "/api/list", Probability: 0.5, Delay: "20ms"}})
+		handler := faults.middleware(ok)
+
+		faults.random = func() float64 { return 0.5 }
+		start := time.Now()
+		if rr := serve(handler, "GET", "/api/list", "", nil); rr.Code != http.StatusOK || time.Since(start) >= 20*time.Millisecond {
+			t.Fatalf("Expected the request to be served right away, got [%d] after [%v]", rr.Code, time.Since(start))
+		}
+		faults.random = func() float64 { return 0.1 }
+		start = time.Now()
+		if rr := serve(handler, "GET", "/api/list", "", nil); rr.Code != http.StatusOK || time.Since(start) < 20*time.Millisecond {
+			t.Fatalf("Expected the request to be delayed and served, got [%d] after [%v]", rr.Code, time.Since(start))
+		}
+	})
+
+	t.Run("swaps rules from the admin endpoint", func(t *testing.T) {
+		webApp := &WebApp{faults: newHTTPFaults()}
+		admin := webApp.faults.middleware(http.HandlerFunc(webApp.faultsHandler))
+
+		rr := serve(admin, "PUT", faultsAdminPath, `{"rules": [
+			{"name": "everything", "probability": 1, "status": 500},
+			{"name": "tail", "path": "/api/leaderboard", "probability": 1, "latency": {"distribution": "pareto", "min": "5ms", "alpha": 2}}
+		]}`, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected the rules to be swapped in, got [%d] [%s]", rr.Code, rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), `"name":"everything"`) || !strings.Contains(rr.Body.String(), `"distribution":"pareto"`) {
+			t.Fatalf("Expected the rules in force, got [%s]", rr.Body.String())
+		}
+
+		if rr := serve(webApp.faults.middleware(ok), "GET", "/api/list", "", nil); rr.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected requests to fail, got [%d]", rr.Code)
+		}
+		if rr := serve(admin, "GET", faultsAdminPath, "", nil); rr.Code != http.StatusOK {
+			t.Fatalf("Expected the admin endpoint to be spared, got [%d]", rr.Code)
+		}
+
+		for _, body := range []string{
+			`{"rules": [{"probability": 1}]}`,
+			`{"rules": [{"probability": 1, "status": 200}]}`,
+			`{"rules": [{"probability": 2, "status": 503}]}`,
+			`{"rules": [{"probability": 1, "delay": "later"}]}`,
+			`{"rules": [{"probability": 1, "delay": "1s", "latency": {"distribution": "fixed", "mean": "1s"}}]}`,
+			`{"rules": `,
+		} {
+			if rr := serve(admin, "PUT", faultsAdminPath, body, nil); rr.Code != http.StatusBadRequest {
+				t.Fatalf("Expected [%s] to be rejected, got [%d]", body, rr.Code)
+			}
+		}
+
+		if rr := serve(admin, "DELETE", faultsAdminPath, "", nil); rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"rules":[]}` {
+			t.Fatalf("Expected the rules to be removed, got [%d] [%s]", rr.Code, rr.Body.String())
+		}
+		if rr := serve(webApp.faults.middleware(ok), "GET", "/api/list", "", nil); rr.Code != http.StatusOK {
+			t.Fatalf("Expected requests to be served again, got [%d]", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// apiKeyHeader carries the API key a client votes with.
+const apiKeyHeader = "X-Api-Key"
+
+// voterClasses decides the voter class passed on to the voting service for
+// its weight policy. Clients can't pick their own class: it comes from
+// header, if set, which a trusted proxy in front of the web service sets
+// after dropping whatever clients sent in it, or else from the class of the
+// client's API key. API keys themselves aren't passed on.
+type voterClasses struct {
+	header string
+	// keys maps each API key to its voter class.
+	keys map[string]string
+}
+
+// classOf is the voter class of a request, or empty if it has none.
+func (c *voterClasses) classOf(r *http.Request) string {
+	if c == nil {
+		return ""
+	}
+	if c.header != "" {
+		if class := r.Header.Get(c.header); class != "" {
+			return class
+		}
+	}
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		return ""
+	}
+	class := ""
+	for k, c := range c.keys {
+		// Compare every key in constant time, so timing gives none away.
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			class = c
+		}
+	}
+	return class
+}
+
+// loadVoterClasses trusts header to carry the voter class, if set, and reads
+// the voter class of each API key from a JSON object at keysPath, if set.
+func loadVoterClasses(header, keysPath string) (*voterClasses, error) {
+	classes := &voterClasses{header: header}
+	if keysPath == "" {
+		return classes, nil
+	}
+	bytes, err := ioutil.ReadFile(keysPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, &classes.keys); err != nil {
+		return nil, fmt.Errorf("reading [%s]: %v", keysPath, err)
+	}
+	for key, class := range classes.keys {
+		if key == "" || class == "" {
+			return nil, fmt.Errorf("reading [%s]: API keys and their classes can't be empty", keysPath)
+		}
+	}
+	return classes, nil
+}
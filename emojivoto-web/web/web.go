@@ -1,18 +1,34 @@
 package web
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/buoyantio/emojivoto/admin"
 	pb "github.com/buoyantio/emojivoto/emojivoto-web/gen/proto"
 	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type WebApp struct {
@@ -21,297 +37,1357 @@ type WebApp struct {
 	indexBundle         string
 	webpackDevServer    string
 	messageOfTheDay     string
+	voterIdentity       bool
+	voterClasses        *voterClasses
+	adminToken          string
+	faults              *httpFaults
+	// emojiUnicode caches the unicode of each shortcode looked up for
+	// leaderboard streams, since the catalog doesn't change.
+	emojiUnicode sync.Map
 }
 
+// sseHeartbeat is how often an idle event stream sends a comment, so
+// proxies don't time it out.
+const sseHeartbeat = 15 * time.Second
+
+const (
+	voterIDCookie        = "emojivoto_voter"
+	voterIDHeader        = "X-Voter-Id"
+	idempotencyKeyHeader = "Idempotency-Key"
+	requestIDHeader      = "X-Request-Id"
+
+	// maxBatchVotes matches the voting service's limit on a batch.
+	maxBatchVotes = 1000
+	maxBatchBytes = 1 << 20
+)
+
+var (
+	voterIDPattern        = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	idempotencyKeyPattern = regexp.MustCompile(`^[\x21-\x7e]{1,128}$`)
+	requestIDPattern      = regexp.MustCompile(`^[\x21-\x7e]{1,128}$`)
+)
+
 func (app *WebApp) listEmojiHandler(w http.ResponseWriter, r *http.Request) {
 	serviceResponse, err := app.emojiServiceClient.ListAll(r.Context(), &pb.ListAllEmojiRequest{})
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	list := make([]map[string]string, 0)
+	for _, e := range serviceResponse.List {
+		list = append(list, map[string]string{
+			"shortcode": e.Shortcode,
+			"unicode":   e.Unicode,
+		})
+	}
+
+	err = writeJsonBody(w, http.StatusOK, list)
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+func (app *WebApp) leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	app.leaderboard(w, r, "")
+}
+
+func (app *WebApp) leaderboard(w http.ResponseWriter, r *http.Request, pollID string) {
+	results, err := app.votingServiceClient.Results(r.Context(), &pb.ResultsRequest{PollId: pollID})
+
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	representations := make([]map[string]string, 0)
+	for _, result := range results.Results {
+		unicode, err := app.unicodeFor(r.Context(), result.Shortcode)
+		if err != nil {
+			writeRPCError(err, w, r)
+			return
+		}
+
+		representation := make(map[string]string)
+		representation["votes"] = strconv.Itoa(int(result.Votes))
+		representation["weighted"] = strconv.FormatFloat(result.WeightedVotes, 'f', -1, 64)
+		representation["rank"] = strconv.Itoa(int(result.Rank))
+		representation["dense_rank"] = strconv.Itoa(int(result.DenseRank))
+		representation["unicode"] = unicode
+		representation["shortcode"] = result.Shortcode
+
+		representations = append(representations, representation)
+	}
+
+	err = writeJsonBody(w, http.StatusOK, representations)
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// leaderboardStreamHandler relays changes to the leaderboard of the poll in
+// the poll query value as Server-Sent Events. Each event's data is a JSON
+// object with the results that changed; full is set when it lists them all.
+func (app *WebApp) leaderboardStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(errors.New("Streaming is not supported"), w, r, http.StatusInternalServerError)
+		return
+	}
+
+	stream, err := app.votingServiceClient.WatchResults(r.Context(), &pb.WatchResultsRequest{PollId: r.FormValue("poll")})
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// The stream shares the request's context, so it ends when the browser
+	// goes away and Recv returns.
+	updates := make(chan *pb.ResultsUpdate)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			update, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case updates <- update:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case err := <-errs:
+			if err != io.EOF && r.Context().Err() == nil {
+				log.Printf("Leaderboard stream ended: %v", err)
+			}
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case update := <-updates:
+			results := make([]map[string]string, 0, len(update.Results))
+			for _, result := range update.Results {
+				unicode, err := app.unicodeFor(r.Context(), result.Shortcode)
+				if err != nil {
+					log.Printf("Leaderboard stream ended: %v", err)
+					return
+				}
+				results = append(results, map[string]string{
+					"shortcode":  result.Shortcode,
+					"unicode":    unicode,
+					"votes":      strconv.Itoa(int(result.Votes)),
+					"weighted":   strconv.FormatFloat(result.WeightedVotes, 'f', -1, 64),
+					"rank":       strconv.Itoa(int(result.Rank)),
+					"dense_rank": strconv.Itoa(int(result.DenseRank)),
+				})
+			}
+			data, err := json.Marshal(map[string]interface{}{"full": update.Full, "results": results})
+			if err != nil {
+				log.Printf("Leaderboard stream ended: %v", err)
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+// unicodeFor looks up the emoji for a shortcode, remembering the answer.
+// Shortcodes the emoji service doesn't know have no emoji.
+func (app *WebApp) unicodeFor(ctx context.Context, shortcode string) (string, error) {
+	if unicode, ok := app.emojiUnicode.Load(shortcode); ok {
+		return unicode.(string), nil
+	}
+	response, err := app.emojiServiceClient.FindByShortcode(ctx, &pb.FindByShortcodeRequest{Shortcode: shortcode})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return "", err
+	}
+	unicode := ""
+	if response.GetEmoji() != nil {
+		unicode = response.Emoji.Unicode
+	}
+	app.emojiUnicode.Store(shortcode, unicode)
+	return unicode, nil
+}
+
+// historyHandler serves the votes cast over time, for graphs, from the poll,
+// shortcode (repeatable), from and to (RFC 3339) and step (e.g. 5m) query
+// values. Each series counts the votes cast in each step, starting at from.
+func (app *WebApp) historyHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+
+	request := &pb.VoteHistoryRequest{
+		PollId:     r.FormValue("poll"),
+		Shortcodes: r.Form["shortcode"],
+	}
+	var err error
+	if request.From, err = formTimestamp(r, "from"); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	if request.To, err = formTimestamp(r, "to"); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	if step := r.FormValue("step"); step != "" {
+		duration, err := time.ParseDuration(step)
+		if err != nil || duration <= 0 {
+			writeError(fmt.Errorf("Invalid step value [%s], expected a duration such as 5m", step), w, r, http.StatusBadRequest)
+			return
+		}
+		request.Step = durationpb.New(duration)
+	}
+
+	history, err := app.votingServiceClient.VoteHistory(r.Context(), request)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	series := make([]map[string]interface{}, 0)
+	for _, s := range history.Series {
+		unicode, err := app.unicodeFor(r.Context(), s.Shortcode)
+		if err != nil {
+			writeRPCError(err, w, r)
+			return
+		}
+		counts := s.Counts
+		if counts == nil {
+			counts = []int32{}
+		}
+		series = append(series, map[string]interface{}{
+			"shortcode": s.Shortcode,
+			"unicode":   unicode,
+			"counts":    counts,
+		})
+	}
+
+	err = writeJsonBody(w, http.StatusOK, map[string]interface{}{
+		"from":         timestampRepresentation(history.From),
+		"step_seconds": int64(history.Step.AsDuration() / time.Second),
+		"series":       series,
+	})
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+func (app *WebApp) voteEmojiHandler(w http.ResponseWriter, r *http.Request) {
+	app.vote(w, r, "")
+}
+
+func (app *WebApp) vote(w http.ResponseWriter, r *http.Request, pollID string) {
+	if r.Method == http.MethodDelete {
+		app.retract(w, r, pollID)
+		return
+	}
+
+	emojiShortcode := r.FormValue("choice")
+	if emojiShortcode == "" {
+		error := errors.New(fmt.Sprintf("Emoji choice [%s] is mandatory", emojiShortcode))
+		writeError(error, w, r, http.StatusBadRequest)
+		return
+	}
+
+	request := &pb.FindByShortcodeRequest{
+		Shortcode: emojiShortcode,
+	}
+	_, err := app.emojiServiceClient.FindByShortcode(r.Context(), request)
+	if status.Code(err) == codes.NotFound {
+		// The choice is what's wrong with the request, not the URL.
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	voteRequest := &pb.VoteRequest{
+		Shortcode: emojiShortcode,
+		PollId:    pollID,
+	}
+	_, err = app.votingServiceClient.Vote(app.voterContext(w, r), voteRequest)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+}
+
+// batchVote is one vote in a request to /api/votes:batch.
+type batchVote struct {
+	Choice string `json:"choice"`
+	Poll   string `json:"poll"`
+}
+
+// voteBatchHandler casts a JSON array of votes at once. It answers 200 as
+// long as the batch itself is valid, with the HTTP status and error of each
+// vote, in order.
+func (app *WebApp) voteBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(fmt.Errorf("Method [%s] is not allowed", r.Method), w, r, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var votes []batchVote
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&votes); err != nil {
+		writeError(fmt.Errorf("Votes must be a JSON array of {\"choice\", \"poll\"}: %v", err), w, r, http.StatusBadRequest)
+		return
+	}
+	if len(votes) == 0 || len(votes) > maxBatchVotes {
+		writeError(fmt.Errorf("[%d] votes in a batch, 1 to [%d] are allowed", len(votes), maxBatchVotes), w, r, http.StatusBadRequest)
+		return
+	}
+
+	request := &pb.VoteBatchRequest{Votes: make([]*pb.VoteRequest, 0, len(votes))}
+	for _, v := range votes {
+		request.Votes = append(request.Votes, &pb.VoteRequest{Shortcode: v.Choice, PollId: v.Poll})
+	}
+	response, err := app.votingServiceClient.VoteBatch(app.voterContext(w, r), request)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	results := make([]map[string]interface{}, 0, len(response.Results))
+	for _, result := range response.Results {
+		representation := map[string]interface{}{
+			"index":  result.Index,
+			"status": httpStatus(codes.Code(result.Code)),
+		}
+		if result.Code != int32(codes.OK) {
+			representation["error"] = result.Message
+		}
+		results = append(results, representation)
+	}
+
+	err = writeJsonBody(w, http.StatusOK, map[string]interface{}{
+		"accepted": response.Accepted,
+		"results":  results,
+	})
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// retract takes back the caller's vote for the choices in the query string,
+// repeated for ranked and approval ballots, or the caller's current vote if
+// they're left out. It needs voter identity on: without a voter ID, nothing
+// tells one caller's vote from another's, so anonymous votes are only taken
+// back by admins, with retractVotesHandler.
+func (app *WebApp) retract(w http.ResponseWriter, r *http.Request, pollID string) {
+	if !app.voterIdentity {
+		writeError(errors.New("Votes can only be taken back with voter identity on; admins can use /api/admin/retract"), w, r, http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	choices := r.Form["choice"]
+
+	request := &pb.RetractRequest{PollId: pollID}
+	if len(choices) == 1 {
+		request.Shortcode = choices[0]
+	} else {
+		request.Shortcodes = choices
+	}
+	_, err := app.votingServiceClient.Retract(app.voterContext(w, r), request)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+}
+
+// retractVotesHandler takes back every vote in the poll form value matching
+// the voter_id, since and until (RFC 3339) form values. It passes the admin
+// token on, as the voting service wants it too.
+func (app *WebApp) retractVotesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(fmt.Errorf("Method [%s] is not allowed", r.Method), w, r, http.StatusMethodNotAllowed)
+		return
+	}
+
+	request := &pb.RetractVotesRequest{
+		PollId:  r.FormValue("poll"),
+		VoterId: r.FormValue("voter_id"),
+	}
+	var err error
+	if request.Since, err = formTimestamp(r, "since"); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	if request.Until, err = formTimestamp(r, "until"); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	if request.VoterId == "" && request.Since == nil && request.Until == nil {
+		writeError(errors.New("One of voter_id, since or until is mandatory"), w, r, http.StatusBadRequest)
+		return
+	}
+
+	response, err := app.votingServiceClient.RetractVotes(admin.WithToken(r.Context(), app.adminToken), request)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	err = writeJsonBody(w, http.StatusOK, map[string]int32{"retracted": response.Retracted})
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// trendingHandler serves the trending leaderboard of the poll in the poll
+// query value: emoji ranked by their recent votes, decayed with the
+// half_life query value, and whether they rose or fell since the window
+// query value ago. Both are durations such as 10m, and default to the voting
+// service's half-life.
+func (app *WebApp) trendingHandler(w http.ResponseWriter, r *http.Request) {
+	request := &pb.TrendingRequest{PollId: r.FormValue("poll")}
+	var err error
+	if request.HalfLife, err = formDuration(r, "half_life"); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	if request.Window, err = formDuration(r, "window"); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+
+	trending, err := app.votingServiceClient.Trending(r.Context(), request)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	results := make([]map[string]interface{}, 0, len(trending.Results))
+	for _, result := range trending.Results {
+		unicode, err := app.unicodeFor(r.Context(), result.Shortcode)
+		if err != nil {
+			writeRPCError(err, w, r)
+			return
+		}
+		results = append(results, map[string]interface{}{
+			"shortcode":     result.Shortcode,
+			"unicode":       unicode,
+			"score":         result.Score,
+			"votes":         result.Votes,
+			"rank":          result.Rank,
+			"previous_rank": result.PreviousRank,
+			"movement":      result.Movement,
+		})
+	}
+
+	err = writeJsonBody(w, http.StatusOK, map[string]interface{}{
+		"half_life_seconds": trending.HalfLife.AsDuration().Seconds(),
+		"window_seconds":    trending.Window.AsDuration().Seconds(),
+		"results":           results,
+	})
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// pollsHandler serves /api/polls and everything below it:
+//
+//	GET  /api/polls?archived=true         list polls, including archived ones if asked for
+//	POST /api/polls                       create a poll from the id, title, ballot, mode,
+//	                                      max_choices, starts_at, ends_at (RFC 3339) and draft
+//	                                      form values
+//	GET  /api/polls/{id}                  describe a poll
+//	POST /api/polls/{id}/open             open a draft poll
+//	POST /api/polls/{id}/close            close a poll
+//	POST /api/polls/{id}/archive          archive a closed poll
+//	     /api/polls/{id}/vote?choice=     vote in a poll, or retract a vote with DELETE
+//	POST /api/polls/{id}/ballot?choice=   rank choices in a ranked poll, most preferred first
+//	POST /api/polls/{id}/approval?choice= pick choices in an approval poll
+//	GET  /api/polls/{id}/runoff           a ranked poll's instant-runoff count, round by round
+//	GET  /api/polls/{id}/leaderboard      a poll's leaderboard
+//	GET  /api/polls/{id}/results          a poll's results, totals and shares, as in /api/v2/leaderboard
+func (app *WebApp) pollsHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/polls"), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			app.listPolls(w, r)
+		case http.MethodPost:
+			app.admin(app.createPoll)(w, r)
+		default:
+			writeError(fmt.Errorf("Method [%s] is not allowed", r.Method), w, r, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	parts := strings.SplitN(path, "/", 2)
+	pollID, action := parts[0], ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch action {
+	case "":
+		app.getPoll(w, r, pollID)
+	case "open", "close", "archive":
+		if r.Method != http.MethodPost {
+			writeError(fmt.Errorf("Method [%s] is not allowed", r.Method), w, r, http.StatusMethodNotAllowed)
+			return
+		}
+		app.admin(func(w http.ResponseWriter, r *http.Request) {
+			app.movePoll(w, r, pollID, action)
+		})(w, r)
+	case "vote":
+		app.vote(w, r, pollID)
+	case "ballot", "approval":
+		if r.Method != http.MethodPost {
+			writeError(fmt.Errorf("Method [%s] is not allowed", r.Method), w, r, http.StatusMethodNotAllowed)
+			return
+		}
+		app.voteBallot(w, r, pollID, action == "approval")
+	case "runoff":
+		app.runoff(w, r, pollID)
+	case "leaderboard":
+		app.leaderboard(w, r, pollID)
+	case "results":
+		app.results(w, r, pollID)
+	default:
+		writeError(fmt.Errorf("Unknown poll resource [%s]", action), w, r, http.StatusNotFound)
+	}
+}
+
+// voteBallot casts a ballot with the choice form values: an approval ballot
+// picking them, or a ranked ballot ranking them in the order given.
+func (app *WebApp) voteBallot(w http.ResponseWriter, r *http.Request, pollID string, approval bool) {
+	if err := r.ParseForm(); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	choices := r.Form["choice"]
+	if len(choices) == 0 {
+		writeError(errors.New("At least one emoji choice is mandatory"), w, r, http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if approval {
+		request := &pb.VoteApprovalRequest{
+			PollId:     pollID,
+			Shortcodes: choices,
+		}
+		_, err = app.votingServiceClient.VoteApproval(app.voterContext(w, r), request)
+	} else {
+		request := &pb.VoteRankedRequest{
+			PollId: pollID,
+			Ballot: &pb.RankedBallot{Shortcodes: choices},
+		}
+		_, err = app.votingServiceClient.VoteRanked(app.voterContext(w, r), request)
+	}
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+}
+
+// results serves the results of a poll along with the number of ballots
+// cast, which approval polls need to make sense of their approval counts.
+func (app *WebApp) results(w http.ResponseWriter, r *http.Request, pollID string) {
+	response, err := app.votingServiceClient.Results(r.Context(), &pb.ResultsRequest{PollId: pollID})
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	representation, err := app.resultsRepresentation(r.Context(), response)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	err = writeJsonBody(w, http.StatusOK, representation)
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// leaderboardV2Handler serves the leaderboard of the poll in the poll query
+// value, the default poll if empty, with numbers as JSON numbers rather than
+// strings. Along with each emoji's votes and rank, it has its share of the
+// ballots and the interval the share likely lies in, and it says whether the
+// leader's lead is statistically significant.
+func (app *WebApp) leaderboardV2Handler(w http.ResponseWriter, r *http.Request) {
+	response, err := app.votingServiceClient.Results(r.Context(), &pb.ResultsRequest{PollId: r.FormValue("poll")})
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	representation, err := app.resultsRepresentation(r.Context(), response)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	err = writeJsonBody(w, http.StatusOK, representation)
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// resultsRepresentation is the results of a poll, their totals and how the
+// leader compares with the runner-up.
+func (app *WebApp) resultsRepresentation(ctx context.Context, response *pb.ResultsResponse) (map[string]interface{}, error) {
+	results := make([]map[string]interface{}, 0, len(response.Results))
+	for _, result := range response.Results {
+		unicode, err := app.unicodeFor(ctx, result.Shortcode)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, map[string]interface{}{
+			"shortcode":  result.Shortcode,
+			"unicode":    unicode,
+			"votes":      result.Votes,
+			"weighted":   result.WeightedVotes,
+			"rank":       result.Rank,
+			"dense_rank": result.DenseRank,
+			"share":      result.Share,
+			"share_low":  result.ShareLow,
+			"share_high": result.ShareHigh,
+		})
+	}
+
+	lead := response.GetLead()
+	return map[string]interface{}{
+		"ballots":    response.Ballots,
+		"votes":      response.Votes,
+		"weighted":   response.WeightedVotes,
+		"confidence": response.Confidence,
+		"lead": map[string]interface{}{
+			"leader":      lead.GetLeader(),
+			"runner_up":   lead.GetRunnerUp(),
+			"margin":      lead.GetMargin(),
+			"significant": lead.GetSignificant(),
+		},
+		"results": results,
+	}, nil
+}
+
+// runoff serves the instant-runoff count of a ranked poll.
+func (app *WebApp) runoff(w http.ResponseWriter, r *http.Request, pollID string) {
+	response, err := app.votingServiceClient.RankedResults(r.Context(), &pb.RankedResultsRequest{PollId: pollID})
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	rounds := make([]map[string]interface{}, 0, len(response.Rounds))
+	for _, round := range response.Rounds {
+		tallies := make([]map[string]interface{}, 0, len(round.Tallies))
+		for _, t := range round.Tallies {
+			unicode, err := app.unicodeFor(r.Context(), t.Shortcode)
+			if err != nil {
+				writeRPCError(err, w, r)
+				return
+			}
+			tallies = append(tallies, map[string]interface{}{
+				"shortcode": t.Shortcode,
+				"unicode":   unicode,
+				"votes":     t.Votes,
+			})
+		}
+		eliminated := round.Eliminated
+		if eliminated == nil {
+			eliminated = []string{}
+		}
+		rounds = append(rounds, map[string]interface{}{
+			"tallies":    tallies,
+			"exhausted":  round.Exhausted,
+			"eliminated": eliminated,
+		})
+	}
+	tied := response.Tied
+	if tied == nil {
+		tied = []string{}
+	}
+
+	err = writeJsonBody(w, http.StatusOK, map[string]interface{}{
+		"ballots": response.Ballots,
+		"winner":  response.Winner,
+		"tied":    tied,
+		"rounds":  rounds,
+	})
+
 	if err != nil {
 		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+func (app *WebApp) listPolls(w http.ResponseWriter, r *http.Request) {
+	includeArchived, _ := strconv.ParseBool(r.FormValue("archived"))
+	response, err := app.votingServiceClient.ListPolls(r.Context(), &pb.ListPollsRequest{IncludeArchived: includeArchived})
+	if err != nil {
+		writeRPCError(err, w, r)
 		return
 	}
 
-	list := make([]map[string]string, 0)
-	for _, e := range serviceResponse.List {
-		list = append(list, map[string]string{
-			"shortcode": e.Shortcode,
-			"unicode":   e.Unicode,
-		})
+	polls := make([]map[string]interface{}, 0)
+	for _, p := range response.Polls {
+		polls = append(polls, pollRepresentation(p))
 	}
 
-	err = writeJsonBody(w, http.StatusOK, list)
+	err = writeJsonBody(w, http.StatusOK, polls)
 
 	if err != nil {
 		writeError(err, w, r, http.StatusInternalServerError)
 	}
 }
 
-func (app *WebApp) leaderboardHandler(w http.ResponseWriter, r *http.Request) {
-	results, err := app.votingServiceClient.Results(r.Context(), &pb.ResultsRequest{})
+func (app *WebApp) createPoll(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+
+	request := &pb.CreatePollRequest{
+		Id:     r.FormValue("id"),
+		Title:  r.FormValue("title"),
+		Ballot: r.Form["ballot"],
+		Mode:   r.FormValue("mode"),
+	}
+	if maxChoices := r.FormValue("max_choices"); maxChoices != "" {
+		n, err := strconv.Atoi(maxChoices)
+		if err != nil || n < 0 {
+			writeError(fmt.Errorf("Invalid max_choices value [%s]", maxChoices), w, r, http.StatusBadRequest)
+			return
+		}
+		request.MaxChoices = int32(n)
+	}
+	if request.Id == "" && request.Title == "" {
+		writeError(errors.New("Poll id or title is mandatory"), w, r, http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if request.StartsAt, err = formTimestamp(r, "starts_at"); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	if request.EndsAt, err = formTimestamp(r, "ends_at"); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
+		return
+	}
+	if draft := r.FormValue("draft"); draft != "" {
+		if request.Draft, err = strconv.ParseBool(draft); err != nil {
+			writeError(fmt.Errorf("Invalid draft value [%s]", draft), w, r, http.StatusBadRequest)
+			return
+		}
+	}
+
+	response, err := app.votingServiceClient.CreatePoll(r.Context(), request)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	err = writeJsonBody(w, http.StatusCreated, pollRepresentation(response.Poll))
 
 	if err != nil {
 		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+func (app *WebApp) getPoll(w http.ResponseWriter, r *http.Request, pollID string) {
+	response, err := app.votingServiceClient.GetPoll(r.Context(), &pb.GetPollRequest{Id: pollID})
+	if err != nil {
+		writeRPCError(err, w, r)
 		return
 	}
 
-	representations := make([]map[string]string, 0)
-	for _, result := range results.Results {
-		findByShortcodeRequest := &pb.FindByShortcodeRequest{
-			Shortcode: result.Shortcode,
+	err = writeJsonBody(w, http.StatusOK, pollRepresentation(response.Poll))
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// movePoll opens, closes or archives a poll.
+func (app *WebApp) movePoll(w http.ResponseWriter, r *http.Request, pollID, action string) {
+	var (
+		poll *pb.Poll
+		err  error
+	)
+	switch action {
+	case "open":
+		var response *pb.OpenPollResponse
+		if response, err = app.votingServiceClient.OpenPoll(r.Context(), &pb.OpenPollRequest{Id: pollID}); err == nil {
+			poll = response.Poll
+		}
+	case "close":
+		var response *pb.ClosePollResponse
+		if response, err = app.votingServiceClient.ClosePoll(r.Context(), &pb.ClosePollRequest{Id: pollID}); err == nil {
+			poll = response.Poll
+		}
+	case "archive":
+		var response *pb.ArchivePollResponse
+		if response, err = app.votingServiceClient.ArchivePoll(r.Context(), &pb.ArchivePollRequest{Id: pollID}); err == nil {
+			poll = response.Poll
 		}
+	}
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
 
-		findByShortcodeResponse, err := app.emojiServiceClient.FindByShortcode(r.Context(), findByShortcodeRequest)
+	err = writeJsonBody(w, http.StatusOK, pollRepresentation(poll))
 
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// tournamentsHandler serves /api/tournaments and everything below it:
+//
+//	GET  /api/tournaments               list tournaments
+//	POST /api/tournaments               create a tournament from the id, title, size, entrant
+//	                                    and matchup_duration (like 90s) form values
+//	GET  /api/tournaments/{id}          a tournament's bracket
+//	GET  /api/tournaments/{id}/matchup  the matchup being voted on, in the poll named by poll_id
+//	POST /api/tournaments/{id}/advance  close the current matchup early and open the next one
+func (app *WebApp) tournamentsHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/tournaments"), "/")
+	parts := strings.SplitN(path, "/", 2)
+	id, action := parts[0], ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		app.listTournaments(w, r)
+	case id == "" && r.Method == http.MethodPost:
+		app.admin(app.createTournament)(w, r)
+	case id == "":
+		writeError(fmt.Errorf("Method [%s] is not allowed", r.Method), w, r, http.StatusMethodNotAllowed)
+	case action == "":
+		response, err := app.votingServiceClient.GetTournament(r.Context(), &pb.GetTournamentRequest{Id: id})
 		if err != nil {
-			writeError(err, w, r, http.StatusInternalServerError)
+			writeRPCError(err, w, r)
+			return
+		}
+		app.writeTournament(w, r, http.StatusOK, response.Tournament)
+	case action == "matchup":
+		app.currentMatchup(w, r, id)
+	case action == "advance":
+		if r.Method != http.MethodPost {
+			writeError(fmt.Errorf("Method [%s] is not allowed", r.Method), w, r, http.StatusMethodNotAllowed)
 			return
 		}
+		app.admin(func(w http.ResponseWriter, r *http.Request) {
+			app.advanceTournament(w, r, id)
+		})(w, r)
+	default:
+		writeError(fmt.Errorf("Unknown tournament resource [%s]", action), w, r, http.StatusNotFound)
+	}
+}
 
-		emoji := findByShortcodeResponse.Emoji
-		representation := make(map[string]string)
-		representation["votes"] = strconv.Itoa(int(result.Votes))
-		representation["unicode"] = emoji.Unicode
-		representation["shortcode"] = emoji.Shortcode
+func (app *WebApp) advanceTournament(w http.ResponseWriter, r *http.Request, id string) {
+	response, err := app.votingServiceClient.AdvanceTournament(r.Context(), &pb.AdvanceTournamentRequest{Id: id})
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+	app.writeTournament(w, r, http.StatusOK, response.Tournament)
+}
 
-		representations = append(representations, representation)
+func (app *WebApp) listTournaments(w http.ResponseWriter, r *http.Request) {
+	response, err := app.votingServiceClient.ListTournaments(r.Context(), &pb.ListTournamentsRequest{})
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
 	}
 
-	err = writeJsonBody(w, http.StatusOK, representations)
+	tournaments := make([]map[string]interface{}, 0, len(response.Tournaments))
+	for _, t := range response.Tournaments {
+		representation, err := app.tournamentRepresentation(r.Context(), t)
+		if err != nil {
+			writeRPCError(err, w, r)
+			return
+		}
+		tournaments = append(tournaments, representation)
+	}
+
+	err = writeJsonBody(w, http.StatusOK, tournaments)
 
 	if err != nil {
 		writeError(err, w, r, http.StatusInternalServerError)
 	}
 }
 
-func (app *WebApp) voteEmojiHandler(w http.ResponseWriter, r *http.Request) {
-	emojiShortcode := r.FormValue("choice")
-	if emojiShortcode == "" {
-		error := errors.New(fmt.Sprintf("Emoji choice [%s] is mandatory", emojiShortcode))
-		writeError(error, w, r, http.StatusBadRequest)
+func (app *WebApp) createTournament(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(err, w, r, http.StatusBadRequest)
 		return
 	}
 
-	request := &pb.FindByShortcodeRequest{
-		Shortcode: emojiShortcode,
+	request := &pb.CreateTournamentRequest{
+		Id:       r.FormValue("id"),
+		Title:    r.FormValue("title"),
+		Entrants: r.Form["entrant"],
+	}
+	if request.Id == "" {
+		writeError(errors.New("Tournament id is mandatory"), w, r, http.StatusBadRequest)
+		return
+	}
+	if size := r.FormValue("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 0 {
+			writeError(fmt.Errorf("Invalid size value [%s]", size), w, r, http.StatusBadRequest)
+			return
+		}
+		request.Size = int32(n)
+	}
+	if value := r.FormValue("matchup_duration"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			writeError(fmt.Errorf("Invalid matchup_duration value [%s], expected a duration like 90s", value), w, r, http.StatusBadRequest)
+			return
+		}
+		request.MatchupDuration = durationpb.New(d)
+	}
+
+	response, err := app.votingServiceClient.CreateTournament(r.Context(), request)
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+	app.writeTournament(w, r, http.StatusCreated, response.Tournament)
+}
+
+func (app *WebApp) currentMatchup(w http.ResponseWriter, r *http.Request, id string) {
+	response, err := app.votingServiceClient.CurrentMatchup(r.Context(), &pb.CurrentMatchupRequest{TournamentId: id})
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	representation := map[string]interface{}{
+		"matchup":  nil,
+		"champion": response.Champion,
+	}
+	if response.Matchup != nil {
+		if representation["matchup"], err = app.matchupRepresentation(r.Context(), response.Matchup); err != nil {
+			writeRPCError(err, w, r)
+			return
+		}
 	}
-	response, err := app.emojiServiceClient.FindByShortcode(r.Context(), request)
+
+	err = writeJsonBody(w, http.StatusOK, representation)
+
 	if err != nil {
 		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+func (app *WebApp) writeTournament(w http.ResponseWriter, r *http.Request, status int, t *pb.Tournament) {
+	representation, err := app.tournamentRepresentation(r.Context(), t)
+	if err != nil {
+		writeRPCError(err, w, r)
 		return
 	}
 
-	if response.Emoji == nil {
-		err = errors.New(fmt.Sprintf("Choosen emoji shortcode [%s] doesnt exist", emojiShortcode))
-		writeError(err, w, r, http.StatusBadRequest)
+	err = writeJsonBody(w, status, representation)
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// writeRPCError answers with the HTTP status matching the gRPC status of a
+// failed call to the emoji or voting service.
+func writeRPCError(err error, w http.ResponseWriter, r *http.Request) {
+	writeError(err, w, r, httpStatus(status.Code(err)))
+}
+
+// httpStatus is the HTTP status matching a gRPC status code. Errors without
+// a status are Unknown, and answered with 500.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.FailedPrecondition, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// codeNames are the names of gRPC status codes, as in the gRPC spec.
+var codeNames = map[codes.Code]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
+
+// codeName is the name of a gRPC status code, as in "UNAVAILABLE", that
+// problems report the failed call with.
+func codeName(code codes.Code) string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return code.String()
+}
+
+// tournamentRepresentation is a tournament's bracket, with every round as a
+// list of matchups.
+func (app *WebApp) tournamentRepresentation(ctx context.Context, t *pb.Tournament) (map[string]interface{}, error) {
+	seeds := make([]map[string]interface{}, 0, len(t.Seeds))
+	for i, shortcode := range t.Seeds {
+		unicode, err := app.unicodeFor(ctx, shortcode)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, map[string]interface{}{"shortcode": shortcode, "unicode": unicode, "seed": i + 1})
+	}
+	rounds := make([][]map[string]interface{}, 0, len(t.Rounds))
+	for _, round := range t.Rounds {
+		matchups := make([]map[string]interface{}, 0, len(round.Matchups))
+		for _, m := range round.Matchups {
+			matchup, err := app.matchupRepresentation(ctx, m)
+			if err != nil {
+				return nil, err
+			}
+			matchups = append(matchups, matchup)
+		}
+		rounds = append(rounds, matchups)
+	}
+	return map[string]interface{}{
+		"id":              t.Id,
+		"title":           t.Title,
+		"seeds":           seeds,
+		"matchup_seconds": t.MatchupDuration.AsDuration().Seconds(),
+		"rounds":          rounds,
+		"champion":        t.Champion,
+		"created_at":      timestampRepresentation(t.CreatedAt),
+	}, nil
+}
+
+func (app *WebApp) matchupRepresentation(ctx context.Context, m *pb.Matchup) (map[string]interface{}, error) {
+	entrants := make([]map[string]interface{}, 0, len(m.Entrants))
+	for _, e := range m.Entrants {
+		unicode := ""
+		if e.Shortcode != "" {
+			var err error
+			if unicode, err = app.unicodeFor(ctx, e.Shortcode); err != nil {
+				return nil, err
+			}
+		}
+		entrants = append(entrants, map[string]interface{}{
+			"shortcode": e.Shortcode,
+			"unicode":   unicode,
+			"seed":      e.Seed,
+			"votes":     e.Votes,
+		})
+	}
+	return map[string]interface{}{
+		"round":     m.Round,
+		"index":     m.Index,
+		"poll_id":   m.PollId,
+		"state":     m.State,
+		"entrants":  entrants,
+		"winner":    m.Winner,
+		"starts_at": timestampRepresentation(m.StartsAt),
+		"ends_at":   timestampRepresentation(m.EndsAt),
+	}, nil
+}
+
+// pairHandler serves pairwise comparisons: GET picks a pair of emoji to
+// compare, and POST records which of a pair was preferred, from the winner
+// and loser form values.
+func (app *WebApp) pairHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		app.nextPair(w, r)
+	case http.MethodPost:
+		app.comparePair(w, r)
+	default:
+		writeError(fmt.Errorf("Method [%s] is not allowed", r.Method), w, r, http.StatusMethodNotAllowed)
+	}
+}
+
+func (app *WebApp) nextPair(w http.ResponseWriter, r *http.Request) {
+	response, err := app.votingServiceClient.NextPair(r.Context(), &pb.NextPairRequest{})
+	if err != nil {
+		writeRPCError(err, w, r)
+		return
+	}
+
+	pair := make([]map[string]interface{}, 0, 2)
+	for _, shortcode := range []string{response.First, response.Second} {
+		unicode, err := app.unicodeFor(r.Context(), shortcode)
+		if err != nil {
+			writeRPCError(err, w, r)
+			return
+		}
+		pair = append(pair, map[string]interface{}{"shortcode": shortcode, "unicode": unicode})
+	}
+
+	err = writeJsonBody(w, http.StatusOK, pair)
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+func (app *WebApp) comparePair(w http.ResponseWriter, r *http.Request) {
+	winner, loser := r.FormValue("winner"), r.FormValue("loser")
+	if winner == "" || loser == "" {
+		writeError(fmt.Errorf("Both winner [%s] and loser [%s] are mandatory", winner, loser), w, r, http.StatusBadRequest)
+		return
+	}
+
+	response, err := app.votingServiceClient.ComparePair(app.voterContext(w, r), &pb.ComparePairRequest{Winner: winner, Loser: loser})
+	if err != nil {
+		writeRPCError(err, w, r)
 		return
 	}
 
-	voteRequest := &pb.VoteRequest{}
-	switch emojiShortcode {
-	case ":poop:":
-		_, err = app.votingServiceClient.VotePoop(r.Context(), voteRequest)
-	case ":joy:":
-		_, err = app.votingServiceClient.VoteJoy(r.Context(), voteRequest)
-	case ":sunglasses:":
-		_, err = app.votingServiceClient.VoteSunglasses(r.Context(), voteRequest)
-	case ":relaxed:":
-		_, err = app.votingServiceClient.VoteRelaxed(r.Context(), voteRequest)
-	case ":stuck_out_tongue_winking_eye:":
-		_, err = app.votingServiceClient.VoteStuckOutTongueWinkingEye(r.Context(), voteRequest)
-	case ":money_mouth_face:":
-		_, err = app.votingServiceClient.VoteMoneyMouthFace(r.Context(), voteRequest)
-	case ":flushed:":
-		_, err = app.votingServiceClient.VoteFlushed(r.Context(), voteRequest)
-	case ":mask:":
-		_, err = app.votingServiceClient.VoteMask(r.Context(), voteRequest)
-	case ":nerd_face:":
-		_, err = app.votingServiceClient.VoteNerdFace(r.Context(), voteRequest)
-	case ":ghost:":
-		_, err = app.votingServiceClient.VoteGhost(r.Context(), voteRequest)
-	case ":skull_and_crossbones:":
-		_, err = app.votingServiceClient.VoteSkullAndCrossbones(r.Context(), voteRequest)
-	case ":heart_eyes_cat:":
-		_, err = app.votingServiceClient.VoteHeartEyesCat(r.Context(), voteRequest)
-	case ":hear_no_evil:":
-		_, err = app.votingServiceClient.VoteHearNoEvil(r.Context(), voteRequest)
-	case ":see_no_evil:":
-		_, err = app.votingServiceClient.VoteSeeNoEvil(r.Context(), voteRequest)
-	case ":speak_no_evil:":
-		_, err = app.votingServiceClient.VoteSpeakNoEvil(r.Context(), voteRequest)
-	case ":boy:":
-		_, err = app.votingServiceClient.VoteBoy(r.Context(), voteRequest)
-	case ":girl:":
-		_, err = app.votingServiceClient.VoteGirl(r.Context(), voteRequest)
-	case ":man:":
-		_, err = app.votingServiceClient.VoteMan(r.Context(), voteRequest)
-	case ":woman:":
-		_, err = app.votingServiceClient.VoteWoman(r.Context(), voteRequest)
-	case ":older_man:":
-		_, err = app.votingServiceClient.VoteOlderMan(r.Context(), voteRequest)
-	case ":policeman:":
-		_, err = app.votingServiceClient.VotePoliceman(r.Context(), voteRequest)
-	case ":guardsman:":
-		_, err = app.votingServiceClient.VoteGuardsman(r.Context(), voteRequest)
-	case ":construction_worker_man:":
-		_, err = app.votingServiceClient.VoteConstructionWorkerMan(r.Context(), voteRequest)
-	case ":prince:":
-		_, err = app.votingServiceClient.VotePrince(r.Context(), voteRequest)
-	case ":princess:":
-		_, err = app.votingServiceClient.VotePrincess(r.Context(), voteRequest)
-	case ":man_in_tuxedo:":
-		_, err = app.votingServiceClient.VoteManInTuxedo(r.Context(), voteRequest)
-	case ":bride_with_veil:":
-		_, err = app.votingServiceClient.VoteBrideWithVeil(r.Context(), voteRequest)
-	case ":mrs_claus:":
-		_, err = app.votingServiceClient.VoteMrsClaus(r.Context(), voteRequest)
-	case ":santa:":
-		_, err = app.votingServiceClient.VoteSanta(r.Context(), voteRequest)
-	case ":turkey:":
-		_, err = app.votingServiceClient.VoteTurkey(r.Context(), voteRequest)
-	case ":rabbit:":
-		_, err = app.votingServiceClient.VoteRabbit(r.Context(), voteRequest)
-	case ":no_good_woman:":
-		_, err = app.votingServiceClient.VoteNoGoodWoman(r.Context(), voteRequest)
-	case ":ok_woman:":
-		_, err = app.votingServiceClient.VoteOkWoman(r.Context(), voteRequest)
-	case ":raising_hand_woman:":
-		_, err = app.votingServiceClient.VoteRaisingHandWoman(r.Context(), voteRequest)
-	case ":bowing_man:":
-		_, err = app.votingServiceClient.VoteBowingMan(r.Context(), voteRequest)
-	case ":man_facepalming:":
-		_, err = app.votingServiceClient.VoteManFacepalming(r.Context(), voteRequest)
-	case ":woman_shrugging:":
-		_, err = app.votingServiceClient.VoteWomanShrugging(r.Context(), voteRequest)
-	case ":massage_woman:":
-		_, err = app.votingServiceClient.VoteMassageWoman(r.Context(), voteRequest)
-	case ":walking_man:":
-		_, err = app.votingServiceClient.VoteWalkingMan(r.Context(), voteRequest)
-	case ":running_man:":
-		_, err = app.votingServiceClient.VoteRunningMan(r.Context(), voteRequest)
-	case ":dancer:":
-		_, err = app.votingServiceClient.VoteDancer(r.Context(), voteRequest)
-	case ":man_dancing:":
-		_, err = app.votingServiceClient.VoteManDancing(r.Context(), voteRequest)
-	case ":dancing_women:":
-		_, err = app.votingServiceClient.VoteDancingWomen(r.Context(), voteRequest)
-	case ":rainbow:":
-		_, err = app.votingServiceClient.VoteRainbow(r.Context(), voteRequest)
-	case ":skier:":
-		_, err = app.votingServiceClient.VoteSkier(r.Context(), voteRequest)
-	case ":golfing_man:":
-		_, err = app.votingServiceClient.VoteGolfingMan(r.Context(), voteRequest)
-	case ":surfing_man:":
-		_, err = app.votingServiceClient.VoteSurfingMan(r.Context(), voteRequest)
-	case ":basketball_man:":
-		_, err = app.votingServiceClient.VoteBasketballMan(r.Context(), voteRequest)
-	case ":biking_man:":
-		_, err = app.votingServiceClient.VoteBikingMan(r.Context(), voteRequest)
-	case ":point_up_2:":
-		_, err = app.votingServiceClient.VotePointUp2(r.Context(), voteRequest)
-	case ":vulcan_salute:":
-		_, err = app.votingServiceClient.VoteVulcanSalute(r.Context(), voteRequest)
-	case ":metal:":
-		_, err = app.votingServiceClient.VoteMetal(r.Context(), voteRequest)
-	case ":call_me_hand:":
-		_, err = app.votingServiceClient.VoteCallMeHand(r.Context(), voteRequest)
-	case ":thumbsup:":
-		_, err = app.votingServiceClient.VoteThumbsup(r.Context(), voteRequest)
-	case ":wave:":
-		_, err = app.votingServiceClient.VoteWave(r.Context(), voteRequest)
-	case ":clap:":
-		_, err = app.votingServiceClient.VoteClap(r.Context(), voteRequest)
-	case ":raised_hands:":
-		_, err = app.votingServiceClient.VoteRaisedHands(r.Context(), voteRequest)
-	case ":pray:":
-		_, err = app.votingServiceClient.VotePray(r.Context(), voteRequest)
-	case ":dog:":
-		_, err = app.votingServiceClient.VoteDog(r.Context(), voteRequest)
-	case ":cat2:":
-		_, err = app.votingServiceClient.VoteCat2(r.Context(), voteRequest)
-	case ":pig:":
-		_, err = app.votingServiceClient.VotePig(r.Context(), voteRequest)
-	case ":hatching_chick:":
-		_, err = app.votingServiceClient.VoteHatchingChick(r.Context(), voteRequest)
-	case ":snail:":
-		_, err = app.votingServiceClient.VoteSnail(r.Context(), voteRequest)
-	case ":bacon:":
-		_, err = app.votingServiceClient.VoteBacon(r.Context(), voteRequest)
-	case ":pizza:":
-		_, err = app.votingServiceClient.VotePizza(r.Context(), voteRequest)
-	case ":taco:":
-		_, err = app.votingServiceClient.VoteTaco(r.Context(), voteRequest)
-	case ":burrito:":
-		_, err = app.votingServiceClient.VoteBurrito(r.Context(), voteRequest)
-	case ":ramen:":
-		_, err = app.votingServiceClient.VoteRamen(r.Context(), voteRequest)
-	case ":doughnut:":
-		_, err = app.votingServiceClient.VoteDoughnut(r.Context(), voteRequest)
-	case ":champagne:":
-		_, err = app.votingServiceClient.VoteChampagne(r.Context(), voteRequest)
-	case ":tropical_drink:":
-		_, err = app.votingServiceClient.VoteTropicalDrink(r.Context(), voteRequest)
-	case ":beer:":
-		_, err = app.votingServiceClient.VoteBeer(r.Context(), voteRequest)
-	case ":tumbler_glass:":
-		_, err = app.votingServiceClient.VoteTumblerGlass(r.Context(), voteRequest)
-	case ":world_map:":
-		_, err = app.votingServiceClient.VoteWorldMap(r.Context(), voteRequest)
-	case ":beach_umbrella:":
-		_, err = app.votingServiceClient.VoteBeachUmbrella(r.Context(), voteRequest)
-	case ":mountain_snow:":
-		_, err = app.votingServiceClient.VoteMountainSnow(r.Context(), voteRequest)
-	case ":camping:":
-		_, err = app.votingServiceClient.VoteCamping(r.Context(), voteRequest)
-	case ":steam_locomotive:":
-		_, err = app.votingServiceClient.VoteSteamLocomotive(r.Context(), voteRequest)
-	case ":flight_departure:":
-		_, err = app.votingServiceClient.VoteFlightDeparture(r.Context(), voteRequest)
-	case ":rocket:":
-		_, err = app.votingServiceClient.VoteRocket(r.Context(), voteRequest)
-	case ":star2:":
-		_, err = app.votingServiceClient.VoteStar2(r.Context(), voteRequest)
-	case ":sun_behind_small_cloud:":
-		_, err = app.votingServiceClient.VoteSunBehindSmallCloud(r.Context(), voteRequest)
-	case ":cloud_with_rain:":
-		_, err = app.votingServiceClient.VoteCloudWithRain(r.Context(), voteRequest)
-	case ":fire:":
-		_, err = app.votingServiceClient.VoteFire(r.Context(), voteRequest)
-	case ":jack_o_lantern:":
-		_, err = app.votingServiceClient.VoteJackOLantern(r.Context(), voteRequest)
-	case ":balloon:":
-		_, err = app.votingServiceClient.VoteBalloon(r.Context(), voteRequest)
-	case ":tada:":
-		_, err = app.votingServiceClient.VoteTada(r.Context(), voteRequest)
-	case ":trophy:":
-		_, err = app.votingServiceClient.VoteTrophy(r.Context(), voteRequest)
-	case ":iphone:":
-		_, err = app.votingServiceClient.VoteIphone(r.Context(), voteRequest)
-	case ":pager:":
-		_, err = app.votingServiceClient.VotePager(r.Context(), voteRequest)
-	case ":fax:":
-		_, err = app.votingServiceClient.VoteFax(r.Context(), voteRequest)
-	case ":bulb:":
-		_, err = app.votingServiceClient.VoteBulb(r.Context(), voteRequest)
-	case ":money_with_wings:":
-		_, err = app.votingServiceClient.VoteMoneyWithWings(r.Context(), voteRequest)
-	case ":crystal_ball:":
-		_, err = app.votingServiceClient.VoteCrystalBall(r.Context(), voteRequest)
-	case ":underage:":
-		_, err = app.votingServiceClient.VoteUnderage(r.Context(), voteRequest)
-	case ":interrobang:":
-		_, err = app.votingServiceClient.VoteInterrobang(r.Context(), voteRequest)
-	case ":100:":
-		_, err = app.votingServiceClient.Vote100(r.Context(), voteRequest)
-	case ":checkered_flag:":
-		_, err = app.votingServiceClient.VoteCheckeredFlag(r.Context(), voteRequest)
-	case ":crossed_swords:":
-		_, err = app.votingServiceClient.VoteCrossedSwords(r.Context(), voteRequest)
-	case ":floppy_disk:":
-		_, err = app.votingServiceClient.VoteFloppyDisk(r.Context(), voteRequest)
+	ratings := make([]map[string]interface{}, 0, 2)
+	for _, rating := range []*pb.Rating{response.Winner, response.Loser} {
+		representation, err := app.ratingRepresentation(r.Context(), rating)
+		if err != nil {
+			writeRPCError(err, w, r)
+			return
+		}
+		ratings = append(ratings, representation)
 	}
+
+	err = writeJsonBody(w, http.StatusOK, map[string]interface{}{"winner": ratings[0], "loser": ratings[1]})
+
 	if err != nil {
 		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+// ratingsHandler serves the leaderboard of pairwise comparisons, best rated
+// first.
+func (app *WebApp) ratingsHandler(w http.ResponseWriter, r *http.Request) {
+	response, err := app.votingServiceClient.Ratings(r.Context(), &pb.RatingsRequest{})
+	if err != nil {
+		writeRPCError(err, w, r)
 		return
 	}
+
+	ratings := make([]map[string]interface{}, 0, len(response.Ratings))
+	for _, rating := range response.Ratings {
+		representation, err := app.ratingRepresentation(r.Context(), rating)
+		if err != nil {
+			writeRPCError(err, w, r)
+			return
+		}
+		ratings = append(ratings, representation)
+	}
+
+	err = writeJsonBody(w, http.StatusOK, ratings)
+
+	if err != nil {
+		writeError(err, w, r, http.StatusInternalServerError)
+	}
+}
+
+func (app *WebApp) ratingRepresentation(ctx context.Context, rating *pb.Rating) (map[string]interface{}, error) {
+	unicode, err := app.unicodeFor(ctx, rating.Shortcode)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"shortcode":   rating.Shortcode,
+		"unicode":     unicode,
+		"rating":      rating.Rating,
+		"deviation":   rating.Deviation,
+		"comparisons": rating.Comparisons,
+	}, nil
+}
+
+func pollRepresentation(p *pb.Poll) map[string]interface{} {
+	ballot := p.Ballot
+	if ballot == nil {
+		ballot = []string{}
+	}
+	return map[string]interface{}{
+		"id":          p.Id,
+		"title":       p.Title,
+		"ballot":      ballot,
+		"mode":        p.Mode,
+		"max_choices": p.MaxChoices,
+		"closed":      p.Closed,
+		"state":       p.State,
+		"starts_at":   timestampRepresentation(p.StartsAt),
+		"ends_at":     timestampRepresentation(p.EndsAt),
+	}
+}
+
+// timestampRepresentation is an RFC 3339 time, or nil when unset.
+func timestampRepresentation(ts *timestamppb.Timestamp) interface{} {
+	if ts == nil {
+		return nil
+	}
+	return ts.AsTime().Format(time.RFC3339)
+}
+
+// formTimestamp parses an optional RFC 3339 form value.
+func formTimestamp(r *http.Request, key string) (*timestamppb.Timestamp, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s value [%s], expected an RFC 3339 time", key, value)
+	}
+	return timestamppb.New(t), nil
+}
+
+func formDuration(r *http.Request, key string) (*durationpb.Duration, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("Invalid %s value [%s], expected a duration such as 10m", key, value)
+	}
+	return durationpb.New(d), nil
+}
+
+// voterContext forwards what we know about the browser to the voting service,
+// along with an idempotency key. With voter identity on, that includes a
+// voter ID, so the voting service keeps a single vote per voter.
+func (app *WebApp) voterContext(w http.ResponseWriter, r *http.Request) context.Context {
+	address := r.Header.Get("X-Forwarded-For")
+	if address == "" {
+		address = r.RemoteAddr
+	}
+	ctx := metadata.AppendToOutgoingContext(r.Context(),
+		"voter-address", address,
+		"voter-user-agent", r.UserAgent())
+	// The voting service's weight policy can weigh votes by their class.
+	if class := app.voterClasses.classOf(r); class != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "voter-class", class)
+	}
+	if key, err := idempotencyKey(w, r); err != nil {
+		log.Printf("Failed to assign an idempotency key: %v", err)
+	} else {
+		ctx = metadata.AppendToOutgoingContext(ctx, "idempotency-key", key)
+	}
+
+	if !app.voterIdentity {
+		return ctx
+	}
+	voterID, err := voterID(w, r)
+	if err != nil {
+		log.Printf("Failed to assign a voter ID: %v", err)
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "voter-id", voterID)
+}
+
+// idempotencyKey accepts the caller's key from the Idempotency-Key header,
+// so that retrying the request doesn't count it twice, and otherwise assigns
+// a new one, which still covers retries between the web and voting services.
+// The key is returned in the same header.
+func idempotencyKey(w http.ResponseWriter, r *http.Request) (string, error) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if !idempotencyKeyPattern.MatchString(key) {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		key = hex.EncodeToString(b)
+	}
+	w.Header().Set(idempotencyKeyHeader, key)
+	return key, nil
+}
+
+// voterID accepts a voter ID from the X-Voter-Id header or the voter cookie,
+// and otherwise assigns a new one in the cookie.
+func voterID(w http.ResponseWriter, r *http.Request) (string, error) {
+	if id := r.Header.Get(voterIDHeader); voterIDPattern.MatchString(id) {
+		return id, nil
+	}
+	if cookie, err := r.Cookie(voterIDCookie); err == nil && voterIDPattern.MatchString(cookie.Value) {
+		return cookie.Value, nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     voterIDCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id, nil
 }
 
 func (app *WebApp) indexHandler(w http.ResponseWriter, r *http.Request) {
@@ -369,45 +1445,163 @@ func writeJsonBody(w http.ResponseWriter, status int, body interface{}) error {
 	return json.NewEncoder(w).Encode(body)
 }
 
-func writeError(err error, w http.ResponseWriter, r *http.Request, status int) {
-	log.Printf("Error serving request [%v]: %v", r, err)
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	w.WriteHeader(status)
-	errorMessage := make(map[string]string)
-	errorMessage["error"] = fmt.Sprintf("%v", err)
-	json.NewEncoder(w).Encode(errorMessage)
+// problem is the RFC 7807 body of an error. Errors from the emoji and
+// voting services add the name of their gRPC status code and what their
+// details say.
+type problem struct {
+	Type          string          `json:"type"`
+	Title         string          `json:"title"`
+	Status        int             `json:"status"`
+	Detail        string          `json:"detail"`
+	Instance      string          `json:"instance"`
+	RequestID     string          `json:"request_id"`
+	Code          string          `json:"code,omitempty"`
+	Reason        string          `json:"reason,omitempty"`
+	Resource      *problemSubject `json:"resource,omitempty"`
+	InvalidParams []problemParam  `json:"invalid_params,omitempty"`
+}
+
+type problemSubject struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type problemParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func writeError(err error, w http.ResponseWriter, r *http.Request, statusCode int) {
+	body := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    err.Error(),
+		Instance:  r.URL.Path,
+		RequestID: requestID(w, r),
+	}
+	if st, ok := status.FromError(err); ok {
+		body.Detail, body.Code = st.Message(), codeName(st.Code())
+		for _, detail := range st.Details() {
+			switch d := detail.(type) {
+			case *errdetails.ErrorInfo:
+				body.Reason = d.Reason
+			case *errdetails.ResourceInfo:
+				body.Resource = &problemSubject{Type: d.ResourceType, Name: d.ResourceName}
+			case *errdetails.BadRequest:
+				for _, v := range d.FieldViolations {
+					body.InvalidParams = append(body.InvalidParams, problemParam{Name: v.Field, Reason: v.Description})
+				}
+			}
+		}
+	}
+
+	log.Printf("Error serving request [%s] [%v]: %v", body.RequestID, r, err)
+	w.Header().Set("Content-Type", "application/problem+json; charset=UTF-8")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+// requestID accepts the caller's ID for the request from the X-Request-Id
+// header, and otherwise assigns a new one. The ID is returned in the same
+// header.
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		return id
+	}
+	id := r.Header.Get(requestIDHeader)
+	if !requestIDPattern.MatchString(id) {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			log.Printf("Failed to assign a request ID: %v", err)
+		}
+		id = hex.EncodeToString(b)
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
+
+// withRequestID tags each request with an ID, which is annotated on its
+// span and passed on to the emoji and voting services as x-request-id.
+func withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(w, r)
+		trace.FromContext(r.Context()).AddAttributes(trace.StringAttribute("request_id", id))
+		ctx := metadata.AppendToOutgoingContext(r.Context(), "x-request-id", id)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
-func handle(path string, h func(w http.ResponseWriter, r *http.Request)) {
+func (app *WebApp) handle(path string, h func(w http.ResponseWriter, r *http.Request)) {
 	http.Handle(path, &ochttp.Handler{
-		Handler: http.HandlerFunc(h),
+		Handler: withRequestID(app.faults.middleware(http.HandlerFunc(h))),
 	})
 }
 
 func StartServer(webPort, webpackDevServer, indexBundle string, emojiServiceClient pb.EmojiServiceClient, votingClient pb.VotingServiceClient) {
 
 	motd := os.Getenv("MESSAGE_OF_THE_DAY")
+	voterIdentity, _ := strconv.ParseBool(os.Getenv("VOTER_IDENTITY"))
+	faults := newHTTPFaults()
+	if faultsPath := os.Getenv("FAULT_RULES"); faultsPath != "" {
+		rules, err := loadHTTPFaultRules(faultsPath)
+		if err == nil {
+			err = faults.setRules(rules)
+		}
+		if err != nil {
+			log.Fatalf("Failed to load FAULT_RULES: %v", err)
+		}
+		log.Printf("Loaded [%d] fault rules from FAULT_RULES=[%s]", len(rules), faultsPath)
+	}
+	voterClasses, err := loadVoterClasses(os.Getenv("VOTER_CLASS_HEADER"), os.Getenv("VOTER_API_KEYS"))
+	if err != nil {
+		log.Fatalf("Failed to load VOTER_API_KEYS: %v", err)
+	}
+	log.Printf("Taking voter classes from [%d] API keys and VOTER_CLASS_HEADER=[%s]", len(voterClasses.keys), voterClasses.header)
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		log.Printf("Turning the admin API off, as ADMIN_TOKEN isn't set")
+	}
 	webApp := &WebApp{
 		emojiServiceClient:  emojiServiceClient,
 		votingServiceClient: votingClient,
 		indexBundle:         indexBundle,
 		webpackDevServer:    webpackDevServer,
 		messageOfTheDay:     motd,
+		voterIdentity:       voterIdentity,
+		voterClasses:        voterClasses,
+		adminToken:          adminToken,
+		faults:              faults,
 	}
 
-	log.Printf("Starting web server on WEB_PORT=[%s] and MESSAGE_OF_THE_DAY=[%s]", webPort, motd)
-	handle("/", webApp.indexHandler)
-	handle("/leaderboard", webApp.indexHandler)
-	handle("/js", webApp.jsHandler)
-	handle("/img/favicon.ico", webApp.faviconHandler)
-	handle("/api/list", webApp.listEmojiHandler)
-	handle("/api/vote", webApp.voteEmojiHandler)
-	handle("/api/leaderboard", webApp.leaderboardHandler)
+	log.Printf("Starting web server on WEB_PORT=[%s], MESSAGE_OF_THE_DAY=[%s] and VOTER_IDENTITY=[%t]", webPort, motd, voterIdentity)
+	webApp.handle("/", webApp.indexHandler)
+	webApp.handle("/leaderboard", webApp.indexHandler)
+	webApp.handle("/tournament", webApp.indexHandler)
+	webApp.handle("/compare", webApp.indexHandler)
+	webApp.handle("/js", webApp.jsHandler)
+	webApp.handle("/img/favicon.ico", webApp.faviconHandler)
+	webApp.handle("/api/list", webApp.listEmojiHandler)
+	webApp.handle("/api/vote", webApp.voteEmojiHandler)
+	webApp.handle("/api/votes:batch", webApp.voteBatchHandler)
+	webApp.handle("/api/leaderboard", webApp.leaderboardHandler)
+	webApp.handle("/api/leaderboard/stream", webApp.leaderboardStreamHandler)
+	webApp.handle("/api/v2/leaderboard", webApp.leaderboardV2Handler)
+	webApp.handle("/api/history", webApp.historyHandler)
+	webApp.handle("/api/trending", webApp.trendingHandler)
+	webApp.handle("/api/polls", webApp.pollsHandler)
+	webApp.handle("/api/polls/", webApp.pollsHandler)
+	webApp.handle("/api/admin/retract", webApp.admin(webApp.retractVotesHandler))
+	webApp.handle(faultsAdminPath, webApp.admin(webApp.faultsHandler))
+	webApp.handle("/api/tournaments", webApp.tournamentsHandler)
+	webApp.handle("/api/tournaments/", webApp.tournamentsHandler)
+	webApp.handle("/api/pair", webApp.pairHandler)
+	webApp.handle("/api/ratings", webApp.ratingsHandler)
 
 	// TODO: make static assets dir configurable
 	http.Handle("/dist/", http.StripPrefix("/dist/", http.FileServer(http.Dir("dist"))))
 
-	err := http.ListenAndServe(fmt.Sprintf(":%s", webPort), nil)
+	err = http.ListenAndServe(fmt.Sprintf(":%s", webPort), nil)
 	if err != nil {
 		panic(err)
 	}
@@ -3,14 +3,28 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/buoyantio/emojivoto/admin"
 	pb "github.com/buoyantio/emojivoto/emojivoto-web/gen/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type MockEmojiServiceClient struct {
@@ -27,6 +41,9 @@ func (c *MockEmojiServiceClient) ListAll(ctx context.Context, in *pb.ListAllEmoj
 
 func (c *MockEmojiServiceClient) FindByShortcode(ctx context.Context, req *pb.FindByShortcodeRequest, opts ...grpc.CallOption) (*pb.FindByShortcodeResponse, error) {
 	foundEmoji := c.findByShortcode(req.Shortcode)
+	if foundEmoji == nil {
+		return nil, status.Errorf(codes.NotFound, "no emoji with shortcode [%s]", req.Shortcode)
+	}
 
 	return &pb.FindByShortcodeResponse{
 		Emoji: foundEmoji,
@@ -47,418 +64,228 @@ func (c *MockEmojiServiceClient) findByShortcode(shortcode string) *pb.Emoji {
 }
 
 type MockVotingServiceClient struct {
+	pb.VotingServiceClient
 	lastChoiceShortcode string
+	lastPollID          string
+	lastVoterID         string
+	lastMetadata        metadata.MD
+	lastRetract         *pb.RetractRequest
+	lastRetractVotes    *pb.RetractVotesRequest
+	lastRetractVotesMD  metadata.MD
+	lastVoteHistory     *pb.VoteHistoryRequest
+	lastTrending        *pb.TrendingRequest
+	lastRanking         []string
+	lastApproval        []string
+	ballotsToReturn     int32
+	tournament          *pb.Tournament
+	lastComparison      *pb.ComparePairRequest
 	resultToReturn      []*pb.VotingResult
+	leadToReturn        *pb.Lead
+	updatesToStream     []*pb.ResultsUpdate
+	polls               []*pb.Poll
 }
 
-func (c *MockVotingServiceClient) vote(shortcode string) (*pb.VoteResponse, error) {
-	c.lastChoiceShortcode = shortcode
-	return &pb.VoteResponse{}, nil
-}
-
-func (c *MockVotingServiceClient) VoteDoughnut(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return nil, fmt.Errorf("ERROR")
-}
-
-func (c *MockVotingServiceClient) VotePoop(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":poop:")
-}
-
-func (c *MockVotingServiceClient) VoteJoy(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":joy:")
-}
-
-func (c *MockVotingServiceClient) VoteSunglasses(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":sunglasses:")
-}
-
-func (c *MockVotingServiceClient) VoteRelaxed(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":relaxed:")
-}
-
-func (c *MockVotingServiceClient) VoteStuckOutTongueWinkingEye(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":stuck_out_tongue_winking_eye:")
-}
-
-func (c *MockVotingServiceClient) VoteMoneyMouthFace(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":money_mouth_face:")
-}
-
-func (c *MockVotingServiceClient) VoteFlushed(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":flushed:")
-}
-
-func (c *MockVotingServiceClient) VoteMask(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":mask:")
-}
-
-func (c *MockVotingServiceClient) VoteNerdFace(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":nerd_face:")
-}
-
-func (c *MockVotingServiceClient) VoteGhost(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":ghost:")
-}
-
-func (c *MockVotingServiceClient) VoteSkullAndCrossbones(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":skull_and_crossbones:")
-}
-
-func (c *MockVotingServiceClient) VoteHeartEyesCat(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":heart_eyes_cat:")
-}
-
-func (c *MockVotingServiceClient) VoteHearNoEvil(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":hear_no_evil:")
-}
-
-func (c *MockVotingServiceClient) VoteSeeNoEvil(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":see_no_evil:")
-}
-
-func (c *MockVotingServiceClient) VoteSpeakNoEvil(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":speak_no_evil:")
-}
-
-func (c *MockVotingServiceClient) VoteBoy(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":boy:")
-}
-
-func (c *MockVotingServiceClient) VoteGirl(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":girl:")
-}
-
-func (c *MockVotingServiceClient) VoteMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":man:")
-}
-
-func (c *MockVotingServiceClient) VoteWoman(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":woman:")
-}
-
-func (c *MockVotingServiceClient) VoteOlderMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":older_man:")
-}
-
-func (c *MockVotingServiceClient) VotePoliceman(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":policeman:")
-}
-
-func (c *MockVotingServiceClient) VoteGuardsman(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":guardsman:")
-}
-
-func (c *MockVotingServiceClient) VoteConstructionWorkerMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":construction_worker_man:")
-}
-
-func (c *MockVotingServiceClient) VotePrince(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":prince:")
-}
-
-func (c *MockVotingServiceClient) VotePrincess(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":princess:")
-}
-
-func (c *MockVotingServiceClient) VoteManInTuxedo(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":man_in_tuxedo:")
-}
-
-func (c *MockVotingServiceClient) VoteBrideWithVeil(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":bride_with_veil:")
-}
-
-func (c *MockVotingServiceClient) VoteMrsClaus(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":mrs_claus:")
-}
-
-func (c *MockVotingServiceClient) VoteSanta(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":santa:")
-}
-
-func (c *MockVotingServiceClient) VoteTurkey(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":turkey:")
-}
-
-func (c *MockVotingServiceClient) VoteRabbit(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":rabbit:")
-}
-
-func (c *MockVotingServiceClient) VoteNoGoodWoman(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":no_good_woman:")
-}
-
-func (c *MockVotingServiceClient) VoteOkWoman(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":ok_woman:")
-}
-
-func (c *MockVotingServiceClient) VoteRaisingHandWoman(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":raising_hand_woman:")
-}
-
-func (c *MockVotingServiceClient) VoteBowingMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":bowing_man:")
-}
-
-func (c *MockVotingServiceClient) VoteManFacepalming(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":man_facepalming:")
-}
-
-func (c *MockVotingServiceClient) VoteWomanShrugging(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":woman_shrugging:")
-}
-
-func (c *MockVotingServiceClient) VoteMassageWoman(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":massage_woman:")
-}
-
-func (c *MockVotingServiceClient) VoteWalkingMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":walking_man:")
-}
-
-func (c *MockVotingServiceClient) VoteRunningMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":running_man:")
-}
-
-func (c *MockVotingServiceClient) VoteDancer(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":dancer:")
-}
-
-func (c *MockVotingServiceClient) VoteManDancing(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":man_dancing:")
-}
-
-func (c *MockVotingServiceClient) VoteDancingWomen(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":dancing_women:")
-}
-
-func (c *MockVotingServiceClient) VoteRainbow(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":rainbow:")
-}
-
-func (c *MockVotingServiceClient) VoteSkier(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":skier:")
-}
-
-func (c *MockVotingServiceClient) VoteGolfingMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":golfing_man:")
-}
-
-func (c *MockVotingServiceClient) VoteSurfingMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":surfing_man:")
-}
-
-func (c *MockVotingServiceClient) VoteBasketballMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":basketball_man:")
-}
-
-func (c *MockVotingServiceClient) VoteBikingMan(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":biking_man:")
-}
-
-func (c *MockVotingServiceClient) VotePointUp2(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":point_up_2:")
-}
-
-func (c *MockVotingServiceClient) VoteVulcanSalute(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":vulcan_salute:")
-}
-
-func (c *MockVotingServiceClient) VoteMetal(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":metal:")
-}
-
-func (c *MockVotingServiceClient) VoteCallMeHand(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":call_me_hand:")
-}
-
-func (c *MockVotingServiceClient) VoteThumbsup(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":thumbsup:")
-}
-
-func (c *MockVotingServiceClient) VoteWave(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":wave:")
-}
-
-func (c *MockVotingServiceClient) VoteClap(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":clap:")
-}
-
-func (c *MockVotingServiceClient) VoteRaisedHands(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":raised_hands:")
-}
-
-func (c *MockVotingServiceClient) VotePray(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":pray:")
-}
-
-func (c *MockVotingServiceClient) VoteDog(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":dog:")
-}
-
-func (c *MockVotingServiceClient) VoteCat2(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":cat2:")
-}
-
-func (c *MockVotingServiceClient) VotePig(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":pig:")
-}
-
-func (c *MockVotingServiceClient) VoteHatchingChick(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":hatching_chick:")
+type MockWatchResultsClient struct {
+	grpc.ClientStream
+	updates []*pb.ResultsUpdate
 }
 
-func (c *MockVotingServiceClient) VoteSnail(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":snail:")
-}
-
-func (c *MockVotingServiceClient) VoteBacon(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":bacon:")
-}
-
-func (c *MockVotingServiceClient) VotePizza(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":pizza:")
-}
-
-func (c *MockVotingServiceClient) VoteTaco(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":taco:")
-}
-
-func (c *MockVotingServiceClient) VoteBurrito(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":burrito:")
-}
-
-func (c *MockVotingServiceClient) VoteRamen(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":ramen:")
-}
-
-func (c *MockVotingServiceClient) VoteChampagne(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":champagne:")
-}
-
-func (c *MockVotingServiceClient) VoteTropicalDrink(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":tropical_drink:")
-}
-
-func (c *MockVotingServiceClient) VoteBeer(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":beer:")
-}
-
-func (c *MockVotingServiceClient) VoteTumblerGlass(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":tumbler_glass:")
-}
-
-func (c *MockVotingServiceClient) VoteWorldMap(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":world_map:")
-}
-
-func (c *MockVotingServiceClient) VoteBeachUmbrella(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":beach_umbrella:")
-}
-
-func (c *MockVotingServiceClient) VoteMountainSnow(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":mountain_snow:")
-}
-
-func (c *MockVotingServiceClient) VoteCamping(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":camping:")
-}
-
-func (c *MockVotingServiceClient) VoteSteamLocomotive(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":steam_locomotive:")
-}
-
-func (c *MockVotingServiceClient) VoteFlightDeparture(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":flight_departure:")
-}
-
-func (c *MockVotingServiceClient) VoteRocket(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":rocket:")
-}
-
-func (c *MockVotingServiceClient) VoteStar2(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":star2:")
+func (c *MockWatchResultsClient) Recv() (*pb.ResultsUpdate, error) {
+	if len(c.updates) == 0 {
+		return nil, io.EOF
+	}
+	update := c.updates[0]
+	c.updates = c.updates[1:]
+	return update, nil
 }
 
-func (c *MockVotingServiceClient) VoteSunBehindSmallCloud(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":sun_behind_small_cloud:")
+func (c *MockVotingServiceClient) Vote(ctx context.Context, in *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
+	if in.Shortcode == ":doughnut:" {
+		return nil, fmt.Errorf("ERROR")
+	}
+	c.lastChoiceShortcode = in.Shortcode
+	c.lastPollID = in.PollId
+	c.lastVoterID = ""
+	c.lastMetadata, _ = metadata.FromOutgoingContext(ctx)
+	if len(c.lastMetadata.Get("voter-id")) > 0 {
+		c.lastVoterID = c.lastMetadata.Get("voter-id")[0]
+	}
+	return &pb.VoteResponse{}, nil
 }
 
-func (c *MockVotingServiceClient) VoteCloudWithRain(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":cloud_with_rain:")
+func (c *MockVotingServiceClient) VoteBatch(ctx context.Context, in *pb.VoteBatchRequest, _ ...grpc.CallOption) (*pb.VoteBatchResponse, error) {
+	c.lastMetadata, _ = metadata.FromOutgoingContext(ctx)
+	response := &pb.VoteBatchResponse{}
+	for i, v := range in.Votes {
+		result := &pb.BatchVoteResult{Index: int32(i)}
+		switch {
+		case v.PollId == "nope":
+			result.Code, result.Message = int32(codes.NotFound), "poll not found: [nope]"
+		case v.Shortcode == ":doughnut:":
+			result.Code, result.Message = int32(codes.Unknown), "ERROR"
+		default:
+			response.Accepted++
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response, nil
 }
 
-func (c *MockVotingServiceClient) VoteFire(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":fire:")
+func (c *MockVotingServiceClient) Retract(_ context.Context, in *pb.RetractRequest, _ ...grpc.CallOption) (*pb.RetractResponse, error) {
+	c.lastRetract = in
+	if in.Shortcode != c.lastChoiceShortcode {
+		return nil, status.Errorf(codes.NotFound, "no vote for [%s]", in.Shortcode)
+	}
+	c.lastChoiceShortcode = ""
+	return &pb.RetractResponse{}, nil
 }
 
-func (c *MockVotingServiceClient) VoteJackOLantern(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":jack_o_lantern:")
+func (c *MockVotingServiceClient) RetractVotes(ctx context.Context, in *pb.RetractVotesRequest, _ ...grpc.CallOption) (*pb.RetractVotesResponse, error) {
+	c.lastRetractVotes = in
+	c.lastRetractVotesMD, _ = metadata.FromOutgoingContext(ctx)
+	return &pb.RetractVotesResponse{Retracted: 3}, nil
 }
 
-func (c *MockVotingServiceClient) VoteBalloon(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":balloon:")
+func (c *MockVotingServiceClient) Trending(_ context.Context, in *pb.TrendingRequest, _ ...grpc.CallOption) (*pb.TrendingResponse, error) {
+	c.lastTrending = in
+	halfLife := in.HalfLife
+	if halfLife == nil {
+		halfLife = durationpb.New(10 * time.Minute)
+	}
+	window := in.Window
+	if window == nil {
+		window = halfLife
+	}
+	return &pb.TrendingResponse{
+		HalfLife: halfLife,
+		Window:   window,
+		Results: []*pb.TrendingResult{
+			{Shortcode: ":doughnut:", Score: 3.5, Votes: 40, Rank: 1, PreviousRank: 2, Movement: "rising"},
+		},
+	}, nil
 }
 
-func (c *MockVotingServiceClient) VoteTada(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":tada:")
+func (c *MockVotingServiceClient) VoteHistory(_ context.Context, in *pb.VoteHistoryRequest, _ ...grpc.CallOption) (*pb.VoteHistoryResponse, error) {
+	c.lastVoteHistory = in
+	return &pb.VoteHistoryResponse{
+		From: timestamppb.New(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)),
+		Step: in.Step,
+		Series: []*pb.VoteHistorySeries{
+			{Shortcode: ":doughnut:", Counts: []int32{1, 0, 2}},
+		},
+	}, nil
 }
 
-func (c *MockVotingServiceClient) VoteTrophy(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":trophy:")
+func (c *MockVotingServiceClient) WatchResults(_ context.Context, in *pb.WatchResultsRequest, _ ...grpc.CallOption) (pb.VotingService_WatchResultsClient, error) {
+	c.lastPollID = in.PollId
+	return &MockWatchResultsClient{updates: c.updatesToStream}, nil
 }
 
-func (c *MockVotingServiceClient) VoteIphone(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":iphone:")
+func (c *MockVotingServiceClient) VoteRanked(_ context.Context, in *pb.VoteRankedRequest, _ ...grpc.CallOption) (*pb.VoteRankedResponse, error) {
+	for _, choice := range in.Ballot.Shortcodes {
+		if choice == ":doughnut:" {
+			return nil, status.Errorf(codes.InvalidArgument, "[%s] is not on the ballot", choice)
+		}
+	}
+	c.lastPollID = in.PollId
+	c.lastRanking = in.Ballot.Shortcodes
+	return &pb.VoteRankedResponse{}, nil
 }
 
-func (c *MockVotingServiceClient) VotePager(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":pager:")
+func (c *MockVotingServiceClient) VoteApproval(_ context.Context, in *pb.VoteApprovalRequest, _ ...grpc.CallOption) (*pb.VoteApprovalResponse, error) {
+	if len(in.Shortcodes) > 2 {
+		return nil, status.Errorf(codes.InvalidArgument, "[%d] choices picked, up to [2] allowed", len(in.Shortcodes))
+	}
+	c.lastPollID = in.PollId
+	c.lastApproval = in.Shortcodes
+	return &pb.VoteApprovalResponse{}, nil
 }
 
-func (c *MockVotingServiceClient) VoteFax(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":fax:")
+func (c *MockVotingServiceClient) RankedResults(_ context.Context, in *pb.RankedResultsRequest, _ ...grpc.CallOption) (*pb.RankedResultsResponse, error) {
+	c.lastPollID = in.PollId
+	return &pb.RankedResultsResponse{
+		Ballots: 5,
+		Winner:  ":taco:",
+		Rounds: []*pb.RunoffRound{
+			{
+				Tallies:    []*pb.VotingResult{{Shortcode: ":pizza:", Votes: 2}, {Shortcode: ":taco:", Votes: 2}, {Shortcode: ":joy:", Votes: 1}},
+				Eliminated: []string{":joy:"},
+			},
+			{Tallies: []*pb.VotingResult{{Shortcode: ":taco:", Votes: 3}, {Shortcode: ":pizza:", Votes: 2}}},
+		},
+	}, nil
 }
 
-func (c *MockVotingServiceClient) VoteBulb(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":bulb:")
+func (c *MockVotingServiceClient) CreateTournament(_ context.Context, in *pb.CreateTournamentRequest, _ ...grpc.CallOption) (*pb.CreateTournamentResponse, error) {
+	c.tournament = &pb.Tournament{
+		Id:              in.Id,
+		Seeds:           in.Entrants,
+		MatchupDuration: in.MatchupDuration,
+		Rounds: []*pb.BracketRound{
+			{Matchups: []*pb.Matchup{{Round: 1, PollId: in.Id + "-r1-m1", State: "open", Entrants: []*pb.MatchupEntrant{
+				{Shortcode: in.Entrants[0], Seed: 1}, {Shortcode: in.Entrants[1], Seed: 2, Votes: 3},
+			}}}},
+		},
+	}
+	return &pb.CreateTournamentResponse{Tournament: c.tournament}, nil
 }
 
-func (c *MockVotingServiceClient) VoteMoneyWithWings(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":money_with_wings:")
+func (c *MockVotingServiceClient) GetTournament(_ context.Context, in *pb.GetTournamentRequest, _ ...grpc.CallOption) (*pb.GetTournamentResponse, error) {
+	if c.tournament == nil || in.Id != c.tournament.Id {
+		return nil, status.Errorf(codes.NotFound, "tournament not found: [%s]", in.Id)
+	}
+	return &pb.GetTournamentResponse{Tournament: c.tournament}, nil
 }
 
-func (c *MockVotingServiceClient) VoteCrystalBall(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":crystal_ball:")
+func (c *MockVotingServiceClient) CurrentMatchup(_ context.Context, in *pb.CurrentMatchupRequest, _ ...grpc.CallOption) (*pb.CurrentMatchupResponse, error) {
+	return &pb.CurrentMatchupResponse{Matchup: c.tournament.Rounds[0].Matchups[0]}, nil
 }
 
-func (c *MockVotingServiceClient) VoteUnderage(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":underage:")
+func (c *MockVotingServiceClient) AdvanceTournament(_ context.Context, in *pb.AdvanceTournamentRequest, _ ...grpc.CallOption) (*pb.AdvanceTournamentResponse, error) {
+	return nil, status.Errorf(codes.FailedPrecondition, "tournament is over: [%s]", in.Id)
 }
 
-func (c *MockVotingServiceClient) VoteInterrobang(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":interrobang:")
+func (c *MockVotingServiceClient) NextPair(_ context.Context, _ *pb.NextPairRequest, _ ...grpc.CallOption) (*pb.NextPairResponse, error) {
+	return &pb.NextPairResponse{First: ":joy:", Second: ":ghost:"}, nil
 }
 
-func (c *MockVotingServiceClient) Vote100(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":100:")
+func (c *MockVotingServiceClient) ComparePair(_ context.Context, in *pb.ComparePairRequest, _ ...grpc.CallOption) (*pb.ComparePairResponse, error) {
+	if in.Winner == in.Loser {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid comparison: [%s] can't be compared with itself", in.Winner)
+	}
+	c.lastComparison = in
+	return &pb.ComparePairResponse{
+		Winner: &pb.Rating{Shortcode: in.Winner, Rating: 1662.3, Deviation: 290.3, Comparisons: 1},
+		Loser:  &pb.Rating{Shortcode: in.Loser, Rating: 1337.7, Deviation: 290.3, Comparisons: 1},
+	}, nil
 }
 
-func (c *MockVotingServiceClient) VoteCheckeredFlag(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":checkered_flag:")
+func (c *MockVotingServiceClient) Ratings(_ context.Context, _ *pb.RatingsRequest, _ ...grpc.CallOption) (*pb.RatingsResponse, error) {
+	return &pb.RatingsResponse{Ratings: []*pb.Rating{
+		{Shortcode: ":ghost:", Rating: 1662.3, Deviation: 290.3, Comparisons: 1},
+		{Shortcode: ":joy:", Rating: 1337.7, Deviation: 290.3, Comparisons: 1},
+	}}, nil
 }
 
-func (c *MockVotingServiceClient) VoteCrossedSwords(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":crossed_swords:")
+func (c *MockVotingServiceClient) CreatePoll(_ context.Context, in *pb.CreatePollRequest, _ ...grpc.CallOption) (*pb.CreatePollResponse, error) {
+	poll := &pb.Poll{Id: in.Id, Title: in.Title, Ballot: in.Ballot, State: "open", StartsAt: in.StartsAt, EndsAt: in.EndsAt}
+	c.polls = append(c.polls, poll)
+	return &pb.CreatePollResponse{Poll: poll}, nil
 }
 
-func (c *MockVotingServiceClient) VoteFloppyDisk(_ context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
-	return c.vote(":floppy_disk:")
+func (c *MockVotingServiceClient) ListPolls(_ context.Context, _ *pb.ListPollsRequest, _ ...grpc.CallOption) (*pb.ListPollsResponse, error) {
+	return &pb.ListPollsResponse{Polls: c.polls}, nil
 }
 
 func (c *MockVotingServiceClient) Results(ctx context.Context, in *pb.ResultsRequest, opts ...grpc.CallOption) (*pb.ResultsResponse, error) {
+	c.lastPollID = in.PollId
+	if in.PollId == "unknown" {
+		return nil, status.Error(codes.NotFound, "poll not found")
+	}
 	return &pb.ResultsResponse{
 		Results: c.resultToReturn,
+		Ballots: c.ballotsToReturn,
+		Lead:    c.leadToReturn,
 	}, nil
 }
 
@@ -546,6 +373,133 @@ func TestVoteHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("identifies voters when voter identity is on", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":100:", Unicode: "\U0001f4af"}}}
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+			voterIdentity:       true,
+		}
+		handler := http.HandlerFunc(webApp.voteEmojiHandler)
+
+		req, _ := http.NewRequest("POST", "/api/vote?choice=:100:", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != voterIDCookie {
+			t.Fatalf("Expected a voter ID cookie to be assigned, got [%v]", cookies)
+		}
+		if votingServiceClient.lastVoterID != cookies[0].Value {
+			t.Fatalf("Expected the vote to be from [%s], got [%s]", cookies[0].Value, votingServiceClient.lastVoterID)
+		}
+
+		req, _ = http.NewRequest("POST", "/api/vote?choice=:100:", nil)
+		req.AddCookie(cookies[0])
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if len(rr.Result().Cookies()) != 0 || votingServiceClient.lastVoterID != cookies[0].Value {
+			t.Fatalf("Expected the voter ID from the cookie to be reused, got [%s]", votingServiceClient.lastVoterID)
+		}
+
+		req, _ = http.NewRequest("POST", "/api/vote?choice=:100:", nil)
+		req.Header.Set("X-Voter-Id", "bot-7")
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if votingServiceClient.lastVoterID != "bot-7" {
+			t.Fatalf("Expected the voter ID from the header to be used, got [%s]", votingServiceClient.lastVoterID)
+		}
+	})
+
+	t.Run("passes on the voter class of a known API key or a trusted header only", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":100:", Unicode: "\U0001f4af"}}}
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+			voterClasses:        &voterClasses{header: "X-Proxy-Voter-Class", keys: map[string]string{"s3cr3t": "partner"}},
+		}
+
+		for _, test := range []struct {
+			headers map[string]string
+			class   string
+		}{
+			{map[string]string{"X-Voter-Class": "employee"}, ""},
+			{map[string]string{"X-Api-Key": "s3cr3t", "X-Voter-Class": "employee"}, "partner"},
+			{map[string]string{"X-Api-Key": "s3cr3t-not"}, ""},
+			{map[string]string{"X-Api-Key": "s3cr3t", "X-Proxy-Voter-Class": "employee"}, "employee"},
+		} {
+			req, _ := http.NewRequest("POST", "/api/vote?choice=:100:", nil)
+			for header, value := range test.headers {
+				req.Header.Set(header, value)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(webApp.voteEmojiHandler).ServeHTTP(rr, req)
+
+			md := votingServiceClient.lastMetadata
+			if len(md.Get("api-key")) != 0 {
+				t.Fatalf("Expected the API key not to be passed on, got [%v]", md)
+			}
+			class := md.Get("voter-class")
+			if (test.class == "" && len(class) != 0) || (test.class != "" && (len(class) != 1 || class[0] != test.class)) {
+				t.Fatalf("Expected voter class [%s] for [%v], got [%v]", test.class, test.headers, class)
+			}
+		}
+	})
+
+	t.Run("loads the voter classes of API keys", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "voter-classes")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "keys.json")
+
+		ioutil.WriteFile(path, []byte(`{"s3cr3t": "partner"}`), 0644)
+		classes, err := loadVoterClasses("", path)
+		if err != nil || classes.keys["s3cr3t"] != "partner" {
+			t.Fatalf("Expected the class of [s3cr3t] to be [partner], got [%v] [%v]", classes, err)
+		}
+		ioutil.WriteFile(path, []byte(`{"s3cr3t": ""}`), 0644)
+		if _, err := loadVoterClasses("", path); err == nil {
+			t.Fatalf("Expected an API key without a class to be rejected")
+		}
+	})
+
+	t.Run("passes on an idempotency key", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":100:", Unicode: "\U0001f4af"}}}
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
+		handler := http.HandlerFunc(webApp.voteEmojiHandler)
+
+		req, _ := http.NewRequest("POST", "/api/vote?choice=:100:", nil)
+		req.Header.Set("Idempotency-Key", "retry-me-1")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if key := votingServiceClient.lastMetadata.Get("idempotency-key"); len(key) != 1 || key[0] != "retry-me-1" {
+			t.Fatalf("Expected the caller's idempotency key to be passed on, got [%v]", key)
+		}
+		if key := rr.Header().Get("Idempotency-Key"); key != "retry-me-1" {
+			t.Fatalf("Expected the idempotency key to be returned, got [%s]", key)
+		}
+
+		req, _ = http.NewRequest("POST", "/api/vote?choice=:100:", nil)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		generated := rr.Header().Get("Idempotency-Key")
+		if key := votingServiceClient.lastMetadata.Get("idempotency-key"); generated == "" || len(key) != 1 || key[0] != generated {
+			t.Fatalf("Expected a generated idempotency key to be passed on and returned, got [%v] and [%s]", key, generated)
+		}
+	})
+
 	t.Run("rejects request if doesnt contain choice parameter", func(t *testing.T) {
 		webApp := &WebApp{}
 
@@ -601,29 +555,307 @@ func TestVoteHandler(t *testing.T) {
 				status, http.StatusBadRequest)
 		}
 	})
-}
 
-func TestLeaderboard(t *testing.T) {
+	t.Run("returns an error if the voting service fails", func(t *testing.T) {
+		emojiIWantToVoteFor := &pb.Emoji{Shortcode: ":doughnut:", Unicode: "\U0001f369"}
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{emojiIWantToVoteFor}}
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
 
-	t.Run("registers the vote if everything is valid", func(t *testing.T) {
-		expectedList := []*pb.Emoji{
-			{Shortcode: ":100:", Unicode: "\U0001f4af"},
-			{Shortcode: ":checkered_flag:", Unicode: "\U0001f3c1"},
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(webApp.voteEmojiHandler)
+
+		req, err := http.NewRequest("POST", "/api/vote", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		form := url.Values{}
+		form.Add("choice", emojiIWantToVoteFor.Shortcode)
+		req.PostForm = form
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusInternalServerError {
+			t.Fatalf("handler returned wrong status code: got %v want %v",
+				status, http.StatusInternalServerError)
+		}
+	})
+}
+
+func TestRetractHandlers(t *testing.T) {
+	t.Run("retracts a vote with DELETE", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{lastChoiceShortcode: ":100:"}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+			voterIdentity:       true,
+		}
+		handler := http.HandlerFunc(webApp.voteEmojiHandler)
+
+		req, _ := http.NewRequest("DELETE", "/api/vote?choice=:100:", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		req, _ = http.NewRequest("DELETE", "/api/vote?choice=:100:", nil)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+	})
+
+	t.Run("refuses to retract anonymous votes", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{lastChoiceShortcode: ":100:"}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+		}
+		handler := http.HandlerFunc(webApp.voteEmojiHandler)
+
+		req, _ := http.NewRequest("DELETE", "/api/vote?choice=:100:", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusForbidden {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+		}
+		if votingServiceClient.lastRetract != nil {
+			t.Fatalf("Expected no vote to be retracted, got [%v]", votingServiceClient.lastRetract)
+		}
+	})
+
+	t.Run("retracts a ballot with repeated choices", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+			voterIdentity:       true,
+		}
+		handler := http.HandlerFunc(webApp.voteEmojiHandler)
+
+		req, _ := http.NewRequest("DELETE", "/api/vote?choice=:joy:&choice=:ghost:", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if in := votingServiceClient.lastRetract; len(in.Shortcodes) != 2 || in.Shortcodes[0] != ":joy:" || in.Shortcodes[1] != ":ghost:" {
+			t.Fatalf("Expected the ballot [:joy:, :ghost:] to be retracted, got [%v]", in)
+		}
+	})
+
+	t.Run("retracts votes in bulk", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+			adminToken:          "secret",
+		}
+		handler := http.HandlerFunc(webApp.retractVotesHandler)
+
+		req, _ := http.NewRequest("POST", "/api/admin/retract", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+
+		req, _ = http.NewRequest("POST", "/api/admin/retract?poll=lunch&since=2020-01-01T12:00:00Z", nil)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if strings.TrimSpace(rr.Body.String()) != `{"retracted":3}` {
+			t.Fatalf("Expected the number of retracted votes, got [%s]", rr.Body.String())
+		}
+		if in := votingServiceClient.lastRetractVotes; in.PollId != "lunch" || in.Since.AsTime().Hour() != 12 {
+			t.Fatalf("Expected votes since noon to be retracted from [lunch], got [%v]", in)
+		}
+		if token := votingServiceClient.lastRetractVotesMD.Get(admin.Key); len(token) != 1 || token[0] != "Bearer secret" {
+			t.Fatalf("Expected the admin token to be passed on, got [%v]", token)
+		}
+	})
+
+	t.Run("retracts votes in bulk only for admins", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+		}
+		handler := http.HandlerFunc(webApp.admin(webApp.retractVotesHandler))
+		retract := func(authorization string) int {
+			req, _ := http.NewRequest("POST", "/api/admin/retract?voter_id=alice", nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		if status := retract("Bearer secret"); status != http.StatusForbidden {
+			t.Fatalf("Expected the admin API to be off without a token, got [%d]", status)
+		}
+		webApp.adminToken = "secret"
+		for _, authorization := range []string{"", "secret", "Bearer guess"} {
+			if status := retract(authorization); status != http.StatusUnauthorized {
+				t.Fatalf("Expected [%s] to be turned away, got [%d]", authorization, status)
+			}
+		}
+		if votingServiceClient.lastRetractVotes != nil {
+			t.Fatalf("Expected no votes to be retracted, got [%v]", votingServiceClient.lastRetractVotes)
+		}
+		if status := retract("Bearer secret"); status != http.StatusOK {
+			t.Fatalf("Expected the admin to retract votes, got [%d]", status)
+		}
+	})
+}
+
+func TestHistoryHandler(t *testing.T) {
+	t.Run("serves the vote history", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":doughnut:", Unicode: "\U0001f369"}}}
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, _ := http.NewRequest("GET", "/api/history?shortcode=:doughnut:&step=5m", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.historyHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if in := votingServiceClient.lastVoteHistory; len(in.Shortcodes) != 1 || in.Step.AsDuration() != 5*time.Minute {
+			t.Fatalf("Expected 5 minute steps for [:doughnut:], got [%v]", in)
+		}
+
+		var history struct {
+			From        string `json:"from"`
+			StepSeconds int    `json:"step_seconds"`
+			Series      []struct {
+				Shortcode string `json:"shortcode"`
+				Unicode   string `json:"unicode"`
+				Counts    []int  `json:"counts"`
+			} `json:"series"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &history); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if history.From != "2020-01-01T12:00:00Z" || history.StepSeconds != 300 {
+			t.Fatalf("Expected 5 minute steps from noon, got [%v]", history)
+		}
+		if len(history.Series) != 1 || history.Series[0].Unicode != "\U0001f369" || len(history.Series[0].Counts) != 3 {
+			t.Fatalf("Expected the :doughnut: series, got [%v]", history.Series)
+		}
+	})
+
+	t.Run("rejects invalid steps", func(t *testing.T) {
+		webApp := &WebApp{}
+
+		req, _ := http.NewRequest("GET", "/api/history?step=often", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.historyHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestTrendingHandler(t *testing.T) {
+	t.Run("serves the trending leaderboard", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":doughnut:", Unicode: "\U0001f369"}}}
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, _ := http.NewRequest("GET", "/api/trending?poll=lunch&half_life=5m", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.trendingHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if in := votingServiceClient.lastTrending; in.PollId != "lunch" || in.HalfLife.AsDuration() != 5*time.Minute || in.Window != nil {
+			t.Fatalf("Expected a half-life of 5 minutes in [lunch], got [%v]", in)
+		}
+
+		var trending struct {
+			HalfLifeSeconds float64 `json:"half_life_seconds"`
+			WindowSeconds   float64 `json:"window_seconds"`
+			Results         []struct {
+				Unicode      string  `json:"unicode"`
+				Score        float64 `json:"score"`
+				Votes        int     `json:"votes"`
+				PreviousRank int     `json:"previous_rank"`
+				Movement     string  `json:"movement"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &trending); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if trending.HalfLifeSeconds != 300 || trending.WindowSeconds != 300 || len(trending.Results) != 1 {
+			t.Fatalf("Expected a half-life and window of 5 minutes, got [%v]", trending)
+		}
+		if r := trending.Results[0]; r.Unicode != "\U0001f369" || r.Score != 3.5 || r.Votes != 40 || r.PreviousRank != 2 || r.Movement != "rising" {
+			t.Fatalf("Expected [:doughnut:] rising, got [%v]", r)
+		}
+	})
+
+	t.Run("rejects invalid half-lives", func(t *testing.T) {
+		webApp := &WebApp{}
+
+		req, _ := http.NewRequest("GET", "/api/trending?half_life=-5m", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.trendingHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestLeaderboard(t *testing.T) {
+
+	t.Run("registers the vote if everything is valid", func(t *testing.T) {
+		expectedList := []*pb.Emoji{
+			{Shortcode: ":100:", Unicode: "\U0001f4af"},
+			{Shortcode: ":checkered_flag:", Unicode: "\U0001f3c1"},
 			{Shortcode: ":crossed_swords:", Unicode: "\u2694\ufe0f"},
 			{Shortcode: ":floppy_disk:", Unicode: "\U0001f4be"},
 		}
 
 		expectedResults := []*pb.VotingResult{
 			{
-				Votes:     10,
-				Shortcode: expectedList[0].Shortcode,
+				Votes:         10,
+				WeightedVotes: 12.5,
+				Rank:          1,
+				DenseRank:     1,
+				Shortcode:     expectedList[0].Shortcode,
 			},
 			{
 				Votes:     5,
+				Rank:      2,
+				DenseRank: 2,
 				Shortcode: expectedList[1].Shortcode,
 			},
 			{
-				Votes:     1,
+				Votes:     5,
+				Rank:      2,
+				DenseRank: 2,
 				Shortcode: expectedList[2].Shortcode,
 			},
 		}
@@ -672,7 +904,776 @@ func TestLeaderboard(t *testing.T) {
 				t.Fatalf("Expected unicode for item [%d] to be [%s] but it was [%s]", i, expectedUnicode, actualUnicode)
 			}
 		}
+
+		if weighted := responseList[0]["weighted"]; weighted != "12.5" {
+			t.Fatalf("Expected the weighted votes of the first item to be [12.5] but they were [%s]", weighted)
+		}
+		if rank, denseRank := responseList[2]["rank"], responseList[2]["dense_rank"]; rank != "2" || denseRank != "2" {
+			t.Fatalf("Expected the tied last item to rank [2] and [2] but it ranked [%s] and [%s]", rank, denseRank)
+		}
+	})
+
+	t.Run("serves typed numbers, shares and the lead in v2", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":doughnut:", Unicode: "\U0001f369"}}}
+		votingServiceClient := &MockVotingServiceClient{
+			resultToReturn:  []*pb.VotingResult{{Shortcode: ":doughnut:", Votes: 60, Rank: 1, DenseRank: 1, Share: 0.6, ShareLow: 0.5, ShareHigh: 0.69}},
+			ballotsToReturn: 100,
+			leadToReturn:    &pb.Lead{Leader: ":doughnut:", Margin: 0.6, Significant: true},
+		}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, _ := http.NewRequest("GET", "/api/v2/leaderboard?poll=lunch", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.leaderboardV2Handler).ServeHTTP(rr, req)
+
+		var leaderboard struct {
+			Ballots int `json:"ballots"`
+			Lead    struct {
+				Leader      string `json:"leader"`
+				Significant bool   `json:"significant"`
+			} `json:"lead"`
+			Results []struct {
+				Votes    int     `json:"votes"`
+				Rank     int     `json:"rank"`
+				Share    float64 `json:"share"`
+				ShareLow float64 `json:"share_low"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &leaderboard); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if votingServiceClient.lastPollID != "lunch" || leaderboard.Ballots != 100 || !leaderboard.Lead.Significant || leaderboard.Lead.Leader != ":doughnut:" {
+			t.Fatalf("Expected a significant lead for [:doughnut:] in [lunch], got [%v]", leaderboard)
+		}
+		if r := leaderboard.Results[0]; r.Votes != 60 || r.Rank != 1 || r.Share != 0.6 || r.ShareLow != 0.5 {
+			t.Fatalf("Expected [60] votes and a share of [0.6], got [%v]", r)
+		}
+
+		req, _ = http.NewRequest("GET", "/api/v2/leaderboard?poll=unknown", nil)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webApp.leaderboardV2Handler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("Expected an unknown poll to be not found, got [%d]", rr.Code)
+		}
+	})
+}
+
+func TestPollsHandler(t *testing.T) {
+	t.Run("creates and lists polls", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+			adminToken:          "secret",
+		}
+
+		form := url.Values{}
+		form.Add("id", "lunch")
+		form.Add("title", "Lunch")
+		form.Add("ballot", ":pizza:")
+		form.Add("ballot", ":taco:")
+
+		req, err := http.NewRequest("POST", "/api/polls", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer secret")
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+
+		req, _ = http.NewRequest("GET", "/api/polls", nil)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		var polls []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &polls); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+
+		if len(polls) != 1 || polls[0]["id"] != "lunch" || len(polls[0]["ballot"].([]interface{})) != 2 {
+			t.Fatalf("Expected the lunch poll to be listed, got [%v]", polls)
+		}
+	})
+
+	t.Run("schedules polls", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+			adminToken:          "secret",
+		}
+
+		form := url.Values{}
+		form.Add("id", "lunch")
+		form.Add("ends_at", "2030-01-01T13:00:00Z")
+
+		req, _ := http.NewRequest("POST", "/api/polls", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusUnauthorized || len(votingServiceClient.polls) != 0 {
+			t.Fatalf("Expected a schedule without the admin token to be turned away, got [%d]", status)
+		}
+
+		req, _ = http.NewRequest("POST", "/api/polls", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer secret")
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+		var poll map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &poll); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if poll["state"] != "open" || poll["starts_at"] != nil || poll["ends_at"] != "2030-01-01T13:00:00Z" {
+			t.Fatalf("Expected an open poll ending at [2030-01-01T13:00:00Z], got [%v]", poll)
+		}
+
+		req, _ = http.NewRequest("POST", "/api/polls/lunch/close", nil)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusUnauthorized {
+			t.Fatalf("Expected closing ahead of schedule without the admin token to be turned away, got [%d]", status)
+		}
+
+		form.Set("ends_at", "tomorrow")
+		req, _ = http.NewRequest("POST", "/api/polls", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer secret")
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("manages polls and tournaments only for admins", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+		}
+		serve := func(method, url, authorization string) int {
+			req, _ := http.NewRequest(method, url, http.NoBody)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rr := httptest.NewRecorder()
+			if strings.HasPrefix(url, "/api/tournaments") {
+				http.HandlerFunc(webApp.tournamentsHandler).ServeHTTP(rr, req)
+			} else {
+				http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+			}
+			return rr.Code
+		}
+		requests := []string{
+			"/api/polls?id=lunch",
+			"/api/polls/lunch/open",
+			"/api/polls/lunch/close",
+			"/api/polls/lunch/archive",
+			"/api/tournaments?id=cup",
+			"/api/tournaments/cup/advance",
+		}
+
+		for _, url := range requests {
+			if status := serve("POST", url, "Bearer secret"); status != http.StatusForbidden {
+				t.Fatalf("Expected [POST %s] to be off without an admin token, got [%d]", url, status)
+			}
+		}
+		webApp.adminToken = "secret"
+		for _, url := range requests {
+			for _, authorization := range []string{"", "Bearer guess"} {
+				if status := serve("POST", url, authorization); status != http.StatusUnauthorized {
+					t.Fatalf("Expected [POST %s] with [%s] to be turned away, got [%d]", url, authorization, status)
+				}
+			}
+		}
+		if len(votingServiceClient.polls) != 0 || votingServiceClient.tournament != nil {
+			t.Fatalf("Expected nothing to reach the voting service, got [%v] [%v]", votingServiceClient.polls, votingServiceClient.tournament)
+		}
+		if status := serve("GET", "/api/polls", ""); status != http.StatusOK {
+			t.Fatalf("Expected anyone to list polls, got [%d]", status)
+		}
+	})
+
+	t.Run("votes in the poll from the path", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":taco:", Unicode: "\U0001f32e"}}}
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, err := http.NewRequest("POST", "/api/polls/lunch/vote?choice=:taco:", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if votingServiceClient.lastPollID != "lunch" || votingServiceClient.lastChoiceShortcode != ":taco:" {
+			t.Fatalf("Expected a vote for [:taco:] in [lunch], got [%s] in [%s]",
+				votingServiceClient.lastChoiceShortcode, votingServiceClient.lastPollID)
+		}
+	})
+
+	t.Run("casts ranked ballots", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+		}
+
+		form := url.Values{}
+		form.Add("choice", ":taco:")
+		form.Add("choice", ":pizza:")
+		req, _ := http.NewRequest("POST", "/api/polls/lunch/ballot", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if ranking := votingServiceClient.lastRanking; votingServiceClient.lastPollID != "lunch" || len(ranking) != 2 || ranking[0] != ":taco:" {
+			t.Fatalf("Expected [:taco:] over [:pizza:] in [lunch], got [%v] in [%s]", ranking, votingServiceClient.lastPollID)
+		}
+
+		for _, query := range []string{"", "?choice=:doughnut:"} {
+			req, _ = http.NewRequest("POST", "/api/polls/lunch/ballot"+query, http.NoBody)
+			rr = httptest.NewRecorder()
+			http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Fatalf("handler returned wrong status code for [%s]: got %v want %v", query, status, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("casts approval ballots", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, _ := http.NewRequest("POST", "/api/polls/lunch/approval?choice=:taco:&choice=:pizza:", http.NoBody)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if choices := votingServiceClient.lastApproval; votingServiceClient.lastPollID != "lunch" || len(choices) != 2 {
+			t.Fatalf("Expected [:taco:] and [:pizza:] in [lunch], got [%v] in [%s]", choices, votingServiceClient.lastPollID)
+		}
+
+		req, _ = http.NewRequest("POST", "/api/polls/lunch/approval?choice=:taco:&choice=:pizza:&choice=:joy:", http.NoBody)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("serves results with the number of ballots", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":taco:", Unicode: "\U0001f32e"}}}
+		votingServiceClient := &MockVotingServiceClient{
+			resultToReturn:  []*pb.VotingResult{{Shortcode: ":taco:", Votes: 3}, {Shortcode: ":pizza:", Votes: 2}},
+			ballotsToReturn: 4,
+		}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, _ := http.NewRequest("GET", "/api/polls/lunch/results", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		var results struct {
+			Ballots int `json:"ballots"`
+			Results []struct {
+				Shortcode string `json:"shortcode"`
+				Unicode   string `json:"unicode"`
+				Votes     int    `json:"votes"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if results.Ballots != 4 || len(results.Results) != 2 || results.Results[0].Unicode != "\U0001f32e" || results.Results[0].Votes != 3 {
+			t.Fatalf("Expected [:taco:] on [3] of [4] ballots, got [%v]", results)
+		}
+	})
+
+	t.Run("serves the runoff of the poll from the path", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":taco:", Unicode: "\U0001f32e"}}}
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, _ := http.NewRequest("GET", "/api/polls/lunch/runoff", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var runoff struct {
+			Ballots int    `json:"ballots"`
+			Winner  string `json:"winner"`
+			Rounds  []struct {
+				Tallies []struct {
+					Shortcode string `json:"shortcode"`
+					Unicode   string `json:"unicode"`
+					Votes     int    `json:"votes"`
+				} `json:"tallies"`
+				Eliminated []string `json:"eliminated"`
+			} `json:"rounds"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &runoff); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if runoff.Winner != ":taco:" || runoff.Ballots != 5 || len(runoff.Rounds) != 2 {
+			t.Fatalf("Expected [:taco:] to win in two rounds, got [%v]", runoff)
+		}
+		if first := runoff.Rounds[1].Tallies[0]; first.Unicode != "\U0001f32e" || first.Votes != 3 {
+			t.Fatalf("Expected [:taco:] to have [3] votes in the last round, got [%v]", first)
+		}
+		if eliminated := runoff.Rounds[0].Eliminated; len(eliminated) != 1 || eliminated[0] != ":joy:" {
+			t.Fatalf("Expected [:joy:] to be eliminated first, got [%v]", eliminated)
+		}
+	})
+
+	t.Run("serves the leaderboard of the poll from the path", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, _ := http.NewRequest("GET", "/api/polls/lunch/leaderboard", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.pollsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if votingServiceClient.lastPollID != "lunch" {
+			t.Fatalf("Expected results for [lunch], got [%s]", votingServiceClient.lastPollID)
+		}
 	})
 }
 
 //TODO: test for errors
+
+func TestLeaderboardStream(t *testing.T) {
+	t.Run("relays result updates as server-sent events", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{
+			{Shortcode: ":100:", Unicode: "\U0001f4af"},
+			{Shortcode: ":floppy_disk:", Unicode: "\U0001f4be"},
+		}}
+		votingServiceClient := &MockVotingServiceClient{updatesToStream: []*pb.ResultsUpdate{
+			{Full: true, Results: []*pb.VotingResult{{Shortcode: ":100:", Votes: 10}, {Shortcode: ":floppy_disk:", Votes: 5}}},
+			{Results: []*pb.VotingResult{{Shortcode: ":floppy_disk:", Votes: 11}}},
+		}}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, _ := http.NewRequest("GET", "/api/leaderboard/stream?poll=lunch", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.leaderboardStreamHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if contentType := rr.Header().Get("Content-Type"); contentType != "text/event-stream" {
+			t.Fatalf("Expected an event stream, got [%s]", contentType)
+		}
+		if votingServiceClient.lastPollID != "lunch" {
+			t.Fatalf("Expected to watch poll [lunch], got [%s]", votingServiceClient.lastPollID)
+		}
+
+		type resultsEvent struct {
+			Full    bool                `json:"full"`
+			Results []map[string]string `json:"results"`
+		}
+		var events []resultsEvent
+		for _, line := range strings.Split(rr.Body.String(), "\n") {
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var event resultsEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("parsing event returned error %v", err)
+			}
+			events = append(events, event)
+		}
+		if len(events) != 2 || !events[0].Full || len(events[0].Results) != 2 {
+			t.Fatalf("Expected every result and then an update, got [%v]", events)
+		}
+		if update := events[1]; update.Full || len(update.Results) != 1 || update.Results[0]["unicode"] != "\U0001f4be" || update.Results[0]["votes"] != "11" {
+			t.Fatalf("Expected :floppy_disk: to have [11] votes, got [%v]", update)
+		}
+	})
+}
+
+func TestTournamentsHandler(t *testing.T) {
+	t.Run("creates tournaments and serves their bracket", func(t *testing.T) {
+		emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{
+			{Shortcode: ":joy:", Unicode: "\U0001f602"},
+			{Shortcode: ":ghost:", Unicode: "\U0001f47b"},
+		}}
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+			adminToken:          "secret",
+		}
+
+		req, _ := http.NewRequest("POST", "/api/tournaments?id=cup&entrant=:joy:&entrant=:ghost:&matchup_duration=90s", http.NoBody)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.tournamentsHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+		if d := votingServiceClient.tournament.MatchupDuration.AsDuration(); d != 90*time.Second {
+			t.Fatalf("Expected 90 second matchups, got [%v]", d)
+		}
+
+		req, _ = http.NewRequest("GET", "/api/tournaments/cup", nil)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webApp.tournamentsHandler).ServeHTTP(rr, req)
+
+		var tournament struct {
+			ID             string  `json:"id"`
+			MatchupSeconds float64 `json:"matchup_seconds"`
+			Seeds          []struct {
+				Unicode string `json:"unicode"`
+				Seed    int    `json:"seed"`
+			} `json:"seeds"`
+			Rounds [][]struct {
+				PollID   string `json:"poll_id"`
+				Entrants []struct {
+					Unicode string `json:"unicode"`
+					Votes   int    `json:"votes"`
+				} `json:"entrants"`
+			} `json:"rounds"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &tournament); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if tournament.ID != "cup" || tournament.MatchupSeconds != 90 || len(tournament.Seeds) != 2 || tournament.Seeds[1].Seed != 2 {
+			t.Fatalf("Expected the [cup] bracket, got [%v]", tournament)
+		}
+		if len(tournament.Rounds) != 1 || tournament.Rounds[0][0].PollID != "cup-r1-m1" || tournament.Rounds[0][0].Entrants[1].Unicode != "\U0001f47b" {
+			t.Fatalf("Expected a matchup between [:joy:] and [:ghost:], got [%v]", tournament.Rounds)
+		}
+
+		req, _ = http.NewRequest("GET", "/api/tournaments/cup/matchup", nil)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webApp.tournamentsHandler).ServeHTTP(rr, req)
+
+		var current struct {
+			Matchup struct {
+				PollID string `json:"poll_id"`
+				State  string `json:"state"`
+			} `json:"matchup"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &current); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if current.Matchup.PollID != "cup-r1-m1" || current.Matchup.State != "open" {
+			t.Fatalf("Expected the open first matchup, got [%v]", current)
+		}
+	})
+
+	t.Run("answers failures with matching status codes", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+			adminToken:          "secret",
+		}
+
+		requests := []struct {
+			method, url string
+			status      int
+		}{
+			{"POST", "/api/tournaments?id=cup&matchup_duration=soon", http.StatusBadRequest},
+			{"GET", "/api/tournaments/bowl", http.StatusNotFound},
+			{"POST", "/api/tournaments/bowl/advance", http.StatusConflict},
+			{"GET", "/api/tournaments/bowl/advance", http.StatusMethodNotAllowed},
+			{"GET", "/api/tournaments/bowl/seeds", http.StatusNotFound},
+		}
+		for _, request := range requests {
+			req, _ := http.NewRequest(request.method, request.url, http.NoBody)
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(webApp.tournamentsHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != request.status {
+				t.Fatalf("handler returned wrong status code for [%s %s]: got %v want %v", request.method, request.url, status, request.status)
+			}
+		}
+	})
+}
+
+func TestPairwise(t *testing.T) {
+	emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{
+		{Shortcode: ":joy:", Unicode: "\U0001f602"},
+		{Shortcode: ":ghost:", Unicode: "\U0001f47b"},
+	}}
+
+	t.Run("serves a pair and records the winner", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: votingServiceClient,
+		}
+
+		req, _ := http.NewRequest("GET", "/api/pair", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.pairHandler).ServeHTTP(rr, req)
+
+		var pair []struct {
+			Shortcode string `json:"shortcode"`
+			Unicode   string `json:"unicode"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &pair); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if len(pair) != 2 || pair[0].Shortcode != ":joy:" || pair[1].Unicode != "\U0001f47b" {
+			t.Fatalf("Expected a pair of [:joy:] and [:ghost:], got [%v]", pair)
+		}
+
+		req, _ = http.NewRequest("POST", "/api/pair?winner=:ghost:&loser=:joy:", http.NoBody)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webApp.pairHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if c := votingServiceClient.lastComparison; c.Winner != ":ghost:" || c.Loser != ":joy:" {
+			t.Fatalf("Expected [:ghost:] to win over [:joy:], got [%v]", c)
+		}
+		var compared struct {
+			Winner struct {
+				Unicode string  `json:"unicode"`
+				Rating  float64 `json:"rating"`
+			} `json:"winner"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &compared); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if compared.Winner.Unicode != "\U0001f47b" || compared.Winner.Rating != 1662.3 {
+			t.Fatalf("Expected the new rating of [:ghost:], got [%v]", compared)
+		}
+	})
+
+	t.Run("rejects invalid comparisons", func(t *testing.T) {
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: &MockVotingServiceClient{},
+		}
+
+		for _, url := range []string{"/api/pair?winner=:joy:", "/api/pair?winner=:joy:&loser=:joy:"} {
+			req, _ := http.NewRequest("POST", url, http.NoBody)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(webApp.pairHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Fatalf("handler returned wrong status code for [%s]: got %v want %v", url, status, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("serves the ratings leaderboard", func(t *testing.T) {
+		webApp := &WebApp{
+			emojiServiceClient:  emojiSvcClient,
+			votingServiceClient: &MockVotingServiceClient{},
+		}
+
+		req, _ := http.NewRequest("GET", "/api/ratings", nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.ratingsHandler).ServeHTTP(rr, req)
+
+		var ratings []struct {
+			Shortcode   string  `json:"shortcode"`
+			Unicode     string  `json:"unicode"`
+			Deviation   float64 `json:"deviation"`
+			Comparisons int     `json:"comparisons"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &ratings); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if len(ratings) != 2 || ratings[0].Shortcode != ":ghost:" || ratings[0].Unicode != "\U0001f47b" || ratings[0].Comparisons != 1 {
+			t.Fatalf("Expected [:ghost:] to lead the ratings, got [%v]", ratings)
+		}
+	})
+}
+
+func TestVoteBatchHandler(t *testing.T) {
+	t.Run("reports on each vote", func(t *testing.T) {
+		votingServiceClient := &MockVotingServiceClient{}
+		webApp := &WebApp{
+			votingServiceClient: votingServiceClient,
+		}
+
+		body := `[{"choice": ":joy:"}, {"choice": ":joy:", "poll": "nope"}, {"choice": ":doughnut:"}, {"choice": ":ghost:", "poll": "lunch"}]`
+		req, _ := http.NewRequest("POST", "/api/votes:batch", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webApp.voteBatchHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var response struct {
+			Accepted int `json:"accepted"`
+			Results  []struct {
+				Index  int    `json:"index"`
+				Status int    `json:"status"`
+				Error  string `json:"error"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		if response.Accepted != 2 || len(response.Results) != 4 {
+			t.Fatalf("Expected [2] of [4] votes accepted, got [%v]", response)
+		}
+		expected := []int{http.StatusOK, http.StatusNotFound, http.StatusInternalServerError, http.StatusOK}
+		for i, r := range response.Results {
+			if r.Index != i || r.Status != expected[i] || (r.Status == http.StatusOK) != (r.Error == "") {
+				t.Fatalf("Expected vote [%d] to end with [%d], got [%v]", i, expected[i], r)
+			}
+		}
+		if len(votingServiceClient.lastMetadata.Get("idempotency-key")) != 1 {
+			t.Fatalf("Expected the batch to carry an idempotency key, got [%v]", votingServiceClient.lastMetadata)
+		}
+	})
+
+	t.Run("rejects invalid batches", func(t *testing.T) {
+		webApp := &WebApp{
+			votingServiceClient: &MockVotingServiceClient{},
+		}
+
+		tooMany := "[" + strings.Repeat(`{"choice": ":joy:"},`, maxBatchVotes) + `{"choice": ":joy:"}]`
+		requests := []struct {
+			method, body string
+			status       int
+		}{
+			{"POST", `{"choice": ":joy:"}`, http.StatusBadRequest},
+			{"POST", `[]`, http.StatusBadRequest},
+			{"POST", tooMany, http.StatusBadRequest},
+			{"GET", ``, http.StatusMethodNotAllowed},
+		}
+		for _, request := range requests {
+			req, _ := http.NewRequest(request.method, "/api/votes:batch", strings.NewReader(request.body))
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(webApp.voteBatchHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != request.status {
+				t.Fatalf("handler returned wrong status code for [%s]: got %v want %v", request.method, status, request.status)
+			}
+		}
+	})
+}
+
+// failingVotingServiceClient fails every vote with err.
+type failingVotingServiceClient struct {
+	pb.VotingServiceClient
+	err          error
+	lastMetadata metadata.MD
+}
+
+func (c *failingVotingServiceClient) Vote(ctx context.Context, _ *pb.VoteRequest, _ ...grpc.CallOption) (*pb.VoteResponse, error) {
+	c.lastMetadata, _ = metadata.FromOutgoingContext(ctx)
+	return nil, c.err
+}
+
+func TestErrors(t *testing.T) {
+	emojiSvcClient := &MockEmojiServiceClient{emojiList: []*pb.Emoji{{Shortcode: ":joy:", Unicode: "😂"}}}
+	vote := func(votingServiceClient pb.VotingServiceClient, headers map[string]string) (*httptest.ResponseRecorder, problem) {
+		webApp := &WebApp{emojiServiceClient: emojiSvcClient, votingServiceClient: votingServiceClient}
+		req, _ := http.NewRequest("POST", "/api/vote?choice=:joy:", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rr := httptest.NewRecorder()
+		withRequestID(http.HandlerFunc(webApp.voteEmojiHandler)).ServeHTTP(rr, req)
+
+		var body problem
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("parsing response returned error %v", err)
+		}
+		return rr, body
+	}
+
+	t.Run("answers failed calls with the matching status and a problem", func(t *testing.T) {
+		invalid, _ := status.New(codes.InvalidArgument, "choice is not on the ballot: [:joy:]").WithDetails(&errdetails.ErrorInfo{Reason: "NOT_ON_BALLOT"})
+		for _, test := range []struct {
+			err    error
+			status int
+			code   string
+		}{
+			{invalid.Err(), http.StatusBadRequest, "INVALID_ARGUMENT"},
+			{status.Error(codes.NotFound, "poll not found"), http.StatusNotFound, "NOT_FOUND"},
+			{status.Error(codes.Unavailable, "poll storage failed"), http.StatusServiceUnavailable, "UNAVAILABLE"},
+			{status.Error(codes.DeadlineExceeded, "too slow"), http.StatusGatewayTimeout, "DEADLINE_EXCEEDED"},
+			{errors.New("ERROR"), http.StatusInternalServerError, ""},
+		} {
+			rr, body := vote(&failingVotingServiceClient{err: test.err}, nil)
+			if rr.Code != test.status || body.Status != test.status || body.Code != test.code || body.Title != http.StatusText(test.status) {
+				t.Fatalf("Expected [%v] to be answered with [%d] [%s], got [%d] [%v]", test.err, test.status, test.code, rr.Code, body)
+			}
+			if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+				t.Fatalf("Expected a problem, got [%s]", ct)
+			}
+			if body.Instance != "/api/vote" || body.Detail != status.Convert(test.err).Message() {
+				t.Fatalf("Expected the path and the message in the problem, got [%v]", body)
+			}
+		}
+
+		_, body := vote(&failingVotingServiceClient{err: invalid.Err()}, nil)
+		if body.Reason != "NOT_ON_BALLOT" {
+			t.Fatalf("Expected the reason in the problem, got [%v]", body)
+		}
+	})
+
+	t.Run("tags requests with an ID passed on to the services", func(t *testing.T) {
+		votingServiceClient := &failingVotingServiceClient{err: status.Error(codes.Unavailable, "down")}
+		rr, body := vote(votingServiceClient, map[string]string{"X-Request-Id": "abc-123"})
+		if rr.Header().Get("X-Request-Id") != "abc-123" || body.RequestID != "abc-123" {
+			t.Fatalf("Expected the caller's request ID to be kept, got [%s] and [%s]", rr.Header().Get("X-Request-Id"), body.RequestID)
+		}
+		if ids := votingServiceClient.lastMetadata.Get("x-request-id"); len(ids) != 1 || ids[0] != "abc-123" {
+			t.Fatalf("Expected the request ID to be passed on, got [%v]", votingServiceClient.lastMetadata)
+		}
+
+		rr, body = vote(votingServiceClient, nil)
+		if id := rr.Header().Get("X-Request-Id"); len(id) != 32 || body.RequestID != id {
+			t.Fatalf("Expected a new request ID, got [%s] and [%s]", id, body.RequestID)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+import React from 'react';
+import _ from 'lodash';
+import 'whatwg-fetch';
+import { Link } from 'react-router-dom';
+
+export default class Bracket extends React.Component {
+  constructor(props) {
+    super(props);
+    this.state = {
+      tournament: null,
+      error: null
+    }
+  }
+
+  componentDidMount() {
+    this.loadFromServer();
+    // Matchups end on a timer, so keep the bracket and its votes current.
+    this.timer = setInterval(() => this.loadFromServer(), 1000);
+  }
+
+  componentWillUnmount() {
+    clearInterval(this.timer);
+  }
+
+  tournamentId() {
+    let params = new URLSearchParams(this.props.location.search);
+    return encodeURIComponent(params.get('id') || '');
+  }
+
+  loadFromServer() {
+    fetch(`/api/tournaments/${this.tournamentId()}`).then(r => {
+      if (!r.ok) {
+        throw new Error(`Unable to load tournament: ${r.statusText}`);
+      }
+      r.json().then(tournament => {
+        this.setState({ tournament: tournament, error: null });
+      }).catch(e => this.setState({ error: e.toString() }));
+    }).catch(e => this.setState({ error: e.toString() }));
+  }
+
+  vote(matchup, entrant) {
+    fetch(`/api/polls/${matchup.poll_id}/vote?choice=${entrant.shortcode}`, { method: 'POST' }).then(r => {
+      if (!r.ok) {
+        throw new Error("Unable to Register Vote");
+      }
+      this.loadFromServer();
+    }).catch(e => this.setState({ error: e.toString() }));
+  }
+
+  renderEntrant(matchup, entrant, i) {
+    if (!entrant.shortcode) {
+      return <div className="emoji" key={`entrant-${i}`}>?</div>;
+    }
+    let open = matchup.state === 'open';
+    let classes = ['emoji'];
+    if (open) {
+      classes.push('emoji-votable');
+    }
+    if (matchup.winner && matchup.winner !== entrant.shortcode) {
+      classes.push('matchup-loser');
+    }
+    return (
+      <div
+        className={classes.join(' ')}
+        key={`entrant-${i}`}
+        title={`#${entrant.seed}, ${entrant.votes} votes`}
+        onClick={open ? () => this.vote(matchup, entrant) : null}
+      >
+        <div>{entrant.unicode}</div>
+        { matchup.state !== 'pending' ? <div className="counter">{entrant.votes}</div> : null }
+      </div>
+    );
+  }
+
+  renderRound(round, r) {
+    return (
+      <div className="bracket-round" key={`round-${r}`}>
+        {_.map(round, (matchup, i) => (
+          <div className={`matchup matchup-${matchup.state}`} key={`matchup-${i}`}>
+            {_.map(matchup.entrants, (entrant, j) => this.renderEntrant(matchup, entrant, j))}
+          </div>
+        ))}
+      </div>
+    );
+  }
+
+  render() {
+    let tournament = this.state.tournament;
+    return (
+      <div className="background">
+        <div className="page-content container-fluid">
+          <div className="row">
+            <div className="col-md-12">
+              {!this.state.error ? null : <div className="error">{this.state.error}</div>}
+              <h1>{tournament ? tournament.title : 'EMOJI TOURNAMENT'}</h1>
+              {tournament && tournament.champion ?
+                <div className="poll-state">Champion: {_.find(tournament.seeds, { shortcode: tournament.champion }).unicode}</div> :
+                null}
+              <div className="bracket">
+                {tournament ? _.map(tournament.rounds, (round, r) => this.renderRound(round, r)) : null}
+              </div>
+              <Link to="/"><div className="btn btn-blue">Vote on your favorite</div></Link>
+            </div>
+          </div>
+        </div>
+      </div>
+    );
+  }
+}
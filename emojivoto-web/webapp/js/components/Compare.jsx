@@ -0,0 +1,87 @@
+import React from 'react';
+import _ from 'lodash';
+import 'whatwg-fetch';
+import { Link } from 'react-router-dom';
+
+export default class Compare extends React.Component {
+  constructor(props) {
+    super(props);
+    this.state = {
+      pair: [],
+      ratings: [],
+      error: null
+    }
+  }
+
+  componentDidMount() {
+    this.loadPair();
+    this.loadRatings();
+  }
+
+  loadPair() {
+    fetch('/api/pair').then(r => {
+      if (!r.ok) {
+        throw new Error(`Unable to load a pair: ${r.statusText}`);
+      }
+      r.json().then(pair => {
+        this.setState({ pair: pair, error: null });
+      }).catch(e => this.setState({ error: e.toString() }));
+    }).catch(e => this.setState({ error: e.toString() }));
+  }
+
+  loadRatings() {
+    fetch('/api/ratings').then(r => {
+      r.json().then(ratings => {
+        this.setState({ ratings: ratings });
+      }).catch(e => this.setState({ error: e.toString() }));
+    }).catch(e => this.setState({ error: e.toString() }));
+  }
+
+  pick(winner, loser) {
+    fetch(`/api/pair?winner=${winner.shortcode}&loser=${loser.shortcode}`, { method: 'POST' }).then(r => {
+      if (!r.ok) {
+        throw new Error("Unable to Register Comparison");
+      }
+      this.loadPair();
+      this.loadRatings();
+    }).catch(e => this.setState({ error: e.toString() }));
+  }
+
+  renderPair() {
+    let [first, second] = this.state.pair;
+    if (!first || !second) {
+      return null;
+    }
+    return (
+      <div className="matchup matchup-open">
+        <div className="emoji emoji-votable" onClick={() => this.pick(first, second)}>{first.unicode}</div>
+        <div className="emoji emoji-votable" onClick={() => this.pick(second, first)}>{second.unicode}</div>
+      </div>
+    );
+  }
+
+  render() {
+    return (
+      <div className="background">
+        <div className="page-content container-fluid">
+          <div className="row">
+            <div className="col-md-12">
+              {!this.state.error ? null : <div className="error">{this.state.error}</div>}
+              <h1>WHICH IS BETTER?</h1>
+              {this.renderPair()}
+              <div className="emoji-list">
+                {_.map(this.state.ratings, (rating, i) => (
+                  <div className="emoji" key={`rating-${i}`} title={`${Math.round(rating.rating)} ± ${Math.round(2 * rating.deviation)}`}>
+                    <div>{rating.unicode}</div>
+                    <div className="counter">{rating.comparisons}</div>
+                  </div>
+                ))}
+              </div>
+              <Link to="/leaderboard"><div className="btn btn-blue">View the leaderboard</div></Link>
+            </div>
+          </div>
+        </div>
+      </div>
+    );
+  }
+}
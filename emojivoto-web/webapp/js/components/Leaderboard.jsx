@@ -7,16 +7,42 @@ export default class Leaderboard extends React.Component {
   constructor(props) {
     super(props);
     this.state = {
-      leaderboard: []
+      leaderboard: [],
+      poll: null,
+      now: Date.now()
     }
   }
 
   componentDidMount() {
-    this.loadFromServer();
+    this.loadPoll();
+    this.watchLeaderboard();
+    this.timer = setInterval(() => this.tick(), 1000);
   }
 
-  loadFromServer(emoji) {
-    fetch('/api/leaderboard').then(r => {
+  componentWillUnmount() {
+    clearInterval(this.timer);
+    if (this.stream) {
+      this.stream.close();
+    }
+  }
+
+  pollId() {
+    let params = new URLSearchParams(this.props.location.search);
+    return encodeURIComponent(params.get('poll') || 'default');
+  }
+
+  loadPoll() {
+    fetch(`/api/polls/${this.pollId()}`).then(r => {
+      r.json().then(poll => {
+        this.setState({
+          poll: poll
+        });
+      }).catch(e => this.setState({ error: e }));
+    }).catch(e => this.setState({ error: e }));
+  }
+
+  loadLeaderboard() {
+    fetch(`/api/polls/${this.pollId()}/leaderboard`).then(r => {
       r.json().then(emojis => {
         this.setState({
           leaderboard: emojis
@@ -25,10 +51,82 @@ export default class Leaderboard extends React.Component {
     }).catch(e => this.setState({ error: e }));
   }
 
+  // watchLeaderboard keeps the leaderboard up to date as votes come in, or
+  // loads it once where the browser can't stream it.
+  watchLeaderboard() {
+    if (!window.EventSource) {
+      this.loadLeaderboard();
+      return;
+    }
+
+    this.stream = new EventSource(`/api/leaderboard/stream?poll=${this.pollId()}`);
+    this.stream.onmessage = e => {
+      let update = JSON.parse(e.data);
+      let votes = update.full ? {} : _.keyBy(this.state.leaderboard, 'shortcode');
+      _.each(update.results, emoji => {
+        votes[emoji.shortcode] = emoji;
+      });
+      // Updates that aren't full leave the order of tied emoji alone, and
+      // the sort is stable, so ties stay in the order the server put them.
+      let leaderboard = _.filter(_.values(votes), emoji => parseInt(emoji.votes, 10) > 0);
+      this.setState({
+        leaderboard: _.orderBy(leaderboard, emoji => parseInt(emoji.rank, 10), 'asc'),
+        error: null
+      });
+    };
+    // EventSource reconnects by itself, and the first update after that
+    // lists every result again.
+    this.stream.onerror = e => this.setState({ error: e });
+  }
+
+  tick() {
+    let poll = this.state.poll;
+    let now = Date.now();
+    this.setState({ now: now });
+
+    // Reload once the poll is due to open or close, to pick up its new state
+    // and, once closed, its final results.
+    let due = poll && ((poll.state === 'draft' && poll.starts_at) || (poll.state === 'open' && poll.ends_at));
+    if (due && Date.parse(poll.state === 'draft' ? poll.starts_at : poll.ends_at) <= now) {
+      this.setState({ poll: null });
+      this.loadPoll();
+      if (!this.stream) {
+        this.loadLeaderboard();
+      }
+    }
+  }
+
+  renderCountdown(until) {
+    let seconds = Math.max(0, Math.floor((Date.parse(until) - this.state.now) / 1000));
+    let hours = Math.floor(seconds / 3600);
+    let minutes = _.padStart(Math.floor(seconds / 60) % 60, 2, '0');
+    return `${hours}:${minutes}:${_.padStart(seconds % 60, 2, '0')}`;
+  }
+
+  renderPollState() {
+    let poll = this.state.poll;
+    if (!poll) {
+      return null;
+    }
+
+    let status;
+    switch (poll.state) {
+      case 'draft':
+        status = poll.starts_at ? `Opens in ${this.renderCountdown(poll.starts_at)}` : 'Not open yet';
+        break;
+      case 'open':
+        status = poll.ends_at ? `Closes in ${this.renderCountdown(poll.ends_at)}` : 'Open';
+        break;
+      default:
+        status = 'Final results';
+    }
+    return <div className={`poll-state poll-state-${poll.state}`}>{status}</div>;
+  }
+
   renderLeaderboard() {
     return _.map(this.state.leaderboard, (emoji, i) => {
       return (
-        <div className="emoji" key={`emoji-${i}`} title={`${emoji.votes} votes`}>
+        <div className="emoji" key={`emoji-${i}`} title={`#${emoji.rank}: ${emoji.votes} votes`}>
           <div>{emoji.unicode}</div>
           { emoji.votes > 0 ? <div className="counter">{emoji.votes}</div> : null}
         </div>
@@ -44,6 +142,7 @@ export default class Leaderboard extends React.Component {
             <div className="col-md-12">
               {!this.state.error ? null : <div className="error">Error loading leaderboard.</div>}
               <h1>EMOJI VOTE LEADERBOARD </h1>
+              {this.renderPollState()}
               <Link to="/"><div className="btn btn-blue">Vote on your favorite</div></Link>
               <div className="emoji-list">{this.renderLeaderboard()}
                 <div className="footer-text">
@@ -2,6 +2,8 @@ import React from 'react';
 import ReactDOM from 'react-dom';
 import Vote from './components/Vote.jsx';
 import Leaderboard from './components/Leaderboard.jsx';
+import Bracket from './components/Bracket.jsx';
+import Compare from './components/Compare.jsx';
 import gridStyles from './../css/grid.css';
 import styles from './../css/styles.css';
 
@@ -17,6 +19,8 @@ ReactDOM.render((
         <Switch>
           <Route exact path="/" component = { Vote } />
           <Route path="/leaderboard" component = { Leaderboard } />
+          <Route path="/tournament" component = { Bracket } />
+          <Route path="/compare" component = { Compare } />
         </Switch>
       </div>
     </div>
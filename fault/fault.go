@@ -0,0 +1,31 @@
+// Package fault holds what injecting faults takes whatever the protocol:
+// latency distributions, pattern matching and cancellable delays.
+package fault
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Match tells if value matches pattern. A pattern ending in * matches any
+// value starting with the rest of it, and other patterns match exactly.
+func Match(pattern, value string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+	return value == pattern
+}
+
+// Sleep waits for d, or until ctx is done, and then returns its error.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,437 @@
+// Package grpcfault injects faults into the gRPC calls of the emoji and
+// voting services by rule, and serves the FaultService that swaps the rules.
+package grpcfault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buoyantio/emojivoto/fault"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrInvalidRule = errors.New("invalid fault rule")
+
+// Rule injects a fault into the calls it matches, at the given probability:
+// it delays them, by Delay or by a delay drawn from Latency, then either
+// drops them, leaving the caller to time out, or fails them with Code and
+// Message. A call matches when RPC, Shortcode and every key in Metadata
+// match it, as in fault.Match, where empty patterns match anything. RPC
+// matches a method by its name, as in "VoteDoughnut", or by its full name.
+type Rule struct {
+	Name        string
+	RPC         string
+	Shortcode   string
+	Metadata    map[string]string
+	Probability float64
+	Code        codes.Code
+	Message     string
+	Delay       time.Duration
+	Latency     *fault.Latency
+	Drop        bool
+}
+
+// ruleJSON is how rules are written in files, with status codes by name,
+// as in "UNAVAILABLE", and delays as durations, as in "250ms".
+type ruleJSON struct {
+	Name        string            `json:"name,omitempty"`
+	RPC         string            `json:"rpc,omitempty"`
+	Shortcode   string            `json:"shortcode,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Probability float64           `json:"probability"`
+	Code        string            `json:"code,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Delay       string            `json:"delay,omitempty"`
+	Latency     *fault.Latency    `json:"latency,omitempty"`
+	Drop        bool              `json:"drop,omitempty"`
+}
+
+func (r Rule) MarshalJSON() ([]byte, error) {
+	rule := ruleJSON{
+		Name:        r.Name,
+		RPC:         r.RPC,
+		Shortcode:   r.Shortcode,
+		Metadata:    r.Metadata,
+		Probability: r.Probability,
+		Message:     r.Message,
+		Latency:     r.Latency,
+		Drop:        r.Drop,
+	}
+	if r.Code != codes.OK {
+		rule.Code = CodeName(r.Code)
+	}
+	if r.Delay > 0 {
+		rule.Delay = r.Delay.String()
+	}
+	return json.Marshal(rule)
+}
+
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var rule ruleJSON
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+	*r = Rule{
+		Name:        rule.Name,
+		RPC:         rule.RPC,
+		Shortcode:   rule.Shortcode,
+		Metadata:    rule.Metadata,
+		Probability: rule.Probability,
+		Message:     rule.Message,
+		Latency:     rule.Latency,
+		Drop:        rule.Drop,
+	}
+	if rule.Code != "" {
+		code, err := ParseCode(rule.Code)
+		if err != nil {
+			return err
+		}
+		r.Code = code
+	}
+	if rule.Delay != "" {
+		delay, err := time.ParseDuration(rule.Delay)
+		if err != nil {
+			return err
+		}
+		r.Delay = delay
+	}
+	return nil
+}
+
+// Check checks the rule injects something, at a probability above 0 and up
+// to 1, and lowercases its metadata keys, as gRPC sends them.
+func (r *Rule) Check() error {
+	if r.Probability <= 0 || r.Probability > 1 {
+		return fmt.Errorf("%w: [%s]: probability [%v] must be above 0 and up to 1", ErrInvalidRule, r.Name, r.Probability)
+	}
+	if r.Delay < 0 {
+		return fmt.Errorf("%w: [%s]: delay [%v] can't be negative", ErrInvalidRule, r.Name, r.Delay)
+	}
+	if r.Latency != nil {
+		if r.Delay != 0 {
+			return fmt.Errorf("%w: [%s]: a rule takes a delay or a latency, not both", ErrInvalidRule, r.Name)
+		}
+		if err := r.Latency.Check(); err != nil {
+			return fmt.Errorf("%w: [%s]: %v", ErrInvalidRule, r.Name, err)
+		}
+	}
+	if r.Code == codes.OK && r.Delay == 0 && r.Latency == nil && !r.Drop {
+		return fmt.Errorf("%w: [%s]: a rule needs a code, a delay, a latency or a drop", ErrInvalidRule, r.Name)
+	}
+	if r.Code != codes.OK && r.Drop {
+		return fmt.Errorf("%w: [%s]: a dropped call can't fail with a code", ErrInvalidRule, r.Name)
+	}
+	if len(r.Metadata) > 0 {
+		metadata := make(map[string]string, len(r.Metadata))
+		for key, pattern := range r.Metadata {
+			metadata[strings.ToLower(key)] = pattern
+		}
+		r.Metadata = metadata
+	}
+	return nil
+}
+
+// Call is what rules are matched against: the full name of the method
+// called, the shortcode voted for, if any, and the request metadata.
+type Call struct {
+	Method    string
+	Shortcode string
+	Metadata  map[string]string
+}
+
+func (r *Rule) matches(call Call) bool {
+	// Rules with a shortcode apply where a vote is cast, and the others to
+	// the whole call, so that each applies once.
+	if (r.Shortcode == "") != (call.Shortcode == "") {
+		return false
+	}
+	if r.Shortcode != "" && !fault.Match(r.Shortcode, call.Shortcode) {
+		return false
+	}
+	if r.RPC != "" && !fault.Match(r.RPC, call.Method) && !fault.Match(r.RPC, methodName(call.Method)) {
+		return false
+	}
+	for key, pattern := range r.Metadata {
+		value, ok := call.Metadata[key]
+		if !ok || !fault.Match(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// methodName is the name of a method from its full name, as in
+// "/emojivoto.v1.VotingService/VoteDoughnut".
+func methodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+// LoadRules reads rules from a JSON file, as in
+//
+//	{"rules": [{"rpc": "VoteDoughnut", "probability": 0.1, "code": "UNAVAILABLE"}]}
+func LoadRules(path string) ([]Rule, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return nil, fmt.Errorf("%w: [%s]: %v", ErrInvalidRule, path, err)
+	}
+	for i := range file.Rules {
+		if err := file.Rules[i].Check(); err != nil {
+			return nil, err
+		}
+	}
+	return file.Rules, nil
+}
+
+// DefaultMaxDrop is how long the caller of a dropped call is left waiting
+// for its reply, at most, unless its own deadline comes first.
+const DefaultMaxDrop = 30 * time.Second
+
+// Injector injects the faults of a set of rules, which can be swapped at
+// any time. Every rule that matches a call gets a go at it, in order: their
+// delays add up, and the first to drop or fail the call ends it.
+type Injector struct {
+	sync.RWMutex
+	rules   []Rule
+	maxDrop time.Duration
+	random  func() float64
+	normal  func() float64
+}
+
+// NewInjector returns an injector for the given rules, which must have been
+// checked.
+func NewInjector(rules []Rule) *Injector {
+	return &Injector{rules: rules, maxDrop: DefaultMaxDrop, random: rand.Float64, normal: rand.NormFloat64}
+}
+
+// SetMaxDrop sets how long the caller of a dropped call is left waiting, at
+// most.
+func (in *Injector) SetMaxDrop(d time.Duration) {
+	in.Lock()
+	defer in.Unlock()
+
+	in.maxDrop = d
+}
+
+// Rules returns the rules in force.
+func (in *Injector) Rules() []Rule {
+	in.RLock()
+	defer in.RUnlock()
+
+	return append([]Rule(nil), in.rules...)
+}
+
+// SetRules checks the given rules and swaps them in for the rules in force.
+func (in *Injector) SetRules(rules []Rule) error {
+	checked := make([]Rule, len(rules))
+	for i, rule := range rules {
+		if err := rule.Check(); err != nil {
+			return err
+		}
+		checked[i] = rule
+	}
+
+	in.Lock()
+	defer in.Unlock()
+
+	in.rules = checked
+	log.Printf("Injecting faults with [%d] rules", len(checked))
+	return nil
+}
+
+// Inject injects the faults of the rules that match call, and returns the
+// error to fail it with, if any. Delays end early when ctx is done, with
+// the status of its error, as when the caller's deadline expires. A drop
+// in a call set up with WithDrop is only recorded, for the call to be
+// handled and its reply dropped by Drop; elsewhere it is dropped right
+// away. Every fault is annotated on the span of ctx. A nil injector
+// injects nothing.
+func (in *Injector) Inject(ctx context.Context, call Call) error {
+	if in == nil {
+		return nil
+	}
+	in.RLock()
+	rules := in.rules
+	maxDrop := in.maxDrop
+	in.RUnlock()
+
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.matches(call) || in.random() >= rule.Probability {
+			continue
+		}
+		if delay, distribution := in.delay(rule); delay > 0 {
+			log.Printf("Delaying [%s] by [%v] for fault rule [%s]", call.Method, delay, rule.Name)
+			annotate(ctx, rule, "Injected latency",
+				trace.StringAttribute("fault.distribution", string(distribution)),
+				trace.Int64Attribute("fault.delay_ms", delay.Milliseconds()))
+			if err := Sleep(ctx, delay); err != nil {
+				annotate(ctx, rule, "Injected latency cut short by the caller",
+					trace.StringAttribute("fault.code", CodeName(status.Code(err))))
+				return err
+			}
+		}
+		if rule.Drop {
+			log.Printf("Dropping the reply to [%s] for fault rule [%s]", call.Method, rule.Name)
+			annotate(ctx, rule, "Dropped the reply")
+			if dropped, ok := ctx.Value(dropKey{}).(*Dropped); ok {
+				dropped.set(rule.Name)
+				return nil
+			}
+			return hold(ctx, rule.Name, maxDrop)
+		}
+		if rule.Code != codes.OK {
+			log.Printf("Failing [%s] with [%v] for fault rule [%s]", call.Method, rule.Code, rule.Name)
+			annotate(ctx, rule, "Injected an error", trace.StringAttribute("fault.code", CodeName(rule.Code)))
+			message := rule.Message
+			if message == "" {
+				message = fmt.Sprintf("fault injected by rule [%s]", rule.Name)
+			}
+			return status.Error(rule.Code, message)
+		}
+	}
+	return nil
+}
+
+// delay draws the delay of a rule that fired, with the distribution it was
+// drawn from.
+func (in *Injector) delay(rule *Rule) (time.Duration, fault.Distribution) {
+	if rule.Latency != nil {
+		return rule.Latency.Sample(in.random, in.normal), rule.Latency.Distribution
+	}
+	return rule.Delay, fault.Fixed
+}
+
+// Dropped records the rule that dropped the reply to a call, if any.
+type Dropped struct {
+	sync.Mutex
+	rule string
+}
+
+type dropKey struct{}
+
+// WithDrop returns a context in which Inject records drops in the returned
+// Dropped instead of holding the call up, so that the call is handled as
+// usual before Drop throws its reply away.
+func WithDrop(ctx context.Context) (context.Context, *Dropped) {
+	dropped := &Dropped{}
+	return context.WithValue(ctx, dropKey{}, dropped), dropped
+}
+
+// set records the first rule to drop the call.
+func (d *Dropped) set(rule string) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.rule == "" {
+		d.rule = rule
+	}
+}
+
+// Rule is the name of the rule that dropped the call, or "" if none did.
+func (d *Dropped) Rule() string {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.rule
+}
+
+// Drop throws away the reply to a call handled in ctx, if a rule dropped
+// it, by leaving the caller waiting until ctx is done or the longest drop
+// is up. It returns the error the call then fails with, or nil if the call
+// wasn't dropped.
+func (in *Injector) Drop(ctx context.Context, dropped *Dropped) error {
+	rule := dropped.Rule()
+	if in == nil || rule == "" {
+		return nil
+	}
+	in.RLock()
+	maxDrop := in.maxDrop
+	in.RUnlock()
+
+	return hold(ctx, rule, maxDrop)
+}
+
+// hold leaves the caller of a call dropped by rule waiting until ctx is
+// done, with the status of its error, or until maxDrop is up, as when the
+// connection is lost.
+func hold(ctx context.Context, rule string, maxDrop time.Duration) error {
+	if err := Sleep(ctx, maxDrop); err != nil {
+		return err
+	}
+	return status.Errorf(codes.Unavailable, "reply dropped by fault rule [%s]", rule)
+}
+
+func annotate(ctx context.Context, rule *Rule, message string, attributes ...trace.Attribute) {
+	attributes = append(attributes, trace.StringAttribute("fault.rule", rule.Name))
+	trace.FromContext(ctx).Annotate(attributes, message)
+}
+
+// Sleep waits for d, or until ctx is done, and then returns the status of
+// its error.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if err := fault.Sleep(ctx, d); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
+
+// codeNames are the names of gRPC status codes, as in the gRPC spec.
+var codeNames = map[codes.Code]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
+
+// CodeName is the name of a gRPC status code, as in "UNAVAILABLE".
+func CodeName(code codes.Code) string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return code.String()
+}
+
+// ParseCode parses the name of a gRPC status code, in any case and with or
+// without underscores, so "UNAVAILABLE", "Unavailable" and "DeadlineExceeded"
+// all do.
+func ParseCode(name string) (codes.Code, error) {
+	normalize := func(name string) string {
+		return strings.ToUpper(strings.Replace(name, "_", "", -1))
+	}
+	for code, codeName := range codeNames {
+		if normalize(codeName) == normalize(name) || (code == codes.Canceled && normalize(name) == "CANCELED") {
+			return code, nil
+		}
+	}
+	return codes.OK, fmt.Errorf("%w: unknown status code [%s]", ErrInvalidRule, name)
+}
@@ -0,0 +1,186 @@
+package grpcfault
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoadRules(t *testing.T) {
+	load := func(t *testing.T, rules string) ([]Rule, error) {
+		dir, err := ioutil.TempDir("", "fault")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "faults.json")
+		if err := ioutil.WriteFile(path, []byte(rules), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return LoadRules(path)
+	}
+
+	t.Run("Loads rules with codes by name and delays", func(t *testing.T) {
+		rules, err := load(t, `{"rules": [
+			{"name": "doughnut", "rpc": "VoteDoughnut", "probability": 0.5, "code": "UNAVAILABLE"},
+			{"name": "slow", "metadata": {"User-Agent": "curl*"}, "probability": 1, "delay": "250ms"},
+			{"name": "black hole", "shortcode": ":ghost:", "probability": 0.1, "drop": true}
+		]}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rules) != 3 || rules[0].Code != codes.Unavailable || rules[1].Delay != 250*time.Millisecond || !rules[2].Drop {
+			t.Fatalf("Expected three rules, got [%v]", rules)
+		}
+		if rules[1].Metadata["user-agent"] != "curl*" {
+			t.Fatalf("Expected metadata keys to be lowercased, got [%v]", rules[1].Metadata)
+		}
+	})
+
+	t.Run("Rejects invalid rules", func(t *testing.T) {
+		for _, rules := range []string{
+			`{"rules": [{"probability": 0.5}]}`,
+			`{"rules": [{"probability": 0, "code": "INTERNAL"}]}`,
+			`{"rules": [{"probability": 1.5, "code": "INTERNAL"}]}`,
+			`{"rules": [{"probability": 1, "code": "NOT_A_CODE"}]}`,
+			`{"rules": [{"probability": 1, "delay": "soon"}]}`,
+			`{"rules": [{"probability": 1, "code": "INTERNAL", "drop": true}]}`,
+			`{"rules": `,
+		} {
+			if _, err := load(t, rules); err == nil {
+				t.Fatalf("Expected [%s] to be rejected", rules)
+			}
+		}
+	})
+}
+
+func TestInject(t *testing.T) {
+	vote := Call{Method: "/emojivoto.v1.VotingService/Vote", Shortcode: ":doughnut:"}
+
+	t.Run("Fails matching calls at the rule's probability", func(t *testing.T) {
+		in := NewInjector([]Rule{{Name: "doughnut", RPC: "Vote", Shortcode: ":dough*", Probability: 0.5, Code: codes.Unavailable}})
+
+		in.random = func() float64 { return 0.49 }
+		if err := in.Inject(context.Background(), vote); status.Code(err) != codes.Unavailable {
+			t.Fatalf("Expected the vote to fail with [Unavailable], got [%v]", err)
+		}
+		in.random = func() float64 { return 0.5 }
+		if err := in.Inject(context.Background(), vote); err != nil {
+			t.Fatalf("Expected the vote to go through, got [%v]", err)
+		}
+	})
+
+	t.Run("Only applies rules with a shortcode to votes", func(t *testing.T) {
+		in := NewInjector([]Rule{
+			{Name: "every call", Probability: 1, Code: codes.Internal},
+			{Name: "ghost", Shortcode: ":ghost:", Probability: 1, Code: codes.Aborted},
+		})
+
+		if err := in.Inject(context.Background(), Call{Method: vote.Method}); status.Code(err) != codes.Internal {
+			t.Fatalf("Expected the call to fail with [Internal], got [%v]", err)
+		}
+		if err := in.Inject(context.Background(), vote); err != nil {
+			t.Fatalf("Expected a vote for [:doughnut:] to go through, got [%v]", err)
+		}
+		if err := in.Inject(context.Background(), Call{Method: vote.Method, Shortcode: ":ghost:"}); status.Code(err) != codes.Aborted {
+			t.Fatalf("Expected a vote for [:ghost:] to fail with [Aborted], got [%v]", err)
+		}
+	})
+
+	t.Run("Matches request metadata", func(t *testing.T) {
+		in := NewInjector(nil)
+		if err := in.SetRules([]Rule{{Name: "curl", Metadata: map[string]string{"User-Agent": "curl/*"}, Probability: 1, Code: codes.PermissionDenied}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := in.Inject(context.Background(), Call{Method: vote.Method, Metadata: map[string]string{"user-agent": "curl/7.0"}}); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("Expected a call from curl to fail, got [%v]", err)
+		}
+		if err := in.Inject(context.Background(), Call{Method: vote.Method, Metadata: map[string]string{"user-agent": "Mozilla/5.0"}}); err != nil {
+			t.Fatalf("Expected a call from a browser to go through, got [%v]", err)
+		}
+	})
+
+	t.Run("Delays and drops calls until the caller gives up", func(t *testing.T) {
+		in := NewInjector([]Rule{
+			{Name: "slow", Probability: 1, Delay: 10 * time.Millisecond},
+			{Name: "black hole", RPC: "Results", Probability: 1, Drop: true},
+		})
+
+		start := time.Now()
+		if err := in.Inject(context.Background(), Call{Method: vote.Method}); err != nil || time.Since(start) < 10*time.Millisecond {
+			t.Fatalf("Expected the call to be delayed and go through, got [%v] after [%v]", err, time.Since(start))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := in.Inject(ctx, Call{Method: "/emojivoto.v1.VotingService/Results"}); status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("Expected the dropped call to exceed its deadline, got [%v]", err)
+		}
+	})
+
+	t.Run("Handles dropped calls and throws their replies away", func(t *testing.T) {
+		in := NewInjector([]Rule{{Name: "black hole", RPC: "Results", Probability: 1, Drop: true}})
+		in.SetMaxDrop(20 * time.Millisecond)
+		handled := false
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			handled = true
+			return "results", nil
+		}
+		info := &grpc.UnaryServerInfo{FullMethod: "/emojivoto.v1.VotingService/Results"}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		resp, err := in.UnaryServerInterceptor(ctx, nil, info, handler)
+		if !handled || resp != nil || status.Code(err) != codes.DeadlineExceeded {
+			t.Fatalf("Expected the call to be handled and its reply dropped until the deadline, got [%v] [%v]", resp, err)
+		}
+
+		handled = false
+		start := time.Now()
+		resp, err = in.UnaryServerInterceptor(context.Background(), nil, info, handler)
+		if !handled || resp != nil || status.Code(err) != codes.Unavailable {
+			t.Fatalf("Expected a caller without a deadline to give up on the reply, got [%v] [%v]", resp, err)
+		}
+		if waited := time.Since(start); waited < 20*time.Millisecond || waited > time.Second {
+			t.Fatalf("Expected the caller to wait for the longest drop, waited [%v]", waited)
+		}
+	})
+
+	t.Run("Never injects faults into the admin service", func(t *testing.T) {
+		in := NewInjector([]Rule{{Name: "every call", Probability: 1, Code: codes.Internal}})
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-agent", "curl/7.0"))
+
+		if _, err := in.UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/" + AdminService + "/SetFaultRules"}, handler); err != nil {
+			t.Fatalf("Expected the admin call to go through, got [%v]", err)
+		}
+		if _, err := in.UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: vote.Method}, handler); status.Code(err) != codes.Internal {
+			t.Fatalf("Expected the vote to fail, got [%v]", err)
+		}
+	})
+}
+
+func TestParseCode(t *testing.T) {
+	for _, name := range []string{"DEADLINE_EXCEEDED", "DeadlineExceeded", "deadline_exceeded"} {
+		if code, err := ParseCode(name); err != nil || code != codes.DeadlineExceeded {
+			t.Fatalf("Expected [%s] to be [DeadlineExceeded], got [%v] [%v]", name, code, err)
+		}
+	}
+	if _, err := ParseCode("SNAFU"); !errors.Is(err, ErrInvalidRule) {
+		t.Fatalf("Expected an unknown code to be invalid, got [%v]", err)
+	}
+	if name := CodeName(codes.Unavailable); name != "UNAVAILABLE" {
+		t.Fatalf("Expected [UNAVAILABLE], got [%s]", name)
+	}
+}
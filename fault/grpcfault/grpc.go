@@ -0,0 +1,95 @@
+package grpcfault
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// AdminService is the gRPC service that swaps the rules in force. Faults
+// are never injected into it, so bad rules can always be taken back.
+const AdminService = "emojivoto.v1.FaultService"
+
+// UnaryServerInterceptor injects the faults of the rules without a shortcode
+// into every unary call. A dropped call is still handled, and its reply
+// thrown away.
+func (in *Injector) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isAdmin(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, dropped := WithDrop(ctx)
+	if err := in.Inject(ctx, Call{Method: info.FullMethod, Metadata: metadataOf(ctx)}); err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	if err := in.Drop(ctx, dropped); err != nil {
+		return nil, err
+	}
+	return resp, err
+}
+
+// StreamServerInterceptor injects the faults of the rules without a
+// shortcode into every stream, before it starts. A dropped stream is still
+// handled, but nothing more is sent on it.
+func (in *Injector) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isAdmin(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, dropped := WithDrop(ss.Context())
+	if err := in.Inject(ctx, Call{Method: info.FullMethod, Metadata: metadataOf(ctx)}); err != nil {
+		return err
+	}
+	err := handler(srv, &droppingStream{ServerStream: ss, ctx: ctx, dropped: dropped})
+	if err := in.Drop(ctx, dropped); err != nil {
+		return err
+	}
+	return err
+}
+
+// droppingStream is a server stream that throws away the messages sent on
+// it once the call is dropped.
+type droppingStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	dropped *Dropped
+}
+
+func (s *droppingStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *droppingStream) SendMsg(m interface{}) error {
+	if s.dropped.Rule() != "" {
+		return nil
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// InjectShortcode injects the faults of the rules with a shortcode into the
+// handling of shortcode, as a vote for it or a lookup, in the call ctx
+// belongs to.
+func (in *Injector) InjectShortcode(ctx context.Context, shortcode string) error {
+	method, _ := grpc.Method(ctx)
+	return in.Inject(ctx, Call{Method: method, Shortcode: shortcode, Metadata: metadataOf(ctx)})
+}
+
+func isAdmin(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+AdminService+"/")
+}
+
+// metadataOf is the first value of each key of the request metadata.
+func metadataOf(ctx context.Context) map[string]string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	values := make(map[string]string, len(md))
+	for key, v := range md {
+		if len(v) > 0 {
+			values[key] = v[0]
+		}
+	}
+	return values
+}
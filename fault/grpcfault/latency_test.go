@@ -0,0 +1,77 @@
+package grpcfault
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/buoyantio/emojivoto/fault"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRuleLatency(t *testing.T) {
+	t.Run("Reads latencies from rules", func(t *testing.T) {
+		var rule Rule
+		err := json.Unmarshal([]byte(`{"rpc": "Vote*", "probability": 1, "latency": {"distribution": "lognormal", "median": "20ms", "sigma": 0.8, "max": "2s"}}`), &rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rule.Check(); err != nil {
+			t.Fatal(err)
+		}
+		if l := rule.Latency; l.Distribution != fault.LogNormal || l.Median != 20*time.Millisecond || l.Sigma != 0.8 || l.Max != 2*time.Second {
+			t.Fatalf("Expected a log-normal latency, got [%v]", l)
+		}
+	})
+
+	t.Run("Rejects rules with a bad latency or a delay as well", func(t *testing.T) {
+		rule := Rule{Probability: 1, Latency: &fault.Latency{Distribution: "uniform", Mean: time.Second}}
+		if err := rule.Check(); err == nil {
+			t.Fatalf("Expected a rule with an unknown distribution to be rejected")
+		}
+		rule = Rule{Probability: 1, Delay: time.Second, Latency: &fault.Latency{Distribution: fault.Fixed, Mean: time.Second}}
+		if err := rule.Check(); err == nil {
+			t.Fatalf("Expected a rule with a delay and a latency to be rejected")
+		}
+	})
+}
+
+type spans []*trace.SpanData
+
+func (s *spans) ExportSpan(span *trace.SpanData) {
+	*s = append(*s, span)
+}
+
+func TestInjectLatency(t *testing.T) {
+	t.Run("Cuts delays short at the caller's deadline and annotates them", func(t *testing.T) {
+		exported := &spans{}
+		trace.RegisterExporter(exported)
+		defer trace.UnregisterExporter(exported)
+
+		in := NewInjector([]Rule{{Name: "tail", Probability: 1, Latency: &fault.Latency{Distribution: fault.Pareto, Min: time.Hour, Alpha: 2}}})
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		ctx, span := trace.StartSpan(ctx, "vote", trace.WithSampler(trace.AlwaysSample()))
+
+		start := time.Now()
+		err := in.Inject(ctx, Call{Method: "/emojivoto.v1.VotingService/Vote"})
+		span.End()
+		if status.Code(err) != codes.DeadlineExceeded || time.Since(start) > time.Second {
+			t.Fatalf("Expected the delay to end at the deadline, got [%v] after [%v]", err, time.Since(start))
+		}
+
+		if len(*exported) != 1 {
+			t.Fatalf("Expected [1] span, got [%d]", len(*exported))
+		}
+		annotations := (*exported)[0].Annotations
+		if len(annotations) != 2 || annotations[0].Message != "Injected latency" || annotations[1].Message != "Injected latency cut short by the caller" {
+			t.Fatalf("Expected the delay and its end to be annotated, got [%v]", annotations)
+		}
+		if a := annotations[0].Attributes; a["fault.distribution"] != "pareto" || a["fault.rule"] != "tail" || a["fault.delay_ms"].(int64) < time.Hour.Milliseconds() {
+			t.Fatalf("Expected the rule, distribution and delay to be annotated, got [%v]", a)
+		}
+	})
+}
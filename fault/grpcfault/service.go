@@ -0,0 +1,131 @@
+package grpcfault
+
+import (
+	"context"
+	"time"
+
+	"github.com/buoyantio/emojivoto/admin"
+	"github.com/buoyantio/emojivoto/fault"
+	pb "github.com/buoyantio/emojivoto/fault/grpcfault/gen/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// FaultServiceServer swaps the rules of an Injector at runtime, for admins
+// only. Anyone can see the rules in force.
+type FaultServiceServer struct {
+	faults     *Injector
+	adminToken string
+	pb.UnimplementedFaultServiceServer
+}
+
+// NewGrpServer serves the FaultService, as AdminService, for faults.
+func NewGrpServer(grpcServer *grpc.Server, faults *Injector, adminToken string) {
+	pb.RegisterFaultServiceServer(grpcServer, &FaultServiceServer{faults: faults, adminToken: adminToken})
+}
+
+func (fS *FaultServiceServer) GetFaultRules(_ context.Context, _ *pb.GetFaultRulesRequest) (*pb.GetFaultRulesResponse, error) {
+	return &pb.GetFaultRulesResponse{Rules: toPbFaultRules(fS.faults.Rules())}, nil
+}
+
+func (fS *FaultServiceServer) SetFaultRules(ctx context.Context, req *pb.SetFaultRulesRequest) (*pb.SetFaultRulesResponse, error) {
+	if err := admin.Check(ctx, fS.adminToken); err != nil {
+		return nil, err
+	}
+	rules := make([]Rule, 0, len(req.Rules))
+	for _, r := range req.Rules {
+		rule := Rule{
+			Name:        r.Name,
+			RPC:         r.Rpc,
+			Shortcode:   r.Shortcode,
+			Metadata:    r.Metadata,
+			Probability: r.Probability,
+			Message:     r.Message,
+			Delay:       r.Delay.AsDuration(),
+			Latency:     fromPbLatency(r.Latency),
+			Drop:        r.Drop,
+		}
+		if r.Code != "" {
+			code, err := ParseCode(r.Code)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			rule.Code = code
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := fS.faults.SetRules(rules); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.SetFaultRulesResponse{Rules: toPbFaultRules(fS.faults.Rules())}, nil
+}
+
+func toPbFaultRules(rules []Rule) []*pb.FaultRule {
+	pbRules := make([]*pb.FaultRule, 0, len(rules))
+	for _, r := range rules {
+		rule := &pb.FaultRule{
+			Name:        r.Name,
+			Rpc:         r.RPC,
+			Shortcode:   r.Shortcode,
+			Metadata:    r.Metadata,
+			Probability: r.Probability,
+			Message:     r.Message,
+			Drop:        r.Drop,
+		}
+		if r.Code != codes.OK {
+			rule.Code = CodeName(r.Code)
+		}
+		if r.Delay > 0 {
+			rule.Delay = durationpb.New(r.Delay)
+		}
+		if r.Latency != nil {
+			rule.Latency = toPbLatency(r.Latency)
+		}
+		pbRules = append(pbRules, rule)
+	}
+	return pbRules
+}
+
+func fromPbLatency(l *pb.Latency) *fault.Latency {
+	if l == nil {
+		return nil
+	}
+	return &fault.Latency{
+		Distribution:    fault.Distribution(l.Distribution),
+		Mean:            l.Mean.AsDuration(),
+		StdDev:          l.Stddev.AsDuration(),
+		Median:          l.Median.AsDuration(),
+		Sigma:           l.Sigma,
+		Min:             l.Min.AsDuration(),
+		Alpha:           l.Alpha,
+		SlowMean:        l.SlowMean.AsDuration(),
+		SlowStdDev:      l.SlowStddev.AsDuration(),
+		SlowProbability: l.SlowProbability,
+		Max:             l.Max.AsDuration(),
+	}
+}
+
+func toPbLatency(l *fault.Latency) *pb.Latency {
+	duration := func(d time.Duration) *durationpb.Duration {
+		if d == 0 {
+			return nil
+		}
+		return durationpb.New(d)
+	}
+	return &pb.Latency{
+		Distribution:    string(l.Distribution),
+		Mean:            duration(l.Mean),
+		Stddev:          duration(l.StdDev),
+		Median:          duration(l.Median),
+		Sigma:           l.Sigma,
+		Min:             duration(l.Min),
+		Alpha:           l.Alpha,
+		SlowMean:        duration(l.SlowMean),
+		SlowStddev:      duration(l.SlowStdDev),
+		SlowProbability: l.SlowProbability,
+		Max:             duration(l.Max),
+	}
+}
@@ -0,0 +1,89 @@
+package grpcfault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buoyantio/emojivoto/admin"
+	pb "github.com/buoyantio/emojivoto/fault/grpcfault/gen/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestFaultService(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(admin.Key, "Bearer secret"))
+	vote := Call{Method: "/emojivoto.v1.VotingService/Vote", Shortcode: ":joy:"}
+
+	t.Run("Swaps the fault rules at runtime", func(t *testing.T) {
+		faultService := &FaultServiceServer{faults: NewInjector(nil), adminToken: "secret"}
+
+		rules := []*pb.FaultRule{
+			{Name: "joy", Shortcode: ":joy:", Probability: 1, Code: "UNAVAILABLE", Message: "no joy"},
+			{Name: "slow results", Rpc: "Results", Probability: 1, Latency: &pb.Latency{Distribution: "lognormal", Median: durationpb.New(20 * time.Millisecond), Sigma: 0.5}},
+		}
+		if _, err := faultService.SetFaultRules(ctx, &pb.SetFaultRulesRequest{Rules: rules}); err != nil {
+			t.Fatal(err)
+		}
+		if err := faultService.faults.Inject(context.Background(), vote); status.Code(err) != codes.Unavailable || status.Convert(err).Message() != "no joy" {
+			t.Fatalf("Expected the vote for [:joy:] to fail with [Unavailable], got [%v]", err)
+		}
+
+		response, err := faultService.GetFaultRules(context.Background(), &pb.GetFaultRulesRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Rules) != 2 || response.Rules[0].Code != "UNAVAILABLE" || response.Rules[0].Shortcode != ":joy:" {
+			t.Fatalf("Expected the rule for [:joy:], got [%v]", response.Rules)
+		}
+		if l := response.Rules[1].Latency; l.GetDistribution() != "lognormal" || l.GetMedian().AsDuration() != 20*time.Millisecond || l.GetSigma() != 0.5 {
+			t.Fatalf("Expected the log-normal latency of [Results], got [%v]", l)
+		}
+
+		if _, err := faultService.SetFaultRules(ctx, &pb.SetFaultRulesRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := faultService.faults.Inject(context.Background(), vote); err != nil {
+			t.Fatalf("Expected the vote to go through without rules, got [%v]", err)
+		}
+	})
+
+	t.Run("Swaps the fault rules only for admins", func(t *testing.T) {
+		rules := []*pb.FaultRule{{Name: "joy", Shortcode: ":joy:", Probability: 1, Code: "UNAVAILABLE"}}
+		for _, test := range []struct {
+			adminToken string
+			ctx        context.Context
+			code       codes.Code
+		}{
+			{"", metadata.NewIncomingContext(context.Background(), metadata.Pairs(admin.Key, "Bearer ")), codes.PermissionDenied},
+			{"secret", context.Background(), codes.Unauthenticated},
+			{"secret", metadata.NewIncomingContext(context.Background(), metadata.Pairs(admin.Key, "Bearer wrong")), codes.Unauthenticated},
+		} {
+			faultService := &FaultServiceServer{faults: NewInjector(nil), adminToken: test.adminToken}
+			_, err := faultService.SetFaultRules(test.ctx, &pb.SetFaultRulesRequest{Rules: rules})
+			if status.Code(err) != test.code {
+				t.Fatalf("Expected [%v], got [%v]", test.code, err)
+			}
+			if r := faultService.faults.Rules(); len(r) != 0 {
+				t.Fatalf("Expected no rules to be set, got [%v]", r)
+			}
+		}
+	})
+
+	t.Run("Rejects invalid rules", func(t *testing.T) {
+		faultService := &FaultServiceServer{faults: NewInjector(nil), adminToken: "secret"}
+		for _, rule := range []*pb.FaultRule{
+			{Name: "never", Probability: 0, Code: "INTERNAL"},
+			{Name: "unknown", Probability: 1, Code: "SNAFU"},
+			{Name: "nothing", Probability: 1},
+			{Name: "slow", Probability: 1, Delay: durationpb.New(time.Second), Code: "ABORTED", Drop: true},
+		} {
+			_, err := faultService.SetFaultRules(ctx, &pb.SetFaultRulesRequest{Rules: []*pb.FaultRule{rule}})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("Expected InvalidArgument for rule [%s], got [%v]", rule.Name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package fault
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Distribution is the shape of the latency a rule adds.
+type Distribution string
+
+const (
+	// Fixed latency is always Mean.
+	Fixed Distribution = "fixed"
+	// Normal latency is spread evenly around Mean, by StdDev.
+	Normal Distribution = "normal"
+	// LogNormal latency is around Median, with a long tail to the right the
+	// larger Sigma is, as service latencies usually have.
+	LogNormal Distribution = "lognormal"
+	// Pareto latency is at least Min, with a heavy tail the smaller Alpha
+	// is: with an Alpha of 2, one call in a hundred takes ten times Min.
+	Pareto Distribution = "pareto"
+	// Bimodal latency is mostly around Mean, by StdDev, and sometimes, at
+	// SlowProbability, around SlowMean, by SlowStdDev, as with cache misses.
+	Bimodal Distribution = "bimodal"
+)
+
+// Latency is a distribution of delays. Delays never go below zero, nor above
+// Max when it is set.
+type Latency struct {
+	Distribution    Distribution
+	Mean            time.Duration
+	StdDev          time.Duration
+	Median          time.Duration
+	Sigma           float64
+	Min             time.Duration
+	Alpha           float64
+	SlowMean        time.Duration
+	SlowStdDev      time.Duration
+	SlowProbability float64
+	Max             time.Duration
+}
+
+// latencyJSON is how latencies are written in files, with durations as in
+// "250ms".
+type latencyJSON struct {
+	Distribution    Distribution `json:"distribution"`
+	Mean            string       `json:"mean,omitempty"`
+	StdDev          string       `json:"stddev,omitempty"`
+	Median          string       `json:"median,omitempty"`
+	Sigma           float64      `json:"sigma,omitempty"`
+	Min             string       `json:"min,omitempty"`
+	Alpha           float64      `json:"alpha,omitempty"`
+	SlowMean        string       `json:"slow_mean,omitempty"`
+	SlowStdDev      string       `json:"slow_stddev,omitempty"`
+	SlowProbability float64      `json:"slow_probability,omitempty"`
+	Max             string       `json:"max,omitempty"`
+}
+
+func (l Latency) MarshalJSON() ([]byte, error) {
+	format := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return d.String()
+	}
+	return json.Marshal(latencyJSON{
+		Distribution:    l.Distribution,
+		Mean:            format(l.Mean),
+		StdDev:          format(l.StdDev),
+		Median:          format(l.Median),
+		Sigma:           l.Sigma,
+		Min:             format(l.Min),
+		Alpha:           l.Alpha,
+		SlowMean:        format(l.SlowMean),
+		SlowStdDev:      format(l.SlowStdDev),
+		SlowProbability: l.SlowProbability,
+		Max:             format(l.Max),
+	})
+}
+
+func (l *Latency) UnmarshalJSON(data []byte) error {
+	var latency latencyJSON
+	if err := json.Unmarshal(data, &latency); err != nil {
+		return err
+	}
+	*l = Latency{
+		Distribution:    latency.Distribution,
+		Sigma:           latency.Sigma,
+		Alpha:           latency.Alpha,
+		SlowProbability: latency.SlowProbability,
+	}
+	for _, d := range []struct {
+		value    string
+		duration *time.Duration
+	}{
+		{latency.Mean, &l.Mean},
+		{latency.StdDev, &l.StdDev},
+		{latency.Median, &l.Median},
+		{latency.Min, &l.Min},
+		{latency.SlowMean, &l.SlowMean},
+		{latency.SlowStdDev, &l.SlowStdDev},
+		{latency.Max, &l.Max},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return err
+		}
+		*d.duration = parsed
+	}
+	return nil
+}
+
+// Check checks the latency has what its distribution needs.
+func (l *Latency) Check() error {
+	if l.Mean < 0 || l.StdDev < 0 || l.Median < 0 || l.Min < 0 || l.SlowMean < 0 || l.SlowStdDev < 0 || l.Max < 0 {
+		return fmt.Errorf("%s latency can't have negative durations", l.Distribution)
+	}
+	switch l.Distribution {
+	case Fixed, Normal:
+		if l.Mean == 0 {
+			return fmt.Errorf("%s latency needs a mean", l.Distribution)
+		}
+	case LogNormal:
+		if l.Median == 0 || l.Sigma <= 0 {
+			return fmt.Errorf("%s latency needs a median and a sigma above 0", l.Distribution)
+		}
+	case Pareto:
+		if l.Min == 0 || l.Alpha <= 0 {
+			return fmt.Errorf("%s latency needs a min and an alpha above 0", l.Distribution)
+		}
+	case Bimodal:
+		if l.SlowMean <= l.Mean || l.SlowProbability <= 0 || l.SlowProbability >= 1 {
+			return fmt.Errorf("%s latency needs a slow mean above its mean, and a slow probability between 0 and 1", l.Distribution)
+		}
+	default:
+		return fmt.Errorf("unknown latency distribution [%s]", l.Distribution)
+	}
+	return nil
+}
+
+// Sample draws a delay from the latency, with uniform drawing from [0, 1)
+// and normal from the standard normal distribution.
+func (l *Latency) Sample(uniform, normal func() float64) time.Duration {
+	var delay float64
+	switch l.Distribution {
+	case Fixed:
+		delay = float64(l.Mean)
+	case Normal:
+		delay = float64(l.Mean) + normal()*float64(l.StdDev)
+	case LogNormal:
+		delay = float64(l.Median) * math.Exp(l.Sigma*normal())
+	case Pareto:
+		delay = float64(l.Min) / math.Pow(1-uniform(), 1/l.Alpha)
+	case Bimodal:
+		if uniform() < l.SlowProbability {
+			delay = float64(l.SlowMean) + normal()*float64(l.SlowStdDev)
+		} else {
+			delay = float64(l.Mean) + normal()*float64(l.StdDev)
+		}
+	}
+	if l.Max > 0 && delay > float64(l.Max) {
+		return l.Max
+	}
+	if delay < 0 || math.IsNaN(delay) {
+		return 0
+	}
+	if delay > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
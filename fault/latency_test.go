@@ -0,0 +1,69 @@
+package fault
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLatency(t *testing.T) {
+	// percentiles draws delays from a latency and returns the given
+	// percentiles of them.
+	percentiles := func(l Latency, ps ...float64) []time.Duration {
+		r := rand.New(rand.NewSource(1))
+		delays := make([]time.Duration, 20000)
+		for i := range delays {
+			delays[i] = l.Sample(r.Float64, r.NormFloat64)
+		}
+		sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+		values := make([]time.Duration, len(ps))
+		for i, p := range ps {
+			values[i] = delays[int(p*float64(len(delays)-1))]
+		}
+		return values
+	}
+	near := func(d, expected time.Duration) bool {
+		return d > expected*9/10 && d < expected*11/10
+	}
+
+	t.Run("Draws delays from each distribution", func(t *testing.T) {
+		for _, test := range []struct {
+			latency  Latency
+			p50, p99 time.Duration
+		}{
+			{Latency{Distribution: Fixed, Mean: 20 * time.Millisecond}, 20 * time.Millisecond, 20 * time.Millisecond},
+			{Latency{Distribution: Normal, Mean: 100 * time.Millisecond, StdDev: 10 * time.Millisecond}, 100 * time.Millisecond, 123 * time.Millisecond},
+			{Latency{Distribution: LogNormal, Median: 20 * time.Millisecond, Sigma: 1}, 20 * time.Millisecond, 205 * time.Millisecond},
+			{Latency{Distribution: Pareto, Min: 10 * time.Millisecond, Alpha: 2}, 14 * time.Millisecond, 100 * time.Millisecond},
+			{Latency{Distribution: Bimodal, Mean: 10 * time.Millisecond, SlowMean: 500 * time.Millisecond, SlowProbability: 0.05}, 10 * time.Millisecond, 500 * time.Millisecond},
+		} {
+			p := percentiles(test.latency, 0.5, 0.99)
+			if !near(p[0], test.p50) || !near(p[1], test.p99) {
+				t.Fatalf("Expected a p50 of [%v] and a p99 of [%v] from [%s] latency, got [%v] and [%v]", test.p50, test.p99, test.latency.Distribution, p[0], p[1])
+			}
+		}
+	})
+
+	t.Run("Caps delays at max and zero", func(t *testing.T) {
+		p := percentiles(Latency{Distribution: Normal, Mean: 10 * time.Millisecond, StdDev: 20 * time.Millisecond, Max: 30 * time.Millisecond}, 0, 1)
+		if p[0] != 0 || p[1] != 30*time.Millisecond {
+			t.Fatalf("Expected delays from [0] to [30ms], got [%v] to [%v]", p[0], p[1])
+		}
+	})
+
+	t.Run("Rejects latencies missing what their distribution needs", func(t *testing.T) {
+		for _, l := range []Latency{
+			{Distribution: "uniform", Mean: time.Second},
+			{Distribution: Normal, StdDev: time.Second},
+			{Distribution: LogNormal, Median: time.Second},
+			{Distribution: Pareto, Alpha: 2},
+			{Distribution: Bimodal, Mean: time.Second, SlowMean: time.Millisecond, SlowProbability: 0.1},
+			{Distribution: Fixed, Mean: time.Second, Max: -time.Second},
+		} {
+			if err := l.Check(); err == nil {
+				t.Fatalf("Expected [%v] to be rejected", l)
+			}
+		}
+	})
+}